@@ -305,6 +305,21 @@ func (a *Application) SetSerializer(serializer cfacade.ISerializer) {
 	a.serializer = serializer
 }
 
+// SetSerializerName set the serializer registered by name. see cserializer.Register
+// the name is sent to the client in the handshake, the same serializer decodes the inbound and encodes the outbound messages.
+func (a *Application) SetSerializerName(name string) {
+	serializer, found := cserializer.Get(name)
+	if !found {
+		clog.Warnf("[SetSerializerName] serializer not found. [name = %s, registered = %v]",
+			name,
+			cserializer.Names(),
+		)
+		return
+	}
+
+	a.SetSerializer(serializer)
+}
+
 func (a *Application) SetDiscovery(discovery cfacade.IDiscovery) {
 	if a.Running() || discovery == nil {
 		return
@@ -19,6 +19,17 @@ const (
 	ActorSourceEqualTarget  int32 = 30 // source equal target
 	ActorPublishRemoteError int32 = 31 // actor publish remote error
 	ActorChildIDNotFound    int32 = 32 // actor child id not found
+	RPCTimeoutError         int32 = 33 // rpc request timeout
+	ActorCallTimeout        int32 = 34 // actor call wait timeout
+	ActorCallCanceled       int32 = 35 // actor call wait canceled by context
+	HandlerError            int32 = 36 // handler returned an error without code
+	ActorCircuitOpen        int32 = 37 // the circuit breaker of the target node is open
+	AdminArgError           int32 = 38 // the arg of the admin func is invalid
+	HandlerPanic            int32 = 39 // handler panic, it's recovered
+	SessionPushFail         int32 = 40 // push to the session fail, eg. closed or the send queue is full
+	SessionUnauthorized     int32 = 41 // the route requires the session bound or not bound, see pomelo actor.SetRouteAuth
+	MessageSizeExceed       int32 = 42 // the inbound message exceeds the max size, see pomelo actor.SetMaxMessageSize
+	ResponseTimeout         int32 = 43 // the request is not responded in time, see pomelo actor.SetResponseTimeout
 
 )
 
@@ -0,0 +1,187 @@
+package cherryGRPC
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	clog "github.com/cherry-game/cherry/logger"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+type (
+	// nodeClient the connection pool of the target node
+	nodeClient struct {
+		nodeId string
+		conns  []*grpc.ClientConn
+		next   uint32
+		local  *publisher
+		remote *publisher
+	}
+
+	publishStream struct {
+		grpc.ClientStream
+		cancel context.CancelFunc
+	}
+
+	// publisher send the packets in order by the client stream
+	publisher struct {
+		nodeId string
+		desc   *grpc.StreamDesc
+		method string
+		conn   *grpc.ClientConn
+		ch     chan *cproto.ClusterPacket
+		wg     sync.WaitGroup
+	}
+)
+
+func newNodeClient(nodeId, address string, poolSize, bufferSize int, opts []grpc.DialOption) (*nodeClient, error) {
+	client := &nodeClient{
+		nodeId: nodeId,
+	}
+
+	for i := 0; i < poolSize; i++ {
+		conn, err := grpc.Dial(address, opts...)
+		if err != nil {
+			client.close()
+			return nil, err
+		}
+		client.conns = append(client.conns, conn)
+	}
+
+	// the packets of a node are published by the first conn to keep the order
+	client.local = newPublisher(nodeId, client.conns[0], localStreamDesc, localMethod, bufferSize)
+	client.remote = newPublisher(nodeId, client.conns[0], remoteStreamDesc, remoteMethod, bufferSize)
+
+	return client, nil
+}
+
+// pick the conn of the request by round robin
+func (p *nodeClient) pick() *grpc.ClientConn {
+	n := atomic.AddUint32(&p.next, 1)
+	return p.conns[int(n)%len(p.conns)]
+}
+
+func (p *nodeClient) isDegraded() bool {
+	for _, conn := range p.conns {
+		if conn.GetState() == connectivity.TransientFailure {
+			return true
+		}
+	}
+
+	return false
+}
+
+// close wait for the queued packets are sent and close the conns
+func (p *nodeClient) close() {
+	if p.local != nil {
+		p.local.close()
+	}
+
+	if p.remote != nil {
+		p.remote.close()
+	}
+
+	for _, conn := range p.conns {
+		if err := conn.Close(); err != nil {
+			clog.Warnf("[grpc] Close conn fail. [nodeId = %s, err = %v]", p.nodeId, err)
+		}
+	}
+}
+
+func newPublisher(nodeId string, conn *grpc.ClientConn, desc *grpc.StreamDesc, method string, bufferSize int) *publisher {
+	p := &publisher{
+		nodeId: nodeId,
+		desc:   desc,
+		method: method,
+		conn:   conn,
+		ch:     make(chan *cproto.ClusterPacket, bufferSize),
+	}
+
+	p.wg.Add(1)
+	go p.run()
+
+	return p
+}
+
+// publish queue the packet without blocking, returns false if the queue is full
+func (p *publisher) publish(packet *cproto.ClusterPacket) bool {
+	select {
+	case p.ch <- packet:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *publisher) run() {
+	defer p.wg.Done()
+
+	var stream *publishStream
+	for packet := range p.ch {
+		if stream == nil {
+			var err error
+			if stream, err = p.openStream(); err != nil {
+				clog.Warnf("[grpc] Open stream fail. [nodeId = %s, method = %s, %s, err = %v]",
+					p.nodeId,
+					p.method,
+					packet.PrintLog(),
+					err,
+				)
+				packet.Recycle()
+				continue
+			}
+		}
+
+		if err := stream.SendMsg(packet); err != nil {
+			clog.Warnf("[grpc] Send packet fail. [nodeId = %s, method = %s, %s, err = %v]",
+				p.nodeId,
+				p.method,
+				packet.PrintLog(),
+				err,
+			)
+			// reopen the stream on the next packet
+			stream.cancel()
+			stream = nil
+		}
+
+		packet.Recycle()
+	}
+
+	if stream != nil {
+		stream.close()
+	}
+}
+
+func (p *publisher) openStream() (*publishStream, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := p.conn.NewStream(ctx, p.desc, p.method)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &publishStream{
+		ClientStream: stream,
+		cancel:       cancel,
+	}, nil
+}
+
+func (p *publisher) close() {
+	close(p.ch)
+	p.wg.Wait()
+}
+
+// close wait for the server has received all packets
+func (p *publishStream) close() {
+	defer p.cancel()
+
+	if err := p.CloseSend(); err != nil {
+		return
+	}
+
+	_ = p.RecvMsg(&cproto.Response{})
+}
@@ -0,0 +1,463 @@
+package cherryGRPC
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cprofile "github.com/cherry-game/cherry/profile"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	Name = "grpc"
+)
+
+var (
+	ErrPublishQueueFull = cerr.Error("grpc publish queue is full")
+)
+
+type (
+	// Cluster the cluster of grpc transport, select it by `cluster->transport = grpc` in profile file.
+	// the server listens on the rpc address of the node, the members are dialed by their rpc address.
+	Cluster struct {
+		app            cfacade.IApplication
+		bufferSize     int
+		poolSize       int           // conns per target node
+		requestTimeout time.Duration // default timeout of RequestRemote
+		drainTimeout   time.Duration // wait the in-flight calls on Stop
+		serverTLS      *tls.Config
+		clientTLS      *tls.Config
+		serverOptions  []grpc.ServerOption
+		dialOptions    []grpc.DialOption
+		server         *grpc.Server
+		lock           sync.RWMutex
+		clients        map[string]*nodeClient // key:nodeId
+		inflight       sync.WaitGroup         // in-flight requests of the client
+		chStop         chan struct{}
+		stopped        bool
+	}
+
+	OptionFunc func(o *Cluster)
+
+	// reply receive the response of the remote actor, see cactor.InvokeRemoteFunc
+	reply struct {
+		ch chan []byte
+	}
+)
+
+func New(app cfacade.IApplication, options ...OptionFunc) cfacade.ICluster {
+	cluster := &Cluster{
+		app:            app,
+		bufferSize:     1024,
+		poolSize:       1,
+		requestTimeout: 3 * time.Second,
+		drainTimeout:   5 * time.Second,
+		clients:        make(map[string]*nodeClient),
+		chStop:         make(chan struct{}),
+	}
+
+	cluster.loadConfig()
+
+	for _, option := range options {
+		option(cluster)
+	}
+
+	return cluster
+}
+
+func (p *Cluster) loadConfig() {
+	config := cprofile.GetConfig("cluster").GetConfig(Name)
+	if config.LastError() != nil {
+		return
+	}
+
+	p.poolSize = config.GetInt("pool_size", p.poolSize)
+	p.bufferSize = config.GetInt("buffer_size", p.bufferSize)
+	p.requestTimeout = config.GetDuration("request_timeout", 3) * time.Second
+	p.drainTimeout = config.GetDuration("drain_timeout", 5) * time.Second
+}
+
+func (p *Cluster) Init() {
+	if p.app.RpcAddress() == "" {
+		panic("rpc_address of the node is empty.")
+	}
+
+	listener, err := net.Listen("tcp", p.app.RpcAddress())
+	if err != nil {
+		clog.Panicf("[grpc] Listen fail. [address = %s, err = %v]", p.app.RpcAddress(), err)
+	}
+
+	opts := p.serverOptions
+	if p.serverTLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(p.serverTLS)))
+	}
+
+	p.server = grpc.NewServer(opts...)
+	p.server.RegisterService(&serviceDesc, p)
+
+	go func() {
+		if err := p.server.Serve(listener); err != nil {
+			clog.Warnf("[grpc] Serve exit. [err = %v]", err)
+		}
+	}()
+
+	p.app.Discovery().OnRemoveMember(func(member cfacade.IMember) {
+		p.removeClient(member.GetNodeId())
+	})
+
+	clog.Infof("grpc cluster execute OnInit(). [address = %s]", p.app.RpcAddress())
+}
+
+// Stop stop accepting new calls, drain the in-flight calls in drainTimeout and close the conns
+func (p *Cluster) Stop() {
+	p.lock.Lock()
+	if p.stopped {
+		p.lock.Unlock()
+		return
+	}
+
+	p.stopped = true
+	clients := p.clients
+	p.clients = make(map[string]*nodeClient)
+	p.lock.Unlock()
+
+	// the client streams of the other nodes are ended by chStop
+	close(p.chStop)
+
+	done := make(chan struct{})
+	go func() {
+		if p.server != nil {
+			p.server.GracefulStop()
+		}
+		p.inflight.Wait()
+
+		for _, client := range clients {
+			client.close()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(p.drainTimeout):
+		clog.Warnf("[grpc] Drain timeout, stop the server. [timeout = %v]", p.drainTimeout)
+		if p.server != nil {
+			p.server.Stop()
+		}
+	}
+
+	clog.Info("grpc cluster execute OnStop().")
+}
+
+// IsDegraded returns true if any conn of the target nodes is transient failure
+func (p *Cluster) IsDegraded() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.stopped {
+		return true
+	}
+
+	for _, client := range p.clients {
+		if client.isDegraded() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Cluster) getClient(nodeId string) (*nodeClient, error) {
+	p.lock.RLock()
+	client, found := p.clients[nodeId]
+	p.lock.RUnlock()
+
+	if found {
+		return client, nil
+	}
+
+	member, found := p.app.Discovery().GetMember(nodeId)
+	if !found {
+		return nil, cerr.DiscoveryMemberListIsEmpty
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.stopped {
+		return nil, cerr.ClusterRPCClientIsStop
+	}
+
+	if client, found = p.clients[nodeId]; found {
+		return client, nil
+	}
+
+	client, err := newNodeClient(nodeId, member.GetAddress(), p.poolSize, p.bufferSize, p.clientDialOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	p.clients[nodeId] = client
+	return client, nil
+}
+
+func (p *Cluster) clientDialOptions() []grpc.DialOption {
+	opts := append([]grpc.DialOption{}, p.dialOptions...)
+	if p.clientTLS != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(p.clientTLS)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	return opts
+}
+
+func (p *Cluster) removeClient(nodeId string) {
+	p.lock.Lock()
+	client, found := p.clients[nodeId]
+	delete(p.clients, nodeId)
+	p.lock.Unlock()
+
+	if found {
+		go client.close()
+	}
+}
+
+func (p *Cluster) PublishLocal(nodeId string, packet *cproto.ClusterPacket) error {
+	return p.publish(nodeId, packet, false)
+}
+
+func (p *Cluster) PublishRemote(nodeId string, packet *cproto.ClusterPacket) error {
+	return p.publish(nodeId, packet, true)
+}
+
+// publish queue the packet to the ordered stream of the node, the packet is recycled after it's sent
+func (p *Cluster) publish(nodeId string, packet *cproto.ClusterPacket, isRemote bool) error {
+	if !p.app.Running() {
+		packet.Recycle()
+		return cerr.ClusterRPCClientIsStop
+	}
+
+	if _, err := p.getClient(nodeId); err != nil {
+		clog.Debugf("[grpc] Get client fail. [nodeId = %s, %s, err = %v]", nodeId, packet.PrintLog(), err)
+		packet.Recycle()
+		return err
+	}
+
+	// the publisher is closed after it's removed from the map
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	client, found := p.clients[nodeId]
+	if !found {
+		packet.Recycle()
+		return cerr.ClusterRPCClientIsStop
+	}
+
+	publisher := client.local
+	if isRemote {
+		publisher = client.remote
+	}
+
+	if clog.PrintLevel(zapcore.DebugLevel) {
+		clog.Debugf("[grpc] Publish. [nodeId = %s, isRemote = %v, %s]", nodeId, isRemote, packet.PrintLog())
+	}
+
+	if !publisher.publish(packet) {
+		packet.Recycle()
+		return ErrPublishQueueFull
+	}
+
+	return nil
+}
+
+func (p *Cluster) RequestRemote(nodeId string, packet *cproto.ClusterPacket, timeout ...time.Duration) cproto.Response {
+	defer packet.Recycle()
+
+	rsp := cproto.Response{}
+
+	p.lock.RLock()
+	stopped := p.stopped
+	if !stopped {
+		p.inflight.Add(1)
+	}
+	p.lock.RUnlock()
+
+	if stopped {
+		rsp.Code = ccode.RPCNetError
+		return rsp
+	}
+	defer p.inflight.Done()
+
+	client, err := p.getClient(nodeId)
+	if err != nil {
+		clog.Debugf("[grpc] Get client fail. [nodeId = %s, %s, err = %v]", nodeId, packet.PrintLog(), err)
+		rsp.Code = ccode.DiscoveryNotFoundNode
+		return rsp
+	}
+
+	t := p.requestTimeout
+	if len(timeout) > 0 && timeout[0] > 0 {
+		t = timeout[0]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t)
+	defer cancel()
+
+	if err = client.pick().Invoke(ctx, requestMethod, packet, &rsp); err != nil {
+		clog.Warnf("[grpc] Request fail. [nodeId = %s, %s, err = %v]", nodeId, packet.PrintLog(), err)
+
+		if status.Code(err) == codes.DeadlineExceeded {
+			rsp.Code = ccode.RPCTimeoutError
+		} else {
+			rsp.Code = ccode.RPCNetError
+		}
+	}
+
+	return rsp
+}
+
+func (p *Cluster) receive(stream grpc.ServerStream, isRemote bool) error {
+	done := make(chan error, 1)
+
+	go func() {
+		for {
+			packet := cproto.GetClusterPacket()
+			if err := stream.RecvMsg(packet); err != nil {
+				packet.Recycle()
+				done <- err
+				return
+			}
+
+			p.post(packet, isRemote, nil)
+		}
+	}()
+
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			return err
+		}
+		// the client closes the stream
+		return stream.SendMsg(&cproto.Response{})
+	case <-p.chStop:
+		return status.Error(codes.Unavailable, "cluster is stopped")
+	}
+}
+
+func (p *Cluster) request(ctx context.Context, packet *cproto.ClusterPacket) (*cproto.Response, error) {
+	r := &reply{
+		ch: make(chan []byte, 1),
+	}
+
+	p.post(packet, true, r)
+
+	select {
+	case data := <-r.ch:
+		rsp := &cproto.Response{}
+		if err := proto.Unmarshal(data, rsp); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return rsp, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (p *Cluster) post(packet *cproto.ClusterPacket, isRemote bool, r *reply) {
+	defer packet.Recycle()
+
+	message := cfacade.GetMessage()
+	message.BuildTime = packet.BuildTime
+	message.Source = packet.SourcePath
+	message.Target = packet.TargetPath
+	message.FuncName = packet.FuncName
+	message.IsCluster = true
+	message.Session = packet.Session
+	message.Header = packet.Header
+	message.Args = packet.ArgBytes
+
+	if isRemote {
+		if r != nil {
+			message.ClusterReply = r
+		}
+		p.app.ActorSystem().PostRemote(&message)
+	} else {
+		p.app.ActorSystem().PostLocal(&message)
+	}
+}
+
+func (r *reply) Respond(data []byte) error {
+	select {
+	case r.ch <- data:
+	default:
+	}
+	return nil
+}
+
+func WithBufferSize(size int) OptionFunc {
+	return func(o *Cluster) {
+		if size > 0 {
+			o.bufferSize = size
+		}
+	}
+}
+
+// WithPoolSize the conns per target node, the requests are balanced by round robin
+func WithPoolSize(size int) OptionFunc {
+	return func(o *Cluster) {
+		if size > 0 {
+			o.poolSize = size
+		}
+	}
+}
+
+func WithRequestTimeout(timeout time.Duration) OptionFunc {
+	return func(o *Cluster) {
+		if timeout > 0 {
+			o.requestTimeout = timeout
+		}
+	}
+}
+
+func WithDrainTimeout(timeout time.Duration) OptionFunc {
+	return func(o *Cluster) {
+		if timeout > 0 {
+			o.drainTimeout = timeout
+		}
+	}
+}
+
+// WithTLS set the tls config of the server and the client. eg. mutual tls
+func WithTLS(server, client *tls.Config) OptionFunc {
+	return func(o *Cluster) {
+		o.serverTLS = server
+		o.clientTLS = client
+	}
+}
+
+func WithServerOptions(opts ...grpc.ServerOption) OptionFunc {
+	return func(o *Cluster) {
+		o.serverOptions = append(o.serverOptions, opts...)
+	}
+}
+
+func WithDialOptions(opts ...grpc.DialOption) OptionFunc {
+	return func(o *Cluster) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
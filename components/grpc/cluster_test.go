@@ -0,0 +1,339 @@
+package cherryGRPC
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cprofile "github.com/cherry-game/cherry/profile"
+	"google.golang.org/protobuf/proto"
+)
+
+const testProfile = `{
+  "node": {
+    "game": [
+      {"node_id": "game-1", "rpc_address": "127.0.0.1:0", "enabled": true}
+    ]
+  }
+}`
+
+type (
+	testApp struct {
+		cfacade.IApplication
+		nodeId    string
+		address   string
+		discovery *testDiscovery
+		system    *testSystem
+	}
+
+	testDiscovery struct {
+		cfacade.IDiscovery
+		members map[string]cfacade.IMember
+	}
+
+	// testSystem echo the args of the requests, the published messages are sent to ch.
+	// the request of the func "hang" is never responded
+	testSystem struct {
+		cfacade.IActorSystem
+		ch chan *cfacade.Message
+	}
+)
+
+// TestMain load the profile without the cluster config, the defaults are used
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "cherry-grpc")
+	if err != nil {
+		panic(err)
+	}
+
+	path := filepath.Join(dir, "profile-test.json")
+	if err = os.WriteFile(path, []byte(testProfile), 0644); err != nil {
+		panic(err)
+	}
+
+	if _, err = cprofile.Init(path, "game-1"); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func (p *testApp) NodeId() string {
+	return p.nodeId
+}
+
+func (p *testApp) RpcAddress() string {
+	return p.address
+}
+
+func (p *testApp) Running() bool {
+	return true
+}
+
+func (p *testApp) Discovery() cfacade.IDiscovery {
+	return p.discovery
+}
+
+func (p *testApp) ActorSystem() cfacade.IActorSystem {
+	return p.system
+}
+
+func (p *testDiscovery) GetMember(nodeId string) (cfacade.IMember, bool) {
+	member, found := p.members[nodeId]
+	return member, found
+}
+
+func (p *testDiscovery) OnRemoveMember(_ cfacade.MemberListener) {
+}
+
+func (p *testSystem) PostRemote(m *cfacade.Message) bool {
+	if m.ClusterReply == nil {
+		p.ch <- m
+		return true
+	}
+
+	if m.FuncName == "hang" {
+		return true
+	}
+
+	data, _ := proto.Marshal(&cproto.Response{Data: m.Args.([]byte)})
+	_ = m.ClusterReply.Respond(data)
+	return true
+}
+
+func (p *testSystem) PostLocal(m *cfacade.Message) bool {
+	p.ch <- m
+	return true
+}
+
+// newTestNodes init the clusters of the nodes on the local addresses
+func newTestNodes(t *testing.T, options ...OptionFunc) (*Cluster, *Cluster, *testSystem) {
+	discovery := &testDiscovery{
+		members: make(map[string]cfacade.IMember),
+	}
+
+	var (
+		clusters []*Cluster
+		system   *testSystem
+	)
+
+	for _, nodeId := range []string{"game-1", "game-2"} {
+		address := freeAddress(t)
+		discovery.members[nodeId] = &cproto.Member{NodeId: nodeId, NodeType: "game", Address: address}
+
+		system = &testSystem{ch: make(chan *cfacade.Message, 16)}
+		app := &testApp{
+			nodeId:    nodeId,
+			address:   address,
+			discovery: discovery,
+			system:    system,
+		}
+
+		cluster := New(app, options...).(*Cluster)
+		cluster.Init()
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters[0], clusters[1], system
+}
+
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func newTestPacket(funcName string, args []byte) *cproto.ClusterPacket {
+	packet := cproto.GetClusterPacket()
+	packet.SourcePath = "game-1.player"
+	packet.TargetPath = "game-2.room"
+	packet.FuncName = funcName
+	packet.ArgBytes = args
+	return packet
+}
+
+func TestClusterRequestPublish(t *testing.T) {
+	node1, node2, system2 := newTestNodes(t)
+	defer node1.Stop()
+	defer node2.Stop()
+
+	rsp := node1.RequestRemote("game-2", newTestPacket("echo", []byte("hello")), time.Second)
+	if rsp.Code != ccode.OK || string(rsp.Data) != "hello" {
+		t.Fatalf("rsp code = %d, data = %s", rsp.Code, rsp.Data)
+	}
+
+	if rsp = node1.RequestRemote("game-3", newTestPacket("echo", nil)); rsp.Code != ccode.DiscoveryNotFoundNode {
+		t.Fatalf("rsp code = %d", rsp.Code)
+	}
+
+	// the published packets are received in order
+	for _, funcName := range []string{"remote1", "remote2"} {
+		if err := node1.PublishRemote("game-2", newTestPacket(funcName, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := node1.PublishLocal("game-2", newTestPacket("local", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(map[string]bool)
+	var remotes []string
+	for i := 0; i < 3; i++ {
+		select {
+		case m := <-system2.ch:
+			received[m.FuncName] = true
+			if m.FuncName != "local" {
+				remotes = append(remotes, m.FuncName)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("received = %v", received)
+		}
+	}
+
+	if !received["local"] || len(remotes) != 2 || remotes[0] != "remote1" || remotes[1] != "remote2" {
+		t.Fatalf("received = %v, remotes = %v", received, remotes)
+	}
+}
+
+func TestClusterPublishQueueFull(t *testing.T) {
+	node1, node2, _ := newTestNodes(t)
+	defer node1.Stop()
+	defer node2.Stop()
+
+	// the publisher without the send goroutine
+	node1.lock.Lock()
+	node1.clients["game-2"] = &nodeClient{
+		nodeId: "game-2",
+		local:  &publisher{ch: make(chan *cproto.ClusterPacket, 1)},
+		remote: &publisher{ch: make(chan *cproto.ClusterPacket, 1)},
+	}
+	node1.lock.Unlock()
+
+	if err := node1.PublishRemote("game-2", newTestPacket("remote1", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node1.PublishRemote("game-2", newTestPacket("remote2", nil)); err != ErrPublishQueueFull {
+		t.Fatalf("publish err = %v", err)
+	}
+
+	// the queued packet is not sent
+	node1.lock.Lock()
+	delete(node1.clients, "game-2")
+	node1.lock.Unlock()
+}
+
+func TestClusterStopDrainTimeout(t *testing.T) {
+	node1, node2, _ := newTestNodes(t, WithDrainTimeout(100*time.Millisecond))
+	defer node1.Stop()
+
+	done := make(chan int32, 1)
+	go func() {
+		rsp := node1.RequestRemote("game-2", newTestPacket("hang", nil), 5*time.Second)
+		done <- rsp.Code
+	}()
+
+	// wait for the request is in-flight on the server
+	time.Sleep(100 * time.Millisecond)
+
+	begin := time.Now()
+	node2.Stop()
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Fatalf("stop elapsed = %v", elapsed)
+	}
+
+	select {
+	case code := <-done:
+		if code != ccode.RPCNetError {
+			t.Fatalf("rsp code = %d", code)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the in-flight request is not stopped")
+	}
+
+	if !node2.IsDegraded() {
+		t.Fatal("the stopped cluster is not degraded")
+	}
+}
+
+func TestClusterTLS(t *testing.T) {
+	cert, pool := newTestCert(t)
+	serverTLS := &tls.Config{Certificates: []tls.Certificate{cert}}
+	clientTLS := &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+
+	node1, node2, _ := newTestNodes(t, WithTLS(serverTLS, clientTLS))
+	defer node1.Stop()
+	defer node2.Stop()
+
+	rsp := node1.RequestRemote("game-2", newTestPacket("echo", []byte("tls")), time.Second)
+	if rsp.Code != ccode.OK || string(rsp.Data) != "tls" {
+		t.Fatalf("rsp code = %d, data = %s", rsp.Code, rsp.Data)
+	}
+
+	// the client without the root ca
+	insecure, insecure2, _ := newTestNodes(t, WithTLS(serverTLS, &tls.Config{ServerName: "127.0.0.1"}))
+	defer insecure.Stop()
+	defer insecure2.Stop()
+
+	insecure.app.Discovery().(*testDiscovery).members["game-2"] = &cproto.Member{
+		NodeId:  "game-2",
+		Address: node2.app.RpcAddress(),
+	}
+
+	if rsp = insecure.RequestRemote("game-2", newTestPacket("echo", nil), time.Second); rsp.Code == ccode.OK {
+		t.Fatal("the request without the root ca is ok")
+	}
+}
+
+// newTestCert returns the self-signed certificate of 127.0.0.1 and the pool of it
+func newTestCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cherry"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
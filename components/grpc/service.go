@@ -0,0 +1,91 @@
+package cherryGRPC
+
+import (
+	"context"
+
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"google.golang.org/grpc"
+)
+
+// the service is written as the protoc-gen-go-grpc output of:
+//
+//	service Cluster {
+//	    rpc Local(stream ClusterPacket) returns (Response);  // ordered PublishLocal
+//	    rpc Remote(stream ClusterPacket) returns (Response); // ordered PublishRemote
+//	    rpc Request(ClusterPacket) returns (Response);       // RequestRemote
+//	}
+//
+// ClusterPacket carries the route(TargetPath + FuncName) and the payload(ArgBytes) marshaled by the app serializer,
+// Response returns the payload(Data) and the error code(Code).
+const (
+	serviceName   = "cherry.Cluster"
+	localMethod   = "/" + serviceName + "/Local"
+	remoteMethod  = "/" + serviceName + "/Remote"
+	requestMethod = "/" + serviceName + "/Request"
+)
+
+var (
+	serviceDesc = grpc.ServiceDesc{
+		ServiceName: serviceName,
+		HandlerType: (*clusterServer)(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Request",
+				Handler:    requestHandler,
+			},
+		},
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName:    "Local",
+				Handler:       localHandler,
+				ClientStreams: true,
+			},
+			{
+				StreamName:    "Remote",
+				Handler:       remoteHandler,
+				ClientStreams: true,
+			},
+		},
+		Metadata: "cluster.proto",
+	}
+
+	localStreamDesc  = &serviceDesc.Streams[0]
+	remoteStreamDesc = &serviceDesc.Streams[1]
+)
+
+type (
+	clusterServer interface {
+		receive(stream grpc.ServerStream, isRemote bool) error
+		request(ctx context.Context, packet *cproto.ClusterPacket) (*cproto.Response, error)
+	}
+)
+
+func localHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(clusterServer).receive(stream, false)
+}
+
+func remoteHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(clusterServer).receive(stream, true)
+}
+
+func requestHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &cproto.ClusterPacket{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(clusterServer).request(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: requestMethod,
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(clusterServer).request(ctx, req.(*cproto.ClusterPacket))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
@@ -0,0 +1,125 @@
+package cherryKCP
+
+import (
+	"sync/atomic"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	"github.com/xtaci/kcp-go/v5"
+)
+
+type (
+	// Connector kcp(reliable udp) connector, the accepted *kcp.UDPSession is a net.Conn.
+	// the agent is created, heartbeat checked and closed the same as the tcp connector.
+	// udp has no connection close, the session is closed by the heartbeat timeout of the parser.
+	Connector struct {
+		cfacade.Component
+		Options
+		listener      *kcp.Listener
+		onConnectFunc cfacade.OnConnectFunc
+		running       int32 // 1 is running, it's read by the accept loop and written by Stop
+	}
+)
+
+func New(address string, opts ...Option) *Connector {
+	if address == "" {
+		clog.Warn("Create kcp connector fail. Address is null.")
+		return nil
+	}
+
+	connector := &Connector{
+		Options: Options{
+			address:    address,
+			chanSize:   256,
+			noDelay:    0,
+			interval:   40,
+			resend:     0,
+			sndWnd:     128,
+			rcvWnd:     128,
+			mtu:        1400,
+			streamMode: true,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&connector.Options)
+	}
+
+	return connector
+}
+
+func (*Connector) Name() string {
+	return "kcp_connector"
+}
+
+func (p *Connector) OnAfterInit() {
+}
+
+func (p *Connector) OnStop() {
+	p.Stop()
+}
+
+func (p *Connector) OnConnect(fn cfacade.OnConnectFunc) {
+	if fn != nil {
+		p.onConnectFunc = fn
+	}
+}
+
+func (p *Connector) Start() {
+	if p.onConnectFunc == nil {
+		panic("onConnectFunc is nil.")
+	}
+
+	listener, err := kcp.ListenWithOptions(p.address, nil, p.dataShards, p.parityShards)
+	if err != nil {
+		clog.Fatalf("failed to listen: %s", err)
+	}
+
+	p.listener = listener
+	atomic.StoreInt32(&p.running, 1)
+
+	clog.Infof("Kcp connector listening at Address %s", p.address)
+
+	connChan := make(chan *kcp.UDPSession, p.chanSize)
+	go func() {
+		for conn := range connChan {
+			p.onConnectFunc(conn)
+		}
+	}()
+
+	for p.isRunning() {
+		conn, err := listener.AcceptKCP()
+		if err != nil {
+			if !p.isRunning() {
+				break
+			}
+			clog.Errorf("Failed to accept KCP connection: %s", err.Error())
+			continue
+		}
+
+		conn.SetStreamMode(p.streamMode)
+		conn.SetNoDelay(p.noDelay, p.interval, p.resend, p.noCongestion)
+		conn.SetWindowSize(p.sndWnd, p.rcvWnd)
+		conn.SetMtu(p.mtu)
+
+		connChan <- conn
+	}
+
+	close(connChan)
+}
+
+func (p *Connector) isRunning() bool {
+	return atomic.LoadInt32(&p.running) == 1
+}
+
+func (p *Connector) Stop() {
+	atomic.StoreInt32(&p.running, 0)
+
+	if p.listener == nil {
+		return
+	}
+
+	if err := p.listener.Close(); err != nil {
+		clog.Errorf("Failed to stop: %s", err)
+	}
+}
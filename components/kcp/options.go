@@ -0,0 +1,64 @@
+package cherryKCP
+
+type (
+	Options struct {
+		address      string
+		chanSize     int
+		dataShards   int  // fec data shards, zero is disabled
+		parityShards int  // fec parity shards
+		noDelay      int  // 0:disable(default), 1:enable
+		interval     int  // internal update timer interval in millisecond
+		resend       int  // fast resend. 0:disable, 2:2 ACK-crosses
+		noCongestion int  // 0:normal congestion control, 1:disable
+		sndWnd       int  // send window size
+		rcvWnd       int  // receive window size
+		mtu          int  // max transmission unit
+		streamMode   bool // stream mode drops the message boundaries, the packets are framed by the pomelo parser
+	}
+
+	Option func(*Options)
+)
+
+// WithNoDelay kcp tuning. eg. the fastest mode: WithNoDelay(1, 10, 2, 1)
+func WithNoDelay(noDelay, interval, resend, noCongestion int) Option {
+	return func(o *Options) {
+		o.noDelay = noDelay
+		o.interval = interval
+		o.resend = resend
+		o.noCongestion = noCongestion
+	}
+}
+
+// WithWindowSize set the send and receive window size
+func WithWindowSize(sndWnd, rcvWnd int) Option {
+	return func(o *Options) {
+		if sndWnd > 0 && rcvWnd > 0 {
+			o.sndWnd = sndWnd
+			o.rcvWnd = rcvWnd
+		}
+	}
+}
+
+func WithMTU(mtu int) Option {
+	return func(o *Options) {
+		if mtu > 0 {
+			o.mtu = mtu
+		}
+	}
+}
+
+// WithFEC enable the forward error correction
+func WithFEC(dataShards, parityShards int) Option {
+	return func(o *Options) {
+		o.dataShards = dataShards
+		o.parityShards = parityShards
+	}
+}
+
+func WithChanSize(size int) Option {
+	return func(o *Options) {
+		if size > 1 {
+			o.chanSize = size
+		}
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/cherry-game/cherry/components/otel
+
+go 1.18
+
+require (
+	github.com/cherry-game/cherry v1.3.14
+	go.opentelemetry.io/otel v1.19.0
+	go.opentelemetry.io/otel/trace v1.19.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.19.0 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
+
+replace github.com/cherry-game/cherry => ../../
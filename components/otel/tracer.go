@@ -0,0 +1,99 @@
+package cherryOtel
+
+import (
+	"context"
+	"strconv"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/cherry-game/cherry"
+)
+
+type (
+	// Tracer propagate the span context by the header of the cluster packet. see cfacade.ITracer
+	// eg. app.ActorSystem().SetTracer(cherryOtel.New())
+	Tracer struct {
+		tracer     trace.Tracer
+		propagator propagation.TextMapPropagator
+	}
+
+	Option func(tracer *Tracer)
+)
+
+// New the global tracer provider and propagator are used by default
+func New(opts ...Option) *Tracer {
+	tracer := &Tracer{
+		tracer:     otel.Tracer(instrumentationName),
+		propagator: otel.GetTextMapPropagator(),
+	}
+
+	for _, opt := range opts {
+		opt(tracer)
+	}
+
+	return tracer
+}
+
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(tracer *Tracer) {
+		tracer.tracer = provider.Tracer(instrumentationName)
+	}
+}
+
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(tracer *Tracer) {
+		tracer.propagator = propagator
+	}
+}
+
+func (p *Tracer) Inject(ctx context.Context, route string, session *cproto.Session) (map[string]string, func(code int32)) {
+	ctx, span := p.tracer.Start(ctx, route,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(sessionAttributes(session)...),
+	)
+
+	header := propagation.MapCarrier{}
+	p.propagator.Inject(ctx, header)
+
+	return header, endSpan(span)
+}
+
+func (p *Tracer) Extract(ctx context.Context, route string, session *cproto.Session, header map[string]string) (context.Context, func(code int32)) {
+	ctx = p.propagator.Extract(ctx, propagation.MapCarrier(header))
+
+	ctx, span := p.tracer.Start(ctx, route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(sessionAttributes(session)...),
+	)
+
+	return ctx, endSpan(span)
+}
+
+func sessionAttributes(session *cproto.Session) []attribute.KeyValue {
+	if session == nil {
+		return nil
+	}
+
+	return []attribute.KeyValue{
+		attribute.String("cherry.sid", session.Sid),
+		attribute.Int64("cherry.uid", session.Uid),
+	}
+}
+
+func endSpan(span trace.Span) func(code int32) {
+	return func(code int32) {
+		if ccode.IsFail(code) {
+			span.SetStatus(codes.Error, "code = "+strconv.Itoa(int(code)))
+			span.SetAttributes(attribute.Int64("cherry.code", int64(code)))
+		}
+		span.End()
+	}
+}
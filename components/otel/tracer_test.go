@@ -0,0 +1,49 @@
+package cherryOtel
+
+import (
+	"context"
+	"testing"
+
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracerPropagation(t *testing.T) {
+	tracer := New(
+		WithTracerProvider(trace.NewNoopTracerProvider()),
+		WithPropagator(propagation.TraceContext{}),
+	)
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02, 0x03},
+		SpanID:     trace.SpanID{0x04, 0x05, 0x06},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), parent)
+	session := &cproto.Session{Sid: "s1", Uid: 1001}
+
+	header, end := tracer.Inject(ctx, "room.join", session)
+	end(0)
+
+	if header["traceparent"] == "" {
+		t.Fatalf("header = %v", header)
+	}
+
+	// the remote node continues the trace of the header
+	extracted, end := tracer.Extract(context.Background(), "room.join", session, header)
+	end(0)
+
+	sc := trace.SpanContextFromContext(extracted)
+	if sc.TraceID() != parent.TraceID() || sc.SpanID() != parent.SpanID() || !sc.IsRemote() {
+		t.Fatalf("span context = %+v", sc)
+	}
+
+	// no trace context in the header
+	extracted, end = tracer.Extract(context.Background(), "room.join", nil, nil)
+	end(0)
+
+	if trace.SpanContextFromContext(extracted).IsValid() {
+		t.Fatal("the span context is extracted from the empty header")
+	}
+}
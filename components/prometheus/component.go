@@ -0,0 +1,89 @@
+package cherryPrometheus
+
+import (
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	cactor "github.com/cherry-game/cherry/net/actor"
+	cprofile "github.com/cherry-game/cherry/profile"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	Name = "prometheus_component"
+)
+
+type (
+	// Component register the collectors to the registerer by the `prometheus` config of the profile.
+	// eg. "prometheus": {"namespace": "game", "session_metrics": true, "rpc_metrics": true, "handler_metrics": true}
+	Component struct {
+		cfacade.Component
+		registerer prometheus.Registerer
+		session    *SessionCollector
+		rpc        *RPCCollector
+		handler    *HandlerCollector
+	}
+)
+
+// New the collectors are registered to prometheus.DefaultRegisterer if registerer is nil
+func New(registerer prometheus.Registerer) *Component {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	return &Component{
+		registerer: registerer,
+	}
+}
+
+func (*Component) Name() string {
+	return Name
+}
+
+func (p *Component) Init() {
+	config := cprofile.GetConfig("prometheus")
+	if config.LastError() != nil {
+		return
+	}
+
+	namespace := config.GetString("namespace", "cherry")
+
+	if config.GetBool("session_metrics", false) {
+		p.session = NewSessionCollector(namespace, p.App().NodeId())
+		p.register(p.session)
+	}
+
+	// the calls are not observed if it's disabled
+	if config.GetBool("rpc_metrics", false) {
+		p.rpc = NewRPCCollector(namespace)
+		p.App().ActorSystem().SetRPCObserver(p.rpc)
+		p.register(p.rpc)
+	}
+
+	// the handlers are not timed if it's disabled
+	if config.GetBool("handler_metrics", false) {
+		p.handler = NewHandlerCollector(namespace)
+		cactor.SetHandlerObserver(p.handler)
+		p.register(p.handler)
+	}
+}
+
+func (p *Component) register(collector prometheus.Collector) {
+	if err := p.registerer.Register(collector); err != nil {
+		clog.Warnf("[prometheus] Register collector fail. [err = %v]", err)
+	}
+}
+
+// RPCCollector returns nil if rpc_metrics is disabled
+func (p *Component) RPCCollector() *RPCCollector {
+	return p.rpc
+}
+
+// HandlerCollector returns nil if handler_metrics is disabled
+func (p *Component) HandlerCollector() *HandlerCollector {
+	return p.handler
+}
+
+// SessionCollector returns nil if session_metrics is disabled
+func (p *Component) SessionCollector() *SessionCollector {
+	return p.session
+}
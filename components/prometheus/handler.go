@@ -0,0 +1,153 @@
+package cherryPrometheus
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// HandlerCollector record the local handlers(client requests) by route. see cfacade.HandlerObserver
+	HandlerCollector struct {
+		calls    *prometheus.CounterVec
+		errors   *prometheus.CounterVec
+		panics   *prometheus.CounterVec
+		duration *prometheus.HistogramVec
+		routes   sync.Map // key:route, value:*routeCounter
+	}
+
+	// RouteStat the counters of the route since start, see HandlerCollector.TopRoutes
+	RouteStat struct {
+		Route      string        `json:"route"`
+		Calls      int64         `json:"calls"`
+		Errors     int64         `json:"errors"` // the handlers returned error or panic
+		Panics     int64         `json:"panics"`
+		AvgLatency time.Duration `json:"avgLatency"`
+	}
+
+	routeCounter struct {
+		calls  int64
+		errors int64
+		panics int64
+		nanos  int64
+	}
+)
+
+// NewHandlerCollector the default buckets are prometheus.DefBuckets
+func NewHandlerCollector(namespace string, buckets ...float64) *HandlerCollector {
+	if len(buckets) < 1 {
+		buckets = prometheus.DefBuckets
+	}
+
+	labels := []string{"route"}
+
+	return &HandlerCollector{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "calls_total",
+			Help:      "Local handler calls.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "errors_total",
+			Help:      "Local handlers returned error by code.",
+		}, append(labels, "code")),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "panics_total",
+			Help:      "Local handlers panic.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "duration_seconds",
+			Help:      "Latency of the local handlers, the network is excluded.",
+			Buckets:   buckets,
+		}, labels),
+	}
+}
+
+func (c *HandlerCollector) ObserveHandler(stat cfacade.HandlerStat) {
+	c.calls.WithLabelValues(stat.Route).Inc()
+	c.duration.WithLabelValues(stat.Route).Observe(stat.Duration.Seconds())
+
+	counter := c.counter(stat.Route)
+	atomic.AddInt64(&counter.calls, 1)
+	atomic.AddInt64(&counter.nanos, int64(stat.Duration))
+
+	if stat.Err != nil {
+		code := "unknown"
+		if value, ok := cerr.Code(stat.Err); ok {
+			code = strconv.Itoa(int(value))
+		}
+		c.errors.WithLabelValues(stat.Route, code).Inc()
+		atomic.AddInt64(&counter.errors, 1)
+	}
+
+	if stat.Panic {
+		c.panics.WithLabelValues(stat.Route).Inc()
+		atomic.AddInt64(&counter.panics, 1)
+	}
+}
+
+func (c *HandlerCollector) counter(route string) *routeCounter {
+	value, found := c.routes.Load(route)
+	if !found {
+		value, _ = c.routes.LoadOrStore(route, &routeCounter{})
+	}
+	return value.(*routeCounter)
+}
+
+// TopRoutes returns the n routes with the most calls, n < 1 returns all routes. eg. the admin http endpoint
+func (c *HandlerCollector) TopRoutes(n int) []RouteStat {
+	var list []RouteStat
+	c.routes.Range(func(key, value interface{}) bool {
+		counter := value.(*routeCounter)
+		stat := RouteStat{
+			Route:  key.(string),
+			Calls:  atomic.LoadInt64(&counter.calls),
+			Errors: atomic.LoadInt64(&counter.errors),
+			Panics: atomic.LoadInt64(&counter.panics),
+		}
+		if stat.Calls > 0 {
+			stat.AvgLatency = time.Duration(atomic.LoadInt64(&counter.nanos) / stat.Calls)
+		}
+		list = append(list, stat)
+		return true
+	})
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Calls == list[j].Calls {
+			return list[i].Route < list[j].Route
+		}
+		return list[i].Calls > list[j].Calls
+	})
+
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+func (c *HandlerCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.calls.Describe(ch)
+	c.errors.Describe(ch)
+	c.panics.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+func (c *HandlerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.calls.Collect(ch)
+	c.errors.Collect(ch)
+	c.panics.Collect(ch)
+	c.duration.Collect(ch)
+}
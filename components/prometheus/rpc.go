@@ -0,0 +1,72 @@
+package cherryPrometheus
+
+import (
+	"strconv"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cfacade "github.com/cherry-game/cherry/facade"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// RPCCollector record the cross-node calls by route and target node type. see cfacade.RPCObserver
+	RPCCollector struct {
+		calls    *prometheus.CounterVec
+		errors   *prometheus.CounterVec
+		duration *prometheus.HistogramVec
+	}
+)
+
+// NewRPCCollector the default buckets are prometheus.DefBuckets
+func NewRPCCollector(namespace string, buckets ...float64) *RPCCollector {
+	if len(buckets) < 1 {
+		buckets = prometheus.DefBuckets
+	}
+
+	labels := []string{"route", "node_type", "wait"}
+
+	return &RPCCollector{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "calls_total",
+			Help:      "Cross-node calls.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "errors_total",
+			Help:      "Failed cross-node calls by code.",
+		}, append(labels, "code")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "duration_seconds",
+			Help:      "Latency of the cross-node calls.",
+			Buckets:   buckets,
+		}, labels),
+	}
+}
+
+func (c *RPCCollector) ObserveRPC(stat cfacade.RPCStat) {
+	wait := strconv.FormatBool(stat.Wait)
+
+	c.calls.WithLabelValues(stat.Route, stat.NodeType, wait).Inc()
+	c.duration.WithLabelValues(stat.Route, stat.NodeType, wait).Observe(stat.Duration.Seconds())
+
+	if ccode.IsFail(stat.Code) {
+		c.errors.WithLabelValues(stat.Route, stat.NodeType, wait, strconv.Itoa(int(stat.Code))).Inc()
+	}
+}
+
+func (c *RPCCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.calls.Describe(ch)
+	c.errors.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+func (c *RPCCollector) Collect(ch chan<- prometheus.Metric) {
+	c.calls.Collect(ch)
+	c.errors.Collect(ch)
+	c.duration.Collect(ch)
+}
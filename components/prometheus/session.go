@@ -0,0 +1,65 @@
+package cherryPrometheus
+
+import (
+	"github.com/cherry-game/cherry/net/parser/pomelo"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// SessionCollector export pomelo.Stats() as prometheus metrics
+	SessionCollector struct {
+		stats       func() pomelo.SessionStats
+		total       *prometheus.Desc
+		bound       *prometheus.Desc
+		created     *prometheus.Desc
+		closed      *prometheus.Desc
+		kicks       *prometheus.Desc
+		messagesIn  *prometheus.Desc
+		messagesOut *prometheus.Desc
+	}
+)
+
+// NewSessionCollector eg. prometheus.MustRegister(NewSessionCollector("game", "node-1"))
+func NewSessionCollector(namespace, nodeId string) *SessionCollector {
+	labels := prometheus.Labels{"node_id": nodeId}
+
+	newDesc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "session", name), help, variableLabels, labels)
+	}
+
+	return &SessionCollector{
+		stats:       pomelo.Stats,
+		total:       newDesc("online", "Online sessions."),
+		bound:       newDesc("bound", "Online sessions bound uid."),
+		created:     newDesc("created_total", "Sessions created since start."),
+		closed:      newDesc("closed_total", "Sessions closed since start."),
+		kicks:       newDesc("kicks_total", "Kicks by reason.", "reason"),
+		messagesIn:  newDesc("messages_in_total", "Data messages received."),
+		messagesOut: newDesc("messages_out_total", "Data messages sent."),
+	}
+}
+
+func (c *SessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.total
+	ch <- c.bound
+	ch <- c.created
+	ch <- c.closed
+	ch <- c.kicks
+	ch <- c.messagesIn
+	ch <- c.messagesOut
+}
+
+func (c *SessionCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stats.Total))
+	ch <- prometheus.MustNewConstMetric(c.bound, prometheus.GaugeValue, float64(stats.Bound))
+	ch <- prometheus.MustNewConstMetric(c.created, prometheus.CounterValue, float64(stats.Created))
+	ch <- prometheus.MustNewConstMetric(c.closed, prometheus.CounterValue, float64(stats.Closed))
+	ch <- prometheus.MustNewConstMetric(c.messagesIn, prometheus.CounterValue, float64(stats.MessagesIn))
+	ch <- prometheus.MustNewConstMetric(c.messagesOut, prometheus.CounterValue, float64(stats.MessagesOut))
+
+	for reason, count := range stats.Kicks {
+		ch <- prometheus.MustNewConstMetric(c.kicks, prometheus.CounterValue, float64(count), reason)
+	}
+}
@@ -0,0 +1,47 @@
+package cherryError
+
+import (
+	"errors"
+)
+
+type (
+	// CodeError error with a status code, the code is responded to the client
+	CodeError struct {
+		err  error
+		code int32
+	}
+)
+
+// WithCode attach the code to the error. returns nil if err is nil
+func WithCode(err error, code int32) error {
+	if err == nil {
+		return nil
+	}
+
+	return &CodeError{
+		err:  err,
+		code: code,
+	}
+}
+
+// Code returns the code attached by WithCode
+func Code(err error) (int32, bool) {
+	var codeErr *CodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.code, true
+	}
+
+	return 0, false
+}
+
+func (e *CodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.err
+}
+
+func (e *CodeError) Code() int32 {
+	return e.code
+}
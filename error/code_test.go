@@ -0,0 +1,33 @@
+package cherryError
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWithCode(t *testing.T) {
+	if err := WithCode(nil, 10); err != nil {
+		t.Fatalf("with code of nil error = %v", err)
+	}
+
+	base := Error("not enough gold")
+	err := WithCode(base, 10)
+
+	if err.Error() != base.Error() {
+		t.Fatalf("error = %s", err)
+	}
+
+	if !errors.Is(err, base) {
+		t.Fatal("code error is not unwrapped")
+	}
+
+	// the code is found through the wrapped errors
+	if code, found := Code(fmt.Errorf("buy item: %w", err)); !found || code != 10 {
+		t.Fatalf("code = %d, found = %v", code, found)
+	}
+
+	if _, found := Code(base); found {
+		t.Fatal("code found in the error without code")
+	}
+}
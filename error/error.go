@@ -28,6 +28,23 @@ var (
 	SessionClosedGroup       = Error("group is closed")
 	SessionDuplication       = Error("session has existed in the current group")
 	SessionNotFoundInContext = Error("session not found in context")
+	SessionReconnectInvalid  = Error("reconnect token is invalid or expired")
+	SessionClosed            = Error("session is closed")
+	SessionSendQueueFull     = Error("session send queue is full")
+	SessionSendBufferExceed  = SessionSendQueueFull // Deprecated: use SessionSendQueueFull
+	SessionDrainTimeout      = Error("session drain timeout")
+	SessionWriteTimeout      = Error("session write timeout")
+	SessionBindRejected      = Error("session bind rejected by listener")
+	SessionAlreadyBound      = Error("session has already bound")
+	SessionUIDBoundOnOther   = Error("uid has already bound on other session")
+	SessionValueNotInteger   = Error("session value is not an integer")
+	SessionKeyExchangeFail   = Error("session key exchange fail")
+	SessionNotEncrypted      = Error("session is not encrypted")
+	SessionPingTimeout       = Error("session ping timeout")
+	SessionResponseTimeout   = Error("session response timeout")
+	SessionCreateRejected    = Error("session create rejected by listener")
+	SessionNoDeadline        = Error("session deadline is not supported")
+	TooManyPendingCalls      = Error("too many pending async calls of the session")
 )
 
 // route
@@ -44,6 +61,8 @@ var (
 	PacketInvalidHeader          = Error("invalid header")
 	PacketMsgSmallerThanExpected = Error("received less data than expected, EOF?")
 	PacketHeadFuncNoSet          = Error("head func no set")
+	PacketDecryptFail            = Error("packet decrypt fail")
+	PacketNonceReused            = Error("packet nonce is reused")
 )
 
 // message
@@ -51,6 +70,7 @@ var (
 	MessageWrongType     = Error("wrong message type")
 	MessageInvalid       = Error("invalid message")
 	MessageRouteNotFound = Error("route info not found in dictionary")
+	MessageHeaderExceed  = Error("message header exceeds the limit")
 )
 
 var (
@@ -66,6 +86,7 @@ var (
 	ClusterRPCClientIsStop = Error("rpc client is stop")
 	ClusterNoImplement     = Error("no implement")
 	NodeTypeIsNil          = Error("node type is nil.")
+	CircuitOpen            = Error("circuit breaker is open")
 )
 
 var (
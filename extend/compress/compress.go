@@ -2,6 +2,7 @@ package cherryCompress
 
 import (
 	"bytes"
+	"compress/gzip"
 	"compress/zlib"
 	"io"
 )
@@ -26,6 +27,30 @@ func InflateData(data []byte) ([]byte, error) {
 	return io.ReadAll(zr)
 }
 
+func GzipData(data []byte) ([]byte, error) {
+	var bb bytes.Buffer
+	z := gzip.NewWriter(&bb)
+	_, err := z.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// flush the gzip trailer
+	if err = z.Close(); err != nil {
+		return nil, err
+	}
+	return bb.Bytes(), nil
+}
+
+func UnGzipData(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
 func IsCompressed(data []byte) bool {
 	return len(data) > 2 &&
 		(
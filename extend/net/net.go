@@ -25,17 +25,33 @@ func LocalIPV4() string {
 }
 
 func GetIPV4(addr net.Addr) string {
-	if addr == nil {
+	ip := GetIP(addr)
+	if ip == nil {
 		return ""
 	}
 
-	if ipNet, ok := addr.(*net.TCPAddr); ok {
-		return ipNet.IP.String()
+	return ip.String()
+}
+
+// GetIP returns the ip of the address. the address of the wrapped conn(eg. websocket) is parsed from addr.String()
+func GetIP(addr net.Addr) net.IP {
+	if addr == nil {
+		return nil
+	}
+
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
 	}
 
-	if ipNet, ok := addr.(*net.UDPAddr); ok {
-		return ipNet.IP.String()
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
 	}
 
-	return ""
+	return net.ParseIP(host)
 }
@@ -2,6 +2,7 @@ package cherryNet
 
 import (
 	"fmt"
+	"net"
 	"testing"
 )
 
@@ -9,3 +10,34 @@ func TestLocalIPV4(t *testing.T) {
 	ip := LocalIPV4()
 	fmt.Println(ip)
 }
+
+func TestGetIP(t *testing.T) {
+	tcpAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 3250}
+	if ip := GetIP(tcpAddr); ip.String() != "10.0.0.1" {
+		t.Fatalf("tcp ip = %v", ip)
+	}
+
+	ipAddr := &net.IPAddr{IP: net.ParseIP("10.0.0.2")}
+	if ip := GetIP(ipAddr); ip.String() != "10.0.0.2" {
+		t.Fatalf("ip addr = %v", ip)
+	}
+
+	// wrapped conn address
+	if ip := GetIP(stringAddr("[::1]:3250")); ip.String() != "::1" {
+		t.Fatalf("string addr = %v", ip)
+	}
+
+	if ip := GetIP(nil); ip != nil {
+		t.Fatalf("nil addr = %v", ip)
+	}
+}
+
+type stringAddr string
+
+func (s stringAddr) Network() string {
+	return "ws"
+}
+
+func (s stringAddr) String() string {
+	return string(s)
+}
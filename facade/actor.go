@@ -1,9 +1,11 @@
 package cherryFacade
 
 import (
+	"context"
 	"time"
 
 	creflect "github.com/cherry-game/cherry/extend/reflect"
+	cproto "github.com/cherry-game/cherry/net/proto"
 )
 
 type (
@@ -15,21 +17,70 @@ type (
 		PostEvent(data IEventData)
 		Call(source, target, funcName string, arg interface{}) int32
 		CallWait(source, target, funcName string, arg interface{}, reply interface{}) int32
+		CallWaitContext(ctx context.Context, source, target, funcName string, arg interface{}, reply interface{}) int32
+		CallWaitWith(source, target, funcName string, arg interface{}, reply interface{}, opts CallOpts) (int32, int)
 		SetLocalInvoke(invoke InvokeFunc)
 		SetRemoteInvoke(invoke InvokeFunc)
 		SetCallTimeout(d time.Duration)
 		SetArrivalTimeout(t int64)
 		SetExecutionTimeout(t int64)
+		SetRPCObserver(observer RPCObserver)
+		SetTracer(tracer ITracer)
+		Tracer() ITracer
 	}
 
 	InvokeFunc func(app IApplication, fi *creflect.FuncInfo, m *Message)
 
+	// CallOpts CallWaitWith的重试参数
+	CallOpts struct {
+		Retries    int           // 首次调用失败后的最大重试次数
+		Backoff    time.Duration // 首次重试的等待时间,每次重试翻倍并加入随机抖动
+		Idempotent bool          // 幂等调用才会在超时或网络错误时重试,非幂等调用失败后直接返回
+	}
+
+	// RPCStat 一次跨节点调用的统计
+	RPCStat struct {
+		Route    string        // actorID.funcName
+		NodeType string        // 目标节点类型,discovery中不存在时为空
+		Wait     bool          // CallWait为true,Call为false
+		Code     int32         // 调用结果
+		Duration time.Duration // Call为序列化+发送,CallWait为序列化+发送+接收+反序列化
+	}
+
+	// RPCObserver 跨节点调用完成后同步调用,实现需并发安全且不能阻塞
+	RPCObserver interface {
+		ObserveRPC(stat RPCStat)
+	}
+
+	// HandlerStat 一次本地handler(客户端请求)的执行统计
+	HandlerStat struct {
+		Route    string        // handler的route, eg. "player.login"
+		Err      error         // handler返回的error, panic时为ccode.HandlerPanic的error
+		Panic    bool          // handler panic, 包括RecoverMiddleware恢复的panic
+		Duration time.Duration // 中间件与handler的执行耗时, 不含参数的反序列化与网络
+	}
+
+	// HandlerObserver 本地handler执行完成后同步调用,实现需并发安全且不能阻塞. see cactor.SetHandlerObserver
+	HandlerObserver interface {
+		ObserveHandler(stat HandlerStat)
+	}
+
+	// ITracer 跨节点调用的链路追踪(eg. OpenTelemetry),默认为空实现. route为actorID.funcName, session可能为nil
+	ITracer interface {
+		// Inject 发送前以ctx为父节点开始route的span, 返回注入了span context的header与结束span的函数
+		Inject(ctx context.Context, route string, session *cproto.Session) (map[string]string, func(code int32))
+		// Extract 接收后从header中继续route的span, 返回span的ctx与结束span的函数
+		Extract(ctx context.Context, route string, session *cproto.Session, header map[string]string) (context.Context, func(code int32))
+	}
+
 	IActor interface {
 		App() IApplication
 		ActorID() string
 		Path() *ActorPath
 		Call(targetPath, funcName string, arg interface{}) int32
 		CallWait(targetPath, funcName string, arg interface{}, reply interface{}) int32
+		CallWaitContext(ctx context.Context, targetPath, funcName string, arg interface{}, reply interface{}) int32
+		CallWaitWith(targetPath, funcName string, arg interface{}, reply interface{}, opts CallOpts) (int32, int)
 		PostRemote(m *Message)
 		PostLocal(m *Message)
 		LastAt() int64
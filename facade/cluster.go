@@ -39,6 +39,7 @@ type (
 		PublishLocal(nodeId string, packet *cproto.ClusterPacket) error                                      // 发布本地消息
 		PublishRemote(nodeId string, packet *cproto.ClusterPacket) error                                     // 发布远程消息
 		RequestRemote(nodeId string, packet *cproto.ClusterPacket, timeout ...time.Duration) cproto.Response // 请求远程消息
+		IsDegraded() bool                                                                                    // 集群连接断开或重连中
 		Stop()                                                                                               // 停止
 	}
 )
@@ -1,6 +1,7 @@
 package cherryFacade
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -12,18 +13,20 @@ import (
 
 type (
 	Message struct {
-		BuildTime    int64            // message build time(ms)
-		PostTime     int64            // post to actor time(ms)
-		Source       string           // 来源actor path
-		Target       string           // 目标actor path
-		targetPath   *ActorPath       // 目标actor path对象
-		FuncName     string           // 请求调用的函数名
-		Session      *cproto.Session  // session of gateway
-		Args         interface{}      // 请求的参数
-		Err          error            // 返回的错误
-		ClusterReply IRespond         // 返回消息的接口
-		IsCluster    bool             // 是否为集群消息
-		ChanResult   chan interface{} //
+		BuildTime    int64             // message build time(ms)
+		PostTime     int64             // post to actor time(ms)
+		Source       string            // 来源actor path
+		Target       string            // 目标actor path
+		targetPath   *ActorPath        // 目标actor path对象
+		FuncName     string            // 请求调用的函数名
+		Session      *cproto.Session   // session of gateway
+		Header       map[string]string // 跨节点消息的头信息, see ITracer
+		Context      context.Context   // 跨节点消息处理时的链路追踪ctx, see ITracer.Extract
+		Args         interface{}       // 请求的参数
+		Err          error             // 返回的错误
+		ClusterReply IRespond          // 返回消息的接口
+		IsCluster    bool              // 是否为集群消息
+		ChanResult   chan interface{}  //
 	}
 
 	IRespond interface {
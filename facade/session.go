@@ -4,3 +4,12 @@ type (
 	SID = string // session unique id
 	UID = int64  // user unique id
 )
+
+const (
+	UnboundUID UID = 0 // uid of the session not bound
+)
+
+// IsValidUID returns true if the uid can be bound and indexed, the valid uid is greater than UnboundUID
+func IsValidUID(uid UID) bool {
+	return uid > UnboundUID
+}
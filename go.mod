@@ -9,6 +9,7 @@ require (
 	github.com/nats-io/nats.go v1.30.2
 	github.com/nats-io/nuid v1.0.1
 	go.uber.org/zap v1.26.0
+	golang.org/x/crypto v0.13.0
 	google.golang.org/protobuf v1.31.0
 )
 
@@ -23,6 +24,5 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/stretchr/testify v1.8.3 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
-	golang.org/x/crypto v0.13.0 // indirect
 	golang.org/x/sys v0.12.0 // indirect
 )
@@ -0,0 +1,91 @@
+package cherryLogger
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	OverflowDrop  = "drop"  // drop the record and count it if the buffer is full, see DroppedRecords()
+	OverflowBlock = "block" // block the caller until the buffer has space
+)
+
+type (
+	// asyncWriter the records are written by a writer goroutine, so a slow disk does not block the callers
+	asyncWriter struct {
+		out      zapcore.WriteSyncer
+		ch       chan asyncRecord
+		block    bool
+		overflow *int64 // dropped records of the logger
+	}
+
+	asyncRecord struct {
+		data []byte
+		done chan error // flush request if it's not nil
+	}
+)
+
+var (
+	droppedRecords int64 // dropped records of all async loggers
+)
+
+func newAsyncWriter(out zapcore.WriteSyncer, bufferSize int, overflowPolicy string) *asyncWriter {
+	if bufferSize < 1 {
+		bufferSize = 1024
+	}
+
+	w := &asyncWriter{
+		out:      out,
+		ch:       make(chan asyncRecord, bufferSize),
+		block:    overflowPolicy == OverflowBlock,
+		overflow: &droppedRecords,
+	}
+
+	go w.run()
+
+	return w
+}
+
+func (w *asyncWriter) run() {
+	for record := range w.ch {
+		if record.done != nil {
+			record.done <- w.out.Sync()
+			continue
+		}
+
+		_, _ = w.out.Write(record.data)
+	}
+}
+
+// Write the bytes are reused by zap after returned, so it's copied
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	record := asyncRecord{
+		data: append([]byte(nil), p...),
+	}
+
+	if w.block {
+		w.ch <- record
+		return len(p), nil
+	}
+
+	select {
+	case w.ch <- record:
+	default:
+		atomic.AddInt64(w.overflow, 1)
+	}
+
+	return len(p), nil
+}
+
+// Sync wait for the buffered records are written and sync the output
+func (w *asyncWriter) Sync() error {
+	done := make(chan error, 1)
+	w.ch <- asyncRecord{done: done}
+	return <-done
+}
+
+// DroppedRecords the records dropped by the full buffer of the async loggers
+func DroppedRecords() int64 {
+	return atomic.LoadInt64(&droppedRecords)
+}
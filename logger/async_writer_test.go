@@ -0,0 +1,87 @@
+package cherryLogger
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+type slowWriter struct {
+	sync.Mutex
+	buf     bytes.Buffer
+	release chan struct{}
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	<-w.release
+
+	w.Lock()
+	defer w.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) Sync() error {
+	return nil
+}
+
+func (w *slowWriter) String() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	w := newAsyncWriter(out, 2, OverflowDrop)
+
+	dropped := DroppedRecords()
+
+	// the writer goroutine holds one record, the buffer holds two
+	for i := 0; i < 5; i++ {
+		_, _ = w.Write([]byte("a"))
+	}
+
+	if n := DroppedRecords() - dropped; n < 1 {
+		t.Fatalf("dropped = %d", n)
+	}
+
+	close(out.release)
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := int64(len(out.String())) + DroppedRecords() - dropped; n != 5 {
+		t.Fatalf("written = %s, dropped = %d", out.String(), DroppedRecords()-dropped)
+	}
+}
+
+func TestAsyncWriterBlock(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	w := newAsyncWriter(out, 1, OverflowBlock)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			_, _ = w.Write([]byte("b"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("write is not blocked by the full buffer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(out.release)
+	<-done
+
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "bbbbb" {
+		t.Fatalf("written = %s", out.String())
+	}
+}
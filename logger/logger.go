@@ -5,8 +5,8 @@ import (
 	"os"
 	"strings"
 	"sync"
-	"time"
 
+	cerr "github.com/cherry-game/cherry/error"
 	cfacade "github.com/cherry-game/cherry/facade"
 	"github.com/cherry-game/cherry/logger/rotatelogs"
 	cprofile "github.com/cherry-game/cherry/profile"
@@ -31,6 +31,8 @@ func init() {
 type CherryLogger struct {
 	*zap.SugaredLogger
 	*Config
+	rotators []*rotatelogs.RotateLogs // the file outputs
+	level    zap.AtomicLevel          // shared by the cores, changed by SetLevel
 }
 
 func (c *CherryLogger) Print(v ...interface{}) {
@@ -95,13 +97,15 @@ func NewConfigLogger(config *Config, opts ...zap.Option) *CherryLogger {
 		}
 	}
 
+	config.FieldNames = config.FieldNames.withDefaults()
+
 	encoderConfig := zapcore.EncoderConfig{
-		TimeKey:        "ts",
-		LevelKey:       "level",
-		CallerKey:      "caller",
-		MessageKey:     "msg",
-		NameKey:        "name",
-		StacktraceKey:  "stack",
+		TimeKey:        config.FieldNames.Time,
+		LevelKey:       config.FieldNames.Level,
+		CallerKey:      config.FieldNames.Caller,
+		MessageKey:     config.FieldNames.Message,
+		NameKey:        config.FieldNames.Name,
+		StacktraceKey:  config.FieldNames.Stack,
 		LineEnding:     zapcore.DefaultLineEnding,
 		EncodeDuration: zapcore.StringDurationEncoder,
 		EncodeCaller:   zapcore.ShortCallerEncoder,
@@ -115,29 +119,50 @@ func NewConfigLogger(config *Config, opts ...zap.Option) *CherryLogger {
 		}
 	}
 
+	encoderConfig.EncodeTime = config.TimeEncoder()
+	encoderConfig.EncodeName = zapcore.FullNameEncoder
+	encoderConfig.FunctionKey = zapcore.OmitKey
+
+	// runtime.Caller is called only if PrintCaller is enabled
 	if config.PrintCaller {
-		encoderConfig.EncodeTime = config.TimeEncoder()
-		encoderConfig.EncodeName = zapcore.FullNameEncoder
-		encoderConfig.FunctionKey = zapcore.OmitKey
-		opts = append(opts, zap.AddCaller())
+		if config.PrintFunction {
+			encoderConfig.FunctionKey = config.FieldNames.Func
+		}
+		opts = append(opts, zap.AddCaller(), zap.AddCallerSkip(config.CallerSkip))
 	}
 
-	opts = append(opts, zap.AddStacktrace(GetLevel(config.StackLevel)))
+	var encoder zapcore.Encoder
+	if config.Encoder == EncoderJSON {
+		// the node id is a field, the time is parsed by the log pipeline
+		encoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
 
-	var writers []zapcore.WriteSyncer
+		if nodeId != "" {
+			opts = append(opts, zap.Fields(zap.String(config.FieldNames.Node, nodeId)))
+		}
+	} else {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
 
-	if config.EnableWriteFile {
-		hook, err := rotatelogs.New(
-			config.FilePathFormat, //filename+"_%Y%m%d%H%M.log",
-			rotatelogs.WithLinkName(config.FileLinkPath),
-			rotatelogs.WithMaxAge(time.Hour*24*time.Duration(config.MaxAge)),
-			rotatelogs.WithRotationTime(time.Second*time.Duration(config.RotationTime)),
-		)
+	if config.StackLevel != StackLevelOff {
+		opts = append(opts, zap.AddStacktrace(GetLevel(config.StackLevel)))
+	}
+
+	var (
+		writers  []zapcore.WriteSyncer
+		cores    []zapcore.Core
+		rotators []*rotatelogs.RotateLogs
+		level    = zap.NewAtomicLevelAt(GetLevel(config.LogLevel))
+	)
 
+	if config.EnableWriteFile {
+		hook, err := newFileSink(config, nil)
 		if err != nil {
 			panic(err)
 		}
 
+		rotators = append(rotators, hook.(*rotatelogs.RotateLogs))
 		writers = append(writers, zapcore.AddSync(hook))
 	}
 
@@ -153,20 +178,125 @@ func NewConfigLogger(config *Config, opts ...zap.Option) *CherryLogger {
 		writers = append(writers, zapcore.Lock(os.Stderr))
 	}
 
-	core := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.AddSync(zapcore.NewMultiWriteSyncer(writers...)),
-		zap.NewAtomicLevelAt(GetLevel(config.LogLevel)),
-	)
+	if len(writers) > 0 || len(config.Sinks) < 1 {
+		writeSyncer := zapcore.NewMultiWriteSyncer(writers...)
+		if config.Async {
+			writeSyncer = newAsyncWriter(writeSyncer, config.AsyncBufferSize, config.AsyncOverflow)
+		}
+		cores = append(cores, zapcore.NewCore(encoder, writeSyncer, level))
+	}
+
+	// each sink has its own core, a slow sink does not block the others if it's async
+	for _, sink := range config.Sinks {
+		fn, found := getSink(sink.Type)
+		if !found {
+			panic(cerr.Errorf("sink type not found. [type = %s]", sink.Type))
+		}
+
+		w, err := fn(config, sink.Config)
+		if err != nil {
+			panic(err)
+		}
+
+		if hook, ok := w.(*rotatelogs.RotateLogs); ok {
+			rotators = append(rotators, hook)
+		}
+
+		writeSyncer := zapcore.Lock(zapcore.AddSync(w))
+		if sink.Async {
+			writeSyncer = newAsyncWriter(writeSyncer, sink.BufferSize, OverflowDrop)
+		}
+
+		cores = append(cores, zapcore.NewCore(encoder, writeSyncer, sinkLevel(level, sink.Level)))
+	}
 
 	cherryLogger := &CherryLogger{
-		SugaredLogger: NewSugaredLogger(core, opts...),
+		SugaredLogger: NewSugaredLogger(zapcore.NewTee(cores...), opts...),
 		Config:        config,
+		rotators:      rotators,
+		level:         level,
 	}
 
 	return cherryLogger
 }
 
+// Flush wait for the buffered records of the async logger are written and sync the outputs. eg. graceful shutdown
+func (c *CherryLogger) Flush() error {
+	return c.Sync()
+}
+
+// Rotate create new log files, the current files are kept as the rotated files. do nothing if there is no file output
+func (c *CherryLogger) Rotate() error {
+	var lastErr error
+	for _, rotator := range c.rotators {
+		if err := rotator.Rotate(); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// WithCallerSkip returns a logger that skips the wrapper funcs of the caller, the level and outputs are shared.
+// eg. the log func of the session reports the real call site
+func (c *CherryLogger) WithCallerSkip(skip int) *CherryLogger {
+	return &CherryLogger{
+		SugaredLogger: c.WithOptions(zap.AddCallerSkip(skip)),
+		Config:        c.Config,
+		rotators:      c.rotators,
+		level:         c.level,
+	}
+}
+
+// SetLevel change the level of the logger at runtime, the loggers created by With are changed too
+func (c *CherryLogger) SetLevel(level zapcore.Level) {
+	c.level.SetLevel(level)
+}
+
+// Level returns the current level of the logger
+func (c *CherryLogger) Level() zapcore.Level {
+	return c.level.Level()
+}
+
+// SetLevel change the level of the named logger at runtime, empty name is DefaultLogger.
+// eg. raise a node to debug temporarily without restart
+func SetLevel(name string, level zapcore.Level) error {
+	rw.RLock()
+	logger, found := loggers[name]
+	if name == "" {
+		logger, found = DefaultLogger, true
+	}
+	rw.RUnlock()
+
+	if !found {
+		return cerr.Errorf("logger not found. [name = %s]", name)
+	}
+
+	logger.SetLevel(level)
+	return nil
+}
+
+// RotateNow rotate the files of all loggers. eg. call it in the SIGHUP handler
+func RotateNow() error {
+	rw.RLock()
+	list := []*CherryLogger{DefaultLogger}
+	for _, logger := range loggers {
+		if logger != DefaultLogger {
+			list = append(list, logger)
+		}
+	}
+	rw.RUnlock()
+
+	var lastErr error
+	for _, logger := range list {
+		if err := logger.Rotate(); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
 func NewSugaredLogger(core zapcore.Core, opts ...zap.Option) *zap.SugaredLogger {
 	zapLogger := zap.New(core, opts...)
 	return zapLogger.Sugar()
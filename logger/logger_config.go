@@ -9,23 +9,117 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	EncoderConsole = "console" // 文本格式
+	EncoderJSON    = "json"    // json格式,每行一条日志
+	StackLevelOff  = "off"     // 不输出堆栈
+)
+
 type (
 	Config struct {
-		LogLevel        string `json:"level"`             // 输出日志等级
-		StackLevel      string `json:"stack_level"`       // 堆栈输出日志等级
-		EnableConsole   bool   `json:"enable_console"`    // 是否控制台输出
-		EnableWriteFile bool   `json:"enable_write_file"` // 是否输出文件(必需配置FilePath)
-		MaxAge          int    `json:"max_age"`           // 最大保留天数(达到限制，则会被清理)
-		TimeFormat      string `json:"time_format"`       // 打印时间输出格式
-		PrintCaller     bool   `json:"print_caller"`      // 是否打印调用函数
-		RotationTime    int    `json:"rotation_time"`     // 日期分割时间(秒)
-		FileLinkPath    string `json:"file_link_path"`    // 日志文件连接路径
-		FilePathFormat  string `json:"file_path_format"`  // 日志文件路径格式
-		IncludeStdout   bool   `json:"include_stdout"`    // 是否包含os.stdout输出
-		IncludeStderr   bool   `json:"include_stderr"`    // 是否包含os.stderr输出
+		LogLevel        string        `json:"level"`             // 输出日志等级
+		StackLevel      string        `json:"stack_level"`       // 堆栈输出日志等级, off为不输出堆栈
+		EnableConsole   bool          `json:"enable_console"`    // 是否控制台输出
+		EnableWriteFile bool          `json:"enable_write_file"` // 是否输出文件(必需配置FilePath)
+		MaxAge          int           `json:"max_age"`           // 最大保留天数(达到限制，则会被清理)
+		MaxSize         int           `json:"max_size"`          // 单个日志文件最大容量(MB),达到后分割文件,0为不限制
+		MaxBackups      int           `json:"max_backups"`       // 最大保留的分割文件数量,0为不限制
+		Compress        bool          `json:"compress"`          // 是否gzip压缩分割后的文件
+		TimeFormat      string        `json:"time_format"`       // 打印时间输出格式
+		PrintCaller     bool          `json:"print_caller"`      // 是否打印调用位置(file:line), 关闭时不调用runtime.Caller
+		PrintFunction   bool          `json:"print_function"`    // 是否打印调用函数名(需开启print_caller)
+		CallerSkip      int           `json:"caller_skip"`       // 调用位置跳过的层数, 用于封装的日志函数
+		RotationTime    int           `json:"rotation_time"`     // 日期分割时间(秒)
+		FileLinkPath    string        `json:"file_link_path"`    // 日志文件连接路径
+		FilePathFormat  string        `json:"file_path_format"`  // 日志文件路径格式
+		IncludeStdout   bool          `json:"include_stdout"`    // 是否包含os.stdout输出
+		IncludeStderr   bool          `json:"include_stderr"`    // 是否包含os.stderr输出
+		Async           bool          `json:"async"`             // 是否异步输出(由单独的goroutine写入)
+		AsyncBufferSize int           `json:"async_buffer_size"` // 异步输出的缓冲数量
+		AsyncOverflow   string        `json:"async_overflow"`    // 异步缓冲已满时的策略, drop(丢弃并计数)或block(阻塞)
+		Sinks           []*SinkConfig `json:"sinks"`             // 额外的输出,每个输出有独立的日志等级
+		Encoder         string        `json:"encoder"`           // 输出格式, console(默认)或json
+		FieldNames      FieldNames    `json:"field_names"`       // 输出的字段名,兼容已有的日志平台
+	}
+
+	// FieldNames 日志字段名, sid/uid/ip为Session日志的字段
+	FieldNames struct {
+		Time    string `json:"time"`
+		Level   string `json:"level"`
+		Message string `json:"message"`
+		Caller  string `json:"caller"`
+		Name    string `json:"name"`
+		Stack   string `json:"stack"`
+		Func    string `json:"func"` // 调用函数名, 需开启print_function
+		Node    string `json:"node"` // 节点id, 仅json格式输出
+		SID     string `json:"sid"`
+		UID     string `json:"uid"`
+		IP      string `json:"ip"`
 	}
 )
 
+func defaultFieldNames() FieldNames {
+	return FieldNames{
+		Time:    "ts",
+		Level:   "level",
+		Message: "msg",
+		Caller:  "caller",
+		Name:    "name",
+		Stack:   "stack",
+		Func:    "func",
+		Node:    "node",
+		SID:     "sid",
+		UID:     "uid",
+		IP:      "ip",
+	}
+}
+
+// withDefaults fill the empty names with the defaults, eg. the Config is built without FieldNames
+func (p FieldNames) withDefaults() FieldNames {
+	names := defaultFieldNames()
+
+	for _, field := range []struct {
+		value *string
+		name  string
+	}{
+		{&p.Time, names.Time},
+		{&p.Level, names.Level},
+		{&p.Message, names.Message},
+		{&p.Caller, names.Caller},
+		{&p.Name, names.Name},
+		{&p.Stack, names.Stack},
+		{&p.Func, names.Func},
+		{&p.Node, names.Node},
+		{&p.SID, names.SID},
+		{&p.UID, names.UID},
+		{&p.IP, names.IP},
+	} {
+		if *field.value == "" {
+			*field.value = field.name
+		}
+	}
+
+	return p
+}
+
+func newFieldNames(jsonConfig cfacade.ProfileJSON) FieldNames {
+	names := defaultFieldNames()
+
+	return FieldNames{
+		Time:    jsonConfig.GetString("time", names.Time),
+		Level:   jsonConfig.GetString("level", names.Level),
+		Message: jsonConfig.GetString("message", names.Message),
+		Caller:  jsonConfig.GetString("caller", names.Caller),
+		Name:    jsonConfig.GetString("name", names.Name),
+		Stack:   jsonConfig.GetString("stack", names.Stack),
+		Func:    jsonConfig.GetString("func", names.Func),
+		Node:    jsonConfig.GetString("node", names.Node),
+		SID:     jsonConfig.GetString("sid", names.SID),
+		UID:     jsonConfig.GetString("uid", names.UID),
+		IP:      jsonConfig.GetString("ip", names.IP),
+	}
+}
+
 func defaultConsoleConfig() *Config {
 	config := &Config{
 		LogLevel:        "debug",
@@ -33,13 +127,23 @@ func defaultConsoleConfig() *Config {
 		EnableConsole:   true,
 		EnableWriteFile: false,
 		MaxAge:          7,
+		MaxSize:         0,
+		MaxBackups:      0,
+		Compress:        false,
 		TimeFormat:      "15:04:05.000", //2006-01-02 15:04:05.000
 		PrintCaller:     true,
+		PrintFunction:   false,
+		CallerSkip:      0,
 		RotationTime:    86400,
 		FileLinkPath:    "logs/debug.log",
 		FilePathFormat:  "logs/debug_%Y%m%d%H%M.log",
 		IncludeStdout:   false,
 		IncludeStderr:   false,
+		Async:           false,
+		AsyncBufferSize: 1024,
+		AsyncOverflow:   OverflowDrop,
+		Encoder:         EncoderConsole,
+		FieldNames:      defaultFieldNames(),
 	}
 	return config
 }
@@ -51,13 +155,24 @@ func NewConfig(jsonConfig cfacade.ProfileJSON) (*Config, error) {
 		EnableConsole:   jsonConfig.GetBool("enable_console", true),
 		EnableWriteFile: jsonConfig.GetBool("enable_write_file", false),
 		MaxAge:          jsonConfig.GetInt("max_age", 7),
+		MaxSize:         jsonConfig.GetInt("max_size", 0),
+		MaxBackups:      jsonConfig.GetInt("max_backups", 0),
+		Compress:        jsonConfig.GetBool("compress", false),
 		TimeFormat:      jsonConfig.GetString("time_format", "15:04:05.000"),
 		PrintCaller:     jsonConfig.GetBool("print_caller", true),
+		PrintFunction:   jsonConfig.GetBool("print_function", false),
+		CallerSkip:      jsonConfig.GetInt("caller_skip", 0),
 		RotationTime:    jsonConfig.GetInt("rotation_time", 86400),
 		FileLinkPath:    jsonConfig.GetString("file_link_path", ""),
 		FilePathFormat:  jsonConfig.GetString("file_path_format", ""),
 		IncludeStdout:   jsonConfig.GetBool("include_stdout", false),
 		IncludeStderr:   jsonConfig.GetBool("include_stderr", false),
+		Async:           jsonConfig.GetBool("async", false),
+		AsyncBufferSize: jsonConfig.GetInt("async_buffer_size", 1024),
+		AsyncOverflow:   jsonConfig.GetString("async_overflow", OverflowDrop),
+		Sinks:           newSinkConfigs(jsonConfig),
+		Encoder:         jsonConfig.GetString("encoder", EncoderConsole),
+		FieldNames:      newFieldNames(jsonConfig.GetConfig("field_names")),
 	}
 
 	if config.EnableWriteFile {
@@ -1,9 +1,11 @@
 package cherryLogger
 
 import (
+	"path/filepath"
 	"testing"
 
 	ctime "github.com/cherry-game/cherry/extend/time"
+	"go.uber.org/zap/zapcore"
 )
 
 func BenchmarkWrite(b *testing.B) {
@@ -19,3 +21,89 @@ func BenchmarkWrite(b *testing.B) {
 		log1.Debug(ctime.Now().ToDateTimeFormat())
 	}
 }
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+	config.EnableWriteFile = true
+	config.FileLinkPath = filepath.Join(dir, "rotate.log")
+	config.FilePathFormat = filepath.Join(dir, "rotate_%Y%m%d.log")
+
+	log1 := NewConfigLogger(config)
+	log1.Info("before rotate")
+
+	before := log1.rotators[0].CurrentFileName()
+	if err := log1.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if after := log1.rotators[0].CurrentFileName(); after == before {
+		t.Fatalf("file is not rotated. [file = %s]", after)
+	}
+
+	// the console logger is not rotated
+	if err := DefaultLogger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkWriteParallel(b *testing.B) {
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+	config.EnableWriteFile = true
+	config.FileLinkPath = "logs/log2.log"
+	config.FilePathFormat = "logs/log2_%Y%m%d%H%M.log"
+
+	log2 := NewConfigLogger(config)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			log2.Debug(ctime.Now().ToDateTimeFormat())
+		}
+	})
+}
+
+// BenchmarkWriteAsync compare with BenchmarkWriteParallel
+func BenchmarkWriteAsync(b *testing.B) {
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+	config.EnableWriteFile = true
+	config.FileLinkPath = "logs/log3.log"
+	config.FilePathFormat = "logs/log3_%Y%m%d%H%M.log"
+	config.Async = true
+	config.AsyncOverflow = OverflowBlock
+
+	log2 := NewConfigLogger(config)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			log2.Debug(ctime.Now().ToDateTimeFormat())
+		}
+	})
+
+	_ = log2.Flush()
+}
+
+func TestSetLevel(t *testing.T) {
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+
+	log1 := NewConfigLogger(config)
+	entry := log1.With("uid", 1)
+
+	log1.SetLevel(zapcore.ErrorLevel)
+	if entry.Desugar().Core().Enabled(zapcore.WarnLevel) {
+		t.Fatal("warn is enabled")
+	}
+
+	log1.SetLevel(zapcore.DebugLevel)
+	if !entry.Desugar().Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug is disabled")
+	}
+
+	if err := SetLevel("not_found", zapcore.DebugLevel); err == nil {
+		t.Fatal("logger is found")
+	}
+}
@@ -0,0 +1,62 @@
+package rotatelogs
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	compressSuffix = ".gz"
+)
+
+// compressFile compress the rotated file to filename.gz and remove it.
+// the file is written to filename.gz_tmp first, so a half compressed file is never purged as a rotated file
+func compressFile(filename string) {
+	// the file may have been purged
+	if err := gzipFile(filename); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "failed to compress %s: %s\n", filename, err)
+	}
+}
+
+func gzipFile(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpName := filename + compressSuffix + "_tmp"
+	dst, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	// keep the mod time, the rotated files are purged by the mod time
+	if fi, err := src.Stat(); err == nil {
+		os.Chtimes(tmpName, fi.ModTime(), fi.ModTime())
+	}
+
+	if err = os.Rename(tmpName, filename+compressSuffix); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(filename)
+}
@@ -41,6 +41,7 @@ type RotateLogs struct {
 	linkName      string
 	maxAge        time.Duration
 	mutex         sync.RWMutex
+	cleanupMutex  sync.Mutex
 	eventHandler  Handler
 	outFh         *os.File
 	pattern       *strftime.Strftime
@@ -48,6 +49,7 @@ type RotateLogs struct {
 	rotationSize  int64
 	rotationCount uint
 	forceNewFile  bool
+	compress      bool
 }
 
 // Clock is the interface used by the RotateLogs
@@ -15,6 +15,7 @@ const (
 	optkeyRotationSize  = "rotation-size"
 	optkeyRotationCount = "rotation-count"
 	optkeyForceNewFile  = "force-new-file"
+	optkeyCompress      = "compress"
 )
 
 // WithClock creates a new Option that sets a clock
@@ -68,8 +69,9 @@ func WithRotationSize(s int64) Option {
 }
 
 // WithRotationCount creates a new Option that sets the
-// number of files should be kept before it gets
-// purged from the file system.
+// number of rotated files should be kept before it gets
+// purged from the file system. It can be used with MaxAge,
+// the file exceeds either of them is purged.
 func WithRotationCount(n uint) Option {
 	return option.New(optkeyRotationCount, n)
 }
@@ -87,3 +89,10 @@ func WithHandler(h Handler) Option {
 func ForceNewFile() Option {
 	return option.New(optkeyForceNewFile, true)
 }
+
+// WithCompress creates a new Option that compresses the
+// rotated file by gzip, the compressed file is named with
+// the ".gz" suffix and the rotated file is removed.
+func WithCompress(compress bool) Option {
+	return option.New(optkeyCompress, compress)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -43,6 +44,7 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 	var maxAge time.Duration
 	var handler Handler
 	var forceNewFile bool
+	var compress bool
 
 	for _, o := range options {
 		switch o.Name() {
@@ -71,13 +73,11 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 			handler = o.Value().(Handler)
 		case optkeyForceNewFile:
 			forceNewFile = true
+		case optkeyCompress:
+			compress = o.Value().(bool)
 		}
 	}
 
-	if maxAge > 0 && rotationCount > 0 {
-		return nil, cerror.Error("options MaxAge and RotationCount cannot be both set")
-	}
-
 	if maxAge == 0 && rotationCount == 0 {
 		// if both are 0, give maxAge a sane default
 		maxAge = 7 * 24 * time.Hour
@@ -94,6 +94,7 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 		rotationSize:  rotationSize,
 		rotationCount: rotationCount,
 		forceNewFile:  forceNewFile,
+		compress:      compress,
 	}, nil
 }
 
@@ -224,6 +225,12 @@ func (rl *RotateLogs) getWriterNoLock(bailOnRotateFail, useGenerationalNames boo
 	rl.curFn = filename
 	rl.generation = generation
 
+	// the previous file has been closed
+	if previousFn == filename {
+		previousFn = ""
+	}
+	go rl.cleanup(previousFn)
+
 	if h := rl.eventHandler; h != nil {
 		go h.Handle(&FileRotatedEvent{
 			prev:    previousFn,
@@ -333,60 +340,88 @@ func (rl *RotateLogs) rotateNoLock(filename string) error {
 		return cerror.Error("panic: maxAge and rotationCount are both set")
 	}
 
-	matches, err := filepath.Glob(rl.globPattern)
+	return nil
+}
+
+// cleanup compress the previous file and purge the rotated files, it runs on a separate goroutine.
+// the cleanups are serialized, so the file being compressed is not purged by other cleanup
+func (rl *RotateLogs) cleanup(previous string) {
+	rl.cleanupMutex.Lock()
+	defer rl.cleanupMutex.Unlock()
+
+	if rl.compress && previous != "" {
+		compressFile(previous)
+	}
+
+	// the file may have been rotated again
+	if err := rl.purge(rl.CurrentFileName()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to purge: %s\n", err.Error())
+	}
+}
+
+// purge unlink the rotated files exceed maxAge or rotationCount, the current file is never purged
+func (rl *RotateLogs) purge(current string) error {
+	// the generational names(foo.1) and the compressed files(foo.gz) are matched by the suffix
+	matches, err := filepath.Glob(rl.globPattern + "*")
 	if err != nil {
 		return err
 	}
 
-	cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
-	var toUnlink []string
+	type rotatedFile struct {
+		path    string
+		modTime time.Time
+	}
+
+	var rotated []rotatedFile
 	for _, path := range matches {
-		// Ignore lock files
-		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
+		// Ignore lock files and the files being compressed
+		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") || strings.HasSuffix(path, compressSuffix+"_tmp") {
 			continue
 		}
 
-		fi, err := os.Stat(path)
-		if err != nil {
+		if path == current {
 			continue
 		}
 
 		fl, err := os.Lstat(path)
-		if err != nil {
+		if err != nil || fl.Mode()&os.ModeSymlink == os.ModeSymlink {
 			continue
 		}
 
-		if rl.maxAge > 0 && fi.ModTime().After(cutoff) {
-			continue
+		rotated = append(rotated, rotatedFile{path: path, modTime: fl.ModTime()})
+	}
+
+	// newest first, the generational names of the same mod time are sorted by the generation
+	sort.Slice(rotated, func(i, j int) bool {
+		if !rotated[i].modTime.Equal(rotated[j].modTime) {
+			return rotated[i].modTime.After(rotated[j].modTime)
 		}
 
-		if rl.rotationCount > 0 && fl.Mode()&os.ModeSymlink == os.ModeSymlink {
-			continue
+		a := strings.TrimSuffix(rotated[i].path, compressSuffix)
+		b := strings.TrimSuffix(rotated[j].path, compressSuffix)
+		if len(a) != len(b) {
+			return len(a) > len(b)
 		}
-		toUnlink = append(toUnlink, path)
-	}
+		return a > b
+	})
 
-	if rl.rotationCount > 0 {
-		// Only delete if we have more than rotationCount
-		if rl.rotationCount >= uint(len(toUnlink)) {
-			return nil
+	cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
+	var toUnlink []string
+	for i, file := range rotated {
+		if rl.maxAge > 0 && file.modTime.Before(cutoff) {
+			toUnlink = append(toUnlink, file.path)
+			continue
 		}
 
-		toUnlink = toUnlink[:len(toUnlink)-int(rl.rotationCount)]
+		if rl.rotationCount > 0 && uint(i) >= rl.rotationCount {
+			toUnlink = append(toUnlink, file.path)
+		}
 	}
 
-	if len(toUnlink) <= 0 {
-		return nil
+	for _, path := range toUnlink {
+		os.Remove(path)
 	}
 
-	guard.Enable()
-	go func() {
-		// unlink files on a separate goroutine
-		for _, path := range toUnlink {
-			os.Remove(path)
-		}
-	}()
-
 	return nil
 }
 
@@ -0,0 +1,89 @@
+package rotatelogs
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitFiles(t *testing.T, pattern string, fn func(files []string) bool) []string {
+	deadline := time.Now().Add(time.Second)
+	for {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fn(files) {
+			return files
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("files = %v", files)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRotationSize(t *testing.T) {
+	dir := t.TempDir()
+
+	rl, err := New(filepath.Join(dir, "app_%Y%m%d.log"),
+		WithRotationSize(10),
+		WithRotationCount(2),
+		WithCompress(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err = rl.Write([]byte("0123456789abcdef\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current := rl.CurrentFileName()
+
+	// the current file and 2 compressed rotated files are kept
+	waitFiles(t, filepath.Join(dir, "*"), func(files []string) bool {
+		var compressed int
+		for _, file := range files {
+			if strings.HasSuffix(file, compressSuffix) {
+				compressed++
+			} else if file != current {
+				return false
+			}
+		}
+		return len(files) == 3 && compressed == 2
+	})
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+
+	rl, err := New(filepath.Join(dir, "app_%Y%m%d.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+
+	if _, err = rl.Write([]byte("before\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	before := rl.CurrentFileName()
+	if err = rl.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if after := rl.CurrentFileName(); after == before || after != before+".1" {
+		t.Fatalf("before = %s, after = %s", before, after)
+	}
+
+	waitFiles(t, filepath.Join(dir, "*"), func(files []string) bool {
+		return len(files) == 2
+	})
+}
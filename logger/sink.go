@@ -0,0 +1,133 @@
+package cherryLogger
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	"github.com/cherry-game/cherry/logger/rotatelogs"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkFile   = "file"
+)
+
+type (
+	// SinkConfig the output of the logger with its own minimum level.
+	// eg. "sinks": [{"type": "stdout", "level": "debug"}, {"type": "file", "level": "info", "file_path_format": "logs/info_%Y%m%d.log"}]
+	SinkConfig struct {
+		Type       string              `json:"type"`        // sink type, see RegisterSink
+		Level      string              `json:"level"`       // minimum level of the sink, empty is the level of the logger
+		Async      bool                `json:"async"`       // write by a goroutine and drop the records if the buffer is full, default is true
+		BufferSize int                 `json:"buffer_size"` // buffer size of the async sink
+		Config     cfacade.ProfileJSON `json:"-"`           // the sink config, it's passed to the SinkFunc
+	}
+
+	// SinkFunc create the writer of the sink, config is the config of the logger
+	SinkFunc func(config *Config, sinkConfig cfacade.ProfileJSON) (io.Writer, error)
+)
+
+var (
+	sinkLock sync.RWMutex
+	sinkMap  = map[string]SinkFunc{}
+)
+
+func init() {
+	RegisterSink(SinkStdout, func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return os.Stdout, nil
+	})
+
+	RegisterSink(SinkStderr, func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return os.Stderr, nil
+	})
+
+	RegisterSink(SinkFile, newFileSink)
+}
+
+// RegisterSink register the sink by type, the sink with the same type is replaced. eg. network sink
+func RegisterSink(typ string, fn SinkFunc) {
+	if typ == "" || fn == nil {
+		return
+	}
+
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+
+	sinkMap[typ] = fn
+}
+
+func getSink(typ string) (SinkFunc, bool) {
+	sinkLock.RLock()
+	defer sinkLock.RUnlock()
+
+	fn, found := sinkMap[typ]
+	return fn, found
+}
+
+func newSinkConfigs(jsonConfig cfacade.ProfileJSON) []*SinkConfig {
+	var sinks []*SinkConfig
+
+	for i := 0; i < jsonConfig.Get("sinks").Size(); i++ {
+		sinkConfig := jsonConfig.GetConfig("sinks", i)
+		sinks = append(sinks, &SinkConfig{
+			Type:       sinkConfig.GetString("type"),
+			Level:      sinkConfig.GetString("level", ""),
+			Async:      sinkConfig.GetBool("async", true),
+			BufferSize: sinkConfig.GetInt("buffer_size", 1024),
+			Config:     sinkConfig,
+		})
+	}
+
+	return sinks
+}
+
+// newFileSink the file sink uses the rotation settings of the logger,
+// file_path_format and file_link_path of the sink config replace the settings of the logger
+func newFileSink(config *Config, sinkConfig cfacade.ProfileJSON) (io.Writer, error) {
+	filePathFormat := config.FilePathFormat
+	fileLinkPath := config.FileLinkPath
+
+	if sinkConfig != nil {
+		filePathFormat = sinkConfig.GetString("file_path_format", filePathFormat)
+		fileLinkPath = sinkConfig.GetString("file_link_path", fileLinkPath)
+	}
+
+	if filePathFormat == "" {
+		return nil, cerr.Error("file_path_format of the file sink is empty")
+	}
+
+	for key, value := range fileNameVarMap {
+		filePathFormat = strings.ReplaceAll(filePathFormat, "%"+key, value)
+		fileLinkPath = strings.ReplaceAll(fileLinkPath, "%"+key, value)
+	}
+
+	return rotatelogs.New(
+		filePathFormat, //filename+"_%Y%m%d%H%M.log",
+		rotatelogs.WithLinkName(fileLinkPath),
+		rotatelogs.WithMaxAge(time.Hour*24*time.Duration(config.MaxAge)),
+		rotatelogs.WithRotationTime(time.Second*time.Duration(config.RotationTime)),
+		rotatelogs.WithRotationSize(int64(config.MaxSize)*1024*1024),
+		rotatelogs.WithRotationCount(uint(config.MaxBackups)),
+		rotatelogs.WithCompress(config.Compress),
+	)
+}
+
+// sinkLevel the records are written if both the logger level and the sink level are enabled
+func sinkLevel(level zap.AtomicLevel, sink string) zapcore.LevelEnabler {
+	if sink == "" {
+		return level
+	}
+
+	minLevel := GetLevel(sink)
+	return zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		return l >= minLevel && level.Enabled(l)
+	})
+}
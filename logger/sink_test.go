@@ -0,0 +1,183 @@
+package cherryLogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+type bufferWriter struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *bufferWriter) String() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.buf.String()
+}
+
+func TestSinkLevel(t *testing.T) {
+	debugOut, warnOut := &bufferWriter{}, &bufferWriter{}
+	RegisterSink("test_debug", func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return debugOut, nil
+	})
+	RegisterSink("test_warn", func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return warnOut, nil
+	})
+
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+	config.Sinks = []*SinkConfig{
+		{Type: "test_debug"},
+		{Type: "test_warn", Level: "warn"},
+	}
+
+	log1 := NewConfigLogger(config)
+	log1.Info("info message")
+	log1.Warn("warn message")
+
+	if s := debugOut.String(); !strings.Contains(s, "info message") || !strings.Contains(s, "warn message") {
+		t.Fatalf("debug sink = %s", s)
+	}
+
+	if s := warnOut.String(); strings.Contains(s, "info message") || !strings.Contains(s, "warn message") {
+		t.Fatalf("warn sink = %s", s)
+	}
+}
+
+func TestSinkSlow(t *testing.T) {
+	slowOut, fastOut := &slowWriter{release: make(chan struct{})}, &bufferWriter{}
+	RegisterSink("test_slow", func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return slowOut, nil
+	})
+	RegisterSink("test_fast", func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return fastOut, nil
+	})
+
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+	config.Sinks = []*SinkConfig{
+		{Type: "test_slow", Async: true, BufferSize: 1},
+		{Type: "test_fast"},
+	}
+
+	log1 := NewConfigLogger(config)
+	for i := 0; i < 10; i++ {
+		log1.Info("message")
+	}
+
+	// the slow sink drops the records, the fast sink gets all of them
+	if n := strings.Count(fastOut.String(), "message"); n != 10 {
+		t.Fatalf("fast sink = %d", n)
+	}
+
+	close(slowOut.release)
+	_ = log1.Flush()
+}
+
+func TestSinkJSON(t *testing.T) {
+	out := &bufferWriter{}
+	RegisterSink("test_json", func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return out, nil
+	})
+
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+	config.Encoder = EncoderJSON
+	config.FieldNames.Message = "message"
+	config.Sinks = []*SinkConfig{{Type: "test_json"}}
+
+	log1 := NewConfigLogger(config)
+	log1.With("sid", "s1", "uid", 1001).Info("json message")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record["message"] != "json message" || record["level"] != "info" || record["sid"] != "s1" || record["uid"] != float64(1001) {
+		t.Fatalf("record = %s", out.String())
+	}
+
+	if _, found := record["caller"]; !found {
+		t.Fatalf("record = %s", out.String())
+	}
+}
+
+func TestSinkFieldNames(t *testing.T) {
+	out := &bufferWriter{}
+	RegisterSink("test_field_names", func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return out, nil
+	})
+
+	// the config built without FieldNames
+	config := &Config{
+		LogLevel:    "debug",
+		StackLevel:  StackLevelOff,
+		PrintCaller: true,
+		Encoder:     EncoderJSON,
+		Sinks:       []*SinkConfig{{Type: "test_field_names"}},
+	}
+
+	log1 := NewConfigLogger(config)
+	log1.Info("default names")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"ts", "level", "msg", "caller"} {
+		if _, found := record[key]; !found {
+			t.Fatalf("record = %s", out.String())
+		}
+	}
+}
+
+func logWrapper(logger *CherryLogger, msg string) {
+	logger.Error(msg)
+}
+
+func TestSinkCaller(t *testing.T) {
+	out := &bufferWriter{}
+	RegisterSink("test_caller", func(_ *Config, _ cfacade.ProfileJSON) (io.Writer, error) {
+		return out, nil
+	})
+
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+	config.Encoder = EncoderJSON
+	config.PrintFunction = true
+	config.CallerSkip = 1
+	config.StackLevel = StackLevelOff
+	config.Sinks = []*SinkConfig{{Type: "test_caller"}}
+
+	log1 := NewConfigLogger(config)
+	logWrapper(log1, "caller message")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	// the wrapper is skipped
+	if fn, _ := record["func"].(string); !strings.HasSuffix(fn, "TestSinkCaller") {
+		t.Fatalf("record = %s", out.String())
+	}
+
+	if _, found := record["stack"]; found {
+		t.Fatalf("record = %s", out.String())
+	}
+}
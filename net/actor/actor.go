@@ -1,9 +1,13 @@
 package cherryActor
 
 import (
+	"context"
+	"runtime/debug"
 	"strings"
 	"time"
 
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
 	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
@@ -196,6 +200,12 @@ func (p *Actor) invokeFunc(mb *mailbox, app cfacade.IApplication, fn cfacade.Inv
 
 	now := time.Now().UnixMilli()
 
+	// 跨节点消息继续发送方的span
+	end := noopEnd
+	if m.IsCluster {
+		m.Context, end = p.system.tracer.Extract(context.Background(), rpcRoute(m.TargetPath(), m.FuncName), m.Session, m.Header)
+	}
+
 	defer func() {
 		p.executionElapsed = time.Now().UnixMilli() - now
 		if p.executionElapsed > p.system.executionTimeout {
@@ -209,14 +219,26 @@ func (p *Actor) invokeFunc(mb *mailbox, app cfacade.IApplication, fn cfacade.Inv
 		}
 
 		if rev := recover(); rev != nil {
-			clog.Errorf("[%s] Invoke error. [source = %s, target = %s->%s, type = %v]",
+			clog.Errorf("[%s] Invoke error. [source = %s, target = %s->%s, type = %v, err = %v]\n%s",
 				mb.name,
 				m.Source,
 				m.Target,
 				m.FuncName,
 				funcInfo.InArgs,
+				rev,
+				debug.Stack(),
 			)
+
+			// 本地消息(客户端请求)由handlerErrorFunc回复错误码
+			if mb == p.localMail {
+				handlerErrorFunc(app, m, cerr.WithCode(cerr.Errorf("handler panic: %v", rev), ccode.HandlerPanic))
+			}
+
+			end(ccode.ActorCallFail)
+			return
 		}
+
+		end(ccode.OK)
 	}()
 
 	fn(app, funcInfo, m)
@@ -309,6 +331,22 @@ func (p *Actor) CallWait(targetPath, funcName string, arg interface{}, reply int
 	return p.system.CallWait(p.path.String(), targetPath, funcName, arg, reply)
 }
 
+func (p *Actor) CallWaitContext(ctx context.Context, targetPath, funcName string, arg interface{}, reply interface{}) int32 {
+	return p.system.CallWaitContext(ctx, p.path.String(), targetPath, funcName, arg, reply)
+}
+
+func (p *Actor) CallWaitWith(targetPath, funcName string, arg interface{}, reply interface{}, opts cfacade.CallOpts) (int32, int) {
+	return p.system.CallWaitWith(p.path.String(), targetPath, funcName, arg, reply, opts)
+}
+
+func (p *Actor) CallType(nodeType, actorID, funcName string, arg interface{}) map[string]error {
+	return p.system.CallType(p.path.String(), nodeType, actorID, funcName, arg)
+}
+
+func (p *Actor) CallWaitType(nodeType, actorID, funcName string, arg interface{}, newReply func() interface{}) map[string]CallReply {
+	return p.system.CallWaitType(p.path.String(), nodeType, actorID, funcName, arg, newReply)
+}
+
 // LastAt second
 func (p *Actor) LastAt() int64 {
 	return p.lastAt
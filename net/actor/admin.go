@@ -0,0 +1,53 @@
+package cherryActor
+
+import (
+	ccode "github.com/cherry-game/cherry/code"
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	AdminActorID        = "__admin"     // 内置的运维actor, 由actor组件在每个节点创建
+	SetLogLevelFuncName = "setLogLevel" // 修改日志等级, 参数为*cproto.LogLevel
+)
+
+type (
+	// adminActor 运维操作的远程函数, 如临时将某个节点的日志等级调整为debug
+	adminActor struct {
+		Base
+	}
+)
+
+func (*adminActor) AliasID() string {
+	return AdminActorID
+}
+
+func (p *adminActor) OnInit() {
+	p.Remote().Register(SetLogLevelFuncName, p.setLogLevel)
+}
+
+func (p *adminActor) setLogLevel(req *cproto.LogLevel) int32 {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		clog.Warnf("[admin] Log level error. [name = %s, level = %s]", req.Name, req.Level)
+		return ccode.AdminArgError
+	}
+
+	if err := clog.SetLevel(req.Name, level); err != nil {
+		clog.Warn(err)
+		return ccode.AdminArgError
+	}
+
+	clog.Infof("[admin] Log level changed. [name = %s, level = %s]", req.Name, level)
+	return ccode.OK
+}
+
+// SetLogLevel 修改nodeID节点的日志等级, name为空时修改默认日志. level为debug,info,warn,error等
+func (p *System) SetLogLevel(source, nodeID, name, level string) int32 {
+	return p.CallWait(source, cfacade.NewPath(nodeID, AdminActorID), SetLogLevelFuncName, &cproto.LogLevel{
+		Name:  name,
+		Level: level,
+	}, nil)
+}
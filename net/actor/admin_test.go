@@ -0,0 +1,48 @@
+package cherryActor
+
+import (
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	clog "github.com/cherry-game/cherry/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+type readyAdminActor struct {
+	adminActor
+	ready chan struct{}
+}
+
+func (p *readyAdminActor) OnInit() {
+	p.adminActor.OnInit()
+	close(p.ready)
+}
+
+func TestSetLogLevel(t *testing.T) {
+	system := newCallSystem(t, time.Second)
+
+	admin := &readyAdminActor{ready: make(chan struct{})}
+	if _, err := system.CreateActor(AdminActorID, admin); err != nil {
+		t.Fatal(err)
+	}
+	<-admin.ready
+
+	defer clog.DefaultLogger.SetLevel(clog.DefaultLogger.Level())
+
+	if code := system.SetLogLevel(".caller", "", "", "warn"); code != ccode.OK {
+		t.Fatalf("code = %d", code)
+	}
+
+	if clog.Enable(zapcore.InfoLevel) || !clog.Enable(zapcore.WarnLevel) {
+		t.Fatalf("level = %s", clog.DefaultLogger.Level())
+	}
+
+	if code := system.SetLogLevel(".caller", "", "", "verbose"); code != ccode.AdminArgError {
+		t.Fatalf("code = %d", code)
+	}
+
+	if code := system.SetLogLevel(".caller", "", "not_found", "debug"); code != ccode.AdminArgError {
+		t.Fatalf("code = %d", code)
+	}
+}
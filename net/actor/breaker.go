@@ -0,0 +1,189 @@
+package cherryActor
+
+import (
+	"sync"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	clog "github.com/cherry-game/cherry/logger"
+)
+
+const (
+	CircuitClosed   CircuitState = 0 // 正常调用
+	CircuitOpen     CircuitState = 1 // 熔断中,调用直接失败
+	CircuitHalfOpen CircuitState = 2 // 冷却结束,允许一次探测调用
+)
+
+type (
+	// CircuitState 目标节点的熔断状态
+	CircuitState int32
+
+	// breakers 按目标节点熔断, threshold为0时不熔断
+	breakers struct {
+		sync.Mutex
+		threshold int           // window内连续失败次数达到threshold时熔断
+		window    time.Duration // 连续失败的统计窗口
+		cooldown  time.Duration // 熔断持续时间,结束后进入半开状态
+		list      map[string]*breaker
+	}
+
+	breaker struct {
+		state       CircuitState
+		failures    int       // 连续失败次数
+		firstFailAt time.Time // 本轮连续失败的首次时间
+		openedAt    time.Time // 熔断时间
+		probing     bool      // 半开状态下是否有探测调用
+		probeAt     time.Time // 探测调用的时间,探测未上报结果时cooldown后允许再次探测
+	}
+)
+
+func newBreakers() *breakers {
+	return &breakers{
+		list: make(map[string]*breaker),
+	}
+}
+
+func (p *breakers) set(threshold int, window, cooldown time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.threshold = threshold
+	p.window = window
+	p.cooldown = cooldown
+	p.list = make(map[string]*breaker)
+}
+
+func (p *breakers) get(nodeID string) *breaker {
+	b, found := p.list[nodeID]
+	if !found {
+		b = &breaker{}
+		p.list[nodeID] = b
+	}
+	return b
+}
+
+// allow 返回cerr.CircuitOpen表示目标节点熔断中
+func (p *breakers) allow(nodeID string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.threshold <= 0 {
+		return nil
+	}
+
+	b := p.get(nodeID)
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < p.cooldown {
+			return cerr.CircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probe()
+		return nil
+	case CircuitHalfOpen:
+		if b.probing && time.Since(b.probeAt) < p.cooldown {
+			return cerr.CircuitOpen
+		}
+		b.probe()
+		return nil
+	default:
+		return nil
+	}
+}
+
+// report 上报调用结果, 仅网络错误与超时计为失败
+func (p *breakers) report(nodeID string, failed bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.threshold <= 0 {
+		return
+	}
+
+	b := p.get(nodeID)
+	now := time.Now()
+
+	if !failed {
+		if b.state != CircuitClosed {
+			clog.Infof("[breaker] Circuit closed. [nodeID = %s]", nodeID)
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	switch b.state {
+	case CircuitHalfOpen:
+		b.open(now)
+		clog.Warnf("[breaker] Probe fail, circuit open again. [nodeID = %s]", nodeID)
+	case CircuitClosed:
+		if b.failures == 0 || now.Sub(b.firstFailAt) > p.window {
+			b.failures = 0
+			b.firstFailAt = now
+		}
+
+		b.failures++
+		if b.failures >= p.threshold {
+			b.open(now)
+			clog.Warnf("[breaker] Circuit open. [nodeID = %s, failures = %d]", nodeID, p.threshold)
+		}
+	}
+}
+
+func (p *breakers) state(nodeID string) CircuitState {
+	p.Lock()
+	defer p.Unlock()
+
+	if b, found := p.list[nodeID]; found {
+		return b.state
+	}
+	return CircuitClosed
+}
+
+func (p *breakers) states() map[string]CircuitState {
+	p.Lock()
+	defer p.Unlock()
+
+	states := make(map[string]CircuitState)
+	for nodeID, b := range p.list {
+		if b.state != CircuitClosed {
+			states[nodeID] = b.state
+		}
+	}
+	return states
+}
+
+func (b *breaker) probe() {
+	b.probing = true
+	b.probeAt = time.Now()
+}
+
+func (b *breaker) open(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.failures = 0
+	b.probing = false
+}
+
+// isNodeFailure 目标节点不可达, 远程函数返回的错误码不计入
+func isNodeFailure(code int32) bool {
+	return code == ccode.RPCNetError || code == ccode.RPCTimeoutError
+}
+
+// SetCircuitBreaker 开启按目标节点的熔断, window内连续失败threshold次后熔断cooldown,
+// 熔断期间调用直接返回ccode.ActorCircuitOpen, 冷却后允许一次探测调用. threshold为0时关闭(默认)
+func (p *System) SetCircuitBreaker(threshold int, window, cooldown time.Duration) {
+	p.breakers.set(threshold, window, cooldown)
+}
+
+// CircuitState 目标节点的熔断状态
+func (p *System) CircuitState(nodeID string) CircuitState {
+	return p.breakers.state(nodeID)
+}
+
+// CircuitStates 未关闭的熔断状态, key:nodeID
+func (p *System) CircuitStates() map[string]CircuitState {
+	return p.breakers.states()
+}
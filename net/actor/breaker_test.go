@@ -0,0 +1,69 @@
+package cherryActor
+
+import (
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+func TestBreakers(t *testing.T) {
+	b := newBreakers()
+
+	// closed-always by default
+	for i := 0; i < 10; i++ {
+		b.report("game-1", true)
+	}
+	if err := b.allow("game-1"); err != nil {
+		t.Fatalf("default allow err = %v", err)
+	}
+
+	b.set(3, time.Second, 20*time.Millisecond)
+
+	b.report("game-1", true)
+	b.report("game-1", true)
+	b.report("game-1", false) // the success resets the failures
+	b.report("game-1", true)
+	b.report("game-1", true)
+	if state := b.state("game-1"); state != CircuitClosed {
+		t.Fatalf("state = %d", state)
+	}
+
+	b.report("game-1", true)
+	if err := b.allow("game-1"); err != cerr.CircuitOpen {
+		t.Fatalf("open allow err = %v", err)
+	}
+
+	if states := b.states(); len(states) != 1 || states["game-1"] != CircuitOpen {
+		t.Fatalf("states = %v", states)
+	}
+
+	// half open after cooldown, only one probe is allowed
+	time.Sleep(30 * time.Millisecond)
+	if err := b.allow("game-1"); err != nil {
+		t.Fatalf("probe allow err = %v", err)
+	}
+	if err := b.allow("game-1"); err != cerr.CircuitOpen {
+		t.Fatalf("half open allow err = %v", err)
+	}
+
+	// the probe fails, open again
+	b.report("game-1", true)
+	if state := b.state("game-1"); state != CircuitOpen {
+		t.Fatalf("state after probe fail = %d", state)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := b.allow("game-1"); err != nil {
+		t.Fatalf("probe allow err = %v", err)
+	}
+
+	b.report("game-1", false)
+	if state := b.state("game-1"); state != CircuitClosed {
+		t.Fatalf("state after probe ok = %d", state)
+	}
+
+	if err := b.allow("game-2"); err != nil {
+		t.Fatalf("other node allow err = %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package cherryActor
+
+import (
+	"sync"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+type (
+	// CallReply CallWaitType的单个节点回复
+	CallReply struct {
+		Code  int32       // 调用结果
+		Reply interface{} // newReply创建的回复,调用失败时为nil
+	}
+)
+
+// CodeError 调用失败的code转为error, 可通过cerr.Code获取code. code为OK时返回nil
+func CodeError(code int32) error {
+	if ccode.IsOK(code) {
+		return nil
+	}
+
+	return cerr.WithCode(cerr.Errorf("actor call fail. [code = %d]", code), code)
+}
+
+// CallType 向nodeType类型的所有节点的actorID发送消息(不等待回复),返回每个节点的结果, key:nodeID
+// 节点列表为调用时discovery中的成员,广播期间加入的节点不会收到,离开的节点返回失败
+func (p *System) CallType(source, nodeType, actorID, funcName string, arg interface{}) map[string]error {
+	members := p.app.Discovery().ListByType(nodeType)
+	result := make(map[string]error, len(members))
+
+	for _, member := range members {
+		target := cfacade.NewPath(member.GetNodeId(), actorID)
+		result[member.GetNodeId()] = CodeError(p.Call(source, target, funcName, arg))
+	}
+
+	return result
+}
+
+// CallWaitType 并发调用nodeType类型的所有节点的actorID并收集回复, key:nodeID
+// newReply为每个节点创建回复对象,为nil时不解析回复
+func (p *System) CallWaitType(source, nodeType, actorID, funcName string, arg interface{}, newReply func() interface{}) map[string]CallReply {
+	members := p.app.Discovery().ListByType(nodeType)
+	result := make(map[string]CallReply, len(members))
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+
+	for _, member := range members {
+		wg.Add(1)
+
+		go func(nodeID string) {
+			defer wg.Done()
+
+			var reply interface{}
+			if newReply != nil {
+				reply = newReply()
+			}
+
+			target := cfacade.NewPath(nodeID, actorID)
+			code := p.CallWait(source, target, funcName, arg, reply)
+			if ccode.IsFail(code) {
+				reply = nil
+			}
+
+			lock.Lock()
+			result[nodeID] = CallReply{Code: code, Reply: reply}
+			lock.Unlock()
+		}(member.GetNodeId())
+	}
+
+	wg.Wait()
+	return result
+}
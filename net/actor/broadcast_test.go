@@ -0,0 +1,115 @@
+package cherryActor
+
+import (
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cserializer "github.com/cherry-game/cherry/net/serializer"
+)
+
+type (
+	broadcastApp struct {
+		cfacade.IApplication
+		discovery cfacade.IDiscovery
+		cluster   cfacade.ICluster
+	}
+
+	broadcastDiscovery struct {
+		cfacade.IDiscovery
+	}
+
+	broadcastCluster struct {
+		cfacade.ICluster
+	}
+
+	echoActor struct {
+		Base
+		ready chan struct{}
+	}
+)
+
+func (broadcastApp) Serializer() cfacade.ISerializer {
+	return cserializer.NewJSON()
+}
+
+func (broadcastApp) NodeId() string {
+	return "game-1"
+}
+
+func (p broadcastApp) Discovery() cfacade.IDiscovery {
+	return p.discovery
+}
+
+func (p broadcastApp) Cluster() cfacade.ICluster {
+	return p.cluster
+}
+
+func (broadcastDiscovery) ListByType(nodeType string, _ ...string) []cfacade.IMember {
+	return []cfacade.IMember{
+		&cproto.Member{NodeId: "game-1", NodeType: nodeType},
+		&cproto.Member{NodeId: "game-2", NodeType: nodeType},
+	}
+}
+
+func (broadcastDiscovery) GetMember(nodeId string) (cfacade.IMember, bool) {
+	return &cproto.Member{NodeId: nodeId, NodeType: "game"}, true
+}
+
+// the remote node game-2 has left
+func (broadcastCluster) PublishRemote(_ string, packet *cproto.ClusterPacket) error {
+	packet.Recycle()
+	return cerr.ClusterRPCClientIsStop
+}
+
+func (broadcastCluster) RequestRemote(_ string, packet *cproto.ClusterPacket, _ ...time.Duration) cproto.Response {
+	packet.Recycle()
+	return cproto.Response{Code: ccode.RPCNetError}
+}
+
+func (p *echoActor) OnInit() {
+	p.Remote().Register("echo", func(v *string) (*string, int32) {
+		return v, ccode.OK
+	})
+	close(p.ready)
+}
+
+func TestCallType(t *testing.T) {
+	system := NewSystem()
+	system.SetApp(broadcastApp{
+		discovery: broadcastDiscovery{},
+		cluster:   broadcastCluster{},
+	})
+
+	echo := &echoActor{ready: make(chan struct{})}
+	if _, err := system.CreateActor("echo", echo); err != nil {
+		t.Fatal(err)
+	}
+	<-echo.ready
+
+	arg := "hello"
+
+	errs := system.CallType("game-1.caller", "game", "echo", "echo", &arg)
+	if len(errs) != 2 || errs["game-1"] != nil {
+		t.Fatalf("call type errs = %v", errs)
+	}
+
+	if code, _ := cerr.Code(errs["game-2"]); code != ccode.ActorPublishRemoteError {
+		t.Fatalf("left node err = %v", errs["game-2"])
+	}
+
+	replies := system.CallWaitType("game-1.caller", "game", "echo", "echo", &arg, func() interface{} {
+		return new(string)
+	})
+
+	if rsp := replies["game-1"]; rsp.Code != ccode.OK || *rsp.Reply.(*string) != "hello" {
+		t.Fatalf("local reply = %+v", rsp)
+	}
+
+	if rsp := replies["game-2"]; rsp.Code != ccode.RPCNetError || rsp.Reply != nil {
+		t.Fatalf("left node reply = %+v", rsp)
+	}
+}
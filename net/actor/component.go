@@ -27,6 +27,9 @@ func (c *Component) Init() {
 }
 
 func (c *Component) OnAfterInit() {
+	// Register the built-in admin actor
+	c.CreateActor(AdminActorID, &adminActor{})
+
 	// Register actor
 	for _, actor := range c.actorHandlers {
 		c.CreateActor(actor.AliasID(), actor)
@@ -1,6 +1,8 @@
 package cherryActor
 
 import (
+	"time"
+
 	cerror "github.com/cherry-game/cherry/error"
 )
 
@@ -14,3 +16,7 @@ const (
 	LocalName  = "local"
 	RemoteName = "remote"
 )
+
+const (
+	maxBackoff = 30 * time.Second // CallWaitWith的最大重试等待时间
+)
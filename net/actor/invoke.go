@@ -2,6 +2,7 @@ package cherryActor
 
 import (
 	"reflect"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
@@ -14,6 +15,29 @@ import (
 	cproto "github.com/cherry-game/cherry/net/proto"
 )
 
+type (
+	// HandlerErrorFunc handle the error returned by the local handler
+	HandlerErrorFunc func(app cfacade.IApplication, m *cfacade.Message, err error)
+)
+
+var (
+	handlerErrorFunc HandlerErrorFunc = logHandlerError
+	handlerObserver  cfacade.HandlerObserver
+)
+
+// SetHandlerErrorFunc set the func to handle the error returned by the local handler. eg. response the error to the client
+func SetHandlerErrorFunc(fn HandlerErrorFunc) {
+	if fn != nil {
+		handlerErrorFunc = fn
+	}
+}
+
+// SetHandlerObserver observe the route, latency and result of each local handler. it must be set before the start,
+// nil is disabled(default) and the handlers are not timed.
+func SetHandlerObserver(observer cfacade.HandlerObserver) {
+	handlerObserver = observer
+}
+
 func InvokeLocalFunc(app cfacade.IApplication, fi *creflect.FuncInfo, m *cfacade.Message) {
 	if app == nil {
 		clog.Errorf("[InvokeLocalFunc] app is nil. [message = %+v]", m)
@@ -22,10 +46,70 @@ func InvokeLocalFunc(app cfacade.IApplication, fi *creflect.FuncInfo, m *cfacade
 
 	EncodeLocalArgs(app, fi, m)
 
-	values := make([]reflect.Value, 2)
-	values[0] = reflect.ValueOf(m.Session) // session
-	values[1] = reflect.ValueOf(m.Args)    // args
-	fi.Value.Call(values)
+	// 中间件按注册顺序执行, see Use
+	chain, ctx := handlerChain(fi), newHandlerContext(app, m)
+
+	var err error
+	if observer := handlerObserver; observer != nil {
+		err = observeHandler(observer, chain, ctx)
+	} else {
+		err = chain(ctx)
+	}
+
+	if err != nil {
+		handlerErrorFunc(app, m, err)
+	}
+}
+
+// observeHandler the unrecovered panic is observed and re-panicked
+func observeHandler(observer cfacade.HandlerObserver, chain HandlerFunc, ctx *HandlerContext) (err error) {
+	begin := time.Now()
+
+	defer func() {
+		stat := cfacade.HandlerStat{
+			Route:    ctx.Route(),
+			Err:      err,
+			Duration: time.Since(begin),
+		}
+
+		rev := recover()
+		if rev != nil {
+			stat.Panic = true
+			stat.Err = cerror.WithCode(cerror.Errorf("handler panic: %v", rev), ccode.HandlerPanic)
+		} else if code, _ := cerror.Code(err); code == ccode.HandlerPanic {
+			stat.Panic = true
+		}
+
+		observer.ObserveHandler(stat)
+
+		if rev != nil {
+			panic(rev)
+		}
+	}()
+
+	return chain(ctx)
+}
+
+// retError returns the error if the last return value of the handler is a non-nil error
+func retError(rets []reflect.Value) error {
+	if len(rets) < 1 {
+		return nil
+	}
+
+	if err, ok := rets[len(rets)-1].Interface().(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+func logHandlerError(_ cfacade.IApplication, m *cfacade.Message, err error) {
+	clog.Warnf("[InvokeLocalFunc] handler error. [source = %s, target = %s -> %s, err = %v]",
+		m.Source,
+		m.Target,
+		m.FuncName,
+		err,
+	)
 }
 
 func InvokeRemoteFunc(app cfacade.IApplication, fi *creflect.FuncInfo, m *cfacade.Message) {
@@ -0,0 +1,99 @@
+package cherryActor
+
+import (
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerror "github.com/cherry-game/cherry/error"
+	creflect "github.com/cherry-game/cherry/extend/reflect"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type (
+	testApp struct {
+		cfacade.IApplication
+	}
+
+	testArg struct {
+		Gold int
+	}
+)
+
+func TestInvokeLocalFuncError(t *testing.T) {
+	defer SetHandlerErrorFunc(logHandlerError)
+
+	var handled error
+	SetHandlerErrorFunc(func(_ cfacade.IApplication, _ *cfacade.Message, err error) {
+		handled = err
+	})
+
+	errNoGold := cerror.Error("no gold")
+	fi, err := creflect.GetFuncInfo(func(_ *cproto.Session, arg *testArg) error {
+		if arg.Gold < 1 {
+			return errNoGold
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	InvokeLocalFunc(testApp{}, &fi, &cfacade.Message{Session: &cproto.Session{}, Args: &testArg{Gold: 1}})
+	if handled != nil {
+		t.Fatalf("handled error = %v", handled)
+	}
+
+	InvokeLocalFunc(testApp{}, &fi, &cfacade.Message{Session: &cproto.Session{}, Args: &testArg{}})
+	if handled != errNoGold {
+		t.Fatalf("handled error = %v", handled)
+	}
+}
+
+type panicActor struct {
+	Base
+	ready chan struct{}
+}
+
+func (p *panicActor) OnInit() {
+	p.Local().Register("boom", func(_ *cproto.Session, _ *testArg) {
+		panic("boom")
+	})
+	close(p.ready)
+}
+
+func TestInvokeLocalFuncPanic(t *testing.T) {
+	defer SetHandlerErrorFunc(logHandlerError)
+
+	handled := make(chan error, 1)
+	SetHandlerErrorFunc(func(_ cfacade.IApplication, _ *cfacade.Message, err error) {
+		handled <- err
+	})
+
+	system := NewSystem()
+	system.SetApp(callApp{})
+
+	actor := &panicActor{ready: make(chan struct{})}
+	if _, err := system.CreateActor("panic", actor); err != nil {
+		t.Fatal(err)
+	}
+	<-actor.ready
+
+	m := cfacade.GetMessage()
+	m.Source = ".caller"
+	m.Target = ".panic"
+	m.FuncName = "boom"
+	m.Session = &cproto.Session{Mid: 1}
+	m.Args = &testArg{}
+	system.PostLocal(&m)
+
+	select {
+	case err := <-handled:
+		if code, _ := cerror.Code(err); code != ccode.HandlerPanic {
+			t.Fatalf("err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the panic is not handled")
+	}
+}
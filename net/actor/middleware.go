@@ -0,0 +1,136 @@
+package cherryActor
+
+import (
+	"context"
+	"reflect"
+	"runtime/debug"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	creflect "github.com/cherry-game/cherry/extend/reflect"
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type (
+	// HandlerContext 本地handler(客户端请求)的调用上下文, 中间件可修改Args
+	HandlerContext struct {
+		context.Context // 请求的ctx, 跨节点消息为链路追踪的ctx
+		App             cfacade.IApplication
+		Message         *cfacade.Message
+		Session         *cproto.Session // session of gateway
+		Args            interface{}     // 反序列化后的参数
+	}
+
+	// HandlerFunc 调用handler, 返回handler的error
+	HandlerFunc func(ctx *HandlerContext) error
+
+	// Middleware 包装handler的调用, 不调用next即可拦截请求. see Use
+	Middleware func(next HandlerFunc) HandlerFunc
+)
+
+var (
+	middlewares []Middleware
+)
+
+// Use 注册本地handler的中间件, 按注册顺序执行(先注册的在外层). 需在启动前注册
+func Use(mw ...Middleware) {
+	for _, m := range mw {
+		if m != nil {
+			middlewares = append(middlewares, m)
+		}
+	}
+}
+
+// Route handler的route, eg. "player.login"
+func (p *HandlerContext) Route() string {
+	if targetPath := p.Message.TargetPath(); targetPath != nil {
+		return rpcRoute(targetPath, p.Message.FuncName)
+	}
+	return p.Message.FuncName
+}
+
+func newHandlerContext(app cfacade.IApplication, m *cfacade.Message) *HandlerContext {
+	ctx := m.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &HandlerContext{
+		Context: ctx,
+		App:     app,
+		Message: m,
+		Session: m.Session,
+		Args:    m.Args,
+	}
+}
+
+// handlerChain 用中间件包装handler的调用, 没有中间件时直接调用
+func handlerChain(fi *creflect.FuncInfo) HandlerFunc {
+	next := func(ctx *HandlerContext) error {
+		values := make([]reflect.Value, 2)
+		values[0] = reflect.ValueOf(ctx.Session) // session
+		values[1] = reflect.ValueOf(ctx.Args)    // args
+		return retError(fi.Value.Call(values))
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+
+	return next
+}
+
+// RecoverMiddleware handler的panic转为ccode.HandlerPanic的error, 由handlerErrorFunc回复错误码
+func RecoverMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *HandlerContext) (err error) {
+			defer func() {
+				if rev := recover(); rev != nil {
+					clog.Errorf("[RecoverMiddleware] handler panic. [route = %s, err = %v]\n%s",
+						ctx.Route(),
+						rev,
+						debug.Stack(),
+					)
+					err = cerr.WithCode(cerr.Errorf("handler panic: %v", rev), ccode.HandlerPanic)
+				}
+			}()
+
+			return next(ctx)
+		}
+	}
+}
+
+// TimingMiddleware 记录handler的执行耗时, 超过slow时打印warn日志, slow为0时所有请求打印debug日志
+func TimingMiddleware(slow time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *HandlerContext) error {
+			begin := time.Now()
+			err := next(ctx)
+			elapsed := time.Since(begin)
+
+			if slow > 0 && elapsed >= slow {
+				clog.Warnf("[TimingMiddleware] slow handler. [route = %s, elapsed = %v, err = %v]", ctx.Route(), elapsed, err)
+			} else if slow <= 0 {
+				clog.Debugf("[TimingMiddleware] [route = %s, elapsed = %v, err = %v]", ctx.Route(), elapsed, err)
+			}
+
+			return err
+		}
+	}
+}
+
+// RequireBoundSession 拦截未绑定uid的session, 返回ccode.SessionUIDNotBind的error
+func RequireBoundSession() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *HandlerContext) error {
+			if ctx.Session == nil || !cfacade.IsValidUID(ctx.Session.Uid) {
+				return cerr.WithCode(cerr.Errorf("session uid not bind. [route = %s]", ctx.Route()), ccode.SessionUIDNotBind)
+			}
+
+			return next(ctx)
+		}
+	}
+}
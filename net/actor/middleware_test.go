@@ -0,0 +1,146 @@
+package cherryActor
+
+import (
+	"testing"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerror "github.com/cherry-game/cherry/error"
+	creflect "github.com/cherry-game/cherry/extend/reflect"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestMiddleware(t *testing.T) {
+	defer func() {
+		middlewares = nil
+		SetHandlerErrorFunc(logHandlerError)
+	}()
+
+	var handled error
+	SetHandlerErrorFunc(func(_ cfacade.IApplication, _ *cfacade.Message, err error) {
+		handled = err
+	})
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *HandlerContext) error {
+				order = append(order, name+":"+ctx.Route())
+				return next(ctx)
+			}
+		}
+	}
+
+	Use(RecoverMiddleware(), trace("first"), trace("second"), RequireBoundSession())
+
+	called := 0
+	fi, err := creflect.GetFuncInfo(func(_ *cproto.Session, arg *testArg) {
+		called++
+		if arg.Gold < 0 {
+			panic("negative gold")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newMessage := func(uid int64, gold int) *cfacade.Message {
+		return &cfacade.Message{
+			Target:   "game-1.player",
+			FuncName: "buy",
+			Session:  &cproto.Session{Uid: uid},
+			Args:     &testArg{Gold: gold},
+		}
+	}
+
+	InvokeLocalFunc(testApp{}, &fi, newMessage(1001, 1))
+	if called != 1 || handled != nil {
+		t.Fatalf("called = %d, handled = %v", called, handled)
+	}
+
+	if len(order) != 2 || order[0] != "first:player.buy" || order[1] != "second:player.buy" {
+		t.Fatalf("order = %v", order)
+	}
+
+	// short-circuit
+	InvokeLocalFunc(testApp{}, &fi, newMessage(0, 1))
+	if code, _ := cerror.Code(handled); called != 1 || code != ccode.SessionUIDNotBind {
+		t.Fatalf("called = %d, handled = %v", called, handled)
+	}
+
+	// recover
+	InvokeLocalFunc(testApp{}, &fi, newMessage(1001, -1))
+	if code, _ := cerror.Code(handled); called != 2 || code != ccode.HandlerPanic {
+		t.Fatalf("called = %d, handled = %v", called, handled)
+	}
+}
+
+type testHandlerObserver struct {
+	stats []cfacade.HandlerStat
+}
+
+func (p *testHandlerObserver) ObserveHandler(stat cfacade.HandlerStat) {
+	p.stats = append(p.stats, stat)
+}
+
+func TestHandlerObserver(t *testing.T) {
+	observer := &testHandlerObserver{}
+	defer func() {
+		middlewares = nil
+		SetHandlerObserver(nil)
+		SetHandlerErrorFunc(logHandlerError)
+	}()
+	SetHandlerObserver(observer)
+	SetHandlerErrorFunc(func(cfacade.IApplication, *cfacade.Message, error) {})
+
+	fi, err := creflect.GetFuncInfo(func(_ *cproto.Session, arg *testArg) error {
+		if arg.Gold < 0 {
+			panic("negative gold")
+		}
+		if arg.Gold == 0 {
+			return cerror.WithCode(cerror.Error("no gold"), ccode.SessionUIDNotBind)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newMessage := func(gold int) *cfacade.Message {
+		return &cfacade.Message{
+			Target:   "game-1.player",
+			FuncName: "buy",
+			Session:  &cproto.Session{Uid: 1001},
+			Args:     &testArg{Gold: gold},
+		}
+	}
+
+	InvokeLocalFunc(testApp{}, &fi, newMessage(1))
+	InvokeLocalFunc(testApp{}, &fi, newMessage(0))
+
+	// the unrecovered panic is observed and re-panicked
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic is recovered by the observer")
+			}
+		}()
+		InvokeLocalFunc(testApp{}, &fi, newMessage(-1))
+	}()
+
+	// the panic recovered by the middleware
+	Use(RecoverMiddleware())
+	InvokeLocalFunc(testApp{}, &fi, newMessage(-1))
+
+	if len(observer.stats) != 4 {
+		t.Fatalf("observed = %d", len(observer.stats))
+	}
+
+	for i, stat := range observer.stats {
+		code, _ := cerror.Code(stat.Err)
+		failed, panicked := i > 0, i > 1
+		if stat.Route != "player.buy" || (stat.Err != nil) != failed || stat.Panic != panicked || (panicked && code != ccode.HandlerPanic) {
+			t.Fatalf("stat[%d] = %+v", i, stat)
+		}
+	}
+}
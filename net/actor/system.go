@@ -1,6 +1,8 @@
 package cherryActor
 
 import (
+	"context"
+	"math/rand"
 	"strings"
 	"sync"
 	"time"
@@ -16,13 +18,16 @@ type (
 	// System Actor系统
 	System struct {
 		app              cfacade.IApplication
-		actorMap         *sync.Map          // key:actorID, value:*actor
-		localInvokeFunc  cfacade.InvokeFunc // default local func
-		remoteInvokeFunc cfacade.InvokeFunc // default remote func
-		wg               *sync.WaitGroup    // wait group
-		callTimeout      time.Duration      // call调用超时
-		arrivalTimeOut   int64              // message到达超时(毫秒)
-		executionTimeout int64              // 消息执行超时(毫秒)
+		actorMap         *sync.Map           // key:actorID, value:*actor
+		localInvokeFunc  cfacade.InvokeFunc  // default local func
+		remoteInvokeFunc cfacade.InvokeFunc  // default remote func
+		wg               *sync.WaitGroup     // wait group
+		callTimeout      time.Duration       // call调用超时
+		arrivalTimeOut   int64               // message到达超时(毫秒)
+		executionTimeout int64               // 消息执行超时(毫秒)
+		breakers         *breakers           // 按目标节点熔断
+		rpcObserver      cfacade.RPCObserver // 跨节点调用统计,为nil时不统计
+		tracer           cfacade.ITracer     // 跨节点调用的链路追踪
 	}
 )
 
@@ -35,6 +40,8 @@ func NewSystem() *System {
 		callTimeout:      3 * time.Second,
 		arrivalTimeOut:   100,
 		executionTimeout: 100,
+		breakers:         newBreakers(),
+		tracer:           noopTracer{},
 	}
 
 	return system
@@ -152,33 +159,19 @@ func (p *System) Call(source, target, funcName string, arg interface{}) int32 {
 	}
 
 	if targetPath.NodeID != "" && targetPath.NodeID != p.NodeId() {
-		clusterPacket := cproto.GetClusterPacket()
-		clusterPacket.SourcePath = source
-		clusterPacket.TargetPath = target
-		clusterPacket.FuncName = funcName
-
-		if arg != nil {
-			argsBytes, err := p.app.Serializer().Marshal(arg)
-			if err != nil {
-				clog.Warnf("[Call] Marshal arg error. [targetPath = %s, error = %s]",
-					target,
-					err,
-				)
-				return ccode.ActorMarshalError
-			}
-			clusterPacket.ArgBytes = argsBytes
+		var begin time.Time
+		if p.rpcObserver != nil {
+			begin = time.Now()
 		}
 
-		err = p.app.Cluster().PublishRemote(targetPath.NodeID, clusterPacket)
-		if err != nil {
-			clog.Warnf("[Call] Publish remote fail. [source = %s, target = %s, funcName = %s, err = %v]",
-				source,
-				target,
-				funcName,
-				err,
-			)
-			return ccode.ActorPublishRemoteError
+		header, end := p.tracer.Inject(context.Background(), rpcRoute(targetPath, funcName), argSession(arg))
+		code := p.callRemote(source, target, targetPath, funcName, arg, header)
+		end(code)
+
+		if p.rpcObserver != nil {
+			p.observeRPC(targetPath, funcName, false, code, begin)
 		}
+		return code
 	} else {
 		remoteMsg := cfacade.GetMessage()
 		remoteMsg.Source = source
@@ -195,8 +188,120 @@ func (p *System) Call(source, target, funcName string, arg interface{}) int32 {
 	return ccode.OK
 }
 
+// callRemote 序列化参数并发送到目标节点,不等待回复
+func (p *System) callRemote(source, target string, targetPath *cfacade.ActorPath, funcName string, arg interface{}, header map[string]string) int32 {
+	clusterPacket := cproto.GetClusterPacket()
+	clusterPacket.SourcePath = source
+	clusterPacket.TargetPath = target
+	clusterPacket.FuncName = funcName
+	clusterPacket.Header = header
+
+	if arg != nil {
+		argsBytes, err := p.app.Serializer().Marshal(arg)
+		if err != nil {
+			clog.Warnf("[Call] Marshal arg error. [targetPath = %s, error = %s]",
+				target,
+				err,
+			)
+			return ccode.ActorMarshalError
+		}
+		clusterPacket.ArgBytes = argsBytes
+	}
+
+	if err := p.breakers.allow(targetPath.NodeID); err != nil {
+		clusterPacket.Recycle()
+		clog.Warnf("[Call] Circuit open. [source = %s, target = %s, funcName = %s]",
+			source,
+			target,
+			funcName,
+		)
+		return ccode.ActorCircuitOpen
+	}
+
+	err := p.app.Cluster().PublishRemote(targetPath.NodeID, clusterPacket)
+	p.breakers.report(targetPath.NodeID, err != nil)
+	if err != nil {
+		clog.Warnf("[Call] Publish remote fail. [source = %s, target = %s, funcName = %s, err = %v]",
+			source,
+			target,
+			funcName,
+			err,
+		)
+		return ccode.ActorPublishRemoteError
+	}
+
+	return ccode.OK
+}
+
 // CallWait 发送远程消息(等待回复)
+// 跨节点调用在callTimeout后超时,本节点调用一直等待回复
 func (p *System) CallWait(source, target, funcName string, arg interface{}, reply interface{}) int32 {
+	return p.callWait(context.Background(), false, source, target, funcName, arg, reply)
+}
+
+// CallWaitContext 发送远程消息(等待回复),ctx取消或超时后返回
+// 跨节点与本节点调用都在callTimeout(或ctx的deadline,取较早者)后超时
+func (p *System) CallWaitContext(ctx context.Context, source, target, funcName string, arg interface{}, reply interface{}) int32 {
+	return p.callWait(ctx, true, source, target, funcName, arg, reply)
+}
+
+// CallWaitWith 发送远程消息(等待回复),失败时按opts重试,返回最后一次调用的code与调用次数
+// 本节点调用也使用callTimeout,仅幂等调用在超时或网络错误时重试
+func (p *System) CallWaitWith(source, target, funcName string, arg interface{}, reply interface{}, opts cfacade.CallOpts) (int32, int) {
+	attempts := 0
+
+	for {
+		attempts++
+
+		code := p.callWait(context.Background(), true, source, target, funcName, arg, reply)
+		if ccode.IsOK(code) || !opts.Idempotent || attempts > opts.Retries || !isRetryable(code) {
+			return code, attempts
+		}
+
+		delay := backoff(opts.Backoff, attempts)
+		clog.Warnf("[CallWaitWith] Retry call. [source = %s, target = %s, funcName = %s, code = %d, attempts = %d, delay = %v]",
+			source,
+			target,
+			funcName,
+			code,
+			attempts,
+			delay,
+		)
+		time.Sleep(delay)
+	}
+}
+
+// isRetryable 超时或网络错误,重试可能成功
+func isRetryable(code int32) bool {
+	switch code {
+	case ccode.ActorCallTimeout, ccode.RPCTimeoutError, ccode.RPCNetError, ccode.ActorPublishRemoteError:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff 第n次重试的等待时间, base * 2^(n-1) 并随机抖动到[d/2, d)
+func backoff(base time.Duration, n int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	d := base
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)))
+}
+
+// callWait localTimeout为false时,本节点调用不使用callTimeout
+func (p *System) callWait(ctx context.Context, localTimeout bool, source, target, funcName string, arg interface{}, reply interface{}) int32 {
 	sourcePath, err := cfacade.ToActorPath(source)
 	if err != nil {
 		clog.Warnf("[CallWait] Source path error. [source = %s, target = %s, funcName = %s, err = %v]",
@@ -237,40 +342,40 @@ func (p *System) CallWait(source, target, funcName string, arg interface{}, repl
 		return ccode.ActorFuncNameError
 	}
 
+	timeout := p.callTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); d < timeout {
+			timeout = d
+		}
+	}
+
+	if timeout <= 0 {
+		return ccode.ActorCallTimeout
+	}
+
 	// forward to remote actor
 	if targetPath.NodeID != "" && targetPath.NodeID != sourcePath.NodeID {
-		clusterPacket := cproto.BuildClusterPacket(source, target, funcName)
-
-		if arg != nil {
-			argsBytes, err := p.app.Serializer().Marshal(arg)
-			if err != nil {
-				clog.Warnf("[CallWait] Marshal arg error. [targetPath = %s, error = %s]", target, err)
-				return ccode.ActorMarshalError
-			}
-			clusterPacket.ArgBytes = argsBytes
+		var begin time.Time
+		if p.rpcObserver != nil {
+			begin = time.Now()
 		}
 
-		rsp := p.app.Cluster().RequestRemote(targetPath.NodeID, clusterPacket, p.callTimeout)
-		if ccode.IsFail(rsp.Code) {
-			return rsp.Code
-		}
+		// ctx为span的父节点
+		header, end := p.tracer.Inject(ctx, rpcRoute(targetPath, funcName), argSession(arg))
+		code := p.callWaitRemote(ctx, source, target, targetPath, funcName, arg, reply, timeout, header)
+		end(code)
 
-		if reply != nil {
-			if err = p.app.Serializer().Unmarshal(rsp.Data, reply); err != nil {
-				clog.Warnf("[CallWait] Marshal reply error. [targetPath = %s, error = %s]", target, err)
-				return ccode.ActorMarshalError
-			}
+		if p.rpcObserver != nil {
+			p.observeRPC(targetPath, funcName, true, code, begin)
 		}
-
+		return code
 	} else {
 		message := cfacade.GetMessage()
 		message.Source = source
 		message.Target = target
 		message.FuncName = funcName
 		message.Args = arg
-		message.ChanResult = make(chan interface{})
-
-		var result interface{}
+		message.ChanResult = make(chan interface{}, 1) // buffered, the invoker never blocks if the caller timeout
 
 		if sourcePath.ActorID == targetPath.ActorID {
 			if sourcePath.ChildID == targetPath.ChildID {
@@ -283,13 +388,26 @@ func (p *System) CallWait(source, target, funcName string, arg interface{}, repl
 			}
 
 			childActor.PostRemote(&message)
-			result = <-message.ChanResult
 		} else {
 			if !p.PostRemote(&message) {
 				clog.Warnf("[CallWait] Post remote fail. [source = %s, target = %s, funcName = %s]", source, target, funcName)
 				return ccode.ActorCallFail
 			}
-			result = <-message.ChanResult
+		}
+
+		if !localTimeout {
+			timeout = 0
+		}
+
+		result, code := waitResult(ctx, message.ChanResult, timeout)
+		if ccode.IsFail(code) {
+			clog.Warnf("[CallWait] Wait result fail. [source = %s, target = %s, funcName = %s, code = %d]",
+				source,
+				target,
+				funcName,
+				code,
+			)
+			return code
 		}
 
 		if result != nil {
@@ -328,6 +446,99 @@ func (p *System) CallWait(source, target, funcName string, arg interface{}, repl
 	return ccode.OK
 }
 
+// callWaitRemote 序列化参数发送到目标节点,等待回复并反序列化
+func (p *System) callWaitRemote(ctx context.Context, source, target string, targetPath *cfacade.ActorPath, funcName string, arg interface{}, reply interface{}, timeout time.Duration, header map[string]string) int32 {
+	clusterPacket := cproto.BuildClusterPacket(source, target, funcName)
+	clusterPacket.Header = header
+
+	if arg != nil {
+		argsBytes, err := p.app.Serializer().Marshal(arg)
+		if err != nil {
+			clog.Warnf("[CallWait] Marshal arg error. [targetPath = %s, error = %s]", target, err)
+			return ccode.ActorMarshalError
+		}
+		clusterPacket.ArgBytes = argsBytes
+	}
+
+	if err := p.breakers.allow(targetPath.NodeID); err != nil {
+		clusterPacket.Recycle()
+		clog.Warnf("[CallWait] Circuit open. [source = %s, target = %s, funcName = %s]",
+			source,
+			target,
+			funcName,
+		)
+		return ccode.ActorCircuitOpen
+	}
+
+	rsp, code := p.requestRemote(ctx, targetPath.NodeID, clusterPacket, timeout)
+	if ccode.IsFail(code) {
+		// canceled by ctx, the probe is released after cooldown
+		return code
+	}
+
+	p.breakers.report(targetPath.NodeID, isNodeFailure(rsp.Code))
+
+	if ccode.IsFail(rsp.Code) {
+		return rsp.Code
+	}
+
+	if reply != nil {
+		if err := p.app.Serializer().Unmarshal(rsp.Data, reply); err != nil {
+			clog.Warnf("[CallWait] Marshal reply error. [targetPath = %s, error = %s]", target, err)
+			return ccode.ActorMarshalError
+		}
+	}
+
+	return ccode.OK
+}
+
+func (p *System) requestRemote(ctx context.Context, nodeID string, packet *cproto.ClusterPacket, timeout time.Duration) (*cproto.Response, int32) {
+	// context.Background() can never be canceled
+	if ctx.Done() == nil {
+		rsp := p.app.Cluster().RequestRemote(nodeID, packet, timeout)
+		return &rsp, ccode.OK
+	}
+
+	rspChan := make(chan *cproto.Response, 1)
+	go func() {
+		rsp := p.app.Cluster().RequestRemote(nodeID, packet, timeout)
+		rspChan <- &rsp
+	}()
+
+	select {
+	case rsp := <-rspChan:
+		return rsp, ccode.OK
+	case <-ctx.Done():
+		return nil, contextCode(ctx)
+	}
+}
+
+// waitResult wait the result until ctx is done or timeout. zero timeout is no timeout
+func waitResult(ctx context.Context, ch chan interface{}, timeout time.Duration) (interface{}, int32) {
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	select {
+	case result := <-ch:
+		return result, ccode.OK
+	case <-timeoutC:
+		return nil, ccode.ActorCallTimeout
+	case <-ctx.Done():
+		return nil, contextCode(ctx)
+	}
+}
+
+func contextCode(ctx context.Context) int32 {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ccode.ActorCallTimeout
+	}
+	return ccode.ActorCallCanceled
+}
+
 // PostRemote 提交远程消息
 func (p *System) PostRemote(m *cfacade.Message) bool {
 	if m == nil {
@@ -417,3 +628,23 @@ func (p *System) SetExecutionTimeout(t int64) {
 		p.executionTimeout = t
 	}
 }
+
+// SetRPCObserver 设置跨节点调用统计,需在调用前设置. 为nil时不统计(默认)
+func (p *System) SetRPCObserver(observer cfacade.RPCObserver) {
+	p.rpcObserver = observer
+}
+
+func (p *System) observeRPC(targetPath *cfacade.ActorPath, funcName string, wait bool, code int32, begin time.Time) {
+	stat := cfacade.RPCStat{
+		Route:    rpcRoute(targetPath, funcName),
+		Wait:     wait,
+		Code:     code,
+		Duration: time.Since(begin),
+	}
+
+	if member, found := p.app.Discovery().GetMember(targetPath.NodeID); found {
+		stat.NodeType = member.GetNodeType()
+	}
+
+	p.rpcObserver.ObserveRPC(stat)
+}
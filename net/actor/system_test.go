@@ -0,0 +1,243 @@
+package cherryActor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cserializer "github.com/cherry-game/cherry/net/serializer"
+)
+
+type (
+	callApp struct {
+		cfacade.IApplication
+	}
+
+	slowActor struct {
+		Base
+		ready chan struct{}
+	}
+)
+
+func (callApp) Serializer() cfacade.ISerializer {
+	return cserializer.NewJSON()
+}
+
+func (callApp) NodeId() string {
+	return ""
+}
+
+func (p *slowActor) OnInit() {
+	p.Remote().Register("slow", func() {
+		time.Sleep(100 * time.Millisecond)
+	})
+	close(p.ready)
+}
+
+func newCallSystem(t *testing.T, callTimeout time.Duration) *System {
+	system := NewSystem()
+	system.SetApp(callApp{})
+	system.SetCallTimeout(callTimeout)
+
+	slow := &slowActor{ready: make(chan struct{})}
+	if _, err := system.CreateActor("slow", slow); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-slow.ready:
+	case <-time.After(time.Second):
+		t.Fatal("actor is not running")
+	}
+
+	return system
+}
+
+func TestCallWaitContextTimeout(t *testing.T) {
+	system := newCallSystem(t, 20*time.Millisecond)
+
+	if code := system.CallWaitContext(context.Background(), ".caller", ".slow", "slow", nil, nil); code != ccode.ActorCallTimeout {
+		t.Fatalf("call timeout code = %d", code)
+	}
+
+	// the deadline of ctx is earlier than callTimeout
+	system.SetCallTimeout(3 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if code := system.CallWaitContext(ctx, ".caller", ".slow", "slow", nil, nil); code != ccode.ActorCallTimeout {
+		t.Fatalf("ctx deadline code = %d", code)
+	}
+}
+
+func TestCallWaitContextCancel(t *testing.T) {
+	system := newCallSystem(t, 3*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if code := system.CallWaitContext(ctx, ".caller", ".slow", "slow", nil, nil); code != ccode.ActorCallCanceled {
+		t.Fatalf("call cancel code = %d", code)
+	}
+}
+
+func TestCallWaitLocalNoTimeout(t *testing.T) {
+	system := newCallSystem(t, 20*time.Millisecond)
+
+	// the local call waits for the reply, callTimeout is only used by the remote call
+	if code := system.CallWait(".caller", ".slow", "slow", nil, nil); code != ccode.OK {
+		t.Fatalf("local call wait code = %d", code)
+	}
+}
+
+func TestCallWaitWithRetry(t *testing.T) {
+	system := newCallSystem(t, 20*time.Millisecond)
+
+	opts := cfacade.CallOpts{
+		Retries:    2,
+		Backoff:    time.Millisecond,
+		Idempotent: true,
+	}
+
+	code, attempts := system.CallWaitWith(".caller", ".slow", "slow", nil, nil, opts)
+	if code != ccode.ActorCallTimeout || attempts != 3 {
+		t.Fatalf("idempotent call code = %d, attempts = %d", code, attempts)
+	}
+
+	// the non-idempotent call is not retried
+	opts.Idempotent = false
+	code, attempts = system.CallWaitWith(".caller", ".slow", "slow", nil, nil, opts)
+	if code != ccode.ActorCallTimeout || attempts != 1 {
+		t.Fatalf("non-idempotent call code = %d, attempts = %d", code, attempts)
+	}
+
+	// the fail is not transient
+	opts.Idempotent = true
+	code, attempts = system.CallWaitWith(".caller", ".slow", "", nil, nil, opts)
+	if code != ccode.ActorFuncNameError || attempts != 1 {
+		t.Fatalf("func name error code = %d, attempts = %d", code, attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for n := 1; n < 5; n++ {
+		d := 10 * time.Millisecond << (n - 1)
+		if b := backoff(10*time.Millisecond, n); b < d/2 || b >= d {
+			t.Fatalf("backoff(%d) = %v", n, b)
+		}
+	}
+
+	if b := backoff(time.Second, 100); b >= maxBackoff {
+		t.Fatalf("backoff exceed max = %v", b)
+	}
+}
+
+type rpcStats []cfacade.RPCStat
+
+func (p *rpcStats) ObserveRPC(stat cfacade.RPCStat) {
+	*p = append(*p, stat)
+}
+
+func TestRPCObserver(t *testing.T) {
+	system := NewSystem()
+	system.SetApp(broadcastApp{
+		discovery: broadcastDiscovery{},
+		cluster:   broadcastCluster{},
+	})
+	system.SetCallTimeout(20 * time.Millisecond)
+
+	stats := &rpcStats{}
+	system.SetRPCObserver(stats)
+
+	system.Call("game-1.caller", "game-2.echo", "echo", nil)
+	system.CallWait("game-1.caller", "game-2.echo", "echo", nil, nil)
+	system.Call("game-1.caller", "game-1.echo", "echo", nil) // the local call is not observed
+
+	if len(*stats) != 2 {
+		t.Fatalf("stats = %+v", *stats)
+	}
+
+	call, wait := (*stats)[0], (*stats)[1]
+	if call.Route != "echo.echo" || call.NodeType != "game" || call.Wait || call.Code != ccode.ActorPublishRemoteError {
+		t.Fatalf("call stat = %+v", call)
+	}
+
+	if !wait.Wait || wait.Code != ccode.RPCNetError {
+		t.Fatalf("call wait stat = %+v", wait)
+	}
+}
+
+type (
+	testTracer struct {
+		ended    []int32
+		sessions []*cproto.Session
+	}
+
+	headerCluster struct {
+		cfacade.ICluster
+		header map[string]string
+	}
+)
+
+func (p *testTracer) Inject(_ context.Context, route string, session *cproto.Session) (map[string]string, func(code int32)) {
+	p.sessions = append(p.sessions, session)
+	return map[string]string{"route": route}, func(code int32) {
+		p.ended = append(p.ended, code)
+	}
+}
+
+func (p *testTracer) Extract(ctx context.Context, _ string, _ *cproto.Session, _ map[string]string) (context.Context, func(code int32)) {
+	return ctx, func(int32) {}
+}
+
+func (p *headerCluster) PublishRemote(_ string, packet *cproto.ClusterPacket) error {
+	p.header = packet.Header
+	packet.Recycle()
+	return nil
+}
+
+func (p *headerCluster) RequestRemote(_ string, packet *cproto.ClusterPacket, _ ...time.Duration) cproto.Response {
+	p.header = packet.Header
+	packet.Recycle()
+	return cproto.Response{Code: ccode.RPCNetError}
+}
+
+func TestTracerInject(t *testing.T) {
+	cluster := &headerCluster{}
+	system := NewSystem()
+	system.SetApp(broadcastApp{
+		discovery: broadcastDiscovery{},
+		cluster:   cluster,
+	})
+
+	tracer := &testTracer{}
+	system.SetTracer(tracer)
+
+	system.CallWait("game-1.caller", "game-2.echo.1001", "echo", nil, nil)
+
+	if cluster.header["route"] != "echo.echo" {
+		t.Fatalf("header = %v", cluster.header)
+	}
+
+	if len(tracer.ended) != 1 || tracer.ended[0] != ccode.RPCNetError {
+		t.Fatalf("ended = %v", tracer.ended)
+	}
+
+	// the session arg is the span attributes
+	session := &cproto.Session{Sid: "s1", Uid: 1001}
+	system.Call("game-1.caller", "game-2.echo.1001", "echo", session)
+	system.CallWait("game-1.caller", "game-2.echo.1001", "echo", session, nil)
+
+	if len(tracer.sessions) != 3 || tracer.sessions[0] != nil || tracer.sessions[1] != session || tracer.sessions[2] != session {
+		t.Fatalf("sessions = %v", tracer.sessions)
+	}
+
+	// reset to the noop tracer
+	system.SetTracer(nil)
+	if _, ok := system.Tracer().(noopTracer); !ok {
+		t.Fatalf("tracer = %T", system.Tracer())
+	}
+}
@@ -0,0 +1,46 @@
+package cherryActor
+
+import (
+	"context"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type (
+	// noopTracer 默认的空实现,不分配header
+	noopTracer struct{}
+)
+
+func noopEnd(int32) {}
+
+func (noopTracer) Inject(_ context.Context, _ string, _ *cproto.Session) (map[string]string, func(code int32)) {
+	return nil, noopEnd
+}
+
+func (noopTracer) Extract(ctx context.Context, _ string, _ *cproto.Session, _ map[string]string) (context.Context, func(code int32)) {
+	return ctx, noopEnd
+}
+
+// rpcRoute span与统计使用的route, 不包含childID
+func rpcRoute(targetPath *cfacade.ActorPath, funcName string) string {
+	return targetPath.ActorID + "." + funcName
+}
+
+// argSession 参数为session时作为span的uid/sid属性,否则为nil
+func argSession(arg interface{}) *cproto.Session {
+	session, _ := arg.(*cproto.Session)
+	return session
+}
+
+// SetTracer 设置跨节点调用的链路追踪,需在调用前设置. 为nil时使用空实现(默认)
+func (p *System) SetTracer(tracer cfacade.ITracer) {
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
+	p.tracer = tracer
+}
+
+func (p *System) Tracer() cfacade.ITracer {
+	return p.tracer
+}
@@ -2,16 +2,43 @@ package cherryCluster
 
 import (
 	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
 	cherryNatsCluster "github.com/cherry-game/cherry/net/cluster/nats_cluster"
+	cprofile "github.com/cherry-game/cherry/profile"
 )
 
 const (
-	Name = "cluster_component"
+	Name             = "cluster_component"
+	DefaultTransport = "nats"
 )
 
-type Component struct {
-	cfacade.Component
-	cfacade.ICluster
+type (
+	Component struct {
+		cfacade.Component
+		cfacade.ICluster
+	}
+
+	// CreateFunc create the cluster of the transport
+	CreateFunc func(app cfacade.IApplication) cfacade.ICluster
+)
+
+var (
+	transportMap = map[string]CreateFunc{
+		DefaultTransport: func(app cfacade.IApplication) cfacade.ICluster {
+			return cherryNatsCluster.New(app)
+		},
+	}
+)
+
+// Register register the cluster of the transport, it's selected by `cluster->transport` in profile file.
+// eg. cherryCluster.Register(cherryGRPC.Name, cherryGRPC.New)
+func Register(transport string, fn CreateFunc) {
+	if transport == "" || fn == nil {
+		clog.Fatal("Cluster transport is empty or create func is nil.")
+		return
+	}
+
+	transportMap[transport] = fn
 }
 
 func New() *Component {
@@ -32,5 +59,13 @@ func (c *Component) OnStop() {
 }
 
 func (c *Component) loadCluster() cfacade.ICluster {
-	return cherryNatsCluster.New(c.App())
+	transport := cprofile.GetConfig("cluster").GetString("transport", DefaultTransport)
+
+	fn, found := transportMap[transport]
+	if !found {
+		clog.Panicf("transport = %s not found in cluster. Please call the Register(...) method.", transport)
+	}
+
+	clog.Infof("Select cluster [transport = %s].", transport)
+	return fn(c.App())
 }
@@ -1,6 +1,7 @@
 package cherryNatsCluster
 
 import (
+	"errors"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -79,6 +80,12 @@ func (p *Cluster) Stop() {
 	clog.Info("nats cluster execute OnStop().")
 }
 
+// IsDegraded returns true if the nats is disconnected, the messages are buffered by nats until it's reconnected
+// and the requests may be timeout.
+func (p *Cluster) IsDegraded() bool {
+	return !cnats.Get().IsConnected()
+}
+
 func (p *Cluster) localProcess() {
 	var err error
 	p.local.subscription, err = cnats.Get().ChanSubscribe(p.local.subject, p.local.ch)
@@ -116,6 +123,7 @@ func (p *Cluster) localProcess() {
 		message.FuncName = packet.FuncName
 		message.IsCluster = true
 		message.Session = packet.Session
+		message.Header = packet.Header
 		message.Args = packet.ArgBytes
 
 		p.app.ActorSystem().PostLocal(&message)
@@ -161,6 +169,7 @@ func (p *Cluster) remoteProcess() {
 		message.Source = packet.SourcePath
 		message.Target = packet.TargetPath
 		message.FuncName = packet.FuncName
+		message.Header = packet.Header
 		if packet.ArgBytes != nil {
 			message.Args = packet.ArgBytes
 		}
@@ -269,7 +278,11 @@ func (p *Cluster) RequestRemote(nodeId string, request *cproto.ClusterPacket, ti
 			err,
 		)
 
-		rsp.Code = ccode.RPCNetError
+		if errors.Is(err, nats.ErrTimeout) {
+			rsp.Code = ccode.RPCTimeoutError
+		} else {
+			rsp.Code = ccode.RPCNetError
+		}
 		return rsp
 	}
 
@@ -47,7 +47,11 @@ func (p *Connector) Start() {
 	}()
 }
 
+// Stop close the listener, it's called once. eg. stopped by the graceful shutdown and the component
 func (p *Connector) Stop() {
+	if !p.running {
+		return
+	}
 	p.running = false
 
 	if err := p.listener.Close(); err != nil {
@@ -59,6 +63,22 @@ func (p *Connector) Running() bool {
 	return p.running
 }
 
+// GetTLSListener listen with the tls config
+func (p *Connector) GetTLSListener(config *tls.Config, address string) (net.Listener, error) {
+	var err error
+	p.listener, err = tls.Listen("tcp", address, config)
+	return p.listener, err
+}
+
+// listen returns the tls listener if the tls config is set, otherwise see GetListener
+func (p *Connector) listen(opts *Options) (net.Listener, error) {
+	if opts.tlsConfig != nil {
+		return p.GetTLSListener(opts.tlsConfig, opts.address)
+	}
+
+	return p.GetListener(opts.certFile, opts.keyFile, opts.address)
+}
+
 func (p *Connector) GetListener(certFile, keyFile, address string) (net.Listener, error) {
 	var err error
 	if certFile == "" || keyFile == "" {
@@ -1,15 +1,18 @@
 package cherryConnector
 
 import (
+	"crypto/tls"
+
 	clog "github.com/cherry-game/cherry/logger"
 )
 
 type (
 	Options struct {
-		address  string
-		certFile string
-		keyFile  string
-		chanSize int
+		address   string
+		certFile  string
+		keyFile   string
+		tlsConfig *tls.Config
+		chanSize  int
 	}
 
 	Option func(*Options)
@@ -26,6 +29,16 @@ func WithCert(certFile, keyFile string) Option {
 	}
 }
 
+// WithTLSConfig listen with the tls config, it's used instead of WithCert.
+// set ClientAuth and ClientCAs of the config for the mutual tls. see Agent.PeerCommonName
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *Options) {
+		if config != nil {
+			o.tlsConfig = config
+		}
+	}
+}
+
 func WithChanSize(size int) Option {
 	return func(o *Options) {
 		if size > 1 {
@@ -49,7 +49,7 @@ func NewTCP(address string, opts ...Option) *TCPConnector {
 }
 
 func (t *TCPConnector) Start() {
-	listener, err := t.GetListener(t.certFile, t.keyFile, t.address)
+	listener, err := t.listen(&t.Options)
 	if err != nil {
 		clog.Fatalf("failed to listen: %s", err)
 	}
@@ -0,0 +1,69 @@
+package cherryConnector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSConnCloseReason(t *testing.T) {
+	closeErr := closeWithReason(t, "another_login")
+	if closeErr.Code != websocket.CloseNormalClosure || closeErr.Text != "another_login" {
+		t.Fatalf("close err = %v", closeErr)
+	}
+
+	// the long non-ascii reason is cut on the rune boundary
+	reason := "k" + strings.Repeat("服务器维护", 10)
+	closeErr = closeWithReason(t, reason)
+	if len(closeErr.Text) > maxCloseReasonLen || !utf8.ValidString(closeErr.Text) || !strings.HasPrefix(reason, closeErr.Text) {
+		t.Fatalf("close reason = %q", closeErr.Text)
+	}
+
+	if text := truncateCloseReason(reason); len(text) != 121 {
+		t.Fatalf("truncated = %d", len(text))
+	}
+}
+
+// closeWithReason returns the close error read by the client
+func closeWithReason(t *testing.T, reason string) *websocket.CloseError {
+	upgrade := &websocket.Upgrader{}
+	addrChan := make(chan string, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		wsConn, err := upgrade.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+
+		conn := NewWSConn(wsConn)
+		addrChan <- conn.RemoteAddr().String()
+
+		conn.SetCloseReason(reason)
+		_ = conn.Close()
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// the remote address is the client socket address
+	if addr := <-addrChan; addr != client.LocalAddr().String() {
+		t.Fatalf("remote addr = %s, client addr = %s", addr, client.LocalAddr())
+	}
+
+	_, _, err = client.ReadMessage()
+
+	closeErr, ok := err.(*websocket.CloseError)
+	if !ok {
+		t.Fatalf("read err = %v", err)
+	}
+
+	return closeErr
+}
@@ -3,13 +3,20 @@ package cherryConnector
 import (
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	closeWriteWait    = time.Second // write timeout of the close frame
+	maxCloseReasonLen = 123         // max payload of the control frame(125) - close code(2)
+)
+
 type (
 	WSConnector struct {
 		cfacade.Component
@@ -22,8 +29,9 @@ type (
 	// interface base on *websocket.INetConn
 	WSConn struct {
 		*websocket.Conn
-		typ    int // message type
-		reader io.Reader
+		typ         int // message type
+		reader      io.Reader
+		closeReason atomic.Value // string, sent in the close frame. see SetCloseReason
 	}
 )
 
@@ -70,7 +78,7 @@ func NewWS(address string, opts ...Option) *WSConnector {
 }
 
 func (w *WSConnector) Start() {
-	listener, err := w.GetListener(w.certFile, w.keyFile, w.address)
+	listener, err := w.listen(&w.Options)
 	if err != nil {
 		clog.Fatalf("failed to listen: %s", err)
 	}
@@ -146,6 +154,37 @@ func (c *WSConn) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
+// SetCloseReason set the reason sent in the close frame. eg. the kick reason.
+// it's safe to call it from other goroutines than Close
+func (c *WSConn) SetCloseReason(reason string) {
+	c.closeReason.Store(reason)
+}
+
+// Close send the close frame and close the underlying connection
+func (c *WSConn) Close() error {
+	reason, _ := c.closeReason.Load().(string)
+	reason = truncateCloseReason(reason)
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason)
+	_ = c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWriteWait))
+
+	return c.Conn.Close()
+}
+
+// truncateCloseReason the reason must be valid utf-8(RFC 6455), it's cut on the rune boundary
+func truncateCloseReason(reason string) string {
+	if len(reason) <= maxCloseReasonLen {
+		return reason
+	}
+
+	i := maxCloseReasonLen
+	for i > 0 && !utf8.RuneStart(reason[i]) {
+		i--
+	}
+
+	return reason[:i]
+}
+
 func (c *WSConn) SetDeadline(t time.Time) error {
 	if err := c.SetReadDeadline(t); err != nil {
 		return err
@@ -44,6 +44,7 @@ func (p *Component) Init() {
 
 	clog.Infof("Select discovery [mode = %s].", mode)
 	p.IDiscovery = discovery
+	consistentHash.Watch(p.IDiscovery)
 	p.IDiscovery.Load(p.App())
 }
 
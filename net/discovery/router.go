@@ -0,0 +1,208 @@
+package cherryDiscovery
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+const (
+	RouterRandom     = "random"      // random, same as the default discovery.Random()
+	RouterRoundRobin = "round_robin" // round robin by node type
+	RouterLeastConn  = "least_conn"  // the node with the least connections, see LeastConn.Add
+	RouterHashUID    = "hash_uid"    // a uid always routes to the same node until the nodes are changed
+)
+
+type (
+	// RouteHint the hint of the router
+	RouteHint struct {
+		UID   cfacade.UID // uid of the session, zero if it's not bound
+		Route string      // message route
+	}
+
+	// Router select a node from the candidates of the node type, the candidates are sorted by node id
+	Router interface {
+		Select(nodeType string, candidates []cfacade.IMember, hint RouteHint) (cfacade.IMember, error)
+	}
+
+	RandomRouter struct{}
+
+	RoundRobinRouter struct {
+		sync.Map // key:nodeType, value:*uint64
+	}
+
+	// LeastConn the connections of the nodes are reported by Add. eg. the agent count of the gate nodes
+	LeastConn struct {
+		sync.Map // key:nodeId, value:*int64
+	}
+
+	// HashUIDRouter rendezvous hashing by uid, only the uids of the changed node are moved.
+	// the session not bound is routed randomly.
+	HashUIDRouter struct{}
+)
+
+var (
+	routerLock  sync.RWMutex
+	routerMap   = map[string]Router{} // key:router name
+	nodeRouters = map[string]Router{} // key:nodeType
+)
+
+func init() {
+	RegisterRouter(RouterRandom, &RandomRouter{})
+	RegisterRouter(RouterRoundRobin, &RoundRobinRouter{})
+	RegisterRouter(RouterLeastConn, &LeastConn{})
+	RegisterRouter(RouterHashUID, &HashUIDRouter{})
+}
+
+// RegisterRouter register the router by name, the router with the same name is replaced
+func RegisterRouter(name string, router Router) {
+	if name == "" || router == nil {
+		return
+	}
+
+	routerLock.Lock()
+	defer routerLock.Unlock()
+
+	routerMap[name] = router
+}
+
+// GetRouter returns the registered router by name
+func GetRouter(name string) (Router, bool) {
+	routerLock.RLock()
+	defer routerLock.RUnlock()
+
+	router, found := routerMap[name]
+	return router, found
+}
+
+// SetRouter set the router of the node type by name
+func SetRouter(nodeType, name string) error {
+	routerLock.Lock()
+	defer routerLock.Unlock()
+
+	router, found := routerMap[name]
+	if !found {
+		return cerr.Errorf("router not found. [name = %s]", name)
+	}
+
+	nodeRouters[nodeType] = router
+	return nil
+}
+
+func getNodeRouter(nodeType string) (Router, bool) {
+	routerLock.RLock()
+	defer routerLock.RUnlock()
+
+	router, found := nodeRouters[nodeType]
+	return router, found
+}
+
+// Route select a member of the node type by the router of the node type.
+// the node type without router uses discovery.Random()
+func Route(discovery cfacade.IDiscovery, nodeType string, hint RouteHint) (cfacade.IMember, error) {
+	router, found := getNodeRouter(nodeType)
+	if !found {
+		member, found := discovery.Random(nodeType)
+		if !found {
+			return nil, cerr.DiscoveryMemberListIsEmpty
+		}
+		return member, nil
+	}
+
+	candidates := discovery.ListByType(nodeType)
+	if len(candidates) < 1 {
+		return nil, cerr.DiscoveryMemberListIsEmpty
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].GetNodeId() < candidates[j].GetNodeId()
+	})
+
+	return router.Select(nodeType, candidates, hint)
+}
+
+func (*RandomRouter) Select(_ string, candidates []cfacade.IMember, _ RouteHint) (cfacade.IMember, error) {
+	if len(candidates) < 1 {
+		return nil, cerr.DiscoveryMemberListIsEmpty
+	}
+
+	return candidates[rand.Intn(len(candidates))], nil
+}
+
+func (p *RoundRobinRouter) Select(nodeType string, candidates []cfacade.IMember, _ RouteHint) (cfacade.IMember, error) {
+	if len(candidates) < 1 {
+		return nil, cerr.DiscoveryMemberListIsEmpty
+	}
+
+	value, _ := p.LoadOrStore(nodeType, new(uint64))
+	n := atomic.AddUint64(value.(*uint64), 1)
+
+	return candidates[n%uint64(len(candidates))], nil
+}
+
+// Add add delta to the connections of the node
+func (p *LeastConn) Add(nodeId string, delta int64) {
+	value, _ := p.LoadOrStore(nodeId, new(int64))
+	atomic.AddInt64(value.(*int64), delta)
+}
+
+// Count returns the connections of the node
+func (p *LeastConn) Count(nodeId string) int64 {
+	if value, found := p.Load(nodeId); found {
+		return atomic.LoadInt64(value.(*int64))
+	}
+	return 0
+}
+
+func (p *LeastConn) Select(_ string, candidates []cfacade.IMember, _ RouteHint) (cfacade.IMember, error) {
+	if len(candidates) < 1 {
+		return nil, cerr.DiscoveryMemberListIsEmpty
+	}
+
+	selected := candidates[0]
+	least := p.Count(selected.GetNodeId())
+
+	for _, member := range candidates[1:] {
+		if count := p.Count(member.GetNodeId()); count < least {
+			selected, least = member, count
+		}
+	}
+
+	return selected, nil
+}
+
+func (*HashUIDRouter) Select(_ string, candidates []cfacade.IMember, hint RouteHint) (cfacade.IMember, error) {
+	if len(candidates) < 1 {
+		return nil, cerr.DiscoveryMemberListIsEmpty
+	}
+
+	if !cfacade.IsValidUID(hint.UID) {
+		return candidates[rand.Intn(len(candidates))], nil
+	}
+
+	uid := strconv.FormatInt(hint.UID, 10)
+
+	var (
+		selected cfacade.IMember
+		maxSum   uint64
+	)
+
+	for _, member := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(uid))
+		h.Write([]byte{0})
+		h.Write([]byte(member.GetNodeId()))
+
+		if sum := h.Sum64(); selected == nil || sum > maxSum {
+			selected, maxSum = member, sum
+		}
+	}
+
+	return selected, nil
+}
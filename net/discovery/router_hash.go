@@ -0,0 +1,181 @@
+package cherryDiscovery
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+const (
+	RouterConsistentHash = "consistent_hash" // weighted consistent hash ring by uid, see ConsistentHashRouter
+
+	SettingWeight = "weight" // member setting, the weight of the node in the hash ring(1~100), default is 1
+	hashReplicas  = 160      // virtual nodes of each weight
+	maxWeight     = 100
+)
+
+type (
+	// ConsistentHashRouter the weighted consistent hash ring by uid, a uid always routes to the same node
+	// until the nodes are changed, only the uids of the changed node are moved.
+	// the ring of the node type is rebuilt when the members are changed. see Watch
+	ConsistentHashRouter struct {
+		sync.RWMutex
+		rings map[string]*hashRing // key:nodeType
+	}
+
+	hashRing struct {
+		members int      // the candidates of the ring
+		hashes  []uint64 // sorted hashes of the virtual nodes
+		nodes   []cfacade.IMember
+	}
+)
+
+var (
+	consistentHash = &ConsistentHashRouter{}
+)
+
+func init() {
+	RegisterRouter(RouterConsistentHash, consistentHash)
+}
+
+// Watch reset the ring of the node type when the members of the discovery are changed
+func (p *ConsistentHashRouter) Watch(discovery cfacade.IDiscovery) {
+	reset := func(member cfacade.IMember) {
+		p.Reset(member.GetNodeType())
+	}
+
+	discovery.OnAddMember(reset)
+	discovery.OnRemoveMember(reset)
+}
+
+// Reset the ring of the node type is rebuilt on the next Select
+func (p *ConsistentHashRouter) Reset(nodeType string) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.rings, nodeType)
+}
+
+func (p *ConsistentHashRouter) Select(nodeType string, candidates []cfacade.IMember, hint RouteHint) (cfacade.IMember, error) {
+	if len(candidates) < 1 {
+		return nil, cerr.DiscoveryMemberListIsEmpty
+	}
+
+	if !cfacade.IsValidUID(hint.UID) {
+		return candidates[rand.Intn(len(candidates))], nil
+	}
+
+	return p.ring(nodeType, candidates).get(hint.UID), nil
+}
+
+// ring returns the ring of the node type, it's rebuilt if the count of the candidates is changed
+func (p *ConsistentHashRouter) ring(nodeType string, candidates []cfacade.IMember) *hashRing {
+	p.RLock()
+	ring, found := p.rings[nodeType]
+	p.RUnlock()
+
+	if found && ring.members == len(candidates) {
+		return ring
+	}
+
+	ring = newHashRing(candidates)
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.rings == nil {
+		p.rings = make(map[string]*hashRing)
+	}
+	p.rings[nodeType] = ring
+
+	return ring
+}
+
+func newHashRing(candidates []cfacade.IMember) *hashRing {
+	type point struct {
+		hash uint64
+		node cfacade.IMember
+	}
+
+	var points []point
+	for _, member := range candidates {
+		replicas := memberWeight(member) * hashReplicas
+		for i := 0; i < replicas; i++ {
+			points = append(points, point{
+				hash: hashKey(member.GetNodeId() + "#" + strconv.Itoa(i)),
+				node: member,
+			})
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return points[i].node.GetNodeId() < points[j].node.GetNodeId()
+		}
+		return points[i].hash < points[j].hash
+	})
+
+	ring := &hashRing{
+		members: len(candidates),
+		hashes:  make([]uint64, len(points)),
+		nodes:   make([]cfacade.IMember, len(points)),
+	}
+
+	for i, pt := range points {
+		ring.hashes[i] = pt.hash
+		ring.nodes[i] = pt.node
+	}
+
+	return ring
+}
+
+// get returns the first virtual node clockwise of the uid
+func (p *hashRing) get(uid cfacade.UID) cfacade.IMember {
+	hash := hashKey(strconv.FormatInt(uid, 10))
+
+	i := sort.Search(len(p.hashes), func(i int) bool {
+		return p.hashes[i] >= hash
+	})
+
+	if i == len(p.hashes) {
+		i = 0
+	}
+
+	return p.nodes[i]
+}
+
+func memberWeight(member cfacade.IMember) int {
+	weight, err := strconv.Atoi(member.GetSettings()[SettingWeight])
+	if err != nil || weight < 1 {
+		return 1
+	}
+
+	if weight > maxWeight {
+		return maxWeight
+	}
+	return weight
+}
+
+// hashKey fnv-1a with the splitmix64 finalizer, the sequential keys are spread on the ring
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// AffinityFor returns the node of the node type which the uid is routed to, see SetRouter
+func AffinityFor(discovery cfacade.IDiscovery, nodeType string, uid cfacade.UID) (cfacade.IMember, error) {
+	return Route(discovery, nodeType, RouteHint{UID: uid})
+}
@@ -0,0 +1,68 @@
+package cherryDiscovery
+
+import (
+	"testing"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestConsistentHashRouter(t *testing.T) {
+	defer delete(nodeRouters, "game")
+
+	if err := SetRouter("game", RouterConsistentHash); err != nil {
+		t.Fatal(err)
+	}
+
+	discovery := newRouterDiscovery("game-1", "game-2")
+	discovery.AddMember(&cproto.Member{NodeId: "game-3", NodeType: "game", Settings: map[string]string{SettingWeight: "2"}})
+
+	consistentHash.Watch(discovery)
+
+	const uids = 10000
+
+	selected := map[cfacade.UID]string{}
+	counts := map[string]int{}
+	for uid := cfacade.UID(1); uid <= uids; uid++ {
+		member, err := AffinityFor(discovery, "game", uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		selected[uid] = member.GetNodeId()
+		counts[member.GetNodeId()]++
+	}
+
+	// the weight 2 node takes about half of the uids
+	if counts["game-3"] < uids*2/5 || counts["game-3"] > uids*3/5 {
+		t.Fatalf("counts = %v", counts)
+	}
+
+	for uid := cfacade.UID(1); uid <= 100; uid++ {
+		if member, _ := AffinityFor(discovery, "game", uid); member.GetNodeId() != selected[uid] {
+			t.Fatalf("uid = %d, node = %s, again = %s", uid, selected[uid], member.GetNodeId())
+		}
+	}
+
+	// only the uids of the removed node are moved
+	discovery.RemoveMember("game-3")
+
+	for uid, nodeId := range selected {
+		member, _ := AffinityFor(discovery, "game", uid)
+		if member.GetNodeId() == "game-3" {
+			t.Fatalf("uid = %d routes to the removed node", uid)
+		}
+		if nodeId != "game-3" && member.GetNodeId() != nodeId {
+			t.Fatalf("uid = %d moved from %s to %s", uid, nodeId, member.GetNodeId())
+		}
+	}
+
+	// the same count of the members, the ring is reset by the listener
+	discovery.AddMember(&cproto.Member{NodeId: "game-4", NodeType: "game"})
+	discovery.RemoveMember("game-1")
+
+	for uid := cfacade.UID(1); uid <= 100; uid++ {
+		if member, _ := AffinityFor(discovery, "game", uid); member.GetNodeId() == "game-1" {
+			t.Fatalf("uid = %d routes to the removed node", uid)
+		}
+	}
+}
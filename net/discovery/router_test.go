@@ -0,0 +1,111 @@
+package cherryDiscovery
+
+import (
+	"testing"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func newRouterDiscovery(nodeIds ...string) *DiscoveryDefault {
+	discovery := &DiscoveryDefault{}
+	discovery.PreInit()
+
+	for _, nodeId := range nodeIds {
+		discovery.AddMember(&cproto.Member{NodeId: nodeId, NodeType: "game"})
+	}
+
+	return discovery
+}
+
+func TestRouteDefault(t *testing.T) {
+	discovery := newRouterDiscovery()
+	if _, err := Route(discovery, "game", RouteHint{}); err == nil {
+		t.Fatal("route on empty members")
+	}
+
+	discovery.AddMember(&cproto.Member{NodeId: "game-1", NodeType: "game"})
+	if member, err := Route(discovery, "game", RouteHint{}); err != nil || member.GetNodeId() != "game-1" {
+		t.Fatalf("route member = %v, err = %v", member, err)
+	}
+}
+
+func TestRoundRobinRouter(t *testing.T) {
+	router := &RoundRobinRouter{}
+	candidates := newRouterDiscovery("game-1", "game-2", "game-3").ListByType("game")
+
+	counts := map[string]int{}
+	for i := 0; i < 30; i++ {
+		member, _ := router.Select("game", candidates, RouteHint{})
+		counts[member.GetNodeId()]++
+	}
+
+	for nodeId, count := range counts {
+		if count != 10 {
+			t.Fatalf("node = %s, count = %d", nodeId, count)
+		}
+	}
+}
+
+func TestLeastConn(t *testing.T) {
+	router := &LeastConn{}
+	candidates := newRouterDiscovery("game-1", "game-2").ListByType("game")
+
+	router.Add("game-1", 2)
+	router.Add("game-2", 1)
+
+	if member, _ := router.Select("game", candidates, RouteHint{}); member.GetNodeId() != "game-2" {
+		t.Fatalf("least conn member = %s", member.GetNodeId())
+	}
+}
+
+func TestHashUIDRouter(t *testing.T) {
+	router := &HashUIDRouter{}
+	candidates := newRouterDiscovery("game-1", "game-2", "game-3").ListByType("game")
+
+	selected := map[cfacade.UID]string{}
+	for uid := cfacade.UID(1); uid <= 100; uid++ {
+		member, _ := router.Select("game", candidates, RouteHint{UID: uid})
+		selected[uid] = member.GetNodeId()
+
+		// a uid always routes to the same node
+		if again, _ := router.Select("game", candidates, RouteHint{UID: uid}); again.GetNodeId() != selected[uid] {
+			t.Fatalf("uid = %d, node = %s, again = %s", uid, selected[uid], again.GetNodeId())
+		}
+	}
+
+	// only the uids of the removed node are moved
+	var remain []cfacade.IMember
+	for _, member := range candidates {
+		if member.GetNodeId() != "game-3" {
+			remain = append(remain, member)
+		}
+	}
+
+	for uid, nodeId := range selected {
+		member, _ := router.Select("game", remain, RouteHint{UID: uid})
+		if nodeId != "game-3" && member.GetNodeId() != nodeId {
+			t.Fatalf("uid = %d moved from %s to %s", uid, nodeId, member.GetNodeId())
+		}
+	}
+}
+
+func TestSetRouter(t *testing.T) {
+	defer delete(nodeRouters, "game")
+
+	if err := SetRouter("game", "not_found"); err == nil {
+		t.Fatal("set the router not registered")
+	}
+
+	if err := SetRouter("game", RouterHashUID); err != nil {
+		t.Fatal(err)
+	}
+
+	discovery := newRouterDiscovery("game-1", "game-2", "game-3")
+	first, _ := Route(discovery, "game", RouteHint{UID: 1001})
+	for i := 0; i < 10; i++ {
+		if member, _ := Route(discovery, "game", RouteHint{UID: 1001}); member.GetNodeId() != first.GetNodeId() {
+			t.Fatalf("member = %s, first = %s", member.GetNodeId(), first.GetNodeId())
+		}
+	}
+}
@@ -21,6 +21,8 @@ type (
 		requestTimeout time.Duration
 		user           string
 		password       string
+		token          string
+		credsFile      string // user credentials file(jwt + nkey)
 	}
 	OptionFunc func(o *options)
 )
@@ -65,6 +67,15 @@ func (p *Conn) Close() {
 	}
 }
 
+// IsConnected returns false if the nats is not connected or is reconnecting
+func (p *Conn) IsConnected() bool {
+	if !p.running || p.Conn == nil {
+		return false
+	}
+
+	return p.Conn.IsConnected()
+}
+
 func (p *Conn) Request(subj string, data []byte, timeout ...time.Duration) (*nats.Msg, error) {
 	if len(timeout) > 0 && timeout[0] > 0 {
 		return p.Conn.Request(subj, data, timeout[0])
@@ -98,7 +109,7 @@ func (p *options) natsOptions() []nats.Option {
 
 	opts = append(opts, nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
 		if err != nil {
-			clog.Warnf("Disconnect error. [error = %v]", err)
+			clog.Warnf("Disconnect error, the cluster is degraded. [error = %v]", err)
 		}
 	}))
 
@@ -125,6 +136,14 @@ func (p *options) natsOptions() []nats.Option {
 		opts = append(opts, nats.UserInfo(p.user, p.password))
 	}
 
+	if p.token != "" {
+		opts = append(opts, nats.Token(p.token))
+	}
+
+	if p.credsFile != "" {
+		opts = append(opts, nats.UserCredentials(p.credsFile))
+	}
+
 	return opts
 }
 
@@ -144,6 +163,7 @@ func (p *options) RequestTimeout() time.Duration {
 	return p.requestTimeout
 }
 
+// WithAddress the nats server address, the cluster servers are separated by comma. eg. nats://a:4222,nats://b:4222
 func WithAddress(address string) OptionFunc {
 	return func(opts *options) {
 		opts.address = address
@@ -164,3 +184,16 @@ func WithAuth(user, password string) OptionFunc {
 		opts.password = password
 	}
 }
+
+func WithToken(token string) OptionFunc {
+	return func(opts *options) {
+		opts.token = token
+	}
+}
+
+// WithCredentials the user credentials file(jwt + nkey seed)
+func WithCredentials(credsFile string) OptionFunc {
+	return func(opts *options) {
+		opts.credsFile = credsFile
+	}
+}
@@ -24,6 +24,8 @@ func NewFromConfig(config cfacade.ProfileJSON) *Conn {
 	conn.requestTimeout = config.GetDuration("request_timeout", 1) * time.Second
 	conn.user = config.GetString("user")
 	conn.password = config.GetString("password")
+	conn.token = config.GetString("token")
+	conn.credsFile = config.GetString("creds_file")
 
 	if conn.address == "" {
 		panic("address is empty!")
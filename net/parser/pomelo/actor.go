@@ -1,20 +1,26 @@
 package pomelo
 
 import (
+	"context"
 	"net"
+	"sync/atomic"
 	"time"
 
 	ccode "github.com/cherry-game/cherry/code"
+	cnet "github.com/cherry-game/cherry/extend/net"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 	cactor "github.com/cherry-game/cherry/net/actor"
 	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
 	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
 	cproto "github.com/cherry-game/cherry/net/proto"
-	"github.com/nats-io/nuid"
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	idleCheckTime = 1 * time.Second // idle agent check interval
+)
+
 type (
 	actor struct {
 		cactor.Base
@@ -45,9 +51,14 @@ func NewActor(agentActorID string) *actor {
 func (p *actor) OnInit() {
 	p.Remote().Register(ResponseFuncName, p.response)
 	p.Remote().Register(PushFuncName, p.push)
+	p.Remote().Register(PushUIDFuncName, p.pushUID)
+	p.Remote().Register(MultiPushFuncName, p.multiPush)
 	p.Remote().Register(KickFuncName, p.kick)
 	p.Remote().Register(BroadcastName, p.broadcast)
 
+	p.Timer().Add(idleCheckTime, p.checkIdle)
+	p.Timer().Add(loadDuration(&cmd.heartbeatTime), p.checkHeartbeat)
+
 	if p.onInitFunc != nil {
 		p.onInitFunc()
 	}
@@ -63,6 +74,7 @@ func (p *actor) Load(app cfacade.IApplication) {
 	}
 
 	cmd.init(app)
+	cactor.SetHandlerErrorFunc(responseHandlerError)
 
 	//  Create agent actor
 	if _, err := app.ActorSystem().CreateActor(p.agentActorID, p); err != nil {
@@ -85,19 +97,57 @@ func (p *actor) Connectors() []cfacade.IConnector {
 
 // defaultOnConnectFunc 创建新连接时，通过当前agentActor创建child agent actor
 func (p *actor) defaultOnConnectFunc(conn net.Conn) {
+	// the conn accepted before the connectors are stopped
+	if isShuttingDown() {
+		_ = conn.Close()
+		return
+	}
+
+	// closed without the kick packet, the denied ip knows nothing
+	remoteIP := cnet.GetIP(conn.RemoteAddr())
+	if !p.IsIPAllowed(remoteIP) {
+		if clog.PrintLevel(zapcore.DebugLevel) {
+			clog.Debugf("[pomelo] Conn rejected by the ip filter. [ip = %s]", remoteIP)
+		}
+		_ = conn.Close()
+		return
+	}
+
+	if p.IsFull() {
+		rejectConn(p.App().Serializer(), conn, KickReason{Code: KickCodeServerFull, Message: KickServerFull})
+		return
+	}
+
+	limitedIP, ok := acquireIP(remoteIP)
+	if !ok {
+		rejectConn(p.App().Serializer(), conn, KickReason{Code: KickCodeIPLimited, Message: KickIPLimited})
+		return
+	}
+
 	session := &cproto.Session{
-		Sid:       nuid.Next(),
+		Sid:       nextSID(),
 		AgentPath: p.Path().String(),
 		Data:      map[string]string{},
 	}
 
-	agent := NewAgent(p.App(), conn, session)
+	applySocketBuffers(conn)
+
+	agent, err := CreateAgent(p.App(), conn, session)
+	if err != nil {
+		releaseIP(limitedIP)
+		if clog.PrintLevel(zapcore.DebugLevel) {
+			clog.Debugf("[pomelo] Conn rejected by the create listener. [ip = %s]", remoteIP)
+		}
+		return
+	}
+	agent.limitedIP = limitedIP
 
 	if p.onNewAgentFunc != nil {
-		p.onNewAgentFunc(&agent)
+		p.onNewAgentFunc(agent)
 	}
+	fireEvent(EventCreate, agent)
 
-	BindSID(&agent)
+	BindSID(agent)
 	agent.Run()
 }
 
@@ -109,21 +159,187 @@ func (*actor) SetDataCompression(compression bool) {
 	pomeloMessage.SetDataCompression(compression)
 }
 
+// SetCompression compress the data which is larger than minBytes with the codec. see pomeloMessage.RegisterCodec
+// only the clients which send the codec in the handshake get the compressed data, see Agent.CompressionEnabled
+func (*actor) SetCompression(codec string, minBytes int) error {
+	return pomeloMessage.SetCompression(codec, minBytes)
+}
+
+// SetWriteBacklog the size of the send queues of the new agents, the size less than 1 is ignored
 func (*actor) SetWriteBacklog(size int) {
-	cmd.writeBacklog = size
+	if size > 0 {
+		atomic.StoreInt32(&cmd.writeBacklog, int32(size))
+	}
+}
+
+// SetIdleTimeout kick the agent if it has not received message within d. zero is disabled.
+// the agent is refreshed by the inbound data messages and Agent.Touch()
+func (*actor) SetIdleTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	storeDuration(&cmd.idleTimeout, d)
+}
+
+// SetReconnectGrace retain the state of bound agent lost by read error or heartbeat timeout for d. zero is disabled.
+// the agent closed by server(Close, CloseGracefully, Kick, Terminate) is not retained.
+func (*actor) SetReconnectGrace(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	storeDuration(&cmd.reconnectGrace, d)
+}
+
+// SetMessageRate limit the inbound messages of each agent by token bucket. zero perSecond is unlimited.
+func (*actor) SetMessageRate(perSecond int, burst int) {
+	if perSecond < 0 {
+		perSecond = 0
+	}
+	atomic.StoreInt32(&cmd.messageRate, int32(perSecond))
+	atomic.StoreInt32(&cmd.messageBurst, int32(burst))
+}
+
+// SetRateViolation kick the agent after n continuous dropped messages. zero is disabled.
+func (*actor) SetRateViolation(n int) {
+	atomic.StoreInt32(&cmd.rateViolation, int32(n))
+}
+
+// SetOverflowPolicy the policy of the send queue(size is writeBacklog) is full, default is OverflowReject.
+func (*actor) SetOverflowPolicy(policy OverflowPolicy) {
+	cmd.overflowPolicy = policy
+}
+
+// SetBindPolicy the policy of the uid bound on other agent, default is BindPolicyKick.
+func (*actor) SetBindPolicy(policy BindPolicy) {
+	cmd.bindPolicy = policy
+}
+
+// AddOnCreate called after the agent of the new connection is created, before it's bound and running.
+// the connection is closed without the kick packet if fn returns false, eg. ip bans or capacity checks.
+func (*actor) AddOnCreate(fn OnCreateFunc) {
+	if fn != nil {
+		cmd.onCreateFuncs = append(cmd.onCreateFuncs, fn)
+	}
+}
+
+// AddOnBind called after the uid is bound, the bind is rolled back if fn returns false.
+func (*actor) AddOnBind(fn OnBindFunc) {
+	if fn != nil {
+		cmd.onBindFuncs = append(cmd.onBindFuncs, fn)
+	}
+}
+
+// AddOnUnbind called once after the bound agent is unbound.
+func (*actor) AddOnUnbind(fn OnUnbindFunc) {
+	if fn != nil {
+		cmd.onUnbindFuncs = append(cmd.onUnbindFuncs, fn)
+	}
+}
+
+// AddOutboundFilter add the filter of the outbound messages(Push, Response, SendRaw).
+// the filters run in order and the first drop stops the chain. eg. audit, feature flag
+func (*actor) AddOutboundFilter(fn OutboundFilter) {
+	if fn != nil {
+		cmd.outboundFilters = append(cmd.outboundFilters, fn)
+	}
 }
 
-func (*actor) SetHeartbeat(t time.Duration) {
-	if t.Seconds() < 1 {
-		t = 60 * time.Second
+// SetEncryption encrypt the data packets by AES-GCM for the conn without tls(eg. kcp).
+// the client sends the X25519 public key in sys.publicKey of the handshake and gets the server public key in the response,
+// the data packets received before the handshake or failed to decrypt close the agent.
+func (*actor) SetEncryption(enable bool) {
+	cmd.encryption = enable
+}
+
+// Stats returns the snapshot of the agent counters. see Stats
+func (*actor) Stats() SessionStats {
+	return Stats()
+}
+
+// KickByUIDs kick and close the agents bound the uids. see KickByUIDs
+func (*actor) KickByUIDs(uids []cfacade.UID, reason string) int {
+	return KickByUIDs(uids, reason)
+}
+
+// UnbindByUID detach the uid from the agent without closing it. see UnbindUID
+func (*actor) UnbindByUID(uid cfacade.UID) bool {
+	return UnbindUID(uid)
+}
+
+// BroadcastAll push the announcement to all agents matching the filter. see BroadcastAll
+func (*actor) BroadcastAll(route string, v interface{}, filter func(a *Agent) bool) (int, PushError) {
+	return BroadcastAll(route, v, filter)
+}
+
+// SetMaxAsyncCalls the pending RPCAsync calls of each agent, zero is unlimited. default is 64.
+func (*actor) SetMaxAsyncCalls(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&cmd.maxAsyncCalls, int32(n))
+}
+
+// SetMaxSessions the online agents limit of the node, the new connections are rejected with KickServerFull
+// when the limit is reached. zero is unlimited(default), it can be changed at runtime.
+func (*actor) SetMaxSessions(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&cmd.maxSessions, int64(n))
+}
+
+// Use register the middlewares of the local handlers, they're run in the registration order.
+// eg. Use(cactor.RecoverMiddleware(), cactor.TimingMiddleware(time.Second), cactor.RequireBoundSession())
+func (*actor) Use(mw ...cactor.Middleware) {
+	cactor.Use(mw...)
+}
+
+// IsFull returns true if the online agents reach the limit. see SetMaxSessions
+func (*actor) IsFull() bool {
+	maxSessions := atomic.LoadInt64(&cmd.maxSessions)
+	return maxSessions > 0 && int64(Count()) >= maxSessions
+}
+
+// rejectConn send the kick packet with the reason and close the conn, the agent is not created
+func rejectConn(serializer cfacade.ISerializer, conn net.Conn, reason KickReason) {
+	defer conn.Close()
+
+	bytes, err := serializer.Marshal(reason)
+	if err != nil {
+		clog.Warnf("[pomelo] Kick marshal fail. [reason = %+v, err = %s]", reason, err)
+		return
+	}
+
+	pkg, err := ppacket.Encode(ppacket.Kick, bytes)
+	if err != nil {
+		clog.Warnf("[pomelo] Kick packet encode error. [reason = %+v, err = %s]", reason, err)
+		return
+	}
+
+	// eg. websocket sends the reason in the close frame
+	if c, ok := conn.(closeReasoner); ok {
+		c.SetCloseReason(reason.Message)
+	}
+
+	_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+	if _, err = conn.Write(pkg); err != nil {
+		clog.Debugf("[pomelo] Kick write fail. [ip = %s, err = %s]", conn.RemoteAddr(), err)
 	}
-	cmd.heartbeatTime = t
+
+	counters.kick(reason.Message)
+}
+
+// SetShutdownDrain write the queued messages of the agents before they are kicked by Shutdown(). default is false.
+func (*actor) SetShutdownDrain(drain bool) {
+	cmd.shutdownDrain = drain
 }
 
 func (*actor) SetSysData(key string, value interface{}) {
 	cmd.sysData[key] = value
 }
 
+// SetOnNewAgent called after the agent is accepted by the onCreate listeners, it can not reject the connection.
+// see AddOnCreate
 func (p *actor) SetOnNewAgent(fn OnNewAgentFunc) {
 	p.onNewAgentFunc = fn
 }
@@ -148,10 +364,11 @@ func (p *actor) response(rsp *cproto.PomeloResponse) {
 	}
 
 	if ccode.IsOK(rsp.Code) {
-		agent.ResponseMID(rsp.Mid, rsp.Data, false)
+		agent.responseMID(context.Background(), rsp.Mid, rsp.Metadata, rsp.Data, false)
 	} else {
 		errRsp := &cproto.Response{
 			Code: rsp.Code,
+			Data: rsp.Data, // error message
 		}
 		agent.ResponseMID(rsp.Mid, errRsp, true)
 	}
@@ -169,6 +386,38 @@ func (p *actor) push(rsp *cproto.PomeloPush) {
 	agent.Push(rsp.Route, rsp.Data)
 }
 
+// pushUID push to the agent bound the uid, the code is returned to the caller of PushToClient
+func (p *actor) pushUID(rsp *cproto.PomeloPush) int32 {
+	agent, found := GetAgentWithUID(rsp.Uid)
+	if !found {
+		return ccode.SessionUIDNotBind
+	}
+
+	if err := agent.Push(rsp.Route, rsp.Data); err != nil {
+		if clog.PrintLevel(zapcore.DebugLevel) {
+			clog.Debugf("[pushUID] Push fail. [uid = %d, route = %s, err = %v]", rsp.Uid, rsp.Route, err)
+		}
+		return ccode.SessionPushFail
+	}
+
+	return ccode.OK
+}
+
+// multiPush push to the agents bound the uids, the codes of the failed uids are returned to MultiPushToClients
+func (p *actor) multiPush(req *cproto.PomeloBroadcastPush) (*cproto.PomeloPushResult, int32) {
+	result := &cproto.PomeloPushResult{
+		Codes: make(map[int64]int32),
+	}
+
+	for _, uid := range req.UidList {
+		if code := p.pushUID(&cproto.PomeloPush{Uid: uid, Route: req.Route, Data: req.Data}); ccode.IsFail(code) {
+			result.Codes[uid] = code
+		}
+	}
+
+	return result, ccode.OK
+}
+
 func (p *actor) kick(rsp *cproto.PomeloKick) {
 	agent, found := GetAgentWithUID(rsp.Uid)
 	if !found {
@@ -176,7 +425,7 @@ func (p *actor) kick(rsp *cproto.PomeloKick) {
 	}
 
 	if found {
-		agent.Kick(rsp.Reason, rsp.Close)
+		agent.kick(rsp.Reason, rsp.Reason, rsp.Close) // the reason is marshaled by the sender
 	}
 }
 
@@ -195,3 +444,21 @@ func (p *actor) broadcast(rsp *cproto.PomeloBroadcastPush) {
 		}
 	}
 }
+
+func (p *actor) checkIdle() {
+	idleTimeout := loadDuration(&cmd.idleTimeout)
+	if idleTimeout <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(-idleTimeout).UnixMilli()
+	for _, agent := range IdleAgents(deadline) {
+		if agent.PrintLevel(zapcore.DebugLevel) {
+			agent.Debugf("Agent idle timeout.")
+		}
+		agent.KickWith(KickReason{
+			Code:    KickCodeIdleTimeout,
+			Message: KickIdleTimeout,
+		}, true)
+	}
+}
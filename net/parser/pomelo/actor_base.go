@@ -1,6 +1,10 @@
 package pomelo
 
 import (
+	"sync"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 	cactor "github.com/cherry-game/cherry/net/actor"
@@ -8,32 +12,65 @@ import (
 )
 
 const (
-	ResponseFuncName = "response"
-	PushFuncName     = "push"
-	KickFuncName     = "kick"
-	BroadcastName    = "broadcast"
+	ResponseFuncName  = "response"
+	PushFuncName      = "push"
+	PushUIDFuncName   = "pushUID"
+	MultiPushFuncName = "multiPush"
+	KickFuncName      = "kick"
+	BroadcastName     = "broadcast"
 )
 
-type ActorBase struct {
-	cactor.Base
-}
+type (
+	ActorBase struct {
+		cactor.Base
+	}
+
+	// ClientTarget the uid connected to the frontend, see MultiPushToClients
+	ClientTarget struct {
+		AgentPath string      // the path of the agent actor on the frontend node, eg. session.AgentPath
+		UID       cfacade.UID // the bound uid
+	}
+)
 
 func (p *ActorBase) Response(session *cproto.Session, v interface{}) {
 	Response(p, session.AgentPath, session.Sid, session.Mid, v)
 }
 
+// ResponseMetadata response the message with the metadata(header)
+func (p *ActorBase) ResponseMetadata(session *cproto.Session, v interface{}, metadata map[string]string) {
+	ResponseMetadata(p, session.AgentPath, session.Sid, session.Mid, v, metadata)
+}
+
 func (p *ActorBase) ResponseCode(session *cproto.Session, statusCode int32) {
 	ResponseCode(p, session.AgentPath, session.Sid, session.Mid, statusCode)
 }
 
+func (p *ActorBase) ResponseError(session *cproto.Session, code int32, msg string) {
+	ResponseError(p, session.AgentPath, session.Sid, session.Mid, code, msg)
+}
+
 func (p *ActorBase) Push(session *cproto.Session, route string, v interface{}) {
 	Push(p, session.AgentPath, session.Sid, route, v)
 }
 
+// PushToClient push to the uid connected to the frontend agent actor, see PushToClient
+func (p *ActorBase) PushToClient(agentPath string, uid cfacade.UID, route string, v interface{}) error {
+	return PushToClient(p, agentPath, uid, route, v)
+}
+
+// MultiPushToClients push to the uids connected to the frontends, see MultiPushToClients
+func (p *ActorBase) MultiPushToClients(targets []ClientTarget, route string, v interface{}) map[cfacade.UID]error {
+	return MultiPushToClients(p, targets, route, v)
+}
+
 func (p *ActorBase) Kick(session *cproto.Session, reason interface{}, closed bool) {
 	Kick(p, session.AgentPath, session.Sid, reason, closed)
 }
 
+func (p *ActorBase) KickWith(session *cproto.Session, reason KickReason, closed bool) {
+	KickWith(p, session.AgentPath, session.Sid, reason, closed)
+}
+
 func (p *ActorBase) Broadcast(agentPath string, uidList []int64, allUID bool, route string, v interface{}) {
 	Broadcast(p, agentPath, uidList, allUID, route, v)
 }
@@ -54,6 +91,24 @@ func Response(iActor cfacade.IActor, agentPath, sid string, mid uint32, v interf
 	iActor.Call(agentPath, ResponseFuncName, rsp)
 }
 
+// ResponseMetadata response the message with the metadata(header) to the client
+func ResponseMetadata(iActor cfacade.IActor, agentPath, sid string, mid uint32, v interface{}, metadata map[string]string) {
+	data, err := iActor.App().Serializer().Marshal(v)
+	if err != nil {
+		clog.Warnf("[ResponseMetadata] Marshal error. v = %+v", v)
+		return
+	}
+
+	rsp := &cproto.PomeloResponse{
+		Sid:      sid,
+		Mid:      mid,
+		Data:     data,
+		Metadata: metadata,
+	}
+
+	iActor.Call(agentPath, ResponseFuncName, rsp)
+}
+
 func ResponseCode(iActor cfacade.IActor, agentPath, sid string, mid uint32, statusCode int32) {
 	rsp := &cproto.PomeloResponse{
 		Sid:  sid,
@@ -64,6 +119,58 @@ func ResponseCode(iActor cfacade.IActor, agentPath, sid string, mid uint32, stat
 	iActor.Call(agentPath, ResponseFuncName, rsp)
 }
 
+// ResponseError response the error code and message, the client receives cproto.Response{Code: code, Data: []byte(msg)}
+func ResponseError(iActor cfacade.IActor, agentPath, sid string, mid uint32, code int32, msg string) {
+	rsp := &cproto.PomeloResponse{
+		Sid:  sid,
+		Mid:  mid,
+		Code: code,
+		Data: []byte(msg),
+	}
+
+	iActor.Call(agentPath, ResponseFuncName, rsp)
+}
+
+// responseHandlerError response the error code(see cerror.WithCode) and message of the local handler to the client.
+// the notify message(mid = 0) has no response, the error is logged.
+// the handler panic(ccode.HandlerPanic) is handled by the panic policy. see actor.SetPanicPolicy
+func responseHandlerError(app cfacade.IApplication, m *cfacade.Message, err error) {
+	code, found := cerr.Code(err)
+	if !found || ccode.IsOK(code) {
+		code = ccode.HandlerError
+	}
+
+	data := []byte(err.Error())
+	if code == ccode.HandlerPanic && m.Session != nil && m.Session.AgentPath != "" {
+		// kicked after the response
+		defer kickHandlerPanic(app, m)
+
+		if !cmd.panicResponse {
+			return
+		}
+		data = []byte(panicMessage)
+	}
+
+	if m.Session == nil || m.Session.AgentPath == "" || m.Session.Mid == 0 {
+		clog.Warnf("[InvokeLocalFunc] handler error. [source = %s, target = %s -> %s, err = %v]",
+			m.Source,
+			m.Target,
+			m.FuncName,
+			err,
+		)
+		return
+	}
+
+	rsp := &cproto.PomeloResponse{
+		Sid:  m.Session.Sid,
+		Mid:  m.Session.Mid,
+		Code: code,
+		Data: data,
+	}
+
+	app.ActorSystem().Call(m.Target, m.Session.AgentPath, ResponseFuncName, rsp)
+}
+
 func Push(iActor cfacade.IActor, agentPath, sid, route string, v interface{}) {
 	if route == "" {
 		clog.Warn("[Push] route value error.")
@@ -85,7 +192,114 @@ func Push(iActor cfacade.IActor, agentPath, sid, route string, v interface{}) {
 	iActor.Call(agentPath, PushFuncName, rsp)
 }
 
+// PushToClient push to the uid connected to the frontend, it waits for the result of the frontend.
+// agentPath is the path of the agent actor on the frontend node(eg. session.AgentPath, "gate-1.user").
+// returns the error with code(see cerr.Code), eg. ccode.DiscoveryNotFoundNode or ccode.RPCNetError if the frontend
+// is unreachable, ccode.SessionUIDNotBind if the uid is not connected to the frontend.
+func PushToClient(iActor cfacade.IActor, agentPath string, uid cfacade.UID, route string, v interface{}) error {
+	if !cfacade.IsValidUID(uid) {
+		return cerr.WithCode(cerr.Errorf("[PushToClient] uid value error. [uid = %d]", uid), ccode.SessionUIDNotBind)
+	}
+
+	if route == "" {
+		return cerr.Error("[PushToClient] route value error.")
+	}
+
+	data, err := iActor.App().Serializer().Marshal(v)
+	if err != nil {
+		return cerr.WithCode(err, ccode.ActorMarshalError)
+	}
+
+	req := &cproto.PomeloPush{
+		Uid:   uid,
+		Route: route,
+		Data:  data,
+	}
+
+	return cactor.CodeError(iActor.CallWait(agentPath, PushUIDFuncName, req, nil))
+}
+
+// MultiPushToClients push to the uids connected to the frontends, the message is marshaled once and
+// one call is sent to each frontend, the frontend pushes to its agents. the frontends are called in parallel.
+// returns the errors of the failed uids with code(see PushToClient), empty if all uids are pushed.
+func MultiPushToClients(iActor cfacade.IActor, targets []ClientTarget, route string, v interface{}) map[cfacade.UID]error {
+	errs := make(map[cfacade.UID]error)
+	if len(targets) < 1 {
+		return errs
+	}
+
+	if route == "" {
+		err := cerr.Error("[MultiPushToClients] route value error.")
+		for _, target := range targets {
+			errs[target.UID] = err
+		}
+		return errs
+	}
+
+	data, err := iActor.App().Serializer().Marshal(v)
+	if err != nil {
+		err = cerr.WithCode(err, ccode.ActorMarshalError)
+		for _, target := range targets {
+			errs[target.UID] = err
+		}
+		return errs
+	}
+
+	frontends := make(map[string][]int64)
+	for _, target := range targets {
+		if !cfacade.IsValidUID(target.UID) {
+			errs[target.UID] = cerr.WithCode(cerr.Errorf("[MultiPushToClients] uid value error. [uid = %d]", target.UID), ccode.SessionUIDNotBind)
+			continue
+		}
+		frontends[target.AgentPath] = append(frontends[target.AgentPath], target.UID)
+	}
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+
+	for agentPath, uidList := range frontends {
+		wg.Add(1)
+		go func(agentPath string, uidList []int64) {
+			defer wg.Done()
+
+			req := &cproto.PomeloBroadcastPush{
+				UidList: uidList,
+				Route:   route,
+				Data:    data,
+			}
+
+			result := &cproto.PomeloPushResult{}
+			code := iActor.CallWait(agentPath, MultiPushFuncName, req, result)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			// the frontend is unreachable, all uids are failed
+			if ccode.IsFail(code) {
+				for _, uid := range uidList {
+					errs[uid] = cactor.CodeError(code)
+				}
+				return
+			}
+
+			for uid, code := range result.Codes {
+				errs[uid] = cactor.CodeError(code)
+			}
+		}(agentPath, uidList)
+	}
+
+	wg.Wait()
+	return errs
+}
+
 func Kick(iActor cfacade.IActor, agentPath, sid string, reason interface{}, closed bool) {
+	if message, ok := reason.(string); ok {
+		KickWith(iActor, agentPath, sid, KickReason{Code: KickCodeDefault, Message: message}, closed)
+		return
+	}
+
 	data, err := iActor.App().Serializer().Marshal(reason)
 	if err != nil {
 		clog.Warnf("[Kick] Marshal error. reason = %+v", reason)
@@ -101,6 +315,22 @@ func Kick(iActor cfacade.IActor, agentPath, sid string, reason interface{}, clos
 	iActor.Call(agentPath, KickFuncName, rsp)
 }
 
+func KickWith(iActor cfacade.IActor, agentPath, sid string, reason KickReason, closed bool) {
+	data, err := iActor.App().Serializer().Marshal(&reason)
+	if err != nil {
+		clog.Warnf("[KickWith] Marshal error. reason = %+v", reason)
+		return
+	}
+
+	rsp := &cproto.PomeloKick{
+		Sid:    sid,
+		Reason: data,
+		Close:  closed,
+	}
+
+	iActor.Call(agentPath, KickFuncName, rsp)
+}
+
 func Broadcast(iActor cfacade.IActor, agentPath string, uidList []int64, allUID bool, route string, v interface{}) {
 	if !allUID && len(uidList) < 1 {
 		clog.Warn("[Broadcast] uidList value error.")
@@ -0,0 +1,66 @@
+package pomelo
+
+import (
+	"testing"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type (
+	testSystem struct {
+		cfacade.IActorSystem
+		calls []*cproto.PomeloResponse
+	}
+
+	testSystemApp struct {
+		testApp
+		system *testSystem
+	}
+)
+
+func (p *testSystem) Call(_, _, funcName string, arg interface{}) int32 {
+	if rsp, ok := arg.(*cproto.PomeloResponse); ok && funcName == ResponseFuncName {
+		p.calls = append(p.calls, rsp)
+	}
+	return ccode.OK
+}
+
+func (p testSystemApp) ActorSystem() cfacade.IActorSystem {
+	return p.system
+}
+
+func TestResponseHandlerError(t *testing.T) {
+	app := testSystemApp{system: &testSystem{}}
+
+	m := &cfacade.Message{
+		Target: ".game",
+		Session: &cproto.Session{
+			Sid:       "1",
+			Mid:       10,
+			AgentPath: ".gate",
+		},
+	}
+
+	responseHandlerError(app, m, cerr.WithCode(cerr.Error("no gold"), 20))
+	responseHandlerError(app, m, cerr.Error("unknown"))
+
+	// notify message has no response
+	m.Session.Mid = 0
+	responseHandlerError(app, m, cerr.Error("notify"))
+
+	calls := app.system.calls
+	if len(calls) != 2 {
+		t.Fatalf("response num = %d", len(calls))
+	}
+
+	if calls[0].Code != 20 || calls[0].Mid != 10 || string(calls[0].Data) != "no gold" {
+		t.Fatalf("response = %v", calls[0])
+	}
+
+	if calls[1].Code != ccode.HandlerError {
+		t.Fatalf("response code = %d", calls[1].Code)
+	}
+}
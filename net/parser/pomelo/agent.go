@@ -1,11 +1,14 @@
 package pomelo
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"sync/atomic"
 	"time"
 
+	cerr "github.com/cherry-game/cherry/error"
 	cnet "github.com/cherry-game/cherry/extend/net"
 	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
@@ -23,6 +26,39 @@ const (
 	AgentClosed  int32 = 3
 )
 
+const (
+	KickIdleTimeout   = "idle_timeout"     // kick reason of idle timeout
+	KickAnotherLogin  = "another_login"    // kick reason of the uid bound on other agent
+	KickRateLimited   = "rate_limited"     // kick reason of message rate limited
+	KickSendQueueFull = "send_queue_full"  // kick reason of the send queue is full
+	KickShutdown      = "server_shutdown"  // kick reason of the server shutdown
+	KickServerFull    = "server_full"      // kick reason of the new connection rejected by the session limit
+	KickIPLimited     = "ip_limited"       // kick reason of the new connection rejected by the session limit of the ip
+	KickHandlerPanic  = "handler_panic"    // kick reason of the handler panic, see PanicPolicyKick
+	KickMessageSize   = "message_size"     // kick reason of the repeated oversized messages, see actor.SetMessageSizeViolation
+	KickMalformed     = "malformed_packet" // kick reason of the malformed packets, see actor.SetMaxMalformedPackets
+	KickSlowConsumer  = "slow_consumer"    // kick reason of the saturated agent, see BroadcastOpts.KickSaturated
+	KickRecvQueueFull = "recv_queue_full"  // kick reason of the parked messages of the paused agent are full, see Agent.PauseRecv
+	KickHeartbeat     = "heartbeat_missed" // kick reason of the missed heartbeats, see actor.SetHeartbeat
+)
+
+const (
+	KickCodeDefault       int32 = 0  // kick code of the string reason
+	KickCodeIdleTimeout   int32 = 1  // kick code of idle timeout
+	KickCodeAnotherLogin  int32 = 2  // kick code of the uid bound on other agent
+	KickCodeRateLimited   int32 = 3  // kick code of message rate limited
+	KickCodeSendQueueFull int32 = 4  // kick code of the send queue is full
+	KickCodeShutdown      int32 = 5  // kick code of the server shutdown
+	KickCodeServerFull    int32 = 6  // kick code of the new connection rejected by the session limit
+	KickCodeIPLimited     int32 = 7  // kick code of the new connection rejected by the session limit of the ip
+	KickCodeHandlerPanic  int32 = 8  // kick code of the handler panic
+	KickCodeMessageSize   int32 = 9  // kick code of the repeated oversized messages
+	KickCodeMalformed     int32 = 10 // kick code of the malformed packets
+	KickCodeSlowConsumer  int32 = 11 // kick code of the saturated agent
+	KickCodeRecvQueueFull int32 = 12 // kick code of the parked messages of the paused agent are full
+	KickCodeHeartbeat     int32 = 13 // kick code of the missed heartbeats
+)
+
 type (
 	Agent struct {
 		cfacade.IApplication                      // app
@@ -32,19 +68,70 @@ type (
 		chDie                chan struct{}        // wait for close
 		chPending            chan *pendingMessage // push message queue
 		chWrite              chan []byte          // push bytes queue
+		chFlush              chan chan struct{}   // flush request, see CloseGracefully()
 		lastAt               int64                // last heartbeat unix time stamp
+		lastActiveAt         int64                // last received message unix milli time stamp
+		lastHeartbeat        int64                // last received heartbeat packet unix milli time stamp
+		reconnectToken       string               // token for reconnect, generated on bind
+		kicked               int32                // closed by kick
+		terminated           int32                // closed by Terminate, the state is never retained
+		closeCause           atomic.Value         // *closeState, see CloseCause()
+		lost                 int32                // connection lost by read error or heartbeat timeout, see retain()
+		draining             int32                // stop accepting new messages, see CloseGracefully()
 		onCloseFunc          []OnCloseFunc        // on close agent
+		logger               atomic.Value         // loggerHolder, see SetLogger()
+		entry                atomic.Value         // loggerHolder, cached logger with the agent fields
+		limiter              *rateLimiter         // inbound message rate limiter
+		pendingBytes         int64                // bytes in chWrite
+		cipher               atomic.Value         // *sessionCipher, see IsEncrypted()
+		codec                atomic.Value         // string, the codec negotiated in the handshake, see NegotiatedCodec()
+		asyncCalls           int32                // pending RPCAsync calls
+		limitedIP            string               // the ip counted by the session limit of the ip, see acquireIP()
+		ctx                  *agentContext        // canceled on close, see Context()
+		delivery             *deliveryState       // sequence state of the messages, see actor.SetDeliveryMode
+		dedup                *requestDedup        // recent request ids, see actor.SetRequestDedup
+		ping                 *pingState           // in-flight ping and rtt, see Ping()
+		prio                 *priorityQueue       // the high and low priority messages, see PushPriority()
+		watchdog             *responseWatchdog    // the requests waiting for the response, see actor.SetResponseTimeout
+		recvPause            *recvPause           // the parked data packets, see PauseRecv()
+		writeDeadline        int64                // unix nano of the deadline set by SetWriteDeadline, see write()
+		oversized            int32                // oversized inbound messages, see actor.SetMaxMessageSize
+		malformed            int32                // malformed inbound packets, see actor.SetMaxMalformedPackets
+		closed               int32                // Close() is run once
+		processed            int32                // closeProcess() is run once
+		fired                int32                // the onClose listeners are fired once
 	}
 
 	pendingMessage struct {
-		typ     pomeloMessage.Type // message type
-		route   string             // message route(push)
-		mid     uint               // response message id(response)
-		payload interface{}        // payload
-		err     bool               // if it's an error
+		typ      pomeloMessage.Type // message type
+		route    string             // message route(push)
+		mid      uint               // response message id(response)
+		header   map[string]string  // message header(response metadata)
+		payload  interface{}        // payload
+		err      bool               // if it's an error
+		ctx      context.Context    // abandon the write if ctx is done
+		priority int                // see PushPriority
+		pkg      []byte             // the encoded packet shared by the agents, see BroadcastAll
 	}
 
 	OnCloseFunc func(*Agent)
+
+	// connFlusher the conn with the write buffer. eg. bufio
+	connFlusher interface {
+		Flush() error
+	}
+
+	// closeReasoner the conn sends the reason when it's closed. see cherryConnector.WSConn
+	closeReasoner interface {
+		SetCloseReason(reason string)
+	}
+
+	// KickReason the machine-readable kick reason, it's marshaled by the app serializer into the kick packet
+	KickReason struct {
+		Code    int32                  `json:"code"`           // kick code. eg. banned, another login, maintenance
+		Message string                 `json:"message"`        // kick message
+		Data    map[string]interface{} `json:"data,omitempty"` // extra data
+	}
 )
 
 func NewAgent(app cfacade.IApplication, conn net.Conn, session *cproto.Session) Agent {
@@ -54,34 +141,69 @@ func NewAgent(app cfacade.IApplication, conn net.Conn, session *cproto.Session)
 		state:        AgentInit,
 		session:      session,
 		chDie:        make(chan struct{}),
-		chPending:    make(chan *pendingMessage, cmd.writeBacklog),
-		chWrite:      make(chan []byte, cmd.writeBacklog),
+		chPending:    make(chan *pendingMessage, atomic.LoadInt32(&cmd.writeBacklog)),
+		chWrite:      make(chan []byte, atomic.LoadInt32(&cmd.writeBacklog)),
+		chFlush:      make(chan chan struct{}),
+		limiter:      newRateLimiter(),
+		ctx:          &agentContext{},
+		delivery:     newDeliveryState(),
+		dedup:        newRequestDedup(),
+		ping:         newPingState(),
+		prio:         newPriorityQueue(),
+		watchdog:     newResponseWatchdog(),
+		recvPause:    &recvPause{},
 		lastAt:       0,
 		onCloseFunc:  nil,
 	}
 
-	agent.session.Ip = agent.RemoteAddr()
+	agent.session.Ip = agent.RemoteAddress()
 	agent.SetLastAt()
+	agent.SetLastActiveAt()
 
-	if clog.PrintLevel(zapcore.DebugLevel) {
-		clog.Debugf("[sid = %s,uid = %d] Agent create. [count = %d, ip = %s]",
-			agent.SID(),
-			agent.UID(),
+	if agent.PrintLevel(zapcore.DebugLevel) {
+		agent.Debugf("Agent create. [count = %d, ip = %s]",
 			Count(),
-			agent.RemoteAddr(),
+			agent.RemoteAddress(),
 		)
 	}
 
 	return agent
 }
 
+// CreateAgent returns the new agent accepted by the onCreate listeners, see actor.AddOnCreate.
+// the rejected agent is never bound and running, the conn is closed and the onClose listeners are not fired,
+// returns cerr.SessionCreateRejected. the custom connect func should use it instead of NewAgent,
+// NewAgent does not call the listeners.
+func CreateAgent(app cfacade.IApplication, conn net.Conn, session *cproto.Session) (*Agent, error) {
+	agent := NewAgent(app, conn, session)
+	if fireOnCreate(&agent) {
+		return &agent, nil
+	}
+
+	agent.cancelContext()
+	if err := conn.Close(); err != nil {
+		agent.Debugf("Rejected conn close fail. [error = %s]", err)
+	}
+
+	return nil, cerr.SessionCreateRejected
+}
+
 func (a *Agent) State() int32 {
-	return a.state
+	return atomic.LoadInt32(&a.state)
 }
 
+// SetState returns true if the state is changed, the closed agent can not be changed to other state
 func (a *Agent) SetState(state int32) bool {
-	oldValue := atomic.SwapInt32(&a.state, state)
-	return oldValue != state
+	for {
+		oldValue := atomic.LoadInt32(&a.state)
+		if oldValue == AgentClosed && state != AgentClosed {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt32(&a.state, oldValue, state) {
+			return oldValue != state
+		}
+	}
 }
 
 func (a *Agent) Session() *cproto.Session {
@@ -96,12 +218,51 @@ func (a *Agent) SID() cfacade.SID {
 	return a.session.Sid
 }
 
+// Bind bind the uid, returns cerr.SessionAlreadyBound if the agent has bound. see Rebind
 func (a *Agent) Bind(uid cfacade.UID) error {
 	return BindUID(a.SID(), uid)
 }
 
+// BindWithData bind the uid and set the data of the session atomically, see BindUIDWithData
+func (a *Agent) BindWithData(uid cfacade.UID, data map[string]interface{}) error {
+	return BindUIDWithData(a.SID(), uid, data)
+}
+
+// Rebind change the bound uid
+func (a *Agent) Rebind(uid cfacade.UID) error {
+	return RebindUID(a.SID(), uid)
+}
+
+// ReconnectToken returns the token used by Reconnect(). it's generated on bind.
+func (a *Agent) ReconnectToken() string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return a.reconnectToken
+}
+
+// IsClosed returns true once Close() is called, the send methods of the closed agent return cerr.SessionClosed
+func (a *Agent) IsClosed() bool {
+	return atomic.LoadInt32(&a.closed) == 1 || a.State() == AgentClosed
+}
+
+func (a *Agent) isKicked() bool {
+	return atomic.LoadInt32(&a.kicked) == 1
+}
+
+// lose mark the connection is lost by the client. the agent closed by server is not marked.
+func (a *Agent) lose() {
+	if !a.IsClosed() {
+		atomic.StoreInt32(&a.lost, 1)
+	}
+}
+
+func (a *Agent) isLost() bool {
+	return atomic.LoadInt32(&a.lost) == 1
+}
+
 func (a *Agent) IsBind() bool {
-	return a.session.Uid > 0
+	return cfacade.IsValidUID(a.session.Uid)
 }
 
 func (a *Agent) Unbind() {
@@ -112,49 +273,244 @@ func (a *Agent) SetLastAt() {
 	atomic.StoreInt64(&a.lastAt, time.Now().Unix())
 }
 
-func (a *Agent) SendRaw(bytes []byte) {
-	a.chWrite <- bytes
+// SetLastActiveAt updated when a data message is received
+func (a *Agent) SetLastActiveAt() {
+	atomic.StoreInt64(&a.lastActiveAt, time.Now().UnixMilli())
+}
+
+func (a *Agent) LastActiveAt() int64 {
+	return atomic.LoadInt64(&a.lastActiveAt)
+}
+
+// Touch refresh the last active time, the agent is not kicked by the idle timeout during the long operations.
+// eg. matchmaking. the inbound data messages already touch the agent. it's no-op if the agent is closed
+func (a *Agent) Touch() {
+	if a.IsClosed() {
+		return
+	}
+	a.SetLastActiveAt()
+}
+
+// Heartbeat updated when a heartbeat packet is received
+func (a *Agent) Heartbeat() {
+	atomic.StoreInt64(&a.lastHeartbeat, time.Now().UnixMilli())
+}
+
+// LastHeartbeat returns the time of the last received heartbeat packet.
+// zero time if the client has not sent heartbeat yet.
+func (a *Agent) LastHeartbeat() time.Time {
+	lastHeartbeat := atomic.LoadInt64(&a.lastHeartbeat)
+	if lastHeartbeat == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(lastHeartbeat)
+}
+
+// SinceHeartbeat returns the time elapsed since the last heartbeat(or last active message)
+func (a *Agent) SinceHeartbeat() time.Duration {
+	lastHeartbeat := atomic.LoadInt64(&a.lastHeartbeat)
+	if lastHeartbeat == 0 {
+		lastHeartbeat = a.LastActiveAt()
+	}
+	return time.Since(time.UnixMilli(lastHeartbeat))
+}
+
+// SendRaw queue the bytes without blocking, returns cerr.SessionSendQueueFull if the queue is full. see OverflowPolicy
+// the bytes are passed to the outbound filters with empty route.
+func (a *Agent) SendRaw(bytes []byte) error {
+	if a.IsClosed() {
+		return cerr.SessionClosed
+	}
+
+	bytes, ok := a.filterOutbound("", bytes)
+	if !ok {
+		return nil
+	}
+
+	return a.sendBytes(bytes)
+}
+
+// sendBytes queue the bytes without the outbound filters
+func (a *Agent) sendBytes(bytes []byte) error {
+	if a.IsClosed() || a.isDraining() {
+		return cerr.SessionClosed
+	}
+
+	return a.queueRaw(bytes)
 }
 
-func (a *Agent) SendPacket(typ pomeloPacket.Type, data []byte) {
+// filterOutbound run the outbound filters in order, returns false if the message is dropped
+func (a *Agent) filterOutbound(route string, payload []byte) ([]byte, bool) {
+	for _, filter := range cmd.outboundFilters {
+		var ok bool
+		if payload, ok = filter(a, route, payload); !ok {
+			if a.PrintLevel(zapcore.DebugLevel) {
+				a.Debugf("Outbound message dropped by filter. [route = %s]", route)
+			}
+			return nil, false
+		}
+	}
+
+	return payload, true
+}
+
+func (a *Agent) queueRaw(bytes []byte) error {
+	// added before enqueue, so the counter is not negative when the write goroutine dequeue it
+	a.addPendingBytes(len(bytes))
+
+	err := enqueue(a, a.chWrite, bytes, func(drop []byte) {
+		a.addPendingBytes(-len(drop))
+	})
+
+	if err != nil {
+		a.addPendingBytes(-len(bytes))
+	}
+
+	return err
+}
+
+func (a *Agent) SendPacket(typ pomeloPacket.Type, data []byte) error {
 	pkg, err := pomeloPacket.Encode(typ, data)
 	if err != nil {
 		clog.Warn(err)
-		return
+		return err
 	}
-	a.SendRaw(pkg)
+	return a.SendRaw(pkg)
 }
 
 func (a *Agent) Close() {
-	if a.SetState(AgentClosed) {
-		select {
-		case <-a.chDie:
-		default:
-			close(a.chDie)
-		}
+	// it's safe to be called by many goroutines. eg. kick and read error
+	if !atomic.CompareAndSwapInt32(&a.closed, 0, 1) {
+		return
+	}
+
+	a.SetState(AgentClosed)
+	close(a.chDie)
+	a.cancelContext()
+}
+
+// CloseGracefully stop accepting new messages, wait for the queued messages are written and close the agent.
+// returns cerr.SessionDrainTimeout if the queue can not be drained in timeout, the agent is closed anyway.
+func (a *Agent) CloseGracefully(timeout time.Duration) error {
+	if a.IsClosed() {
+		return cerr.SessionClosed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	defer a.Close()
+
+	return a.drain(ctx)
+}
+
+// drain stop accepting new messages and wait for the queued messages are written,
+// returns cerr.SessionDrainTimeout if ctx is done before the queue is drained
+func (a *Agent) drain(ctx context.Context) error {
+	atomic.StoreInt32(&a.draining, 1)
+
+	err := a.flush(ctx)
+	if err != nil && err != cerr.SessionClosed {
+		return cerr.SessionDrainTimeout
+	}
+	return err
+}
+
+// flush wait for the queued messages are written by the write goroutine,
+// returns ctx.Err() if ctx is done before the queue is flushed
+func (a *Agent) flush(ctx context.Context) error {
+	flush := make(chan struct{})
+
+	select {
+	case a.chFlush <- flush:
+	case <-a.chDie:
+		return cerr.SessionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flush:
+		return nil
+	case <-a.chDie:
+		return cerr.SessionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush wait for the queued messages are written to the conn, the buffered conn is flushed.
+// the messages are written by the write goroutine as soon as possible, call it only if the caller
+// must know the messages are sent. eg. before the conn is closed. it's no-op if the agent is closed.
+func (a *Agent) Flush() error {
+	if a.IsClosed() {
+		return nil
+	}
+
+	if err := a.flush(context.Background()); err != nil && err != cerr.SessionClosed {
+		return err
+	}
+	return nil
+}
+
+// PushFlush push the message and wait for it's written to the conn, see Flush
+func (a *Agent) PushFlush(route string, val interface{}) error {
+	if err := a.Push(route, val); err != nil {
+		return err
+	}
+
+	return a.Flush()
+}
+
+// flushConn flush the buffered conn, it's called by the write goroutine
+func (a *Agent) flushConn() {
+	flusher, ok := a.conn.(connFlusher)
+	if !ok {
+		return
+	}
+
+	if err := flusher.Flush(); err != nil {
+		a.Debugf("Conn flush fail. [err = %v]", err)
 	}
 }
 
+func (a *Agent) isDraining() bool {
+	return atomic.LoadInt32(&a.draining) == 1
+}
+
 func (a *Agent) Run() {
+	a.watchHandshake()
 	go a.writeChan()
 	go a.readChan()
 }
 
 func (a *Agent) readChan() {
 	defer func() {
-		if clog.PrintLevel(zapcore.DebugLevel) {
-			clog.Debugf("[sid = %s,uid = %d] Agent read chan exit.",
-				a.SID(),
-				a.UID(),
-			)
+		if a.PrintLevel(zapcore.DebugLevel) {
+			a.Debugf("Agent read chan exit.")
 		}
 
 		a.Close()
 	}()
 
+	conn := a.readConn()
+
 	for {
-		packets, isBreak, err := pomeloPacket.Read(a.conn)
-		if isBreak || err != nil {
+		packets, isBreak, err := pomeloPacket.Read(conn)
+		if unframed(err) {
+			a.malformedPacket(err, nil)
+			return
+		}
+
+		if isBreak {
+			a.lose()
+			a.setCloseCause(readCause(err), err)
+			return
+		}
+
+		if err != nil {
+			if a.malformedPacket(err, nil) {
+				continue
+			}
 			return
 		}
 
@@ -162,22 +518,31 @@ func (a *Agent) readChan() {
 			continue
 		}
 
+		// the packets in process are waited by Shutdown()
+		atomic.AddInt64(&inflightPackets, 1)
+		if pool := cmd.dispatchPool; pool != nil {
+			pool.dispatch(a, packets)
+			continue
+		}
+
 		for _, packet := range packets {
 			a.processPacket(packet)
 		}
+		atomic.AddInt64(&inflightPackets, -1)
 	}
 }
 
 func (a *Agent) writeChan() {
-	ticker := time.NewTicker(cmd.heartbeatTime)
+	heartbeatTime := loadDuration(&cmd.heartbeatTime)
+	ticker := time.NewTicker(heartbeatTime)
 	defer func() {
-		if clog.PrintLevel(zapcore.DebugLevel) {
-			clog.Debugf("[sid = %s,uid = %d] Agent write chan exit.", a.SID(), a.UID())
+		if a.PrintLevel(zapcore.DebugLevel) {
+			a.Debugf("Agent write chan exit.")
 		}
 
 		ticker.Stop()
-		a.closeProcess()
 		a.Close()
+		a.closeProcess()
 	}()
 
 	var lastAt, deadline int64
@@ -190,12 +555,19 @@ func (a *Agent) writeChan() {
 			}
 		case <-ticker.C:
 			{
+				// the missed heartbeats of the working agent are checked by the actor, see actor.SetHeartbeat
+				if atomic.LoadInt32(&cmd.heartbeatMissed) > 0 && a.State() == AgentWorking {
+					continue
+				}
+
 				lastAt = atomic.LoadInt64(&a.lastAt)
-				deadline = time.Now().Add(-cmd.heartbeatTime).Unix()
+				deadline = time.Now().Add(-heartbeatTime).Unix()
 				if lastAt < deadline {
-					if clog.PrintLevel(zapcore.DebugLevel) {
-						clog.Debugf("[sid = %s,uid = %d] Check heartbeat timeout.", a.SID(), a.UID())
+					if a.PrintLevel(zapcore.DebugLevel) {
+						a.Debugf("Check heartbeat timeout.")
 					}
+					a.lose()
+					a.setCloseCause(CauseHeartbeatTimeout, nil)
 					return
 				}
 			}
@@ -205,61 +577,126 @@ func (a *Agent) writeChan() {
 			}
 		case bytes := <-a.chWrite:
 			{
+				a.addPendingBytes(-len(bytes))
 				a.write(bytes)
 			}
+		case <-a.prio.notify:
+			{
+				a.drainPriority(false)
+			}
+		case flush := <-a.chFlush:
+			{
+				a.drainPriority(false)
+				a.drainWrite()
+				a.drainPending()
+				a.drainPriority(true)
+				a.drainWrite()
+				a.flushConn()
+				close(flush)
+			}
 		}
+
+		// the high priority messages are ahead of the next queued message
+		a.drainPriority(false)
 	}
 }
 
 func (a *Agent) closeProcess() {
-	cutils.Try(func() {
-		for _, fn := range a.onCloseFunc {
-			fn(a)
-		}
-	}, func(errString string) {
-		clog.Warn(errString)
-	})
+	if !atomic.CompareAndSwapInt32(&a.processed, 0, 1) {
+		return
+	}
+
+	atomic.AddInt64(&counters.closed, 1)
+	releaseIP(a.limitedIP)
 
+	// only the resumable close is retained, the onClose listeners are fired after the reconnect grace
+	if !a.resumable() || !retain(a) {
+		a.fireOnClose()
+	}
+
+	// the retained state holds a copy of the expire times
+	a.session.ReleaseExpires()
+	a.watchdog.stop()
+	leaveGroups(a.SID())
+	leaveTopics(a.SID())
+	leaveTags(a.SID())
 	a.Unbind()
 
 	if err := a.conn.Close(); err != nil {
-		clog.Debugf("[sid = %s,uid = %d] Agent connect closed. [error = %s]",
-			a.SID(),
-			a.UID(),
+		a.Debugf("Agent connect closed. [error = %s]",
 			err,
 		)
 	}
 
-	if clog.PrintLevel(zapcore.DebugLevel) {
-		clog.Debugf("[sid = %s,uid = %d] Agent closed. [count = %d, ip = %s]",
-			a.SID(),
-			a.UID(),
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Agent closed. [count = %d, ip = %s]",
 			Count(),
-			a.RemoteAddr(),
+			a.RemoteAddress(),
 		)
 	}
 
-	close(a.chPending)
-	close(a.chWrite)
+	// chPending and chWrite are not closed, other goroutines may still send on them.
+	// the senders are released by chDie and the queued messages are dropped.
+}
+
+func (a *Agent) fireOnClose() {
+	if !atomic.CompareAndSwapInt32(&a.fired, 0, 1) {
+		return
+	}
+
+	cutils.Try(func() {
+		for _, fn := range a.onCloseFunc {
+			fn(a)
+		}
+	}, func(errString string) {
+		clog.Warn(errString)
+	})
+
+	fireEvent(EventClose, a)
 }
 
 func (a *Agent) write(bytes []byte) {
+	if cmd.encryption {
+		var err error
+		if bytes, err = a.sealBytes(bytes); err != nil {
+			a.Warnf("Write bytes encrypt fail. [error = %s]", err)
+			return
+		}
+	}
+
+	if timeout := loadDuration(&cmd.writeTimeout); timeout > 0 {
+		_ = a.conn.SetWriteDeadline(a.earlierDeadline(time.Now().Add(timeout)))
+	}
+
 	_, err := a.conn.Write(bytes)
 	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			a.onWriteTimeout()
+			return
+		}
 		clog.Warn(err)
 	}
 }
 
 func (a *Agent) processPacket(packet *pomeloPacket.Packet) {
+	// the data packets of the paused agent are processed by ResumeRecv()
+	if a.parkPacket(packet) {
+		a.SetLastAt()
+		return
+	}
+
+	a.handlePacket(packet)
+}
+
+func (a *Agent) handlePacket(packet *pomeloPacket.Packet) {
 	process, found := cmd.onPacketFuncMap[packet.Type()]
 	if !found {
-		if clog.PrintLevel(zapcore.DebugLevel) {
-			clog.Warnf("[sid = %s,uid = %d] Packet type not found, close connect! [packet = %+v]",
-				a.SID(),
-				a.UID(),
+		if a.PrintLevel(zapcore.DebugLevel) {
+			a.Warnf("Packet type not found, close connect! [packet = %+v]",
 				packet,
 			)
 		}
+		a.setCloseCause(CauseProtocolError, cerr.PacketWrongType)
 		a.Close()
 		return
 	}
@@ -269,12 +706,46 @@ func (a *Agent) processPacket(packet *pomeloPacket.Packet) {
 	a.SetLastAt()
 }
 
-func (a *Agent) RemoteAddr() string {
+// RemoteAddr returns the remote address of the conn, nil if the conn is nil
+func (a *Agent) RemoteAddr() net.Addr {
 	if a.conn != nil {
-		return cnet.GetIPV4(a.conn.RemoteAddr())
+		return a.conn.RemoteAddr()
 	}
 
-	return ""
+	return nil
+}
+
+// RemoteIP returns the ip of the remote address
+func (a *Agent) RemoteIP() net.IP {
+	return cnet.GetIP(a.RemoteAddr())
+}
+
+// RemoteAddress returns the ip string of the remote address
+func (a *Agent) RemoteAddress() string {
+	return cnet.GetIPV4(a.RemoteAddr())
+}
+
+// PeerCommonName returns the subject common name of the verified client certificate(mutual tls).
+// empty if the conn is not tls or the client has not sent the certificate.
+func (a *Agent) PeerCommonName() string {
+	var conn net.Conn = a.conn
+
+	// eg. websocket over tls
+	if c, ok := conn.(interface{ UnderlyingConn() net.Conn }); ok {
+		conn = c.UnderlyingConn()
+	}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) < 1 || len(state.PeerCertificates) < 1 {
+		return ""
+	}
+
+	return state.PeerCertificates[0].Subject.CommonName
 }
 
 func (p *pendingMessage) String() string {
@@ -282,140 +753,305 @@ func (p *pendingMessage) String() string {
 }
 
 func (a *Agent) processPending(data *pendingMessage) {
-	payload, err := a.Serializer().Marshal(data.payload)
-	if err != nil {
-		clog.Warnf("[sid = %s,uid = %d] Payload marshal error. [data = %s]",
-			a.SID(),
-			a.UID(),
+	if data.ctx != nil && data.ctx.Err() != nil {
+		if a.PrintLevel(zapcore.DebugLevel) {
+			a.Debugf("Pending message abandoned. [data = %s, err = %v]",
+				data.String(),
+				data.ctx.Err(),
+			)
+		}
+		return
+	}
+
+	// the packet of the broadcast is encoded once, see BroadcastAll
+	pkg := data.pkg
+	if pkg == nil {
+		var err error
+		if pkg, err = a.encodePending(data); err != nil {
+			a.Warnf("Pending message encode error. [data = %s, err = %v]",
+				data.String(),
+				err,
+			)
+			return
+		}
+	}
+
+	// dropped by the outbound filters
+	if pkg == nil {
+		return
+	}
+
+	// the high priority message is written before the queued bytes
+	if data.priority > PriorityNormal {
+		a.write(pkg)
+		atomic.AddInt64(&counters.messagesOut, 1)
+		return
+	}
+
+	// the queued messages are still written when draining
+	if err := a.queueRaw(pkg); err != nil {
+		a.Warnf("Pending message write fail. [data = %s, err = %v]",
 			data.String(),
+			err,
 		)
 		return
 	}
 
+	atomic.AddInt64(&counters.messagesOut, 1)
+}
+
+// encodePending marshal the payload and encode it to packet bytes, returns nil bytes if it's dropped by the outbound filters
+func (a *Agent) encodePending(data *pendingMessage) ([]byte, error) {
+	payload, err := a.Serializer().Marshal(data.payload)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, ok := a.filterOutbound(data.route, payload)
+	if !ok {
+		return nil, nil
+	}
+
+	// the push is numbered in the write goroutine, so the seq is in the order of the writes
+	header, seq := a.outboundSeq(data)
+
 	// construct message and encode
 	m := &pomeloMessage.Message{
-		Type:  data.typ,
-		ID:    data.mid,
-		Route: data.route,
-		Data:  payload,
-		Error: data.err,
+		Type:         data.typ,
+		ID:           data.mid,
+		Route:        data.route,
+		Header:       header,
+		Data:         payload,
+		Error:        data.err,
+		Uncompressed: !a.CompressionEnabled(),
 	}
 
 	// encode message
 	em, err := pomeloMessage.Encode(m)
 	if err != nil {
-		clog.Warn(err)
-		return
+		return nil, err
 	}
 
 	// encode packet
-	a.SendPacket(pomeloPacket.Data, em)
+	pkg, err := pomeloPacket.Encode(pomeloPacket.Data, em)
+	if err != nil {
+		return nil, err
+	}
+
+	a.keepOutbound(seq, pkg)
+	return pkg, nil
 }
 
-func (a *Agent) sendPending(typ pomeloMessage.Type, route string, mid uint32, v interface{}, isError bool) {
-	if a.state == AgentClosed {
-		clog.Warnf("[sid = %s,uid = %d] Session is closed. [typ = %v, route = %s, mid = %d, val = %+v, err = %v]",
-			a.SID(),
-			a.UID(),
-			typ,
-			route,
-			mid,
-			v,
-			isError,
-		)
-		return
+// drainPending process and write all queued messages, must be called in the write goroutine
+func (a *Agent) drainPending() {
+	for {
+		select {
+		case pending := <-a.chPending:
+			a.processPending(pending)
+			a.drainWrite()
+		default:
+			return
+		}
+	}
+}
+
+// drainWrite write all queued bytes, must be called in the write goroutine
+func (a *Agent) drainWrite() {
+	for {
+		select {
+		case bytes := <-a.chWrite:
+			a.addPendingBytes(-len(bytes))
+			a.write(bytes)
+		default:
+			return
+		}
+	}
+}
+
+func (a *Agent) sendPending(ctx context.Context, typ pomeloMessage.Type, route string, mid uint32, header map[string]string, v interface{}, isError bool) error {
+	if a.IsClosed() {
+		return cerr.SessionClosed
 	}
 
-	if len(a.chPending) >= cmd.writeBacklog {
-		clog.Warnf("[sid = %s,uid = %d] send buffer exceed. [typ = %v, route = %s, mid = %d, val = %+v, err = %v]",
-			a.SID(),
-			a.UID(),
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if a.isDraining() {
+		a.Warnf("Session is closed. [typ = %v, route = %s, mid = %d, val = %+v, err = %v]",
 			typ,
 			route,
 			mid,
 			v,
 			isError,
 		)
-		return
+		return cerr.SessionClosed
 	}
 
 	pending := &pendingMessage{
 		typ:     typ,
 		mid:     uint(mid),
 		route:   route,
+		header:  header,
 		payload: v,
 		err:     isError,
 	}
 
-	a.chPending <- pending
+	a.cacheResponse(pending)
+
+	// wait for the queue until ctx is done
+	if ctx.Done() != nil {
+		pending.ctx = ctx
+
+		select {
+		case a.chPending <- pending:
+			return nil
+		case <-a.chDie:
+			return cerr.SessionClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	err := enqueue(a, a.chPending, pending, func(drop *pendingMessage) {
+		if a.PrintLevel(zapcore.DebugLevel) {
+			a.Debugf("Pending message dropped. [data = %s]", drop.String())
+		}
+	})
+
+	if err != nil {
+		a.Warnf("send buffer exceed. [typ = %v, route = %s, mid = %d, val = %+v, err = %v]",
+			typ,
+			route,
+			mid,
+			v,
+			isError,
+		)
+	}
+
+	return err
+}
+
+// Response response the message, returns cerr.SessionClosed if the agent is closed
+func (a *Agent) Response(session *cproto.Session, v interface{}, isError ...bool) error {
+	return a.ResponseMID(session.Mid, v, isError...)
 }
 
-func (a *Agent) Response(session *cproto.Session, v interface{}, isError ...bool) {
-	a.ResponseMID(session.Mid, v, isError...)
+// ResponseContext response the message, returns ctx.Err() if ctx is done before the message is queued.
+func (a *Agent) ResponseContext(ctx context.Context, session *cproto.Session, v interface{}, isError ...bool) error {
+	return a.responseMID(ctx, session.Mid, nil, v, isError...)
 }
 
-func (a *Agent) ResponseCode(session *cproto.Session, statusCode int32, isError ...bool) {
+// ResponseMetadata response the message with the metadata(header), eg. server version.
+// see pomeloMessage.SetHeaderLimit
+func (a *Agent) ResponseMetadata(session *cproto.Session, v interface{}, metadata map[string]string, isError ...bool) error {
+	return a.responseMID(context.Background(), session.Mid, metadata, v, isError...)
+}
+
+func (a *Agent) ResponseCode(session *cproto.Session, statusCode int32, isError ...bool) error {
 	rsp := &cproto.Response{
 		Code: statusCode,
 	}
-	a.ResponseMID(session.Mid, rsp, isError...)
+	return a.ResponseMID(session.Mid, rsp, isError...)
 }
 
-func (a *Agent) ResponseMID(mid uint32, v interface{}, isError ...bool) {
+// ResponseError response the error envelope cproto.Response{Code: code, Data: []byte(msg)} to the client
+func (a *Agent) ResponseError(mid uint32, code int32, msg string) error {
+	rsp := &cproto.Response{
+		Code: code,
+		Data: []byte(msg),
+	}
+	return a.responseMID(context.Background(), mid, nil, rsp, true)
+}
+
+func (a *Agent) ResponseMID(mid uint32, v interface{}, isError ...bool) error {
+	return a.responseMID(context.Background(), mid, nil, v, isError...)
+}
+
+func (a *Agent) responseMID(ctx context.Context, mid uint32, metadata map[string]string, v interface{}, isError ...bool) error {
 	isErr := false
 	if len(isError) > 0 {
 		isErr = isError[0]
 	}
 
-	a.sendPending(pomeloMessage.Response, "", mid, v, isErr)
-	if clog.PrintLevel(zapcore.DebugLevel) {
-		clog.Debugf("[sid = %s,uid = %d] Response ok. [mid = %d, isError = %v]",
-			a.SID(),
-			a.UID(),
+	if err := a.checkResponded(mid); err != nil {
+		return err
+	}
+
+	if err := a.sendPending(ctx, pomeloMessage.Response, "", mid, metadata, v, isErr); err != nil {
+		return err
+	}
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Response ok. [mid = %d, isError = %v]",
 			mid,
 			isErr,
 		)
 	}
+
+	return nil
 }
 
-func (a *Agent) Push(route string, val interface{}) {
-	a.sendPending(pomeloMessage.Push, route, 0, val, false)
+func (a *Agent) Push(route string, val interface{}) error {
+	return a.PushContext(context.Background(), route, val)
+}
 
-	if clog.PrintLevel(zapcore.DebugLevel) {
-		clog.Debugf("[sid = %s,uid = %d] Push ok. [route = %s]",
-			a.SID(),
-			a.UID(),
+// PushContext push the message, returns ctx.Err() if ctx is done before the message is written.
+// a Background ctx keeps the non-blocking behavior of Push().
+func (a *Agent) PushContext(ctx context.Context, route string, val interface{}) error {
+	if err := a.sendPending(ctx, pomeloMessage.Push, route, 0, nil, val, false); err != nil {
+		return err
+	}
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Push ok. [route = %s]",
 			route,
 		)
 	}
+
+	return nil
+}
+
+// KickWith kick the client with the reason marshaled by the app serializer.
+// the kick packet is written before the connection is closed.
+func (a *Agent) KickWith(reason KickReason, closed bool) error {
+	return a.Kick(&reason, closed)
 }
 
-func (a *Agent) Kick(reason interface{}, closed bool) {
+// Kick kick the client. a string reason is sent as KickReason with KickCodeDefault,
+// other values are marshaled by the serializer. returns cerr.SessionClosed if the agent is closed
+func (a *Agent) Kick(reason interface{}, closed bool) error {
+	if a.IsClosed() {
+		return cerr.SessionClosed
+	}
+
+	if message, ok := reason.(string); ok {
+		return a.KickWith(KickReason{Code: KickCodeDefault, Message: message}, closed)
+	}
+
 	bytes, err := a.Serializer().Marshal(reason)
 	if err != nil {
-		clog.Warnf("[sid = %s,uid = %d] Kick marshal fail. [reason = {%+v}, err = %s]",
-			a.SID(),
-			a.UID(),
+		a.Warnf("Kick marshal fail. [reason = {%+v}, err = %s]",
 			reason,
 			err,
 		)
 	}
 
+	return a.kick(bytes, reason, closed)
+}
+
+func (a *Agent) kick(bytes []byte, reason interface{}, closed bool) error {
 	pkg, err := pomeloPacket.Encode(pomeloPacket.Kick, bytes)
 	if err != nil {
-		clog.Warnf("[sid = %s,uid = %d] Kick packet encode error.[reason = %+v, err = %s]",
-			a.SID(),
-			a.UID(),
+		a.Warnf("Kick packet encode error.[reason = %+v, err = %s]",
 			reason,
 			err,
 		)
-		return
+		return err
 	}
 
-	if clog.PrintLevel(zapcore.DebugLevel) {
-		clog.Debugf("[sid = %s,uid = %d] Kick ok. [reason = %+v, closed = %v]",
-			a.SID(),
-			a.UID(),
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Kick ok. [reason = %+v, closed = %v]",
 			reason,
 			closed,
 		)
@@ -423,10 +1059,34 @@ func (a *Agent) Kick(reason interface{}, closed bool) {
 
 	// 不进入pending chan，直接踢了
 	a.write(pkg)
+	counters.kick(kickMessage(reason))
+	fireEvent(EventKick, a)
 
 	if closed {
+		// eg. websocket sends the reason in the close frame
+		if c, ok := a.conn.(closeReasoner); ok {
+			c.SetCloseReason(kickMessage(reason))
+		}
+
+		atomic.StoreInt32(&a.kicked, 1)
+		a.setCloseCause(kickCause(reason), nil)
 		a.Close()
 	}
+
+	return nil
+}
+
+func kickMessage(reason interface{}) string {
+	switch r := reason.(type) {
+	case string:
+		return r
+	case KickReason:
+		return r.Message
+	case *KickReason:
+		return r.Message
+	default:
+		return "kick"
+	}
 }
 
 func (a *Agent) AddOnClose(fn OnCloseFunc) {
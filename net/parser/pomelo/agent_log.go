@@ -0,0 +1,114 @@
+package pomelo
+
+import (
+	"sort"
+
+	clog "github.com/cherry-game/cherry/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	loggerHolder struct {
+		*zap.SugaredLogger
+		fieldNames clog.FieldNames // the field names of the logger config
+	}
+)
+
+// SetLogger set the logger of the agent, nil is clog.DefaultLogger.
+// eg. raise the level of a suspicious player without changing the whole server.
+func (a *Agent) SetLogger(logger *clog.CherryLogger) {
+	if logger == nil {
+		a.logger.Store(loggerHolder{})
+	} else {
+		fieldNames := clog.DefaultLogger.FieldNames
+		if logger.Config != nil {
+			fieldNames = logger.FieldNames
+		}
+
+		// skip the agent log func as clog.DefaultLogger
+		a.logger.Store(loggerHolder{
+			SugaredLogger: logger.WithOptions(zap.AddCallerSkip(1)),
+			fieldNames:    fieldNames,
+		})
+	}
+
+	a.resetLogEntry()
+}
+
+// Logger returns the logger of the agent
+func (a *Agent) Logger() *zap.SugaredLogger {
+	if holder, ok := a.logger.Load().(loggerHolder); ok && holder.SugaredLogger != nil {
+		return holder.SugaredLogger
+	}
+
+	return clog.DefaultLogger.SugaredLogger
+}
+
+// PrintLevel returns true if the level is enabled by the agent logger.
+func (a *Agent) PrintLevel(level zapcore.Level) bool {
+	if holder, ok := a.logger.Load().(loggerHolder); ok && holder.SugaredLogger != nil {
+		return holder.Desugar().Core().Enabled(level)
+	}
+
+	return clog.PrintLevel(level)
+}
+
+// With returns a logger with sid/uid/ip fields and the fields of args.
+func (a *Agent) With(fields map[string]interface{}) *zap.SugaredLogger {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]interface{}, 0, len(fields)*2)
+	for _, k := range keys {
+		args = append(args, k, fields[k])
+	}
+
+	// called by user directly, remove the skip of the agent log func
+	return a.logEntry().WithOptions(zap.AddCallerSkip(-1)).With(args...)
+}
+
+func (a *Agent) Debugf(template string, args ...interface{}) {
+	a.logEntry().Debugf(template, args...)
+}
+
+func (a *Agent) Infof(template string, args ...interface{}) {
+	a.logEntry().Infof(template, args...)
+}
+
+func (a *Agent) Warnf(template string, args ...interface{}) {
+	a.logEntry().Warnf(template, args...)
+}
+
+func (a *Agent) Errorf(template string, args ...interface{}) {
+	a.logEntry().Errorf(template, args...)
+}
+
+// logEntry the logger with agent fields, cached until the uid or logger is changed
+func (a *Agent) logEntry() *zap.SugaredLogger {
+	if holder, ok := a.entry.Load().(loggerHolder); ok && holder.SugaredLogger != nil {
+		return holder.SugaredLogger
+	}
+
+	names := clog.DefaultLogger.FieldNames
+	if holder, ok := a.logger.Load().(loggerHolder); ok && holder.SugaredLogger != nil {
+		names = holder.fieldNames
+	}
+
+	// real fields of the json encoder
+	entry := a.Logger().With(
+		names.SID, a.SID(),
+		names.UID, a.UID(),
+		names.IP, a.RemoteAddress(),
+	)
+	a.entry.Store(loggerHolder{SugaredLogger: entry})
+
+	return entry
+}
+
+func (a *Agent) resetLogEntry() {
+	a.entry.Store(loggerHolder{})
+}
@@ -0,0 +1,95 @@
+package pomelo
+
+import (
+	"testing"
+
+	clog "github.com/cherry-game/cherry/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newObservedLogger(level zapcore.Level) (*clog.CherryLogger, *observer.ObservedLogs) {
+	core, logs := observer.New(level)
+	return &clog.CherryLogger{SugaredLogger: zap.New(core).Sugar()}, logs
+}
+
+func TestAgentLogger(t *testing.T) {
+	agent := newTestAgent("agent-log")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	logger, logs := newObservedLogger(zapcore.DebugLevel)
+	agent.SetLogger(logger)
+
+	if !agent.PrintLevel(zapcore.DebugLevel) {
+		t.Fatal("debug level is not enabled by the agent logger")
+	}
+
+	agent.Debugf("before bind")
+	agent.With(map[string]interface{}{"room": 1}).Info("with fields")
+
+	if err := agent.Bind(7001); err != nil {
+		t.Fatal(err)
+	}
+
+	agent.Debugf("after bind")
+
+	entries := logs.All()
+	if len(entries) != 3 {
+		t.Fatalf("log entries = %d", len(entries))
+	}
+
+	if fields := entries[0].ContextMap(); fields["sid"] != agent.SID() || fields["uid"] != int64(0) {
+		t.Fatalf("fields before bind = %v", fields)
+	}
+
+	if fields := entries[1].ContextMap(); fields["room"] != int64(1) || fields["sid"] != agent.SID() {
+		t.Fatalf("with fields = %v", fields)
+	}
+
+	// the cached fields are reset on bind
+	if fields := entries[2].ContextMap(); fields["uid"] != int64(7001) {
+		t.Fatalf("fields after bind = %v", fields)
+	}
+
+	// nil is the default logger
+	agent.SetLogger(nil)
+	if agent.Logger() != clog.DefaultLogger.SugaredLogger {
+		t.Fatal("logger is not reset to the default logger")
+	}
+}
+
+func TestAgentLoggerLevel(t *testing.T) {
+	agent := newTestAgent("agent-log-level")
+
+	logger, logs := newObservedLogger(zapcore.WarnLevel)
+	agent.SetLogger(logger)
+
+	if agent.PrintLevel(zapcore.DebugLevel) {
+		t.Fatal("debug level is enabled by the warn logger")
+	}
+
+	agent.Debugf("debug")
+	agent.Warnf("warn")
+
+	if logs.Len() != 1 || logs.All()[0].Message != "warn" {
+		t.Fatalf("log entries = %v", logs.All())
+	}
+}
+
+func TestAgentLoggerFieldNames(t *testing.T) {
+	agent := newTestAgent("agent-log-names")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	logger, logs := newObservedLogger(zapcore.DebugLevel)
+	logger.Config = &clog.Config{FieldNames: clog.FieldNames{SID: "session_id", UID: "user_id", IP: "ip"}}
+	agent.SetLogger(logger)
+
+	agent.Infof("field names")
+
+	if fields := logs.All()[0].ContextMap(); fields["session_id"] != agent.SID() || fields["user_id"] != int64(0) {
+		t.Fatalf("fields = %+v", fields)
+	}
+}
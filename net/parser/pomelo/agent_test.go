@@ -0,0 +1,394 @@
+package pomelo
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestPushContextRaceClose(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		agent := newTestAgent("push-close-" + strconv.Itoa(i))
+		BindSID(agent)
+		agent.Run()
+
+		wg := &sync.WaitGroup{}
+		for j := 0; j < 50; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+
+				_ = agent.PushContext(ctx, "test.push", []byte("hello"))
+				_ = agent.Push("test.push", []byte("hello"))
+			}()
+		}
+
+		agent.Close()
+		wg.Wait()
+	}
+}
+
+func TestPendingSendBytes(t *testing.T) {
+	agent := newTestAgent("pending-bytes")
+
+	for i := 0; i < int(cmd.writeBacklog); i++ {
+		if err := agent.SendRaw([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := agent.SendRaw([]byte("hello")); err != cerr.SessionSendQueueFull {
+		t.Fatalf("send on full queue err = %v", err)
+	}
+
+	if n := agent.PendingSendBytes(); n != int(cmd.writeBacklog)*5 {
+		t.Fatalf("pending bytes = %d", n)
+	}
+
+	agent.drainWrite()
+
+	if n := agent.PendingSendBytes(); n != 0 {
+		t.Fatalf("pending bytes after drain = %d", n)
+	}
+}
+
+func TestCloseGracefully(t *testing.T) {
+	defer func() {
+		cmd.overflowPolicy = OverflowReject
+	}()
+	cmd.overflowPolicy = OverflowDropOldest
+
+	agent := newTestAgent("close-gracefully")
+
+	conn, peer := net.Pipe()
+	agent.conn = conn
+
+	var readBytes int64
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := peer.Read(buf)
+			atomic.AddInt64(&readBytes, int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// the queue is full before the write goroutine is running
+	var wantBytes int64
+	for i := 0; i < int(cmd.writeBacklog); i++ {
+		if err := agent.Push("test.push", "hello"); err != nil {
+			t.Fatal(err)
+		}
+
+		pkg, err := agent.encodePending(&pendingMessage{typ: pomeloMessage.Push, route: "test.push", payload: "hello"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantBytes += int64(len(pkg))
+	}
+
+	agent.Run()
+
+	if err := agent.CloseGracefully(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// the peer counts the bytes after the pipe write returns
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&readBytes) < wantBytes && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := atomic.LoadInt64(&readBytes); n != wantBytes {
+		t.Fatalf("read bytes = %d, want = %d", n, wantBytes)
+	}
+
+	if err := agent.Push("test.push", "hello"); err != cerr.SessionClosed {
+		t.Fatalf("push after close err = %v", err)
+	}
+}
+
+func TestCloseGracefullyTimeout(t *testing.T) {
+	agent := newTestAgent("close-gracefully-timeout")
+
+	// the write goroutine is not running, the queue can not be drained
+	if err := agent.Push("test.push", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := agent.CloseGracefully(50 * time.Millisecond); err != cerr.SessionDrainTimeout {
+		t.Fatalf("close gracefully err = %v", err)
+	}
+
+	if agent.State() != AgentClosed {
+		t.Fatalf("agent state = %d", agent.State())
+	}
+}
+
+func TestKickReason(t *testing.T) {
+	tests := []struct {
+		reason interface{}
+		want   KickReason
+	}{
+		{"banned", KickReason{Code: KickCodeDefault, Message: "banned"}},
+		{KickReason{Code: KickCodeRateLimited, Message: KickRateLimited}, KickReason{Code: KickCodeRateLimited, Message: KickRateLimited}},
+	}
+
+	for _, tt := range tests {
+		agent := newTestAgent("kick-reason")
+
+		conn, peer := net.Pipe()
+		agent.conn = conn
+
+		go func(reason interface{}) {
+			if kickReason, ok := reason.(KickReason); ok {
+				agent.KickWith(kickReason, true)
+			} else {
+				agent.Kick(reason, true)
+			}
+		}(tt.reason)
+
+		packets, _, err := pomeloPacket.Read(peer)
+		if err != nil || len(packets) != 1 || packets[0].Type() != pomeloPacket.Kick {
+			t.Fatalf("read kick packet fail. packets = %v, err = %v", packets, err)
+		}
+
+		// the reason is marshaled by the app serializer(json in test)
+		var got KickReason
+		if err := jsoniter.Unmarshal(packets[0].Data(), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Code != tt.want.Code || got.Message != tt.want.Message {
+			t.Fatalf("kick reason = %+v, want = %+v", got, tt.want)
+		}
+	}
+}
+
+func TestSinceHeartbeat(t *testing.T) {
+	agent := newTestAgent("since-heartbeat")
+
+	if !agent.LastHeartbeat().IsZero() {
+		t.Fatalf("last heartbeat = %v", agent.LastHeartbeat())
+	}
+
+	// no heartbeat yet, elapsed since the last active message
+	atomic.StoreInt64(&agent.lastActiveAt, time.Now().Add(-time.Minute).UnixMilli())
+	if d := agent.SinceHeartbeat(); d < time.Minute {
+		t.Fatalf("since heartbeat = %v", d)
+	}
+
+	heartbeatCommand(agent, nil)
+
+	if agent.LastHeartbeat().IsZero() {
+		t.Fatal("heartbeat is not updated")
+	}
+
+	if d := agent.SinceHeartbeat(); d > time.Second {
+		t.Fatalf("since heartbeat = %v", d)
+	}
+
+	// the heartbeat packet is responded
+	if len(agent.chWrite) != 1 {
+		t.Fatalf("heartbeat response queued = %d", len(agent.chWrite))
+	}
+}
+
+func TestOutboundFilter(t *testing.T) {
+	defer func() {
+		cmd.outboundFilters = nil
+	}()
+
+	cmd.outboundFilters = []OutboundFilter{
+		func(_ *Agent, route string, payload []byte) ([]byte, bool) {
+			if route == "test.rewrite" {
+				return []byte(`"world"`), true
+			}
+			return payload, true
+		},
+		func(_ *Agent, route string, payload []byte) ([]byte, bool) {
+			return payload, route != "test.drop"
+		},
+	}
+
+	agent := newTestAgent("outbound-filter")
+
+	pkg, err := agent.encodePending(&pendingMessage{typ: pomeloMessage.Push, route: "test.rewrite", payload: "hello"})
+	if err != nil || pkg == nil {
+		t.Fatalf("encode rewrite pkg = %v, err = %v", pkg, err)
+	}
+
+	packets, err := pomeloPacket.Decode(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := pomeloMessage.Decode(packets[0].Data())
+	if err != nil || string(msg.Data) != `"world"` {
+		t.Fatalf("rewrite msg = %v, err = %v", msg, err)
+	}
+
+	if pkg, _ := agent.encodePending(&pendingMessage{typ: pomeloMessage.Push, route: "test.drop", payload: "hello"}); pkg != nil {
+		t.Fatal("message is not dropped")
+	}
+
+	// the dropped pending message is not queued
+	if err := agent.Push("test.drop", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	agent.processPending(<-agent.chPending)
+
+	if len(agent.chWrite) != 0 {
+		t.Fatalf("dropped message queued = %d", len(agent.chWrite))
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	agent := newTestAgent("concurrent-close")
+	agent.SetState(AgentWorking)
+	BindSID(agent)
+
+	if err := agent.Bind(5001); err != nil {
+		t.Fatal(err)
+	}
+
+	var fired int32
+	agent.AddOnClose(func(*Agent) {
+		atomic.AddInt32(&fired, 1)
+	})
+
+	closed := atomic.LoadInt64(&counters.closed)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent.Close()
+			agent.closeProcess()
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fired); n != 1 {
+		t.Fatalf("onClose fired = %d", n)
+	}
+
+	if n := atomic.LoadInt64(&counters.closed) - closed; n != 1 {
+		t.Fatalf("closed = %d", n)
+	}
+
+	if _, found := GetAgent(agent.SID()); found {
+		t.Fatal("agent is not unbound")
+	}
+
+	if _, found := GetAgentWithUID(5001); found {
+		t.Fatal("uid is not unbound")
+	}
+
+	// the closed agent can not be reopened
+	if agent.SetState(AgentWorking) || agent.State() != AgentClosed {
+		t.Fatalf("state = %d", agent.State())
+	}
+}
+
+func TestClosedAgentSend(t *testing.T) {
+	agent := newTestAgent("closed-send")
+	agent.SetState(AgentWorking)
+
+	if agent.IsClosed() {
+		t.Fatal("agent is closed")
+	}
+
+	agent.Close()
+
+	if !agent.IsClosed() {
+		t.Fatal("agent is not closed")
+	}
+
+	session := agent.Session()
+	errs := map[string]error{
+		"SendRaw":  agent.SendRaw([]byte(`hello`)),
+		"Push":     agent.Push("test.push", "hello"),
+		"Response": agent.Response(session, "hello"),
+		"RPCAsync": agent.RPCAsync("game.room.join", nil, nil),
+		"Kick":     agent.Kick("bye", true),
+	}
+
+	for name, err := range errs {
+		if err != cerr.SessionClosed {
+			t.Fatalf("%s err = %v", name, err)
+		}
+	}
+
+	if len(agent.chPending) != 0 || len(agent.chWrite) != 0 {
+		t.Fatal("message is queued")
+	}
+}
+
+func TestCreateAgentRejected(t *testing.T) {
+	defer func() {
+		cmd.onCreateFuncs = nil
+	}()
+
+	var closed int32
+	(&actor{}).AddOnCreate(func(agent *Agent) bool {
+		agent.AddOnClose(func(*Agent) {
+			atomic.AddInt32(&closed, 1)
+		})
+		return agent.SID() != "create-rejected"
+	})
+
+	server, client := newTCPPair(t)
+	defer client.Close()
+
+	count := Count()
+	agent, err := CreateAgent(testApp{}, server, &cproto.Session{Sid: "create-rejected", Data: map[string]string{}})
+	if err != cerr.SessionCreateRejected || agent != nil {
+		t.Fatalf("create err = %v", err)
+	}
+
+	if _, err = client.Read(make([]byte, 1)); err == nil {
+		t.Fatal("the rejected conn is not closed")
+	}
+
+	if Count() != count {
+		t.Fatalf("rejected agent is bound. [count = %d]", Count())
+	}
+
+	server, client = newTCPPair(t)
+	defer client.Close()
+
+	agent, err = CreateAgent(testApp{}, server, &cproto.Session{Sid: "create-accepted", Data: map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	BindSID(agent)
+	agent.Run()
+	agent.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&closed) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// only the accepted agent fires onClose
+	if atomic.LoadInt32(&closed) != 1 {
+		t.Fatalf("onClose fired = %d", atomic.LoadInt32(&closed))
+	}
+}
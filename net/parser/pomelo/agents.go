@@ -2,10 +2,12 @@ package pomelo
 
 import (
 	"sync"
+	"sync/atomic"
 
 	cerr "github.com/cherry-game/cherry/error"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
+	cproto "github.com/cherry-game/cherry/net/proto"
 )
 
 var (
@@ -18,70 +20,285 @@ func BindSID(agent *Agent) {
 	lock.Lock()
 	defer lock.Unlock()
 
+	if _, found := sidAgentMap[agent.SID()]; !found {
+		atomic.AddInt64(&counters.total, 1)
+		atomic.AddInt64(&counters.created, 1)
+	}
+
 	sidAgentMap[agent.SID()] = agent
 }
 
+// BindUID bind the uid to the agent, returns cerr.SessionAlreadyBound if it has bound. see RebindUID
 func BindUID(sid cfacade.SID, uid cfacade.UID) error {
+	return bindUID(sid, uid, false, nil)
+}
+
+// BindUIDWithData bind the uid and set the data of the session, the data is set before the uid is bound,
+// so the bound agent is never seen without the data and the onBind listeners can read it.
+// the data is rolled back if the bind is rejected. returns the same errors as BindUID
+func BindUIDWithData(sid cfacade.SID, uid cfacade.UID, data map[string]interface{}) error {
+	return bindUID(sid, uid, false, data)
+}
+
+// RebindUID change the bound uid of the agent, the old uid mapping is removed.
+func RebindUID(sid cfacade.SID, uid cfacade.UID) error {
+	return bindUID(sid, uid, true, nil)
+}
+
+func bindUID(sid cfacade.SID, uid cfacade.UID, rebind bool, data map[string]interface{}) error {
 	if sid == "" {
 		return cerr.Errorf("[sid = %s] less than 1.", sid)
 	}
 
-	if uid < 1 {
+	if !cfacade.IsValidUID(uid) {
 		return cerr.Errorf("[uid = %d] less than 1.", uid)
 	}
 
+	// the store is read without lock
+	stored := loadStoredData(uid)
+
 	lock.Lock()
-	defer lock.Unlock()
 
 	agent, found := sidAgentMap[sid]
 	if !found {
+		lock.Unlock()
 		return cerr.Errorf("[sid = %s] does not exist.", sid)
 	}
 
-	if agent.UID() > 0 && agent.UID() == uid {
-		return cerr.Errorf("[uid = %d] has already bound.", agent.UID())
+	if agent.IsBind() && (!rebind || agent.UID() == uid) {
+		lock.Unlock()
+		return cerr.SessionAlreadyBound
+	}
+
+	oldSID, oldFound := uidMap[uid]
+	if oldFound && cmd.bindPolicy == BindPolicyReject {
+		lock.Unlock()
+		return cerr.SessionUIDBoundOnOther
+	}
+
+	oldData := bindData(agent.session, data)
+	filled := fillData(agent.session, stored)
+
+	// remove the old uid mapping of this agent
+	oldUID := agent.UID()
+	if cfacade.IsValidUID(oldUID) && uidMap[oldUID] == sid {
+		delete(uidMap, oldUID)
 	}
 
 	agent.session.Uid = uid
-	uidMap[uid] = sid
+	agent.reconnectToken = newReconnectToken()
+	agent.resetLogEntry()
+	uidMap[uid] = sid // replace the mapping if the uid is bound on other sid
+
+	oldAgent := sidAgentMap[oldSID]
+
+	lock.Unlock()
+
+	// the listeners are called without lock, so they can access the agents
+	if fireOnBind(agent) {
+		if !cfacade.IsValidUID(oldUID) {
+			atomic.AddInt64(&counters.bound, 1)
+		}
+		fireEvent(EventBind, agent)
+		if s := loadSessionStore(); s != nil {
+			s.mark(sid) // the data before the bind is saved
+		}
+		if oldAgent != nil {
+			oldAgent.KickWith(KickReason{
+				Code:    KickCodeAnotherLogin,
+				Message: KickAnotherLogin,
+			}, true)
+		}
+		return nil
+	}
+
+	// rollback
+	lock.Lock()
+	defer lock.Unlock()
+
+	if uidMap[uid] == sid {
+		delete(uidMap, uid)
+		if oldFound {
+			uidMap[uid] = oldSID
+		}
+	}
+
+	agent.session.Uid = oldUID
+	agent.resetLogEntry()
+	rollbackData(agent.session, data, oldData)
+	for _, k := range filled {
+		agent.session.Remove(k)
+	}
+	if cfacade.IsValidUID(oldUID) {
+		uidMap[oldUID] = sid
+	}
 
-	return nil
+	return cerr.SessionBindRejected
+}
+
+// bindData set the data of the session and returns the old values of the keys
+func bindData(session *cproto.Session, data map[string]interface{}) map[string]string {
+	if len(data) < 1 {
+		return nil
+	}
+
+	oldData := make(map[string]string, len(data))
+	for k, v := range data {
+		if old, found := session.Get(k); found {
+			oldData[k] = old
+		}
+		session.Add(k, v)
+	}
+
+	return oldData
+}
+
+func rollbackData(session *cproto.Session, data map[string]interface{}, oldData map[string]string) {
+	for k := range data {
+		if old, found := oldData[k]; found {
+			session.Set(k, old)
+			continue
+		}
+		session.Remove(k)
+	}
 }
 
 func Unbind(sid cfacade.SID) {
 	lock.Lock()
-	defer lock.Unlock()
 
 	agent, found := sidAgentMap[sid]
 	if !found {
+		lock.Unlock()
 		return
 	}
 
 	delete(sidAgentMap, sid)
-	delete(uidMap, agent.UID())
+	atomic.AddInt64(&counters.total, -1)
+	if agent.IsBind() {
+		atomic.AddInt64(&counters.bound, -1)
+	}
+
+	// the uid may have been rebound to a new sid
+	if uidMap[agent.UID()] == sid {
+		delete(uidMap, agent.UID())
+	}
 
 	sidCount := len(sidAgentMap)
 	uidCount := len(uidMap)
+	lock.Unlock()
+
 	if sidCount == 0 || uidCount == 0 {
 		clog.Infof("Unbind agent. sid = %s, sidCount = %d, uidCount = %d", sid, sidCount, uidCount)
 	}
+
+	// fired once, the agent has been removed
+	if agent.IsBind() {
+		fireOnUnbind(agent)
+	}
 }
 
-func GetAgent(sid cfacade.SID) (*Agent, bool) {
+// UnbindUID detach the uid from the bound agent without closing it, returns false if the uid is not bound.
+// the onUnbind listeners are fired with the uid before it's cleared.
+func UnbindUID(uid cfacade.UID) bool {
+	if !cfacade.IsValidUID(uid) {
+		return false
+	}
+
+	lock.Lock()
+
+	sid, found := uidMap[uid]
+	if !found {
+		lock.Unlock()
+		return false
+	}
+
+	agent, found := sidAgentMap[sid]
+	delete(uidMap, uid)
+	lock.Unlock()
+
+	if !found {
+		return false
+	}
+
+	fireOnUnbind(agent)
+
 	lock.Lock()
 	defer lock.Unlock()
 
+	// the agent closed during the listeners is counted by Unbind(),
+	// and the uid may have been bound to the agent again
+	if sidAgentMap[sid] == agent && agent.UID() == uid && uidMap[uid] != sid {
+		agent.session.Uid = cfacade.UnboundUID
+		agent.resetLogEntry()
+		atomic.AddInt64(&counters.bound, -1)
+	}
+
+	return true
+}
+
+// KickByUIDs kick and close the agents bound the uids, returns the count of the kicked agents.
+// the uid is not bound or the agent has been closed is skipped.
+func KickByUIDs(uids []cfacade.UID, reason string) int {
+	count := 0
+
+	for _, uid := range uids {
+		agent, found := GetAgentWithUID(uid)
+		if !found {
+			continue
+		}
+
+		if err := agent.Kick(reason, true); err == nil {
+			count++
+		}
+	}
+
+	return count
+}
+
+func fireOnCreate(agent *Agent) bool {
+	for _, fn := range cmd.onCreateFuncs {
+		if !fn(agent) {
+			return false
+		}
+	}
+	return true
+}
+
+func fireOnBind(agent *Agent) bool {
+	for _, fn := range cmd.onBindFuncs {
+		if !fn(agent) {
+			return false
+		}
+	}
+	return true
+}
+
+func fireOnUnbind(agent *Agent) {
+	if s := loadSessionStore(); s != nil {
+		s.unbound(agent)
+	}
+
+	for _, fn := range cmd.onUnbindFuncs {
+		fn(agent)
+	}
+	fireEvent(EventUnbind, agent)
+}
+
+func GetAgent(sid cfacade.SID) (*Agent, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
 	agent, found := sidAgentMap[sid]
 	return agent, found
 }
 
 func GetAgentWithUID(uid cfacade.UID) (*Agent, bool) {
-	if uid < 1 {
+	if !cfacade.IsValidUID(uid) {
 		return nil, false
 	}
 
-	lock.Lock()
-	defer lock.Unlock()
+	lock.RLock()
+	defer lock.RUnlock()
 
 	sid, found := uidMap[uid]
 	if !found {
@@ -92,15 +309,51 @@ func GetAgentWithUID(uid cfacade.UID) (*Agent, bool) {
 	return agent, found
 }
 
-func ForeachAgent(fn func(a *Agent)) {
+// IdleAgents returns the agents which have not received message since deadline(unix milli)
+func IdleAgents(deadline int64) []*Agent {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var list []*Agent
 	for _, agent := range sidAgentMap {
+		if !agent.IsClosed() && agent.LastActiveAt() < deadline {
+			list = append(list, agent)
+		}
+	}
+
+	return list
+}
+
+// ForeachAgent iterate all agents. see RangeAgent
+func ForeachAgent(fn func(a *Agent)) {
+	for _, agent := range agents() {
 		fn(agent)
 	}
 }
 
-func Count() int {
+// RangeAgent iterate all agents and stops when fn returns false.
+// the iteration order is not specified, it walks on a snapshot of the agents,
+// so fn can safely call Close()/Unbind() and the agent may be closed during the iteration.
+func RangeAgent(fn func(a *Agent) bool) {
+	for _, agent := range agents() {
+		if !fn(agent) {
+			return
+		}
+	}
+}
+
+func agents() []*Agent {
 	lock.RLock()
 	defer lock.RUnlock()
 
-	return len(sidAgentMap)
+	list := make([]*Agent, 0, len(sidAgentMap))
+	for _, agent := range sidAgentMap {
+		list = append(list, agent)
+	}
+	return list
+}
+
+// Count returns the online agents, it's the same counter of Stats().Total and the session limit. see actor.SetMaxSessions
+func Count() int {
+	return int(atomic.LoadInt64(&counters.total))
 }
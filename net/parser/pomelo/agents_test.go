@@ -0,0 +1,438 @@
+package pomelo
+
+import (
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cserializer "github.com/cherry-game/cherry/net/serializer"
+)
+
+type testApp struct {
+	cfacade.IApplication
+}
+
+func (testApp) Serializer() cfacade.ISerializer {
+	return cserializer.NewJSON()
+}
+
+func newTestAgent(sid string) *Agent {
+	conn, peer := net.Pipe()
+	go io.Copy(io.Discard, peer)
+
+	return &Agent{
+		IApplication: testApp{},
+		conn:         conn,
+		chDie:        make(chan struct{}),
+		chPending:    make(chan *pendingMessage, cmd.writeBacklog),
+		chWrite:      make(chan []byte, cmd.writeBacklog),
+		chFlush:      make(chan chan struct{}),
+		limiter:      newRateLimiter(),
+		ctx:          &agentContext{},
+		delivery:     newDeliveryState(),
+		dedup:        newRequestDedup(),
+		ping:         newPingState(),
+		prio:         newPriorityQueue(),
+		watchdog:     newResponseWatchdog(),
+		recvPause:    &recvPause{},
+		session: &cproto.Session{
+			Sid:  sid,
+			Data: map[string]string{},
+		},
+	}
+}
+
+func TestRebindUID(t *testing.T) {
+	agent1 := newTestAgent("rebind-1")
+	agent2 := newTestAgent("rebind-2")
+	BindSID(agent1)
+	BindSID(agent2)
+	defer Unbind(agent1.SID())
+	defer Unbind(agent2.SID())
+
+	if err := BindUID(agent1.SID(), 1001); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := BindUID(agent1.SID(), 1002); err != cerr.SessionAlreadyBound {
+		t.Fatalf("bind on bound agent err = %v", err)
+	}
+
+	// uid login on a new agent, the old agent is kicked
+	if err := BindUID(agent2.SID(), 1001); err != nil {
+		t.Fatal(err)
+	}
+
+	if agent1.State() != AgentClosed {
+		t.Fatalf("old agent state = %d", agent1.State())
+	}
+
+	if agent, found := GetAgentWithUID(1001); !found || agent != agent2 {
+		t.Fatalf("uid 1001 found = %v, agent = %v", found, agent)
+	}
+
+	// the old agent closed, the new mapping must be kept
+	Unbind(agent1.SID())
+
+	if agent, found := GetAgentWithUID(1001); !found || agent != agent2 {
+		t.Fatalf("uid 1001 found = %v, agent = %v", found, agent)
+	}
+}
+
+func TestConcurrentBind(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			agent := newTestAgent("concurrent-" + strconv.Itoa(i))
+			BindSID(agent)
+			_ = BindUID(agent.SID(), int64(2000+i))
+			GetAgent(agent.SID())
+			GetAgentWithUID(int64(2000 + i))
+			Unbind(agent.SID())
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRangeAgent(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		agent := newTestAgent("range-" + strconv.Itoa(i))
+		BindSID(agent)
+	}
+
+	num := 0
+	RangeAgent(func(a *Agent) bool {
+		num++
+		return num < 5
+	})
+
+	if num != 5 {
+		t.Fatalf("range num = %d", num)
+	}
+
+	// unbind during the iteration
+	ForeachAgent(func(a *Agent) {
+		Unbind(a.SID())
+	})
+
+	if Count() != 0 {
+		t.Fatalf("count = %d", Count())
+	}
+}
+
+func TestBindListener(t *testing.T) {
+	defer func() {
+		cmd.onBindFuncs = nil
+		cmd.onUnbindFuncs = nil
+	}()
+
+	unbindNum := 0
+	cmd.onBindFuncs = []OnBindFunc{func(agent *Agent) bool {
+		return agent.UID() != 3001
+	}}
+	cmd.onUnbindFuncs = []OnUnbindFunc{func(agent *Agent) {
+		unbindNum++
+	}}
+
+	agent := newTestAgent("listener-1")
+	BindSID(agent)
+
+	if err := BindUID(agent.SID(), 3001); err != cerr.SessionBindRejected {
+		t.Fatalf("bind err = %v", err)
+	}
+
+	if _, found := GetAgentWithUID(3001); found || agent.IsBind() {
+		t.Fatalf("rejected bind is not rolled back. uid = %d", agent.UID())
+	}
+
+	if err := BindUID(agent.SID(), 3002); err != nil {
+		t.Fatal(err)
+	}
+
+	Unbind(agent.SID())
+	Unbind(agent.SID())
+
+	if unbindNum != 1 {
+		t.Fatalf("unbind listener num = %d", unbindNum)
+	}
+}
+
+func TestBindWithData(t *testing.T) {
+	defer func() {
+		cmd.onBindFuncs = nil
+	}()
+
+	var bound map[string]string
+	cmd.onBindFuncs = []OnBindFunc{func(agent *Agent) bool {
+		bound = agent.Session().CloneData()
+		return agent.UID() != 3101
+	}}
+
+	agent := newTestAgent("bind-with-data")
+	agent.Session().Set("level", "1")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	// rejected, the data is rolled back
+	err := agent.BindWithData(3101, map[string]interface{}{"level": 10, "name": "cherry"})
+	if err != cerr.SessionBindRejected {
+		t.Fatalf("bind err = %v", err)
+	}
+
+	if level, _ := agent.Session().Get("level"); level != "1" || agent.Session().Contains("name") || agent.IsBind() {
+		t.Fatalf("rejected bind is not rolled back. data = %v", agent.Session().CloneData())
+	}
+
+	if err = agent.BindWithData(3102, map[string]interface{}{"level": 10, "name": "cherry"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the data is set before the onBind listeners
+	if bound["level"] != "10" || bound["name"] != "cherry" {
+		t.Fatalf("data on bind = %v", bound)
+	}
+
+	if err = agent.BindWithData(3103, map[string]interface{}{"level": 20}); err != cerr.SessionAlreadyBound {
+		t.Fatalf("bind the bound agent err = %v", err)
+	}
+
+	if level, _ := agent.Session().Get("level"); level != "10" {
+		t.Fatalf("data of the bound agent is changed. level = %s", level)
+	}
+}
+
+func TestRebindPolicy(t *testing.T) {
+	defer func() {
+		cmd.bindPolicy = BindPolicyKick
+	}()
+	cmd.bindPolicy = BindPolicyReject
+
+	agent1 := newTestAgent("policy-1")
+	agent2 := newTestAgent("policy-2")
+	BindSID(agent1)
+	BindSID(agent2)
+	defer Unbind(agent1.SID())
+	defer Unbind(agent2.SID())
+
+	if err := agent1.Bind(4001); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := agent2.Bind(4001); err != cerr.SessionUIDBoundOnOther {
+		t.Fatalf("bind err = %v", err)
+	}
+
+	if err := agent1.Rebind(4002); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := GetAgentWithUID(4001); found {
+		t.Fatal("old uid mapping is not removed")
+	}
+
+	if err := agent2.Bind(4001); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckIdle(t *testing.T) {
+	defer func() {
+		cmd.idleTimeout = 0
+	}()
+	cmd.idleTimeout = time.Minute
+
+	idle := newTestAgent("idle-1")
+	active := newTestAgent("idle-2")
+	BindSID(idle)
+	BindSID(active)
+	defer Unbind(idle.SID())
+	defer Unbind(active.SID())
+
+	atomic.StoreInt64(&idle.lastActiveAt, time.Now().Add(-2*time.Minute).UnixMilli())
+	active.SetLastActiveAt()
+
+	deadline := time.Now().Add(-cmd.idleTimeout).UnixMilli()
+	if !containsAgent(IdleAgents(deadline), idle) || containsAgent(IdleAgents(deadline), active) {
+		t.Fatal("idle agents error")
+	}
+
+	(&actor{}).checkIdle()
+
+	if idle.State() != AgentClosed || !idle.isKicked() {
+		t.Fatalf("idle agent state = %d, kicked = %v", idle.State(), idle.isKicked())
+	}
+
+	if active.State() == AgentClosed {
+		t.Fatal("active agent is closed")
+	}
+
+	// the closed agent is not swept again
+	if containsAgent(IdleAgents(deadline), idle) {
+		t.Fatal("closed agent in idle agents")
+	}
+
+	idle.Touch()
+	if idle.LastActiveAt() >= deadline {
+		t.Fatal("closed agent is touched")
+	}
+}
+
+func TestTouch(t *testing.T) {
+	agent := newTestAgent("touch")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	atomic.StoreInt64(&agent.lastActiveAt, time.Now().Add(-2*time.Minute).UnixMilli())
+
+	deadline := time.Now().Add(-time.Minute).UnixMilli()
+	if !containsAgent(IdleAgents(deadline), agent) {
+		t.Fatal("agent is not idle")
+	}
+
+	agent.Touch()
+	if containsAgent(IdleAgents(deadline), agent) {
+		t.Fatalf("touched agent is idle. [lastActiveAt = %d]", agent.LastActiveAt())
+	}
+}
+
+func containsAgent(list []*Agent, agent *Agent) bool {
+	for _, a := range list {
+		if a == agent {
+			return true
+		}
+	}
+	return false
+}
+
+func TestKickByUIDs(t *testing.T) {
+	var list []*Agent
+	for i := 0; i < 3; i++ {
+		agent := newTestAgent("kick-uids-" + strconv.Itoa(i))
+		agent.SetState(AgentWorking)
+		BindSID(agent)
+		defer Unbind(agent.SID())
+
+		if err := agent.Bind(int64(6001 + i)); err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, agent)
+	}
+
+	// 6009 is not connected, 6001 is duplicated
+	if n := KickByUIDs([]int64{6001, 6002, 6009, 6001}, "banned"); n != 2 {
+		t.Fatalf("kicked = %d", n)
+	}
+
+	if !list[0].IsClosed() || !list[1].IsClosed() || list[2].IsClosed() {
+		t.Fatal("wrong agents are kicked")
+	}
+}
+
+func TestUnbindUID(t *testing.T) {
+	var unbound int32
+	cmd.onUnbindFuncs = append(cmd.onUnbindFuncs, func(agent *Agent) {
+		if agent.UID() == 7001 {
+			atomic.AddInt32(&unbound, 1)
+		}
+	})
+	defer func() {
+		cmd.onUnbindFuncs = nil
+	}()
+
+	agent := newTestAgent("unbind-uid")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	if err := agent.Bind(7001); err != nil {
+		t.Fatal(err)
+	}
+
+	bound := Stats().Bound
+
+	if !UnbindUID(7001) || UnbindUID(7001) || UnbindUID(7002) {
+		t.Fatal("unbind uid result")
+	}
+
+	if agent.IsBind() || agent.IsClosed() || atomic.LoadInt32(&unbound) != 1 {
+		t.Fatalf("uid = %d, closed = %v, unbound = %d", agent.UID(), agent.IsClosed(), unbound)
+	}
+
+	if _, found := GetAgentWithUID(7001); found || Stats().Bound != bound-1 {
+		t.Fatal("uid mapping is not removed")
+	}
+
+	// bind again
+	if err := agent.Bind(7001); err != nil {
+		t.Fatal(err)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			UnbindUID(7001)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = agent.Bind(7001)
+		}()
+	}
+	wg.Wait()
+
+	UnbindUID(7001)
+	if agent.IsBind() || Stats().Bound != bound-1 {
+		t.Fatalf("uid = %d, bound = %d", agent.UID(), Stats().Bound)
+	}
+}
+
+func TestBindBoundaryUID(t *testing.T) {
+	agent := newTestAgent("boundary-uid")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	for _, uid := range []cfacade.UID{cfacade.UnboundUID, -1, math.MinInt64} {
+		if cfacade.IsValidUID(uid) {
+			t.Fatalf("uid %d is valid", uid)
+		}
+
+		if err := agent.Bind(uid); err == nil || agent.IsBind() {
+			t.Fatalf("bind uid %d err = %v", uid, err)
+		}
+
+		if _, found := GetAgentWithUID(uid); found {
+			t.Fatalf("agent of uid %d is found", uid)
+		}
+
+		if UnbindUID(uid) || DiscardReconnect(uid) {
+			t.Fatalf("uid %d is unbound", uid)
+		}
+	}
+
+	if !cfacade.IsValidUID(1) {
+		t.Fatal("uid 1 is not valid")
+	}
+
+	if err := agent.Bind(math.MaxInt64); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, ok := GetAgentWithUID(math.MaxInt64); !ok || found != agent {
+		t.Fatal("agent of the max uid is not found")
+	}
+
+	if !UnbindUID(math.MaxInt64) || agent.IsBind() || agent.UID() != cfacade.UnboundUID {
+		t.Fatalf("uid = %d after unbind", agent.UID())
+	}
+}
@@ -0,0 +1,57 @@
+package pomelo
+
+import (
+	ccode "github.com/cherry-game/cherry/code"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	AuthEither    AuthRequirement = 0 // the route is callable before and after the bind(default)
+	AuthAnonymous AuthRequirement = 1 // the route is callable only before the bind. eg. login, register
+	AuthBound     AuthRequirement = 2 // the route is callable only after the bind
+)
+
+const (
+	unauthorizedMessage = "unauthorized" // the response data of ccode.SessionUnauthorized
+)
+
+type (
+	// AuthRequirement the access policy of the route, see actor.SetRouteAuth
+	AuthRequirement int32
+)
+
+// SetRouteAuth the access policy of the route(eg. "game.player.buy"), the dispatcher checks Agent.IsBind().
+// the mismatch request is responded ccode.SessionUnauthorized, the mismatch notify is dropped.
+// it must be set before the actor is started.
+func (*actor) SetRouteAuth(route string, require AuthRequirement) {
+	if require == AuthEither {
+		delete(cmd.routeAuth, route)
+		return
+	}
+
+	cmd.routeAuth[route] = require
+}
+
+// authorized returns false if the agent mismatches the requirement of the route, the request is responded
+func (a *Agent) authorized(msg *pmessage.Message) bool {
+	require, found := cmd.routeAuth[msg.Route]
+	if !found {
+		return true
+	}
+
+	bound := a.IsBind()
+	if (require == AuthBound && bound) || (require == AuthAnonymous && !bound) {
+		return true
+	}
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Unauthorized message. [route = %s, require = %d, bound = %v]", msg.Route, require, bound)
+	}
+
+	if msg.Type == pmessage.Request {
+		_ = a.ResponseError(uint32(msg.ID), ccode.SessionUnauthorized, unauthorizedMessage)
+	}
+
+	return false
+}
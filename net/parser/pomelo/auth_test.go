@@ -0,0 +1,69 @@
+package pomelo
+
+import (
+	"testing"
+
+	ccode "github.com/cherry-game/cherry/code"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestRouteAuth(t *testing.T) {
+	defer func() {
+		cmd.routeAuth = make(map[string]AuthRequirement)
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+
+	var routed []string
+	cmd.onDataRouteFunc = func(_ *Agent, _ *pmessage.Route, msg *pmessage.Message) {
+		routed = append(routed, msg.Route)
+	}
+
+	p := &actor{}
+	p.SetRouteAuth("game.player.login", AuthAnonymous)
+	p.SetRouteAuth("game.player.buy", AuthBound)
+	p.SetRouteAuth("game.player.chat", AuthBound)
+	p.SetRouteAuth("game.player.chat", AuthEither)
+
+	agent := newTestAgent("route-auth")
+	dispatch := func(typ pmessage.Type, mid uint, route string) {
+		r, _ := pmessage.DecodeRoute(route)
+		dispatchData(agent, r, &pmessage.Message{Type: typ, ID: mid, Route: route})
+	}
+
+	dispatch(pmessage.Request, 1, "game.player.buy")
+	dispatch(pmessage.Notify, 0, "game.player.buy")
+	dispatch(pmessage.Request, 2, "game.player.login")
+	dispatch(pmessage.Notify, 0, "game.player.chat")
+
+	if len(routed) != 2 || routed[0] != "game.player.login" || routed[1] != "game.player.chat" {
+		t.Fatalf("anonymous routed = %v", routed)
+	}
+
+	// the request is responded, the notify is dropped
+	if len(agent.chPending) != 1 {
+		t.Fatalf("pending = %d", len(agent.chPending))
+	}
+
+	pending := <-agent.chPending
+	rsp, ok := pending.payload.(*cproto.Response)
+	if !ok || pending.mid != 1 || !pending.err || rsp.Code != ccode.SessionUnauthorized {
+		t.Fatalf("pending = %s", pending.String())
+	}
+
+	// bound
+	routed = nil
+	agent.session.Uid = 6001
+
+	dispatch(pmessage.Request, 3, "game.player.login")
+	dispatch(pmessage.Request, 4, "game.player.buy")
+	dispatch(pmessage.Notify, 0, "game.player.chat")
+
+	if len(routed) != 2 || routed[0] != "game.player.buy" || routed[1] != "game.player.chat" {
+		t.Fatalf("bound routed = %v", routed)
+	}
+
+	if pending = <-agent.chPending; pending.mid != 3 {
+		t.Fatalf("pending = %s", pending.String())
+	}
+}
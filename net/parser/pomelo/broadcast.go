@@ -0,0 +1,139 @@
+package pomelo
+
+import (
+	"sync/atomic"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+)
+
+const (
+	BroadcastSent    BroadcastOutcome = 0 // the message is queued
+	BroadcastSkipped BroadcastOutcome = 1 // the member is saturated, the message is dropped
+	BroadcastKicked  BroadcastOutcome = 2 // the member is saturated and kicked with KickSlowConsumer
+	BroadcastFailed  BroadcastOutcome = 3 // the push is failed, the error is returned as PushError
+)
+
+type (
+	// BroadcastOpts the back-pressure of the broadcast, the slow consumers do not delay the fan-out.
+	// see Group.BroadcastWith
+	BroadcastOpts struct {
+		SkipSaturated bool // skip the members with more than MaxPending bytes waiting for write
+		MaxPending    int  // threshold of Agent.PendingSendBytes, zero is disabled
+		KickSaturated bool // kick the saturated members instead of skipping them
+	}
+
+	// BroadcastOutcome the result of the broadcast to a member
+	BroadcastOutcome int32
+
+	// BroadcastReport the outcomes of the broadcast by sid
+	BroadcastReport map[cfacade.SID]BroadcastOutcome
+)
+
+// BroadcastWith push the message to the members, the saturated members are skipped or kicked by opts.
+// the dropped messages are counted in Stats().SlowConsumerDrops. returns the outcome of each member,
+// and the failed members are returned as PushError.
+func (g *Group) BroadcastWith(route string, v interface{}, opts BroadcastOpts) (BroadcastReport, error) {
+	return broadcastWith(g.agents(), route, v, opts)
+}
+
+// BroadcastToTagWith push the message to the agents with the tag. see Group.BroadcastWith
+func BroadcastToTagWith(tag, route string, v interface{}, opts BroadcastOpts) (BroadcastReport, error) {
+	return broadcastWith(tagAgents(tag), route, v, opts)
+}
+
+func (p BroadcastOpts) saturated(agent *Agent) bool {
+	return p.SkipSaturated && p.MaxPending > 0 && agent.PendingSendBytes() > p.MaxPending
+}
+
+func broadcastWith(list []*Agent, route string, v interface{}, opts BroadcastOpts) (BroadcastReport, error) {
+	report := make(BroadcastReport, len(list))
+	sendable := make([]*Agent, 0, len(list))
+
+	for _, agent := range list {
+		if !opts.saturated(agent) {
+			sendable = append(sendable, agent)
+			continue
+		}
+
+		atomic.AddInt64(&counters.slowConsumerDrops, 1)
+
+		if opts.KickSaturated {
+			agent.KickWith(KickReason{
+				Code:    KickCodeSlowConsumer,
+				Message: KickSlowConsumer,
+			}, true)
+			report[agent.SID()] = BroadcastKicked
+			continue
+		}
+
+		report[agent.SID()] = BroadcastSkipped
+	}
+
+	err := multiPush(sendable, route, v, PushError{})
+	errs, _ := err.(PushError)
+
+	for _, agent := range sendable {
+		if _, failed := errs[agent.SID()]; failed || (err != nil && errs == nil) {
+			report[agent.SID()] = BroadcastFailed
+			continue
+		}
+		report[agent.SID()] = BroadcastSent
+	}
+
+	return report, err
+}
+
+// BroadcastAll push the announcement to all agents matching the filter(nil is all), eg. the server is restarting.
+// the message is sent with PriorityHigh, so it's written before the queued messages. the payload is encoded once
+// unless the pushes are numbered by the delivery mode. the filter runs on a snapshot of the agents, so it can call
+// the methods of the agent. the agents closed during the broadcast are not sent and not failed.
+// returns the number of the sent agents, and the failed agents.
+func BroadcastAll(route string, v interface{}, filter func(a *Agent) bool) (int, PushError) {
+	var (
+		sent   int
+		errs   = PushError{}
+		shared = newSharedPush(route, v)
+	)
+
+	for _, agent := range agents() {
+		if filter != nil && !filter(agent) {
+			continue
+		}
+
+		if err := broadcastPriority(agent, shared, route, v); err != nil {
+			if err != cerr.SessionClosed {
+				errs[agent.SID()] = err
+			}
+			continue
+		}
+
+		sent++
+	}
+
+	return sent, errs
+}
+
+// broadcastPriority the packet is encoded by the agent if it's not sharable, see sharable
+func broadcastPriority(agent *Agent, shared *sharedPush, route string, v interface{}) error {
+	if !sharable() {
+		return agent.PushPriority(PriorityHigh, route, v)
+	}
+
+	if agent.IsClosed() {
+		return cerr.SessionClosed
+	}
+
+	pkg, err := shared.packet(agent)
+	if err != nil {
+		return err
+	}
+
+	return agent.pushPriority(&pendingMessage{
+		typ:      pomeloMessage.Push,
+		route:    route,
+		priority: PriorityHigh,
+		pkg:      pkg,
+	})
+}
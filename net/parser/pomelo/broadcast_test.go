@@ -0,0 +1,142 @@
+package pomelo
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+func TestBroadcastWith(t *testing.T) {
+	group := NewGroup("test-saturated")
+	defer group.Close()
+
+	fast := newTestAgent("saturated-fast")
+	slow := newTestAgent("saturated-slow")
+	_ = group.Add(fast)
+	_ = group.Add(slow)
+
+	// the write goroutine is not running, the bytes are pending
+	if err := slow.sendBytes([]byte(strings.Repeat("x", 128))); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := Stats()
+	opts := BroadcastOpts{SkipSaturated: true, MaxPending: 64}
+
+	report, err := group.BroadcastWith("test.push", "hello", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report[fast.SID()] != BroadcastSent || report[slow.SID()] != BroadcastSkipped {
+		t.Fatalf("report = %v", report)
+	}
+
+	if len(fast.chWrite) != 1 || len(slow.chWrite) != 1 || Stats().SlowConsumerDrops != stats.SlowConsumerDrops+1 {
+		t.Fatalf("queued = %d, %d, drops = %d", len(fast.chWrite), len(slow.chWrite), Stats().SlowConsumerDrops-stats.SlowConsumerDrops)
+	}
+
+	// the check is disabled
+	if report, _ = group.BroadcastWith("test.push", "hello", BroadcastOpts{MaxPending: 64}); report[slow.SID()] != BroadcastSent {
+		t.Fatalf("report = %v", report)
+	}
+
+	opts.KickSaturated = true
+	if report, _ = group.BroadcastWith("test.push", "hello", opts); report[slow.SID()] != BroadcastKicked || !slow.IsClosed() {
+		t.Fatalf("report = %v, closed = %v", report, slow.IsClosed())
+	}
+
+	if Stats().Kicks[KickSlowConsumer] != stats.Kicks[KickSlowConsumer]+1 {
+		t.Fatalf("kicks = %v", Stats().Kicks)
+	}
+
+	// the closed member is failed
+	report, err = group.BroadcastWith("test.push", "hello", BroadcastOpts{})
+	if errs, ok := err.(PushError); !ok || errs[slow.SID()] == nil || report[slow.SID()] != BroadcastFailed || report[fast.SID()] != BroadcastSent {
+		t.Fatalf("report = %v, err = %v", report, err)
+	}
+}
+
+func TestBroadcastAll(t *testing.T) {
+	defer func() {
+		cmd.onPacketFuncMap = make(map[pomeloPacket.Type]PacketFunc, 4)
+		cmd.deliveryMode = DeliveryNone
+	}()
+	cmd.setOnPacketFunc()
+
+	announced := func(a *Agent) bool {
+		return strings.HasPrefix(a.SID(), "announce-") && a.SID() != "announce-filtered"
+	}
+
+	var list []*Agent
+	for _, sid := range []string{"announce-1", "announce-2", "announce-closed", "announce-filtered"} {
+		agent := newTestAgent(sid)
+		BindSID(agent)
+		defer Unbind(sid)
+		list = append(list, agent)
+	}
+	list[2].Close()
+
+	sent, errs := BroadcastAll("test.announce", "restart in 5 minutes", announced)
+	if sent != 2 || len(errs) > 0 {
+		t.Fatalf("sent = %d, errs = %v", sent, errs)
+	}
+
+	// the packet is shared and queued with the high priority
+	first, second := list[0].prio.items[0], list[1].prio.items[0]
+	if first.priority != PriorityHigh || &first.pending.pkg[0] != &second.pending.pkg[0] || list[3].prio.queued != 0 {
+		t.Fatalf("queued = %d, %d, %d", list[0].prio.queued, list[1].prio.queued, list[3].prio.queued)
+	}
+
+	// the numbered announcement is encoded by each agent
+	(&actor{}).SetDeliveryMode(DeliveryAtLeastOnce)
+	sent, errs = BroadcastAll("test.announce", "restart in 5 minutes", announced)
+
+	if sent != 2 || len(errs) > 0 {
+		t.Fatalf("sent = %d, errs = %v", sent, errs)
+	}
+
+	for _, agent := range list[:2] {
+		item := agent.prio.items[len(agent.prio.items)-1]
+		if item.priority != PriorityHigh || item.pending.pkg != nil || agent.prio.queued != 2 {
+			t.Fatalf("agent %s queued = %d", agent.SID(), agent.prio.queued)
+		}
+
+		pkg, err := agent.encodePending(item.pending)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seq := pushSeq(t, pkg); seq != "1" || len(agent.delivery.unackedPackets()) != 1 {
+			t.Fatalf("agent %s seq = %s, kept = %d", agent.SID(), seq, len(agent.delivery.unackedPackets()))
+		}
+	}
+	cmd.deliveryMode = DeliveryNone
+
+	// the priority queue is full
+	backlog := atomic.LoadInt32(&cmd.writeBacklog)
+	atomic.StoreInt32(&cmd.writeBacklog, 1)
+	sent, errs = BroadcastAll("test.announce", "restart in 5 minutes", announced)
+	atomic.StoreInt32(&cmd.writeBacklog, backlog)
+
+	if sent != 0 || errs[list[0].SID()] != cerr.SessionSendQueueFull || errs[list[1].SID()] != cerr.SessionSendQueueFull {
+		t.Fatalf("sent = %d, errs = %v", sent, errs)
+	}
+
+	// the announcement is written to the running agent
+	agent, peer := handshakeAgent(t, "announce-running", nil)
+	if sent, errs = BroadcastAll("test.announce", "restart in 5 minutes", func(a *Agent) bool {
+		return a == agent
+	}); sent != 1 || len(errs) > 0 {
+		t.Fatalf("sent = %d, errs = %v", sent, errs)
+	}
+
+	msg, err := pomeloMessage.Decode(readData(t, peer))
+	if err != nil || msg.Route != "test.announce" || string(msg.Data) != `"restart in 5 minutes"` {
+		t.Fatalf("message = %+v, err = %v", msg, err)
+	}
+}
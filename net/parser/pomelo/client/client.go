@@ -265,6 +265,15 @@ func (p *Client) handlePackets() {
 
 					p.processMessage(&m)
 				}
+			case pomeloPacket.Heartbeat:
+				{
+					// echo the ping of the server as the pong
+					if len(pkg.Data()) > 0 {
+						if err := p.SendRaw(pomeloPacket.Heartbeat, pkg.Data()); err != nil {
+							clog.Warnf("[%s] send pong fail. %s", p.TagName, err.Error())
+						}
+					}
+				}
 			case pomeloPacket.Kick:
 				{
 					clog.Warnf("[%s] got kick packet from the server! disconnecting...", p.TagName)
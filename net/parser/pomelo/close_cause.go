@@ -0,0 +1,107 @@
+package pomelo
+
+import (
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+const (
+	CauseUnknown          CloseCause = iota // nothing set the cause, eg. Close by the application
+	CauseClientClosed                       // the client closed the connection
+	CauseReadError                          // read from the conn fail, eg. connection reset
+	CauseHeartbeatTimeout                   // no heartbeat from the client, see actor.SetHeartbeat
+	CauseWriteTimeout                       // the write is timeout, see actor.SetWriteTimeout
+	CauseProtocolError                      // the unknown packet type, handshake or decrypt fail
+	CauseHandshakeTimeout                   // see actor.SetHandshakeTimeout
+	CauseIdleTimeout                        // kicked by the idle timeout, see actor.SetIdleTimeout
+	CauseShutdown                           // kicked by Shutdown
+	CauseKick                               // kicked by other reasons, see KickReason
+	CauseTerminate                          // closed by Terminate, eg. ban
+	causeMax
+)
+
+type (
+	// CloseCause why the agent is closed, see Agent.CloseCause
+	CloseCause int32
+
+	closeState struct {
+		cause CloseCause
+		err   error
+	}
+)
+
+var (
+	causeNames = [causeMax]string{
+		CauseUnknown:          "unknown",
+		CauseClientClosed:     "client_closed",
+		CauseReadError:        "read_error",
+		CauseHeartbeatTimeout: "heartbeat_timeout",
+		CauseWriteTimeout:     "write_timeout",
+		CauseProtocolError:    "protocol_error",
+		CauseHandshakeTimeout: "handshake_timeout",
+		CauseIdleTimeout:      "idle_timeout",
+		CauseShutdown:         "shutdown",
+		CauseKick:             "kick",
+		CauseTerminate:        "terminate",
+	}
+)
+
+func (c CloseCause) String() string {
+	if c < 0 || c >= causeMax {
+		return "unknown"
+	}
+	return causeNames[c]
+}
+
+// CloseCause returns why the agent is closed, it's set once before the agent is closed,
+// so it's readable in the onClose listeners. returns CauseUnknown if the agent is not closed by a known cause.
+func (a *Agent) CloseCause() CloseCause {
+	if state, _ := a.closeCause.Load().(*closeState); state != nil {
+		return state.cause
+	}
+	return CauseUnknown
+}
+
+// CloseError returns the underlying error of the close cause, eg. the read error. nil if there is none
+func (a *Agent) CloseError() error {
+	if state, _ := a.closeCause.Load().(*closeState); state != nil {
+		return state.err
+	}
+	return nil
+}
+
+// setCloseCause the first cause wins, it must be called before the agent is closed
+func (a *Agent) setCloseCause(cause CloseCause, err error) {
+	a.closeCause.CompareAndSwap(nil, &closeState{cause: cause, err: err})
+}
+
+// readCause the cause of the read goroutine is broken
+func readCause(err error) CloseCause {
+	if err == cerr.PacketConnectClosed {
+		return CauseClientClosed
+	}
+	return CauseReadError
+}
+
+// kickCause the cause of the reason sent by Kick
+func kickCause(reason interface{}) CloseCause {
+	var code int32 = -1
+	switch r := reason.(type) {
+	case KickReason:
+		code = r.Code
+	case *KickReason:
+		if r != nil {
+			code = r.Code
+		}
+	}
+
+	switch code {
+	case KickCodeIdleTimeout:
+		return CauseIdleTimeout
+	case KickCodeShutdown:
+		return CauseShutdown
+	case KickCodeHeartbeat:
+		return CauseHeartbeatTimeout
+	default:
+		return CauseKick
+	}
+}
@@ -0,0 +1,75 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+// closeCauseOf run the agent, close it by fn and returns the cause read in the onClose listener
+func closeCauseOf(t *testing.T, agent *Agent, fn func()) (CloseCause, error) {
+	type result struct {
+		cause CloseCause
+		err   error
+	}
+
+	ch := make(chan result, 1)
+	agent.AddOnClose(func(a *Agent) {
+		ch <- result{a.CloseCause(), a.CloseError()}
+	})
+
+	BindSID(agent)
+	agent.Run()
+	fn()
+
+	select {
+	case r := <-ch:
+		return r.cause, r.err
+	case <-time.After(time.Second):
+		t.Fatal("onClose is not fired")
+		return CauseUnknown, nil
+	}
+}
+
+func TestCloseCause(t *testing.T) {
+	agent := newTestAgent("cause-unknown")
+	if cause, _ := closeCauseOf(t, agent, agent.Close); cause != CauseUnknown {
+		t.Fatalf("close cause = %s", cause)
+	}
+
+	// the client closed the connection
+	server, client := newTCPPair(t)
+	agent = newTestAgent("cause-client")
+	agent.conn = server
+	if cause, err := closeCauseOf(t, agent, func() { _ = client.Close() }); cause != CauseClientClosed || err != cerr.PacketConnectClosed {
+		t.Fatalf("close cause = %s, err = %v", cause, err)
+	}
+
+	agent = newTestAgent("cause-read")
+	if cause, err := closeCauseOf(t, agent, func() { _ = agent.conn.Close() }); cause != CauseReadError || err == nil {
+		t.Fatalf("close cause = %s, err = %v", cause, err)
+	}
+
+	agent = newTestAgent("cause-idle")
+	if cause, _ := closeCauseOf(t, agent, func() {
+		_ = agent.KickWith(KickReason{Code: KickCodeIdleTimeout, Message: KickIdleTimeout}, true)
+	}); cause != CauseIdleTimeout {
+		t.Fatalf("close cause = %s", cause)
+	}
+
+	agent = newTestAgent("cause-kick")
+	if cause, _ := closeCauseOf(t, agent, func() { _ = agent.Kick("banned", true) }); cause != CauseKick {
+		t.Fatalf("close cause = %s", cause)
+	}
+
+	agent = newTestAgent("cause-terminate")
+	if cause, _ := closeCauseOf(t, agent, func() { _ = agent.Terminate("banned") }); cause != CauseTerminate {
+		t.Fatalf("close cause = %s", cause)
+	}
+
+	// the first cause wins, the read error after the kick is not the cause
+	if agent.setCloseCause(CauseReadError, nil); agent.CloseCause() != CauseTerminate || agent.CloseCause().String() != "terminate" {
+		t.Fatalf("close cause = %s", agent.CloseCause())
+	}
+}
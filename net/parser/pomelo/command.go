@@ -1,6 +1,8 @@
 package pomelo
 
 import (
+	"runtime/debug"
+	"sync/atomic"
 	"time"
 
 	cfacade "github.com/cherry-game/cherry/facade"
@@ -13,39 +15,102 @@ import (
 
 type (
 	Command struct {
-		writeBacklog    int
-		sysData         map[string]interface{}
-		heartbeatTime   time.Duration
-		handshakeBytes  []byte
-		heartbeatBytes  []byte
-		onPacketFuncMap map[ppacket.Type]PacketFunc
-		onDataRouteFunc DataRouteFunc
+		writeBacklog      int32 // size of the send queues of the new agent
+		sysData           map[string]interface{}
+		heartbeatTime     time.Duration
+		heartbeatMissed   int32 // kick the agent after the missed heartbeats, zero is disabled
+		idleTimeout       time.Duration
+		reconnectGrace    time.Duration
+		handshakeBytes    []byte
+		heartbeatBytes    []byte
+		onPacketFuncMap   map[ppacket.Type]PacketFunc
+		onDataRouteFunc   DataRouteFunc
+		bindPolicy        BindPolicy
+		overflowPolicy    OverflowPolicy
+		messageRate       int32 // inbound messages per second, zero is unlimited
+		messageBurst      int32 // burst of the inbound messages
+		rateViolation     int32 // kick after continuous dropped messages, zero is disabled
+		onCreateFuncs     []OnCreateFunc
+		onBindFuncs       []OnBindFunc
+		onUnbindFuncs     []OnUnbindFunc
+		outboundFilters   []OutboundFilter
+		encryption        bool  // encrypt the data packets with the key exchanged in the handshake
+		maxAsyncCalls     int32 // pending RPCAsync calls of each agent, zero is unlimited
+		shutdownDrain     bool  // write the queued messages before the agents are kicked by Shutdown()
+		panicPolicy       PanicPolicy
+		panicResponse     bool  // response HandlerPanic to the request when the handler panic
+		maxSessions       int64 // online agents limit of the node, zero is unlimited. it's changed at runtime by atomic
+		sidGenerator      SIDGenerator
+		writeTimeout      time.Duration         // deadline of each write to the conn, zero is disabled
+		writeTimeoutClose int32                 // 1: close the agent on the write timeout
+		eventFuncs        [eventMax][]EventFunc // see actor.On
+		deliveryMode      DeliveryMode
+		replaySize        int32                      // the kept pushes of each agent for the reconnect, see actor.SetReplayBuffer
+		dedupWindow       time.Duration              // the window of the request dedup, zero is disabled
+		dedupResponses    bool                       // replay the cached response of the duplicated request
+		readBuffer        int32                      // kernel read buffer and bufio size of the new connections, zero is the os default
+		writeBuffer       int32                      // kernel write buffer of the new connections, zero is the os default
+		routeAuth         map[string]AuthRequirement // see actor.SetRouteAuth
+		snapshotRedact    map[string]struct{}        // the keys of the session data are not in the snapshot
+		maxMessageSize    int32                      // max data bytes of the inbound messages, zero is unlimited
+		routeMessageSize  map[string]int32           // max data bytes of the route, see actor.SetMaxMessageSize
+		sizeViolation     int32                      // kick after the oversized messages of the agent, zero is disabled
+		maxMalformed      int32                      // kick after the malformed packets of the agent, zero is disabled
+		dispatchPool      *dispatchPool              // nil is DispatchPerConnection, see actor.SetDispatchMode
+		handshakeTimeout  time.Duration              // close the agent not working after it, zero is disabled
+		responseTimeout   time.Duration              // respond ccode.ResponseTimeout to the request after it, zero is disabled
+		sessionStore      atomic.Value               // *sessionStorer, nil is disabled. see actor.EnableSessionStore
+		pauseBacklog      int32                      // the parked data packets of the paused agent, see actor.SetRecvPause
+		pausePolicy       OverflowPolicy             // the policy of the parked data packets are full
 	}
 
 	PacketFunc    func(agent *Agent, packet *ppacket.Packet)
 	DataRouteFunc func(agent *Agent, route *pmessage.Route, msg *pmessage.Message)
+	OnCreateFunc  func(agent *Agent) bool // returns false to reject the connection
+	OnBindFunc    func(agent *Agent) bool // returns false to reject the bind
+	OnUnbindFunc  func(agent *Agent)
+	// OutboundFilter returns the replaced payload, or false to drop the message.
+	// route is empty for Response and SendRaw, payload is the raw bytes for SendRaw.
+	OutboundFilter func(agent *Agent, route string, payload []byte) ([]byte, bool)
+	BindPolicy     int32 // policy of the uid bound on other agent
+)
+
+const (
+	BindPolicyKick   BindPolicy = 0 // kick the old agent
+	BindPolicyReject BindPolicy = 1 // reject the new bind
 )
 
 const (
 	DataHeartbeat  = "heartbeat"
 	DataDict       = "dict"
 	DataSerializer = "serializer"
+	DataPublicKey  = "publicKey" // X25519 public key(base64) in the sys of the handshake, see actor.SetEncryption
 )
 
 var (
 	cmd = Command{
-		writeBacklog:    64,
-		sysData:         make(map[string]interface{}),
-		heartbeatTime:   60 * time.Second,
-		handshakeBytes:  make([]byte, 0),
-		heartbeatBytes:  make([]byte, 0),
-		onPacketFuncMap: make(map[ppacket.Type]PacketFunc, 4),
-		onDataRouteFunc: DefaultDataRoute,
+		writeBacklog:     64,
+		pauseBacklog:     64,
+		sysData:          make(map[string]interface{}),
+		heartbeatTime:    60 * time.Second,
+		idleTimeout:      0,
+		reconnectGrace:   0,
+		bindPolicy:       BindPolicyKick,
+		maxAsyncCalls:    64,
+		panicPolicy:      PanicPolicyContinue,
+		panicResponse:    true,
+		handshakeBytes:   make([]byte, 0),
+		heartbeatBytes:   make([]byte, 0),
+		onPacketFuncMap:  make(map[ppacket.Type]PacketFunc, 4),
+		onDataRouteFunc:  DefaultDataRoute,
+		sidGenerator:     NuidSID,
+		routeAuth:        make(map[string]AuthRequirement),
+		routeMessageSize: make(map[string]int32),
 	}
 )
 
 func (p *Command) init(app cfacade.IApplication) {
-	p.setData(DataHeartbeat, p.heartbeatTime.Seconds())
+	p.setData(DataHeartbeat, loadDuration(&p.heartbeatTime).Seconds())
 	p.setData(DataDict, pmessage.GetDictionary())
 	p.setData(DataSerializer, app.Serializer().Name())
 
@@ -109,15 +174,50 @@ func (p *Command) setOnPacketFunc() {
 	}
 }
 
-func handshakeCommand(agent *Agent, _ *ppacket.Packet) {
+func handshakeCommand(agent *Agent, pkg *ppacket.Packet) {
+	if cmd.encryption {
+		encryptedHandshakeCommand(agent, pkg)
+		return
+	}
+
+	agent.negotiateHandshake(pkg)
+	agent.SetState(AgentWaitAck)
+	agent.sendBytes(cmd.handshakeBytes)
+
+	if agent.PrintLevel(zapcore.DebugLevel) {
+		agent.Debugf("Request handshake. [address = %s]",
+			agent.RemoteAddress(),
+		)
+	}
+}
+
+func encryptedHandshakeCommand(agent *Agent, pkg *ppacket.Packet) {
+	// the key is exchanged only once, the counters can not be reset
+	if agent.IsEncrypted() {
+		agent.Warnf("Handshake repeated, close connect! [address = %s]", agent.RemoteAddress())
+		agent.setCloseCause(CauseProtocolError, nil)
+		agent.Close()
+		return
+	}
+
+	handshakeBytes, err := agent.encryptedHandshake(pkg)
+	if err != nil {
+		agent.Warnf("Handshake key exchange fail, close connect! [address = %s, error = %s]",
+			agent.RemoteAddress(),
+			err,
+		)
+		agent.write(handshakeFailBytes())
+		agent.setCloseCause(CauseProtocolError, err)
+		agent.Close()
+		return
+	}
+
 	agent.SetState(AgentWaitAck)
-	agent.SendRaw(cmd.handshakeBytes)
+	agent.sendBytes(handshakeBytes)
 
-	if clog.PrintLevel(zapcore.DebugLevel) {
-		clog.Debugf("[sid = %s,uid = %d] Request handshake. [address = %s]",
-			agent.SID(),
-			agent.UID(),
-			agent.RemoteAddr(),
+	if agent.PrintLevel(zapcore.DebugLevel) {
+		agent.Debugf("Request encrypted handshake. [address = %s]",
+			agent.RemoteAddress(),
 		)
 	}
 }
@@ -125,50 +225,71 @@ func handshakeCommand(agent *Agent, _ *ppacket.Packet) {
 func handshakeACKCommand(agent *Agent, _ *ppacket.Packet) {
 	agent.SetState(AgentWorking)
 
-	if clog.PrintLevel(zapcore.DebugLevel) {
-		clog.Debugf("[sid = %s,uid = %d] request handshakeACK. [address = %s]",
-			agent.SID(),
-			agent.UID(),
-			agent.RemoteAddr(),
+	if agent.PrintLevel(zapcore.DebugLevel) {
+		agent.Debugf("request handshakeACK. [address = %s]",
+			agent.RemoteAddress(),
 		)
 	}
 }
 
-func heartbeatCommand(agent *Agent, _ *ppacket.Packet) {
-	agent.SendRaw(cmd.heartbeatBytes)
+func heartbeatCommand(agent *Agent, pkg *ppacket.Packet) {
+	agent.Heartbeat()
+
+	// the pong of the server ping is not echoed, see Agent.Ping
+	if pkg != nil && len(pkg.Data()) > 0 {
+		agent.pong(pkg.Data())
+		return
+	}
+
+	agent.sendBytes(cmd.heartbeatBytes)
 }
 
 func dataCommand(agent *Agent, pkg *ppacket.Packet) {
 	if agent.State() != AgentWorking {
-		if clog.PrintLevel(zapcore.DebugLevel) {
-			clog.Warnf("[sid = %s,uid = %d] Data State is not working. [state = %d]",
-				agent.SID(),
-				agent.UID(),
+		if agent.PrintLevel(zapcore.DebugLevel) {
+			agent.Warnf("Data State is not working. [state = %d]",
 				agent.State(),
 			)
 		}
 		return
 	}
 
-	msg, err := pmessage.Decode(pkg.Data())
-	if err != nil {
-		if clog.PrintLevel(zapcore.DebugLevel) {
-			clog.Warnf("[sid = %s,uid = %d] Data message decode error. [data = %s, error = %s]",
-				agent.SID(),
-				agent.UID(),
-				pkg.Data(),
-				err,
-			)
+	if !agent.AllowMessage() {
+		return
+	}
+
+	// no new handler work after Shutdown() started
+	if isShuttingDown() {
+		return
+	}
+
+	agent.SetLastActiveAt()
+
+	data := pkg.Data()
+	if cmd.encryption {
+		var err error
+		if data, err = agent.openData(data); err != nil {
+			agent.Warnf("Data decrypt error, close connect! [error = %s]", err)
+			agent.setCloseCause(CauseProtocolError, err)
+			agent.Close()
+			return
 		}
+	}
+
+	msg, err := pmessage.Decode(data)
+	if err != nil {
+		agent.malformedPacket(err, data)
+		return
+	}
+
+	if !agent.allowMessageSize(&msg) {
 		return
 	}
 
 	route, err := pmessage.DecodeRoute(msg.Route)
 	if err != nil {
-		if clog.PrintLevel(zapcore.DebugLevel) {
-			clog.Warnf("[sid = %s,uid = %d] Data Message decode route error. [data = %s, error = %s]",
-				agent.SID(),
-				agent.UID(),
+		if agent.PrintLevel(zapcore.DebugLevel) {
+			agent.Warnf("Data Message decode route error. [data = %s, error = %s]",
 				pkg.Data(),
 				err,
 			)
@@ -176,5 +297,23 @@ func dataCommand(agent *Agent, pkg *ppacket.Packet) {
 		return
 	}
 
-	cmd.onDataRouteFunc(agent, route, &msg)
+	atomic.AddInt64(&counters.messagesIn, 1)
+	agent.deliver(route, &msg)
+}
+
+// dispatchData the panic of the route func is recovered, the read goroutine of the agent keeps running
+func dispatchData(agent *Agent, route *pmessage.Route, msg *pmessage.Message) {
+	defer func() {
+		if rev := recover(); rev != nil {
+			agent.Errorf("Handler panic. [route = %s, err = %v]\n%s", msg.Route, rev, debug.Stack())
+			onHandlerPanic(agent, msg.Type, uint32(msg.ID))
+		}
+	}()
+
+	if !agent.authorized(msg) || agent.duplicateRequest(msg) {
+		return
+	}
+
+	agent.watchResponse(msg)
+	cmd.onDataRouteFunc(agent, route, msg)
 }
@@ -0,0 +1,46 @@
+package pomelo
+
+import (
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// CompressionEnabled returns true if the pushes and responses larger than the min bytes are compressed,
+// the client sends the codecs it can inflate in sys.compression of the handshake. eg. {"sys":{"compression":["zlib"]}}.
+// the data to the legacy clients without it is never compressed. see actor.SetCompression
+func (a *Agent) CompressionEnabled() bool {
+	codec := a.NegotiatedCodec()
+	return codec != "" && pomeloMessage.IsDataCompression() && codec == pomeloMessage.CodecName()
+}
+
+// NegotiatedCodec returns the codec negotiated in the handshake, empty if the client does not support the codec of the server
+func (a *Agent) NegotiatedCodec() string {
+	codec, _ := a.codec.Load().(string)
+	return codec
+}
+
+// negotiateHandshake negotiate the codec with the plain handshake, the legacy clients may send nothing
+func (a *Agent) negotiateHandshake(pkg *pomeloPacket.Packet) {
+	req := handshakeRequest{}
+	if pkg != nil && len(pkg.Data()) > 0 {
+		_ = jsoniter.Unmarshal(pkg.Data(), &req)
+	}
+
+	a.negotiateCodec(req.Sys.Compression)
+}
+
+// negotiateCodec the codec of the server is used if the client can inflate it
+func (a *Agent) negotiateCodec(codecs []string) {
+	var negotiated string
+	if pomeloMessage.IsDataCompression() {
+		for _, codec := range codecs {
+			if codec == pomeloMessage.CodecName() {
+				negotiated = codec
+				break
+			}
+		}
+	}
+
+	a.codec.Store(negotiated)
+}
@@ -0,0 +1,141 @@
+package pomelo
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+// handshakeAgent run the agent and handshake with the data, returns the agent and the peer conn
+func handshakeAgent(t *testing.T, sid string, handshake []byte) (*Agent, net.Conn) {
+	agent := newTestAgent(sid)
+	conn, peer := net.Pipe()
+	agent.conn = conn
+
+	BindSID(agent)
+	agent.Run()
+	t.Cleanup(func() {
+		agent.Close()
+		peer.Close()
+	})
+
+	writePacket(t, peer, pomeloPacket.Handshake, handshake)
+	writePacket(t, peer, pomeloPacket.HandshakeAck, nil)
+
+	for agent.State() != AgentWorking {
+		time.Sleep(time.Millisecond)
+	}
+
+	return agent, peer
+}
+
+// readData returns the raw message of the next data packet, the handshake response is skipped
+func readData(t *testing.T, peer net.Conn) []byte {
+	for {
+		if pkg := readPacket(t, peer); pkg.Type() == pomeloPacket.Data {
+			return pkg.Data()
+		}
+	}
+}
+
+// handshakePush handshake with the data and returns the raw message of the push above the min bytes
+func handshakePush(t *testing.T, sid string, handshake []byte) (*Agent, []byte) {
+	agent, peer := handshakeAgent(t, sid, handshake)
+	if err := agent.Push("test.push", announcement); err != nil {
+		t.Fatal(err)
+	}
+
+	return agent, readData(t, peer)
+}
+
+var announcement = strings.Repeat("compress", 64)
+
+func TestCompressionNegotiation(t *testing.T) {
+	defer func() {
+		pomeloMessage.SetDataCompression(false)
+		cmd.onPacketFuncMap = make(map[pomeloPacket.Type]PacketFunc, 4)
+	}()
+
+	cmd.setOnPacketFunc()
+	if err := pomeloMessage.SetCompression(pomeloMessage.CodecZlib, 16); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client can inflate the codec of the server
+	agent, data := handshakePush(t, "compression-zlib", []byte(`{"sys":{"compression":["gzip","zlib"]}}`))
+	if !agent.CompressionEnabled() || agent.NegotiatedCodec() != pomeloMessage.CodecZlib {
+		t.Fatalf("enabled = %v, codec = %s", agent.CompressionEnabled(), agent.NegotiatedCodec())
+	}
+	if data[0]&pomeloMessage.GZIPMask == 0 {
+		t.Fatal("the push is not compressed")
+	}
+
+	// the legacy client without the capability
+	agent, data = handshakePush(t, "compression-legacy", nil)
+	if agent.CompressionEnabled() || agent.NegotiatedCodec() != "" {
+		t.Fatalf("enabled = %v, codec = %s", agent.CompressionEnabled(), agent.NegotiatedCodec())
+	}
+	if data[0]&pomeloMessage.GZIPMask != 0 {
+		t.Fatal("the push to the legacy client is compressed")
+	}
+
+	msg, err := pomeloMessage.Decode(data)
+	if err != nil || !strings.Contains(string(msg.Data), "compress") {
+		t.Fatalf("push message = %+v, err = %v", msg, err)
+	}
+
+	// the client can not inflate the codec of the server
+	agent, data = handshakePush(t, "compression-gzip", []byte(`{"sys":{"compression":["gzip"]}}`))
+	if agent.CompressionEnabled() || data[0]&pomeloMessage.GZIPMask != 0 {
+		t.Fatalf("enabled = %v, codec = %s", agent.CompressionEnabled(), agent.NegotiatedCodec())
+	}
+
+	// the shared packet of the batch push is encoded for the agents with and without the compression
+	zlib, zlibPeer := handshakeAgent(t, "compression-multi-zlib", []byte(`{"sys":{"compression":["zlib"]}}`))
+	legacy, legacyPeer := handshakeAgent(t, "compression-multi-legacy", nil)
+
+	if err := MultiPush([]string{legacy.SID(), zlib.SID()}, "test.push", announcement); err != nil {
+		t.Fatal(err)
+	}
+
+	if data = readData(t, legacyPeer); data[0]&pomeloMessage.GZIPMask != 0 {
+		t.Fatal("the batch push to the legacy client is compressed")
+	}
+	if data = readData(t, zlibPeer); data[0]&pomeloMessage.GZIPMask == 0 {
+		t.Fatal("the batch push is not compressed")
+	}
+
+	// the numbered batch push is encoded by each agent with its own sequence
+	(&actor{}).SetDeliveryMode(DeliveryAtLeastOnce)
+	defer func() {
+		cmd.deliveryMode = DeliveryNone
+	}()
+
+	if err := MultiPush([]string{legacy.SID(), zlib.SID()}, "test.push", announcement); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, peer := range []net.Conn{legacyPeer, zlibPeer} {
+		msg, err := pomeloMessage.Decode(readData(t, peer))
+		if err != nil || msg.Header[HeaderSeq] != "1" {
+			t.Fatalf("push message = %+v, err = %v", msg, err)
+		}
+	}
+
+	for _, agent := range []*Agent{legacy, zlib} {
+		if kept := len(agent.delivery.unackedPackets()); kept != 1 {
+			t.Fatalf("agent %s kept = %d", agent.SID(), kept)
+		}
+	}
+	cmd.deliveryMode = DeliveryNone
+
+	// the compression is disabled after the handshake
+	agent, _ = handshakePush(t, "compression-disabled", []byte(`{"sys":{"compression":["zlib"]}}`))
+	if pomeloMessage.SetDataCompression(false); agent.CompressionEnabled() {
+		t.Fatal("compression is enabled")
+	}
+}
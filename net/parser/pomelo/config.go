@@ -0,0 +1,131 @@
+package pomelo
+
+import (
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+)
+
+type (
+	// Config the tunables of the agents which can be changed at runtime by actor.ApplyConfig.
+	//
+	// take effect immediately:
+	//   MaxSessions, MaxSessionsPerIP: the new connections
+	//   MessageRate, MessageBurst, RateViolation: the next inbound message of all agents
+	//   MaxAsyncCalls: the next RPCAsync call of all agents
+	//   WriteTimeout, WriteTimeoutClose: the next write of all agents
+	//   CompressMinBytes: the next outbound message, the compression must be enabled by SetCompression
+	// take effect on the next check:
+	//   IdleTimeout: the next idle check(every second)
+	//   ReconnectGrace: the next lost agent, the retained agents keep the old grace
+	// take effect only for the new agents:
+	//   WriteBacklog: the size of the send queues
+	Config struct {
+		IdleTimeout       time.Duration // zero is disabled
+		ReconnectGrace    time.Duration // zero is disabled
+		MessageRate       int           // inbound messages per second of each agent, zero is unlimited
+		MessageBurst      int           // zero is the same as MessageRate
+		RateViolation     int           // kick after continuous dropped messages, zero is disabled
+		MaxAsyncCalls     int           // pending RPCAsync calls of each agent, zero is unlimited
+		MaxSessions       int           // online agents of the node, zero is unlimited
+		MaxSessionsPerIP  int           // online agents of each ip, zero is unlimited
+		WriteTimeout      time.Duration // deadline of each write, zero is disabled
+		WriteTimeoutClose bool          // close the agent on the write timeout
+		WriteBacklog      int           // must be greater than zero
+		CompressMinBytes  int           // the data less than min bytes is not compressed
+	}
+)
+
+// Validate returns the error of the negative value or the impossible combination
+func (c Config) Validate() error {
+	fields := []struct {
+		name  string
+		value int64
+	}{
+		{"IdleTimeout", int64(c.IdleTimeout)},
+		{"ReconnectGrace", int64(c.ReconnectGrace)},
+		{"WriteTimeout", int64(c.WriteTimeout)},
+		{"MessageRate", int64(c.MessageRate)},
+		{"MessageBurst", int64(c.MessageBurst)},
+		{"RateViolation", int64(c.RateViolation)},
+		{"MaxAsyncCalls", int64(c.MaxAsyncCalls)},
+		{"MaxSessions", int64(c.MaxSessions)},
+		{"MaxSessionsPerIP", int64(c.MaxSessionsPerIP)},
+		{"CompressMinBytes", int64(c.CompressMinBytes)},
+	}
+
+	for _, field := range fields {
+		if field.value < 0 {
+			return cerr.Errorf("config %s is negative. [value = %d]", field.name, field.value)
+		}
+	}
+
+	if c.WriteBacklog < 1 {
+		return cerr.Errorf("config WriteBacklog must be greater than zero. [value = %d]", c.WriteBacklog)
+	}
+
+	if c.MessageRate == 0 && (c.MessageBurst > 0 || c.RateViolation > 0) {
+		return cerr.Errorf("config MessageBurst and RateViolation require MessageRate. [burst = %d, violation = %d]",
+			c.MessageBurst,
+			c.RateViolation,
+		)
+	}
+
+	if c.MaxSessions > 0 && c.MaxSessionsPerIP > c.MaxSessions {
+		return cerr.Errorf("config MaxSessionsPerIP is greater than MaxSessions. [perIP = %d, max = %d]",
+			c.MaxSessionsPerIP,
+			c.MaxSessions,
+		)
+	}
+
+	return nil
+}
+
+// Config returns the current tunables, it's the base of ApplyConfig
+func (*actor) Config() Config {
+	return Config{
+		IdleTimeout:       loadDuration(&cmd.idleTimeout),
+		ReconnectGrace:    loadDuration(&cmd.reconnectGrace),
+		MessageRate:       int(atomic.LoadInt32(&cmd.messageRate)),
+		MessageBurst:      int(atomic.LoadInt32(&cmd.messageBurst)),
+		RateViolation:     int(atomic.LoadInt32(&cmd.rateViolation)),
+		MaxAsyncCalls:     int(atomic.LoadInt32(&cmd.maxAsyncCalls)),
+		MaxSessions:       int(atomic.LoadInt64(&cmd.maxSessions)),
+		MaxSessionsPerIP:  int(atomic.LoadInt64(&ipLimit.max)),
+		WriteTimeout:      loadDuration(&cmd.writeTimeout),
+		WriteTimeoutClose: atomic.LoadInt32(&cmd.writeTimeoutClose) == 1,
+		WriteBacklog:      int(atomic.LoadInt32(&cmd.writeBacklog)),
+		CompressMinBytes:  pmessage.CompressMinBytes(),
+	}
+}
+
+// ApplyConfig validate and apply the tunables at runtime, nothing is changed if it returns error.
+// each field is swapped by atomic, see Config for when the fields take effect.
+func (p *actor) ApplyConfig(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	storeDuration(&cmd.idleTimeout, c.IdleTimeout)
+	storeDuration(&cmd.reconnectGrace, c.ReconnectGrace)
+	p.SetMessageRate(c.MessageRate, c.MessageBurst)
+	p.SetRateViolation(c.RateViolation)
+	p.SetMaxAsyncCalls(c.MaxAsyncCalls)
+	p.SetMaxSessions(c.MaxSessions)
+	p.SetMaxSessionsPerIP(c.MaxSessionsPerIP)
+	p.SetWriteTimeout(c.WriteTimeout, c.WriteTimeoutClose)
+	p.SetWriteBacklog(c.WriteBacklog)
+	pmessage.SetCompressMinBytes(c.CompressMinBytes)
+
+	return nil
+}
+
+func loadDuration(d *time.Duration) time.Duration {
+	return time.Duration(atomic.LoadInt64((*int64)(d)))
+}
+
+func storeDuration(d *time.Duration, value time.Duration) {
+	atomic.StoreInt64((*int64)(d), int64(value))
+}
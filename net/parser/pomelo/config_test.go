@@ -0,0 +1,55 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyConfig(t *testing.T) {
+	p := &actor{}
+	old := p.Config()
+	defer func() {
+		if err := p.ApplyConfig(old); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	for _, c := range []Config{
+		{IdleTimeout: -time.Second, WriteBacklog: 64},
+		{MaxAsyncCalls: -1, WriteBacklog: 64},
+		{WriteBacklog: 0},
+		{MessageBurst: 5, WriteBacklog: 64},
+		{MaxSessions: 10, MaxSessionsPerIP: 20, WriteBacklog: 64},
+	} {
+		if err := p.ApplyConfig(c); err == nil {
+			t.Fatalf("config = %+v is applied", c)
+		}
+	}
+
+	// nothing is changed by the invalid config
+	if p.Config() != old {
+		t.Fatalf("config = %+v", p.Config())
+	}
+
+	c := old
+	c.IdleTimeout = time.Minute
+	c.MessageRate, c.MessageBurst, c.RateViolation = 10, 20, 3
+	c.MaxSessions, c.MaxSessionsPerIP = 100, 10
+	c.WriteTimeout, c.WriteTimeoutClose = time.Second, true
+	c.WriteBacklog = 8
+
+	if err := p.ApplyConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Config() != c {
+		t.Fatalf("config = %+v", p.Config())
+	}
+
+	// the new agent uses the new backlog
+	tmp := newTestAgent("apply-config")
+	agent := NewAgent(testApp{}, tmp.conn, tmp.session)
+	if cap(agent.chWrite) != 8 {
+		t.Fatalf("backlog = %d", cap(agent.chWrite))
+	}
+}
@@ -0,0 +1,58 @@
+package pomelo
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// agentContext the context of the agent lifetime, it's created on the first Context() call
+	agentContext struct {
+		sync.Mutex
+		ctx    context.Context
+		cancel context.CancelFunc
+	}
+)
+
+// Context returns the context canceled when the agent is closed,
+// the goroutines spawned by the agent should exit on ctx.Done().
+// the context of the closed agent is already canceled.
+func (a *Agent) Context() context.Context {
+	a.ctx.Lock()
+	defer a.ctx.Unlock()
+
+	return a.lazyContext()
+}
+
+// WithValue attach the value to the context of the agent, see Context()
+func (a *Agent) WithValue(key, val interface{}) {
+	a.ctx.Lock()
+	defer a.ctx.Unlock()
+
+	a.ctx.ctx = context.WithValue(a.lazyContext(), key, val)
+}
+
+// lazyContext must be called under the lock
+func (a *Agent) lazyContext() context.Context {
+	if a.ctx.ctx == nil {
+		a.ctx.ctx, a.ctx.cancel = context.WithCancel(context.Background())
+
+		if a.IsClosed() {
+			a.ctx.cancel()
+			a.ctx.cancel = nil
+		}
+	}
+
+	return a.ctx.ctx
+}
+
+// cancelContext called by Close(), the cancel func is invoked at most once
+func (a *Agent) cancelContext() {
+	a.ctx.Lock()
+	defer a.ctx.Unlock()
+
+	if a.ctx.cancel != nil {
+		a.ctx.cancel()
+		a.ctx.cancel = nil
+	}
+}
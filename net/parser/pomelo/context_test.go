@@ -0,0 +1,50 @@
+package pomelo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testContextKey struct{}
+
+func TestAgentContext(t *testing.T) {
+	agent := newTestAgent("agent-context")
+
+	ctx := agent.Context()
+	if ctx != agent.Context() {
+		t.Fatal("context is created again")
+	}
+
+	agent.WithValue(testContextKey{}, "trace-1")
+	ctx = agent.Context()
+	if ctx.Value(testContextKey{}) != "trace-1" {
+		t.Fatalf("value = %v", ctx.Value(testContextKey{}))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(done)
+	}()
+
+	agent.Close()
+	agent.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("context is not canceled")
+	}
+
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("err = %v", ctx.Err())
+	}
+
+	// created after close
+	closed := newTestAgent("agent-context-closed")
+	closed.Close()
+	if closed.Context().Err() != context.Canceled {
+		t.Fatal("context of the closed agent is not canceled")
+	}
+}
@@ -0,0 +1,270 @@
+package pomelo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	jsoniter "github.com/json-iterator/go"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	nonceCounterSize = 8 // big end counter in front of the sealed data
+	handshakeFail    = 500
+)
+
+var (
+	infoClientToServer = []byte("cherry pomelo client to server")
+	infoServerToClient = []byte("cherry pomelo server to client")
+)
+
+type (
+	// sessionCipher AES-GCM cipher of the data packets, the keys are derived from the X25519 key exchange in the handshake.
+	// the body of the encrypted data packet is 8 bytes counter + sealed data, each direction has its own key,
+	// the counter increases on every packet, so the nonce is never reused.
+	sessionCipher struct {
+		sealer cipher.AEAD
+		opener cipher.AEAD
+		sealed uint64 // last sealed counter, used in the write goroutine
+		opened uint64 // last opened counter, used in the read goroutine
+	}
+
+	// handshakeRequest the sys of the client handshake
+	handshakeRequest struct {
+		Sys struct {
+			PublicKey   string   `json:"publicKey"`
+			Compression []string `json:"compression"` // the codecs the client can inflate, see negotiateCodec()
+		} `json:"sys"`
+	}
+)
+
+// exchangeKey returns the public key of the server and the cipher of the shared secret with the peer public key
+func exchangeKey(peerPublicKey []byte) ([]byte, *sessionCipher, error) {
+	if len(peerPublicKey) != curve25519.PointSize {
+		return nil, nil, cerr.SessionKeyExchangeFail
+	}
+
+	privateKey := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(privateKey); err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// returns error if the peer public key is a low order point
+	shared, err := curve25519.X25519(privateKey, peerPublicKey)
+	if err != nil {
+		return nil, nil, cerr.SessionKeyExchangeFail
+	}
+
+	c, err := newSessionCipher(shared, peerPublicKey, publicKey, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return publicKey, c, nil
+}
+
+func newSessionCipher(shared, clientPublicKey, serverPublicKey []byte, isServer bool) (*sessionCipher, error) {
+	salt := make([]byte, 0, len(clientPublicKey)+len(serverPublicKey))
+	salt = append(salt, clientPublicKey...)
+	salt = append(salt, serverPublicKey...)
+
+	c2s, err := deriveAEAD(shared, salt, infoClientToServer)
+	if err != nil {
+		return nil, err
+	}
+
+	s2c, err := deriveAEAD(shared, salt, infoServerToClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if isServer {
+		return &sessionCipher{sealer: s2c, opener: c2s}, nil
+	}
+
+	return &sessionCipher{sealer: c2s, opener: s2c}, nil
+}
+
+func deriveAEAD(shared, salt, info []byte) (cipher.AEAD, error) {
+	key := make([]byte, 32) // AES-256
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, info), key); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (p *sessionCipher) nonce(counter uint64, size int) []byte {
+	nonce := make([]byte, size)
+	binary.BigEndian.PutUint64(nonce[size-nonceCounterSize:], counter)
+	return nonce
+}
+
+func (p *sessionCipher) seal(data []byte) ([]byte, error) {
+	if p.sealed == math.MaxUint64 {
+		return nil, cerr.PacketNonceReused
+	}
+	p.sealed++
+
+	dst := make([]byte, nonceCounterSize, nonceCounterSize+len(data)+p.sealer.Overhead())
+	binary.BigEndian.PutUint64(dst, p.sealed)
+
+	return p.sealer.Seal(dst, p.nonce(p.sealed, p.sealer.NonceSize()), data, nil), nil
+}
+
+// open returns cerr.PacketNonceReused if the counter is not greater than the last opened(replayed or reordered)
+func (p *sessionCipher) open(body []byte) ([]byte, error) {
+	if len(body) < nonceCounterSize {
+		return nil, cerr.PacketDecryptFail
+	}
+
+	counter := binary.BigEndian.Uint64(body)
+	if counter <= p.opened {
+		return nil, cerr.PacketNonceReused
+	}
+
+	data, err := p.opener.Open(nil, p.nonce(counter, p.opener.NonceSize()), body[nonceCounterSize:], nil)
+	if err != nil {
+		return nil, cerr.PacketDecryptFail
+	}
+
+	p.opened = counter
+	return data, nil
+}
+
+// sealPackets encrypt the body of the data packets in bytes, other packets are not encrypted
+func (p *sessionCipher) sealPackets(bytes []byte) ([]byte, error) {
+	packets, err := pomeloPacket.Decode(bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, 0, len(bytes)+len(packets)*(nonceCounterSize+p.sealer.Overhead()))
+	for _, pkg := range packets {
+		data := pkg.Data()
+		if pkg.Type() == pomeloPacket.Data {
+			if data, err = p.seal(data); err != nil {
+				return nil, err
+			}
+		}
+
+		encoded, err := pomeloPacket.Encode(pkg.Type(), data)
+		if err != nil {
+			return nil, err
+		}
+		sealed = append(sealed, encoded...)
+	}
+
+	return sealed, nil
+}
+
+func (a *Agent) getCipher() *sessionCipher {
+	c, _ := a.cipher.Load().(*sessionCipher)
+	return c
+}
+
+// IsEncrypted returns true if the key is exchanged in the handshake. see actor.SetEncryption
+func (a *Agent) IsEncrypted() bool {
+	return a.getCipher() != nil
+}
+
+// sealBytes encrypt the data packets, returns cerr.SessionNotEncrypted if the key has not been exchanged
+func (a *Agent) sealBytes(bytes []byte) ([]byte, error) {
+	if c := a.getCipher(); c != nil {
+		return c.sealPackets(bytes)
+	}
+
+	packets, err := pomeloPacket.Decode(bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// the data packets are never written in plain text
+	for _, pkg := range packets {
+		if pkg.Type() == pomeloPacket.Data {
+			return nil, cerr.SessionNotEncrypted
+		}
+	}
+
+	return bytes, nil
+}
+
+func (a *Agent) openData(data []byte) ([]byte, error) {
+	c := a.getCipher()
+	if c == nil {
+		return nil, cerr.SessionNotEncrypted
+	}
+
+	return c.open(data)
+}
+
+// encryptedHandshake exchange the key with the client public key and returns the handshake response bytes
+func (a *Agent) encryptedHandshake(pkg *pomeloPacket.Packet) ([]byte, error) {
+	req := handshakeRequest{}
+	if err := jsoniter.Unmarshal(pkg.Data(), &req); err != nil {
+		return nil, err
+	}
+
+	a.negotiateCodec(req.Sys.Compression)
+
+	peerPublicKey, err := base64.StdEncoding.DecodeString(req.Sys.PublicKey)
+	if err != nil {
+		return nil, cerr.SessionKeyExchangeFail
+	}
+
+	publicKey, c, err := exchangeKey(peerPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sys := make(map[string]interface{}, len(cmd.sysData)+1)
+	for k, v := range cmd.sysData {
+		sys[k] = v
+	}
+	sys[DataPublicKey] = base64.StdEncoding.EncodeToString(publicKey)
+
+	bytes, err := jsoniter.Marshal(map[string]interface{}{
+		"code": 200,
+		"sys":  sys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	handshakeBytes, err := pomeloPacket.Encode(pomeloPacket.Handshake, bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	a.cipher.Store(c)
+	return handshakeBytes, nil
+}
+
+// handshakeFailBytes the handshake response of the key exchange fail
+func handshakeFailBytes() []byte {
+	bytes, _ := jsoniter.Marshal(map[string]interface{}{
+		"code": handshakeFail,
+	})
+
+	pkg, _ := pomeloPacket.Encode(pomeloPacket.Handshake, bytes)
+	return pkg
+}
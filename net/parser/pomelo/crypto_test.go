@@ -0,0 +1,206 @@
+package pomelo
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	jsoniter "github.com/json-iterator/go"
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestSessionCipher(t *testing.T) {
+	clientPrivateKey := make([]byte, curve25519.ScalarSize)
+	rand.Read(clientPrivateKey)
+	clientPublicKey, _ := curve25519.X25519(clientPrivateKey, curve25519.Basepoint)
+
+	serverPublicKey, server, err := exchangeKey(clientPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared, _ := curve25519.X25519(clientPrivateKey, serverPublicKey)
+	client, err := newSessionCipher(shared, clientPublicKey, serverPublicKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body1, _ := client.seal([]byte("hello"))
+	body2, _ := client.seal([]byte("hello"))
+	if string(body1) == string(body2) {
+		t.Fatal("the nonce is reused")
+	}
+
+	if data, err := server.open(body1); err != nil || string(data) != "hello" {
+		t.Fatalf("open data = %s, err = %v", data, err)
+	}
+
+	// replayed
+	if _, err := server.open(body1); err != cerr.PacketNonceReused {
+		t.Fatalf("open replayed err = %v", err)
+	}
+
+	// the server can not open its own sealed data
+	sealed, _ := server.seal([]byte("hello"))
+	if _, err := server.open(sealed); err == nil {
+		t.Fatal("open the data of the other direction")
+	}
+
+	body2[len(body2)-1] ^= 0xff
+	if _, err := server.open(body2); err != cerr.PacketDecryptFail {
+		t.Fatalf("open tampered err = %v", err)
+	}
+
+	if _, _, err := exchangeKey(make([]byte, curve25519.PointSize)); err != cerr.SessionKeyExchangeFail {
+		t.Fatalf("exchange low order point err = %v", err)
+	}
+}
+
+func TestEncryptedHandshake(t *testing.T) {
+	defer func() {
+		cmd.encryption = false
+		cmd.onPacketFuncMap = make(map[pomeloPacket.Type]PacketFunc, 4)
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+
+	cmd.encryption = true
+	cmd.setOnPacketFunc()
+
+	routed := make(chan string, 1)
+	cmd.onDataRouteFunc = func(_ *Agent, route *pomeloMessage.Route, msg *pomeloMessage.Message) {
+		routed <- string(msg.Data)
+	}
+
+	agent := newTestAgent("encrypted-handshake")
+	conn, peer := net.Pipe()
+	agent.conn = conn
+	defer peer.Close()
+
+	BindSID(agent)
+	agent.Run()
+
+	// the cmd is reset after the write goroutine exits,
+	// the peer is closed first, the write goroutine may be blocked on the pipe. eg. heartbeat
+	defer func() {
+		agent.Close()
+		peer.Close()
+		for _, found := GetAgent(agent.SID()); found; _, found = GetAgent(agent.SID()) {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	// the data packet before handshake is not written in plain text
+	if err := agent.Push("test.push", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	clientPrivateKey := make([]byte, curve25519.ScalarSize)
+	rand.Read(clientPrivateKey)
+	clientPublicKey, _ := curve25519.X25519(clientPrivateKey, curve25519.Basepoint)
+
+	handshake, _ := jsoniter.Marshal(map[string]interface{}{
+		"sys": map[string]interface{}{
+			DataPublicKey: base64.StdEncoding.EncodeToString(clientPublicKey),
+		},
+	})
+	writePacket(t, peer, pomeloPacket.Handshake, handshake)
+
+	pkg := readPacket(t, peer)
+	if pkg.Type() != pomeloPacket.Handshake {
+		t.Fatalf("packet type = %d", pkg.Type())
+	}
+
+	rsp := struct {
+		Code int                    `json:"code"`
+		Sys  map[string]interface{} `json:"sys"`
+	}{}
+	if err := jsoniter.Unmarshal(pkg.Data(), &rsp); err != nil || rsp.Code != 200 {
+		t.Fatalf("handshake response = %s, err = %v", pkg.Data(), err)
+	}
+
+	serverPublicKey, _ := base64.StdEncoding.DecodeString(rsp.Sys[DataPublicKey].(string))
+	shared, _ := curve25519.X25519(clientPrivateKey, serverPublicKey)
+	client, err := newSessionCipher(shared, clientPublicKey, serverPublicKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !agent.IsEncrypted() {
+		t.Fatal("agent is not encrypted")
+	}
+
+	writePacket(t, peer, pomeloPacket.HandshakeAck, nil)
+
+	// server -> client
+	if err := agent.Push("test.push", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	pkg = readPacket(t, peer)
+	data, err := client.open(pkg.Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := pomeloMessage.Decode(data)
+	if err != nil || msg.Route != "test.push" || string(msg.Data) != `"hello"` {
+		t.Fatalf("push message = %+v, err = %v", msg, err)
+	}
+
+	// client -> server
+	em, _ := pomeloMessage.Encode(&pomeloMessage.Message{
+		Type:  pomeloMessage.Notify,
+		Route: "game.player.hello",
+		Data:  []byte("world"),
+	})
+	body, _ := client.seal(em)
+	writePacket(t, peer, pomeloPacket.Data, body)
+
+	select {
+	case data := <-routed:
+		if data != "world" {
+			t.Fatalf("routed data = %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("data is not routed")
+	}
+
+	// replayed data closes the agent
+	writePacket(t, peer, pomeloPacket.Data, body)
+
+	deadline := time.Now().Add(time.Second)
+	for agent.State() != AgentClosed && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if agent.State() != AgentClosed {
+		t.Fatalf("agent state = %d", agent.State())
+	}
+}
+
+func writePacket(t *testing.T, conn net.Conn, typ pomeloPacket.Type, data []byte) {
+	pkg, err := pomeloPacket.Encode(typ, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Write(pkg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readPacket(t *testing.T, conn net.Conn) *pomeloPacket.Packet {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	packets, _, err := pomeloPacket.Read(conn)
+	if err != nil || len(packets) != 1 {
+		t.Fatalf("read packets = %v, err = %v", packets, err)
+	}
+
+	return packets[0]
+}
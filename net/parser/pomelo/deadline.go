@@ -0,0 +1,61 @@
+package pomelo
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+// SetDeadline set the read and write deadlines of the conn, eg. expect the client confirms within 10s.
+// the zero time clears them. see SetReadDeadline and SetWriteDeadline
+func (a *Agent) SetDeadline(t time.Time) error {
+	if err := a.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return a.SetWriteDeadline(t)
+}
+
+// SetReadDeadline set the read deadline of the conn, the zero time clears it.
+// the agent is closed with CauseReadError if nothing is read before it, the read loop is the only reader.
+// returns cerr.SessionNoDeadline if the conn does not support the deadline.
+func (a *Agent) SetReadDeadline(t time.Time) error {
+	if a.conn == nil {
+		return cerr.SessionNoDeadline
+	}
+	return deadlineError(a.conn.SetReadDeadline(t))
+}
+
+// SetWriteDeadline set the write deadline of the conn, the zero time clears it.
+// the earlier one of it and actor.SetWriteTimeout is applied to each write, the expired write is
+// handled as the write timeout. returns cerr.SessionNoDeadline if the conn does not support the deadline.
+func (a *Agent) SetWriteDeadline(t time.Time) error {
+	if a.conn == nil {
+		return cerr.SessionNoDeadline
+	}
+
+	var nanos int64
+	if !t.IsZero() {
+		nanos = t.UnixNano()
+	}
+	atomic.StoreInt64(&a.writeDeadline, nanos)
+
+	return deadlineError(a.conn.SetWriteDeadline(t))
+}
+
+// earlierDeadline returns the write deadline set by SetWriteDeadline if it's earlier than t
+func (a *Agent) earlierDeadline(t time.Time) time.Time {
+	if nanos := atomic.LoadInt64(&a.writeDeadline); nanos > 0 && nanos < t.UnixNano() {
+		return time.Unix(0, nanos)
+	}
+	return t
+}
+
+func deadlineError(err error) error {
+	if errors.Is(err, os.ErrNoDeadline) {
+		return cerr.SessionNoDeadline
+	}
+	return err
+}
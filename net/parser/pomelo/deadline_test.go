@@ -0,0 +1,86 @@
+package pomelo
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+type noDeadlineConn struct {
+	net.Conn
+}
+
+func (noDeadlineConn) SetReadDeadline(time.Time) error {
+	return os.ErrNoDeadline
+}
+
+func (noDeadlineConn) SetWriteDeadline(time.Time) error {
+	return os.ErrNoDeadline
+}
+
+func TestSetDeadline(t *testing.T) {
+	// nothing is read before the deadline
+	server, client := newTCPPair(t)
+	defer client.Close()
+
+	agent := newTestAgent("deadline-read")
+	agent.conn = server
+	cause, err := closeCauseOf(t, agent, func() {
+		if err := agent.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if ne, ok := err.(net.Error); cause != CauseReadError || !ok || !ne.Timeout() {
+		t.Fatalf("close cause = %s, err = %v", cause, err)
+	}
+
+	// the cleared deadline never expires
+	server, client = newTCPPair(t)
+	defer client.Close()
+
+	cleared := newTestAgent("deadline-cleared")
+	cleared.conn = server
+	BindSID(cleared)
+	cleared.Run()
+	defer cleared.Close()
+
+	if err = cleared.SetDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err = cleared.SetDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if cleared.IsClosed() {
+		t.Fatalf("agent is closed, cause = %s", cleared.CloseCause())
+	}
+
+	// the earlier one of the write deadline and the write timeout
+	now := time.Now()
+	if d := cleared.earlierDeadline(now); !d.Equal(now) {
+		t.Fatalf("cleared write deadline = %v", d)
+	}
+
+	_ = cleared.SetWriteDeadline(now.Add(time.Second))
+	if d := cleared.earlierDeadline(now.Add(time.Minute)); !d.Equal(now.Add(time.Second)) {
+		t.Fatalf("write deadline = %v", d)
+	}
+	if d := cleared.earlierDeadline(now); !d.Equal(now) {
+		t.Fatalf("write deadline = %v", d)
+	}
+
+	// the conn does not support the deadline
+	unsupported := newTestAgent("deadline-unsupported")
+	unsupported.conn = noDeadlineConn{unsupported.conn}
+	if err = unsupported.SetDeadline(time.Now()); err != cerr.SessionNoDeadline {
+		t.Fatalf("err = %v", err)
+	}
+
+	if err = (&Agent{}).SetWriteDeadline(time.Now()); err != cerr.SessionNoDeadline {
+		t.Fatalf("nil conn err = %v", err)
+	}
+}
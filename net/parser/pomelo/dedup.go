@@ -0,0 +1,138 @@
+package pomelo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	dedupMaxEntries = 128 // the oldest request id is dropped if the cached ids of the agent exceed it
+)
+
+type (
+	// requestDedup the recent request ids of the agent, see actor.SetRequestDedup
+	requestDedup struct {
+		sync.Mutex
+		entries map[uint]*dedupEntry
+		order   []uint // request ids in the received order
+	}
+
+	dedupEntry struct {
+		at       time.Time
+		response *pendingMessage // the cached response, nil if it's not responded or not cached
+	}
+)
+
+func newRequestDedup() *requestDedup {
+	return &requestDedup{
+		entries: make(map[uint]*dedupEntry),
+	}
+}
+
+// SetRequestDedup the duplicated request(same mid) received in the window is not dispatched again,
+// the cached response is replayed if cacheResponses is true, otherwise it's dropped.
+// the notify messages bypass the dedup. zero window is disabled(default).
+func (*actor) SetRequestDedup(window time.Duration, cacheResponses bool) {
+	if window < 0 {
+		window = 0
+	}
+
+	cmd.dedupWindow = window
+	cmd.dedupResponses = cacheResponses
+}
+
+// check returns the entry of the duplicated mid, the new mid is recorded
+func (p *requestDedup) check(mid uint, window time.Duration) (*dedupEntry, bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	p.expire(now.Add(-window))
+
+	if entry, found := p.entries[mid]; found {
+		return entry, true
+	}
+
+	if len(p.order) >= dedupMaxEntries {
+		delete(p.entries, p.order[0])
+		p.order = p.order[1:]
+	}
+
+	p.entries[mid] = &dedupEntry{at: now}
+	p.order = append(p.order, mid)
+
+	return nil, false
+}
+
+// expire remove the request ids received before the deadline, it must be called under the lock
+func (p *requestDedup) expire(deadline time.Time) {
+	i := 0
+	for i < len(p.order) {
+		entry, found := p.entries[p.order[i]]
+		if found && entry.at.After(deadline) {
+			break
+		}
+		delete(p.entries, p.order[i])
+		i++
+	}
+	p.order = p.order[i:]
+}
+
+// cache keep the first response of the recorded mid
+func (p *requestDedup) cache(pending *pendingMessage) {
+	p.Lock()
+	defer p.Unlock()
+
+	if entry, found := p.entries[pending.mid]; found && entry.response == nil {
+		response := *pending
+		response.ctx = nil
+		entry.response = &response
+	}
+}
+
+func (p *requestDedup) cached(entry *dedupEntry) *pendingMessage {
+	p.Lock()
+	defer p.Unlock()
+
+	return entry.response
+}
+
+// duplicateRequest returns true if the request is duplicated, the cached response is replayed
+func (a *Agent) duplicateRequest(msg *pmessage.Message) bool {
+	window := cmd.dedupWindow
+	if window <= 0 || msg.Type != pmessage.Request || a.dedup == nil {
+		return false
+	}
+
+	entry, found := a.dedup.check(msg.ID, window)
+	if !found {
+		return false
+	}
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Duplicated request. [route = %s, mid = %d]", msg.Route, msg.ID)
+	}
+
+	// dropped if it's in process or the response is not cached
+	if response := a.dedup.cached(entry); response != nil {
+		if err := a.sendPending(context.Background(), response.typ, response.route, uint32(response.mid),
+			response.header, response.payload, response.err); err != nil {
+			a.Debugf("Replay response fail. [mid = %d, err = %v]", msg.ID, err)
+		}
+	}
+
+	return true
+}
+
+// cacheResponse called by sendPending, the payload is kept by reference
+func (a *Agent) cacheResponse(pending *pendingMessage) {
+	if pending.typ != pmessage.Response || !cmd.dedupResponses || cmd.dedupWindow <= 0 || a.dedup == nil {
+		return
+	}
+
+	a.dedup.cache(pending)
+}
@@ -0,0 +1,89 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+)
+
+func TestRequestDedup(t *testing.T) {
+	defer func() {
+		cmd.dedupWindow = 0
+		cmd.dedupResponses = false
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+
+	(&actor{}).SetRequestDedup(time.Minute, true)
+
+	dispatched := 0
+	cmd.onDataRouteFunc = func(agent *Agent, _ *pmessage.Route, msg *pmessage.Message) {
+		dispatched++
+		if msg.Type == pmessage.Request {
+			agent.ResponseMID(uint32(msg.ID), "bought")
+		}
+	}
+
+	agent := newTestAgent("request-dedup")
+	route, _ := pmessage.DecodeRoute("game.shop.buy")
+
+	request := &pmessage.Message{Type: pmessage.Request, ID: 1, Route: "game.shop.buy"}
+	dispatchData(agent, route, request)
+	dispatchData(agent, route, request)
+
+	if dispatched != 1 {
+		t.Fatalf("dispatched = %d", dispatched)
+	}
+
+	// the cached response is replayed
+	for i := 0; i < 2; i++ {
+		pending := <-agent.chPending
+		if pending.mid != 1 || pending.payload != "bought" {
+			t.Fatalf("pending = %s", pending.String())
+		}
+	}
+
+	// notify bypass the dedup
+	notify := &pmessage.Message{Type: pmessage.Notify, Route: "game.shop.buy"}
+	dispatchData(agent, route, notify)
+	dispatchData(agent, route, notify)
+
+	if dispatched != 3 {
+		t.Fatalf("notify dispatched = %d", dispatched)
+	}
+
+	// the response is not cached, the duplicated request is dropped
+	cmd.dedupResponses = false
+	request = &pmessage.Message{Type: pmessage.Request, ID: 2, Route: "game.shop.buy"}
+	dispatchData(agent, route, request)
+	dispatchData(agent, route, request)
+
+	if dispatched != 4 || len(agent.chPending) != 1 {
+		t.Fatalf("dispatched = %d, pending = %d", dispatched, len(agent.chPending))
+	}
+}
+
+func TestRequestDedupBounded(t *testing.T) {
+	dedup := newRequestDedup()
+
+	for mid := uint(1); mid <= dedupMaxEntries+10; mid++ {
+		if _, found := dedup.check(mid, time.Minute); found {
+			t.Fatalf("mid %d is duplicated", mid)
+		}
+	}
+
+	if len(dedup.entries) != dedupMaxEntries || len(dedup.order) != dedupMaxEntries {
+		t.Fatalf("entries = %d, order = %d", len(dedup.entries), len(dedup.order))
+	}
+
+	// the oldest is dropped
+	if _, found := dedup.check(1, time.Minute); found {
+		t.Fatal("the oldest mid is not dropped")
+	}
+
+	// expired out of the window
+	time.Sleep(time.Millisecond)
+	if _, found := dedup.check(dedupMaxEntries+10, time.Nanosecond); found {
+		t.Fatal("the mid out of the window is duplicated")
+	}
+}
@@ -0,0 +1,303 @@
+package pomelo
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	DeliveryNone        DeliveryMode = 0 // no sequence number(default), eg. TCP, TLS
+	DeliveryAtMostOnce  DeliveryMode = 1 // the pushes are numbered, the duplicated inbound messages are dropped
+	DeliveryAtLeastOnce DeliveryMode = 2 // the pushes are numbered and resent on reconnect until they are acked
+	DeliveryOrdered     DeliveryMode = 3 // at least once pushes, the inbound messages are deduplicated and dispatched in order
+)
+
+const (
+	HeaderSeq = "seq" // message header, the sequence number of the push or the inbound message starts from 1
+	HeaderAck = "ack" // message header, the highest sequence number of the continuous pushes received by the client
+
+	deliveryWindow     = 64  // the window of the inbound dedup and reorder
+	deliveryMaxUnacked = 256 // the oldest unacked push is dropped if the unacked pushes exceed it
+)
+
+type (
+	// DeliveryMode the delivery guarantee of the messages carry the HeaderSeq, see actor.SetDeliveryMode
+	DeliveryMode int32
+
+	// deliveryState the sequence state of the agent, it's adopted by the agent reconnected. see Agent.Reconnect
+	deliveryState struct {
+		sync.Mutex
+		outSeq    uint64                    // the last sequence number of the pushes
+		unacked   []unackedPacket           // the pushes waiting for the ack
+		inMax     uint64                    // the highest sequence number of the inbound messages
+		inBits    uint64                    // the received bitmap of [inMax-63, inMax]
+		inNext    uint64                    // the next sequence number of the ordered inbound messages
+		inPending map[uint64]inboundMessage // the out-of-order inbound messages
+	}
+
+	unackedPacket struct {
+		seq uint64
+		pkg []byte // encoded packet, it's encrypted by write()
+	}
+
+	inboundMessage struct {
+		route *pmessage.Route
+		msg   *pmessage.Message
+	}
+)
+
+func newDeliveryState() *deliveryState {
+	return &deliveryState{
+		inNext:    1,
+		inPending: make(map[uint64]inboundMessage),
+	}
+}
+
+// SetReplayBuffer keep the last size numbered pushes of each agent for the reconnect, the pushes after the last
+// acked sequence of the client are replayed by Agent.ReconnectFrom. the buffer is cleared if the reconnect grace
+// is expired. the pushes are numbered by the delivery mode, see SetDeliveryMode.
+// zero is the default, deliveryMaxUnacked pushes in DeliveryAtLeastOnce and none in DeliveryAtMostOnce.
+func (*actor) SetReplayBuffer(size int) {
+	if size < 0 {
+		size = 0
+	}
+	atomic.StoreInt32(&cmd.replaySize, int32(size))
+}
+
+// SetDeliveryMode the delivery guarantee of the messages, the client must send the HeaderSeq and HeaderAck.
+// it's opt-in for the unreliable transports or the reconnection, default is DeliveryNone.
+func (*actor) SetDeliveryMode(mode DeliveryMode) {
+	if mode < DeliveryNone || mode > DeliveryOrdered {
+		mode = DeliveryNone
+	}
+	cmd.deliveryMode = mode
+}
+
+func (p *deliveryState) nextSeq() uint64 {
+	p.Lock()
+	defer p.Unlock()
+
+	p.outSeq++
+	return p.outSeq
+}
+
+// keep the push until it's acked, the oldest push is dropped if the unacked pushes exceed limit
+func (p *deliveryState) keep(seq uint64, pkg []byte, limit int) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.unacked = append(p.unacked, unackedPacket{seq: seq, pkg: pkg})
+	if len(p.unacked) > limit {
+		p.unacked = p.unacked[len(p.unacked)-limit:]
+	}
+}
+
+// clear release the unacked pushes, eg. the reconnect grace is expired
+func (p *deliveryState) clear() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.unacked = nil
+}
+
+// ack remove the unacked pushes which seq <= ack
+func (p *deliveryState) ack(ack uint64) {
+	p.Lock()
+	defer p.Unlock()
+
+	i := 0
+	for i < len(p.unacked) && p.unacked[i].seq <= ack {
+		i++
+	}
+	p.unacked = p.unacked[i:]
+}
+
+func (p *deliveryState) unackedPackets() [][]byte {
+	p.Lock()
+	defer p.Unlock()
+
+	packets := make([][]byte, 0, len(p.unacked))
+	for _, packet := range p.unacked {
+		packets = append(packets, packet.pkg)
+	}
+	return packets
+}
+
+// accept returns false if the seq is duplicated or older than the window
+func (p *deliveryState) accept(seq uint64) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if seq > p.inMax {
+		if shift := seq - p.inMax; shift >= deliveryWindow {
+			p.inBits = 1
+		} else {
+			p.inBits = p.inBits<<shift | 1
+		}
+		p.inMax = seq
+		return true
+	}
+
+	diff := p.inMax - seq
+	if diff >= deliveryWindow {
+		return false
+	}
+
+	bit := uint64(1) << diff
+	if p.inBits&bit != 0 {
+		return false
+	}
+
+	p.inBits |= bit
+	return true
+}
+
+// reorder returns the inbound messages can be dispatched in order,
+// the duplicated message or the message beyond the window is dropped.
+func (p *deliveryState) reorder(seq uint64, in inboundMessage) []inboundMessage {
+	p.Lock()
+	defer p.Unlock()
+
+	if seq < p.inNext || seq >= p.inNext+deliveryWindow {
+		return nil
+	}
+
+	if seq > p.inNext {
+		p.inPending[seq] = in
+		return nil
+	}
+
+	list := []inboundMessage{in}
+	for p.inNext++; ; p.inNext++ {
+		next, found := p.inPending[p.inNext]
+		if !found {
+			break
+		}
+		delete(p.inPending, p.inNext)
+		list = append(list, next)
+	}
+
+	return list
+}
+
+// resume copy the sequence state of the retained agent
+func (p *deliveryState) resume(old *deliveryState) {
+	old.Lock()
+	defer old.Unlock()
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.outSeq = old.outSeq
+	p.unacked = append([]unackedPacket(nil), old.unacked...)
+	p.inMax = old.inMax
+	p.inBits = old.inBits
+	p.inNext = old.inNext
+	p.inPending = make(map[uint64]inboundMessage, len(old.inPending))
+	for seq, in := range old.inPending {
+		p.inPending[seq] = in
+	}
+}
+
+// outboundSeq returns the header with the sequence number of the push, zero seq if it's not numbered
+func (a *Agent) outboundSeq(data *pendingMessage) (map[string]string, uint64) {
+	if data.typ != pmessage.Push || cmd.deliveryMode == DeliveryNone {
+		return data.header, 0
+	}
+
+	seq := a.delivery.nextSeq()
+
+	header := make(map[string]string, len(data.header)+1)
+	for k, v := range data.header {
+		header[k] = v
+	}
+	header[HeaderSeq] = strconv.FormatUint(seq, 10)
+
+	return header, seq
+}
+
+// keepOutbound the encoded push is kept until it's acked, see actor.SetReplayBuffer
+func (a *Agent) keepOutbound(seq uint64, pkg []byte) {
+	if seq < 1 {
+		return
+	}
+
+	size := int(atomic.LoadInt32(&cmd.replaySize))
+	if size > 0 {
+		a.delivery.keep(seq, pkg, size)
+	} else if cmd.deliveryMode >= DeliveryAtLeastOnce {
+		a.delivery.keep(seq, pkg, deliveryMaxUnacked)
+	}
+}
+
+// deliver dispatch the inbound message by the delivery mode, the message without HeaderSeq is dispatched directly
+func (a *Agent) deliver(route *pmessage.Route, msg *pmessage.Message) {
+	mode := cmd.deliveryMode
+	if mode == DeliveryNone || len(msg.Header) < 1 {
+		dispatchData(a, route, msg)
+		return
+	}
+
+	if ack, ok := parseSeq(msg.Header[HeaderAck]); ok && mode >= DeliveryAtLeastOnce {
+		a.delivery.ack(ack)
+	}
+
+	seq, ok := parseSeq(msg.Header[HeaderSeq])
+	if !ok {
+		dispatchData(a, route, msg)
+		return
+	}
+
+	switch mode {
+	case DeliveryAtMostOnce:
+		if !a.delivery.accept(seq) {
+			a.dropInbound(seq, msg)
+			return
+		}
+		dispatchData(a, route, msg)
+	case DeliveryOrdered:
+		for _, in := range a.delivery.reorder(seq, inboundMessage{route: route, msg: msg}) {
+			dispatchData(a, in.route, in.msg)
+		}
+	default:
+		dispatchData(a, route, msg)
+	}
+}
+
+func (a *Agent) dropInbound(seq uint64, msg *pmessage.Message) {
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Duplicated message dropped. [route = %s, seq = %d]", msg.Route, seq)
+	}
+}
+
+// resumeDelivery adopt the sequence state of the retained agent and resend the unacked pushes
+func (a *Agent) resumeDelivery(old *deliveryState) {
+	if cmd.deliveryMode == DeliveryNone || old == nil {
+		return
+	}
+
+	a.delivery.resume(old)
+
+	for _, pkg := range a.delivery.unackedPackets() {
+		if err := a.queueRaw(pkg); err != nil {
+			a.Warnf("Resend unacked push fail. [err = %v]", err)
+			return
+		}
+	}
+}
+
+func parseSeq(value string) (uint64, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || seq < 1 {
+		return 0, false
+	}
+	return seq, true
+}
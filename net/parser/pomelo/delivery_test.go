@@ -0,0 +1,180 @@
+package pomelo
+
+import (
+	"bytes"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+func TestDeliveryAccept(t *testing.T) {
+	state := newDeliveryState()
+
+	for _, c := range []struct {
+		seq    uint64
+		accept bool
+	}{
+		{1, true}, {2, true}, {2, false}, {100, true}, {30, false}, {99, true}, {99, false}, {37, true}, {36, false},
+	} {
+		if state.accept(c.seq) != c.accept {
+			t.Fatalf("seq = %d, accept = %v", c.seq, !c.accept)
+		}
+	}
+}
+
+func TestDeliveryOrdered(t *testing.T) {
+	defer func() {
+		cmd.onDataRouteFunc = DefaultDataRoute
+		cmd.deliveryMode = DeliveryNone
+	}()
+
+	var routes []string
+	cmd.onDataRouteFunc = func(_ *Agent, _ *pmessage.Route, msg *pmessage.Message) {
+		routes = append(routes, msg.Route)
+	}
+	(&actor{}).SetDeliveryMode(DeliveryOrdered)
+
+	agent := newTestAgent("delivery-ordered")
+	for _, seq := range []string{"2", "3", "1", "1", "", "5", "4", "100"} {
+		route := "game.room.m" + seq
+		r, _ := pmessage.DecodeRoute(route)
+
+		msg := &pmessage.Message{Type: pmessage.Notify, Route: route}
+		if seq != "" {
+			msg.Header = map[string]string{HeaderSeq: seq}
+		}
+		agent.deliver(r, msg)
+	}
+
+	want := []string{"game.room.m1", "game.room.m2", "game.room.m3", "game.room.m", "game.room.m4", "game.room.m5"}
+	if len(routes) != len(want) {
+		t.Fatalf("routes = %v", routes)
+	}
+
+	for i, route := range want {
+		if routes[i] != route {
+			t.Fatalf("routes = %v", routes)
+		}
+	}
+}
+
+func TestDeliveryResend(t *testing.T) {
+	defer func() {
+		cmd.deliveryMode = DeliveryNone
+	}()
+	(&actor{}).SetDeliveryMode(DeliveryAtLeastOnce)
+
+	agent := newTestAgent("delivery-resend")
+
+	var packets [][]byte
+	for i := 1; i <= 2; i++ {
+		pkg, err := agent.encodePending(&pendingMessage{typ: pmessage.Push, route: "room.sync", payload: i})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seq := pushSeq(t, pkg); seq != strconv.Itoa(i) {
+			t.Fatalf("seq = %s", seq)
+		}
+		packets = append(packets, pkg)
+	}
+
+	// the client acked the first push
+	r, _ := pmessage.DecodeRoute("game.room.ack")
+	agent.deliver(r, &pmessage.Message{Type: pmessage.Notify, Route: "game.room.ack", Header: map[string]string{HeaderAck: "1"}})
+
+	reconnected := newTestAgent("delivery-reconnected")
+	reconnected.resumeDelivery(agent.delivery)
+
+	if len(reconnected.chWrite) != 1 || !bytes.Equal(<-reconnected.chWrite, packets[1]) {
+		t.Fatal("the unacked push is not resent")
+	}
+
+	pkg, _ := reconnected.encodePending(&pendingMessage{typ: pmessage.Push, route: "room.sync", payload: 3})
+	if seq := pushSeq(t, pkg); seq != "3" {
+		t.Fatalf("seq after reconnect = %s", seq)
+	}
+}
+
+func pushSeq(t *testing.T, pkg []byte) string {
+	packets, err := ppacket.Decode(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := pmessage.Decode(packets[0].Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msg.Header[HeaderSeq]
+}
+
+func TestReplayBuffer(t *testing.T) {
+	defer func() {
+		cmd.deliveryMode = DeliveryNone
+		cmd.reconnectGrace = 0
+		(&actor{}).SetReplayBuffer(0)
+	}()
+	(&actor{}).SetDeliveryMode(DeliveryAtMostOnce)
+	(&actor{}).SetReplayBuffer(3)
+	cmd.reconnectGrace = time.Second
+
+	// pushes retains the agent with 5 pushes, the last 3 are kept
+	pushes := func(sid string, uid int64) (*Agent, string) {
+		agent := newTestAgent(sid)
+		BindSID(agent)
+		if err := agent.Bind(uid); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 1; i <= 5; i++ {
+			if _, err := agent.encodePending(&pendingMessage{typ: pmessage.Push, route: "room.sync", payload: i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		token := agent.ReconnectToken()
+		atomic.StoreInt32(&agent.lost, 1)
+		Unbind(agent.SID())
+		if !retain(agent) {
+			t.Fatal("agent is not retained")
+		}
+		return agent, token
+	}
+
+	_, token := pushes("replay-lost", 6101)
+
+	reconnected := newTestAgent("replay-reconnected")
+	BindSID(reconnected)
+	defer Unbind(reconnected.SID())
+
+	// the client has received the seq 3
+	if err := reconnected.ReconnectFrom(token, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reconnected.chWrite) != 2 || pushSeq(t, <-reconnected.chWrite) != "4" || pushSeq(t, <-reconnected.chWrite) != "5" {
+		t.Fatalf("replayed = %d", len(reconnected.chWrite))
+	}
+
+	// the buffer is cleared after the grace
+	cmd.reconnectGrace = 10 * time.Millisecond
+	expired, _ := pushes("replay-expired", 6102)
+	if len(expired.delivery.unackedPackets()) != 3 {
+		t.Fatalf("kept = %d", len(expired.delivery.unackedPackets()))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(expired.delivery.unackedPackets()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if kept := len(expired.delivery.unackedPackets()); kept != 0 {
+		t.Fatalf("kept after the grace = %d", kept)
+	}
+}
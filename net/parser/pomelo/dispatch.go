@@ -0,0 +1,106 @@
+package pomelo
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+const (
+	DispatchPerConnection DispatchMode = 0 // the packets are processed by the read goroutine of the agent(default)
+	DispatchWorkerPool    DispatchMode = 1 // the packets are processed by the bounded worker pool, see actor.SetDispatchMode
+
+	dispatchBacklog = 128 // the queued packets of each worker
+)
+
+type (
+	// DispatchMode how the inbound packets are processed, see actor.SetDispatchMode
+	DispatchMode int32
+
+	// dispatchPool the workers keyed by sid, the packets of the agent are processed by the same worker in order
+	dispatchPool struct {
+		workers   []chan dispatchTask
+		queued    int64 // the packets queued in the workers
+		saturated int64 // the dispatches blocked by the full worker
+	}
+
+	dispatchTask struct {
+		agent   *Agent
+		packets []*ppacket.Packet
+	}
+)
+
+// SetDispatchMode the inbound packets are processed by the read goroutines(DispatchPerConnection) or
+// the worker pool of size(DispatchWorkerPool). the read goroutine of each connection only reads the packets
+// in the pool mode, the handlers of the packets(eg. decrypt, decode, route) are run by the workers.
+// the read goroutine is blocked if the worker is full, see Stats().DispatchSaturated.
+// it must be called before the actor is started, default is DispatchPerConnection.
+func (*actor) SetDispatchMode(mode DispatchMode, size int) {
+	if pool := cmd.dispatchPool; pool != nil {
+		pool.stop()
+		cmd.dispatchPool = nil
+	}
+
+	if mode == DispatchWorkerPool && size > 0 {
+		cmd.dispatchPool = newDispatchPool(size)
+	}
+}
+
+func newDispatchPool(size int) *dispatchPool {
+	pool := &dispatchPool{
+		workers: make([]chan dispatchTask, size),
+	}
+
+	for i := range pool.workers {
+		pool.workers[i] = make(chan dispatchTask, dispatchBacklog)
+		go pool.run(pool.workers[i])
+	}
+
+	return pool
+}
+
+// shard returns the index of the worker of the sid, the sid is always processed by the same worker.
+// the slow agent only delays the agents of the same worker, the other workers run in parallel.
+func (p *dispatchPool) shard(sid string) int {
+	h := fnv.New32a()
+	h.Write([]byte(sid))
+	return int(h.Sum32() % uint32(len(p.workers)))
+}
+
+// dispatch queue the packets to the worker of the agent, the inflightPackets are released by the worker
+func (p *dispatchPool) dispatch(agent *Agent, packets []*ppacket.Packet) {
+	worker := p.workers[p.shard(agent.SID())]
+
+	task := dispatchTask{
+		agent:   agent,
+		packets: packets,
+	}
+
+	atomic.AddInt64(&p.queued, 1)
+
+	select {
+	case worker <- task:
+	default:
+		atomic.AddInt64(&p.saturated, 1)
+		worker <- task
+	}
+}
+
+func (p *dispatchPool) run(worker chan dispatchTask) {
+	for task := range worker {
+		atomic.AddInt64(&p.queued, -1)
+
+		for _, packet := range task.packets {
+			task.agent.processPacket(packet)
+		}
+		atomic.AddInt64(&inflightPackets, -1)
+	}
+}
+
+// stop the workers exit after the queued packets are processed
+func (p *dispatchPool) stop() {
+	for _, worker := range p.workers {
+		close(worker)
+	}
+}
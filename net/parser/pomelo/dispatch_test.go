@@ -0,0 +1,240 @@
+package pomelo
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+func newNotifyPacket(t testing.TB, route string, data string) *ppacket.Packet {
+	encode, err := pmessage.Encode(&pmessage.Message{
+		Type:  pmessage.Notify,
+		Route: route,
+		Data:  []byte(data),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg, _ := ppacket.Encode(ppacket.Data, encode)
+	packets, _ := ppacket.Decode(pkg)
+	return packets[0]
+}
+
+func TestDispatchWorkerPool(t *testing.T) {
+	p := &actor{}
+	defer func() {
+		p.SetDispatchMode(DispatchPerConnection, 0)
+		cmd.onPacketFuncMap = make(map[ppacket.Type]PacketFunc, 4)
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+
+	cmd.setOnPacketFunc()
+	p.SetDispatchMode(DispatchWorkerPool, 4)
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		routed = map[string][]int{}
+	)
+
+	cmd.onDataRouteFunc = func(agent *Agent, _ *pmessage.Route, msg *pmessage.Message) {
+		n, _ := strconv.Atoi(string(msg.Data))
+
+		mu.Lock()
+		routed[agent.SID()] = append(routed[agent.SID()], n)
+		mu.Unlock()
+		wg.Done()
+	}
+
+	var agents []*Agent
+	for i := 0; i < 8; i++ {
+		agent := newTestAgent("dispatch-" + strconv.Itoa(i))
+		agent.SetState(AgentWorking)
+		agents = append(agents, agent)
+	}
+
+	// the read goroutines of the agents
+	var readers sync.WaitGroup
+	for _, agent := range agents {
+		readers.Add(1)
+		go func(agent *Agent) {
+			defer readers.Done()
+			for n := 0; n < 100; n++ {
+				wg.Add(1)
+				atomic.AddInt64(&inflightPackets, 1)
+				cmd.dispatchPool.dispatch(agent, []*ppacket.Packet{newNotifyPacket(t, "game.room.move", strconv.Itoa(n))})
+			}
+		}(agent)
+	}
+
+	readers.Wait()
+	wg.Wait()
+
+	for _, agent := range agents {
+		list := routed[agent.SID()]
+		for n, v := range list {
+			if v != n {
+				t.Fatalf("sid = %s, routed = %v", agent.SID(), list)
+			}
+		}
+
+		if len(list) != 100 {
+			t.Fatalf("sid = %s, routed = %d", agent.SID(), len(list))
+		}
+	}
+
+	if waitInflight(); atomic.LoadInt64(&inflightPackets) != 0 {
+		t.Fatalf("inflight packets = %d", atomic.LoadInt64(&inflightPackets))
+	}
+}
+
+func TestDispatchSaturated(t *testing.T) {
+	p := &actor{}
+	defer func() {
+		p.SetDispatchMode(DispatchPerConnection, 0)
+		cmd.onPacketFuncMap = make(map[ppacket.Type]PacketFunc, 4)
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+
+	cmd.setOnPacketFunc()
+	p.SetDispatchMode(DispatchWorkerPool, 1)
+
+	entered := make(chan struct{}, 1)
+	blocked := make(chan struct{})
+	cmd.onDataRouteFunc = func(_ *Agent, _ *pmessage.Route, _ *pmessage.Message) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-blocked
+	}
+
+	agent := newTestAgent("dispatch-saturated")
+	agent.SetState(AgentWorking)
+	pkg := newNotifyPacket(t, "game.room.move", "0")
+
+	// the worker is blocked by the first packet
+	atomic.AddInt64(&inflightPackets, 1)
+	cmd.dispatchPool.dispatch(agent, []*ppacket.Packet{pkg})
+	<-entered
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 0; n < dispatchBacklog+1; n++ {
+			atomic.AddInt64(&inflightPackets, 1)
+			cmd.dispatchPool.dispatch(agent, []*ppacket.Packet{pkg})
+		}
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for Stats().DispatchSaturated < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := Stats()
+	close(blocked)
+	<-done
+
+	if stats.DispatchSaturated != 1 || stats.DispatchQueued != dispatchBacklog+1 {
+		t.Fatalf("saturated = %d, queued = %d", stats.DispatchSaturated, stats.DispatchQueued)
+	}
+
+	if waitInflight(); Stats().DispatchQueued != 0 {
+		t.Fatalf("queued = %d", Stats().DispatchQueued)
+	}
+}
+
+func waitInflight() {
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&inflightPackets) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatchInterleaved(t *testing.T) {
+	p := &actor{}
+	defer func() {
+		p.SetDispatchMode(DispatchPerConnection, 0)
+		cmd.onPacketFuncMap = make(map[ppacket.Type]PacketFunc, 4)
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+
+	cmd.setOnPacketFunc()
+	p.SetDispatchMode(DispatchWorkerPool, 2)
+	pool := cmd.dispatchPool
+
+	// two sessions on the different workers
+	slow := newTestAgent("interleaved-slow")
+	fast := newTestAgent("interleaved-0")
+	for i := 1; pool.shard(fast.SID()) == pool.shard(slow.SID()); i++ {
+		fast = newTestAgent("interleaved-" + strconv.Itoa(i))
+	}
+	slow.SetState(AgentWorking)
+	fast.SetState(AgentWorking)
+
+	const count = 50
+
+	var (
+		mu      sync.Mutex
+		routed  = map[string][]int{}
+		blocked = make(chan struct{})
+		fastAll = make(chan struct{})
+	)
+
+	cmd.onDataRouteFunc = func(agent *Agent, _ *pmessage.Route, msg *pmessage.Message) {
+		if agent == slow {
+			<-blocked
+		}
+
+		n, _ := strconv.Atoi(string(msg.Data))
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		routed[agent.SID()] = append(routed[agent.SID()], n)
+		if agent == fast && len(routed[fast.SID()]) == count {
+			close(fastAll)
+		}
+	}
+
+	for n := 0; n < count; n++ {
+		for _, agent := range []*Agent{slow, fast} {
+			atomic.AddInt64(&inflightPackets, 1)
+			pool.dispatch(agent, []*ppacket.Packet{newNotifyPacket(t, "game.room.move", strconv.Itoa(n))})
+		}
+	}
+
+	// the fast session is not delayed by the slow session
+	select {
+	case <-fastAll:
+	case <-time.After(time.Second):
+		close(blocked)
+		t.Fatal("the fast session is blocked by the slow session")
+	}
+
+	close(blocked)
+	waitInflight()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, agent := range []*Agent{slow, fast} {
+		list := routed[agent.SID()]
+		if len(list) != count {
+			t.Fatalf("sid = %s, routed = %d", agent.SID(), len(list))
+		}
+
+		for n, v := range list {
+			if v != n {
+				t.Fatalf("sid = %s, routed = %v", agent.SID(), list)
+			}
+		}
+	}
+}
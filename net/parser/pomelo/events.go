@@ -0,0 +1,62 @@
+package pomelo
+
+import (
+	cutils "github.com/cherry-game/cherry/extend/utils"
+	clog "github.com/cherry-game/cherry/logger"
+)
+
+const (
+	EventCreate    SessionEvent = iota // the agent is created, before it's running
+	EventClose                         // the agent is closed, it's fired after the reconnect grace if the agent is retained
+	EventBind                          // the uid is bound, after the OnBind listeners accept it
+	EventUnbind                        // the bound agent is unbound
+	EventKick                          // the kick packet is written, before the agent is closed
+	EventReconnect                     // the retained state is adopted by the agent. see Agent.Reconnect
+	eventMax
+)
+
+type (
+	// SessionEvent the lifecycle event of the agent
+	SessionEvent int32
+
+	// EventFunc the handler of the session event, the panic is recovered
+	EventFunc func(agent *Agent)
+)
+
+var (
+	eventNames = [eventMax]string{
+		EventCreate:    "create",
+		EventClose:     "close",
+		EventBind:      "bind",
+		EventUnbind:    "unbind",
+		EventKick:      "kick",
+		EventReconnect: "reconnect",
+	}
+)
+
+func (e SessionEvent) String() string {
+	if e < 0 || e >= eventMax {
+		return "unknown"
+	}
+	return eventNames[e]
+}
+
+// On add the handler of the session event, the handlers are called in order on the goroutine firing the event.
+// it should be called before the connectors are started.
+func (*actor) On(event SessionEvent, fn EventFunc) {
+	if fn == nil || event < 0 || event >= eventMax {
+		return
+	}
+
+	cmd.eventFuncs[event] = append(cmd.eventFuncs[event], fn)
+}
+
+func fireEvent(event SessionEvent, agent *Agent) {
+	for _, fn := range cmd.eventFuncs[event] {
+		cutils.Try(func() {
+			fn(agent)
+		}, func(errString string) {
+			clog.Warnf("[pomelo] Event handler panic. [event = %s, err = %s]", event, errString)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package pomelo
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSessionEvent(t *testing.T) {
+	defer func() {
+		cmd.eventFuncs = [eventMax][]EventFunc{}
+	}()
+
+	var (
+		lock  sync.Mutex
+		fired []SessionEvent
+	)
+
+	p := &actor{}
+	for event := EventCreate; event < eventMax; event++ {
+		e := event
+		p.On(e, func(agent *Agent) {
+			lock.Lock()
+			defer lock.Unlock()
+			fired = append(fired, e)
+		})
+	}
+
+	// the panic handler does not stop the others
+	p.On(EventBind, func(*Agent) { panic("boom") })
+	p.On(eventMax, func(*Agent) { t.Fatal("unknown event") })
+
+	agent := newTestAgent("session-event")
+	agent.SetState(AgentWorking)
+	BindSID(agent)
+
+	if err := agent.Bind(8001); err != nil {
+		t.Fatal(err)
+	}
+
+	UnbindUID(8001)
+	_ = agent.Kick("bye", true)
+	agent.closeProcess()
+
+	want := []SessionEvent{EventBind, EventUnbind, EventKick, EventClose}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(fired) != len(want) {
+		t.Fatalf("fired = %v", fired)
+	}
+
+	for i, event := range want {
+		if fired[i] != event {
+			t.Fatalf("fired = %v", fired)
+		}
+	}
+
+	if EventReconnect.String() != "reconnect" || eventMax.String() != "unknown" {
+		t.Fatal("event name")
+	}
+}
@@ -0,0 +1,59 @@
+package pomelo
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+// testFlushConn the conn with the write buffer
+type testFlushConn struct {
+	net.Conn
+	written int32
+	flushed int32
+}
+
+func (c *testFlushConn) Write(b []byte) (int, error) {
+	atomic.AddInt32(&c.written, 1)
+	return c.Conn.Write(b)
+}
+
+func (c *testFlushConn) Flush() error {
+	atomic.StoreInt32(&c.flushed, atomic.LoadInt32(&c.written))
+	return nil
+}
+
+func TestAgentFlush(t *testing.T) {
+	agent := newTestAgent("agent-flush")
+	conn := &testFlushConn{Conn: agent.conn}
+	agent.conn = conn
+	agent.SetState(AgentWorking)
+
+	done := make(chan struct{})
+	go func() {
+		agent.writeChan()
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		if err := agent.Push("test.push", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := agent.PushFlush("test.push", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pushes are written and flushed
+	if written, flushed := atomic.LoadInt32(&conn.written), atomic.LoadInt32(&conn.flushed); written != 4 || flushed != 4 {
+		t.Fatalf("written = %d, flushed = %d", written, flushed)
+	}
+
+	agent.Close()
+	<-done
+
+	if err := agent.Flush(); err != nil {
+		t.Fatalf("flush the closed agent err = %v", err)
+	}
+}
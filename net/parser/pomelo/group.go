@@ -0,0 +1,150 @@
+package pomelo
+
+import (
+	"sync"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+type (
+	// Group a set of agents for broadcast. eg. room, zone, guild
+	Group struct {
+		name    string
+		lock    sync.RWMutex
+		members map[cfacade.SID]*Agent // sid -> Agent
+		closed  bool
+	}
+)
+
+var (
+	groupLock = &sync.RWMutex{}
+	groupMap  = make(map[string]*Group) // group name -> Group
+)
+
+// NewGroup returns the group with the name, create it if it does not exist.
+func NewGroup(name string) *Group {
+	groupLock.Lock()
+	defer groupLock.Unlock()
+
+	if group, found := groupMap[name]; found {
+		return group
+	}
+
+	group := &Group{
+		name:    name,
+		members: make(map[cfacade.SID]*Agent),
+	}
+	groupMap[name] = group
+
+	return group
+}
+
+func GetGroup(name string) (*Group, bool) {
+	groupLock.RLock()
+	defer groupLock.RUnlock()
+
+	group, found := groupMap[name]
+	return group, found
+}
+
+// leaveGroups remove the agent from all groups
+func leaveGroups(sid cfacade.SID) {
+	groupLock.RLock()
+	defer groupLock.RUnlock()
+
+	for _, group := range groupMap {
+		_ = group.Remove(sid)
+	}
+}
+
+func (g *Group) Name() string {
+	return g.name
+}
+
+func (g *Group) Add(agent *Agent) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.closed {
+		return cerr.SessionClosedGroup
+	}
+
+	if _, found := g.members[agent.SID()]; found {
+		return cerr.SessionDuplication
+	}
+
+	g.members[agent.SID()] = agent
+	return nil
+}
+
+func (g *Group) Remove(sid cfacade.SID) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, found := g.members[sid]; !found {
+		return cerr.SessionMemberNotFound
+	}
+
+	delete(g.members, sid)
+	return nil
+}
+
+func (g *Group) Contains(sid cfacade.SID) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	_, found := g.members[sid]
+	return found
+}
+
+func (g *Group) Count() int {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return len(g.members)
+}
+
+// Members returns the uid list of the group members
+func (g *Group) Members() []cfacade.UID {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	list := make([]cfacade.UID, 0, len(g.members))
+	for _, agent := range g.members {
+		list = append(list, agent.UID())
+	}
+	return list
+}
+
+// Broadcast push the message to all members, the failed members are returned as PushError.
+// see MultiPush, and BroadcastWith for the slow consumers
+func (g *Group) Broadcast(route string, v interface{}) error {
+	return multiPush(g.agents(), route, v, PushError{})
+}
+
+// Close remove all members and delete the group
+func (g *Group) Close() {
+	g.lock.Lock()
+	g.closed = true
+	g.members = make(map[cfacade.SID]*Agent)
+	g.lock.Unlock()
+
+	groupLock.Lock()
+	defer groupLock.Unlock()
+
+	if group, found := groupMap[g.name]; found && group == g {
+		delete(groupMap, g.name)
+	}
+}
+
+func (g *Group) agents() []*Agent {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	list := make([]*Agent, 0, len(g.members))
+	for _, agent := range g.members {
+		list = append(list, agent)
+	}
+	return list
+}
@@ -0,0 +1,104 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+func TestGroup(t *testing.T) {
+	group := NewGroup("test-room")
+	defer group.Close()
+
+	if g, found := GetGroup("test-room"); !found || g != group || NewGroup("test-room") != group {
+		t.Fatal("get group error")
+	}
+
+	agent1 := newTestAgent("group-1")
+	agent2 := newTestAgent("group-2")
+
+	if err := group.Add(agent1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := group.Add(agent2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := group.Add(agent1); err != cerr.SessionDuplication {
+		t.Fatalf("add twice err = %v", err)
+	}
+
+	if group.Count() != 2 || !group.Contains(agent1.SID()) {
+		t.Fatalf("group count = %d", group.Count())
+	}
+
+	if err := group.Broadcast("test.push", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(agent1.chWrite) != 1 || len(agent2.chWrite) != 1 {
+		t.Fatalf("broadcast queued = %d, %d", len(agent1.chWrite), len(agent2.chWrite))
+	}
+
+	if err := group.Remove(agent2.SID()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := group.Remove(agent2.SID()); err != cerr.SessionMemberNotFound {
+		t.Fatalf("remove twice err = %v", err)
+	}
+
+	// the closed member is returned as PushError
+	agent1.Close()
+	errs, ok := group.Broadcast("test.push", "hello").(PushError)
+	if !ok || errs[agent1.SID()] != cerr.SessionClosed {
+		t.Fatalf("broadcast errs = %v", errs)
+	}
+}
+
+func TestGroupLeaveOnClose(t *testing.T) {
+	group := NewGroup("test-leave")
+	defer group.Close()
+
+	agent := newTestAgent("group-leave")
+	BindSID(agent)
+
+	if err := group.Add(agent); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	agent.AddOnClose(func(*Agent) {
+		close(closed)
+	})
+
+	agent.Run()
+	agent.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("agent is not closed")
+	}
+
+	// onClose is fired before the agent leaves the groups
+	deadline := time.Now().Add(time.Second)
+	for group.Contains(agent.SID()) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if group.Contains(agent.SID()) {
+		t.Fatal("closed agent is not removed from the group")
+	}
+
+	group.Close()
+	if _, found := GetGroup("test-leave"); found {
+		t.Fatal("closed group is found")
+	}
+
+	if err := group.Add(agent); err != cerr.SessionClosedGroup {
+		t.Fatalf("add to closed group err = %v", err)
+	}
+}
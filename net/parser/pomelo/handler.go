@@ -0,0 +1,59 @@
+package pomelo
+
+import (
+	"fmt"
+	"reflect"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+var (
+	sessionType = reflect.TypeOf((*cproto.Session)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Register the typed handler of the client messages, see NewHandler
+//
+//	p.Register("login", func(session *cproto.Session, req *pb.LoginRequest) (*pb.LoginResponse, error) {...})
+func (p *ActorBase) Register(funcName string, fn interface{}) {
+	p.Local().Register(funcName, NewHandler(p, fn))
+}
+
+// NewHandler wrap fn(func(session *cproto.Session, req *Req) (*Resp, error)) to the local handler of the actor.
+// the req is decoded by the app serializer, the resp is responded to the client if the message is a request,
+// the notify message has no response. the returned error is responded by the handler error func(see ResponseError),
+// nil resp without error is not responded. panics if the shape of fn is wrong.
+func NewHandler(iActor cfacade.IActor, fn interface{}) interface{} {
+	value := reflect.ValueOf(fn)
+	typ := reflect.TypeOf(fn)
+
+	if typ == nil || typ.Kind() != reflect.Func || typ.NumIn() != 2 || typ.NumOut() != 2 ||
+		typ.In(0) != sessionType || typ.In(1).Kind() != reflect.Ptr || typ.Out(1) != errorType {
+		panic(fmt.Sprintf("[pomelo] handler must be func(*cproto.Session, *Req) (*Resp, error). [fn = %v]", typ))
+	}
+
+	handlerType := reflect.FuncOf([]reflect.Type{sessionType, typ.In(1)}, []reflect.Type{errorType}, false)
+
+	return reflect.MakeFunc(handlerType, func(args []reflect.Value) []reflect.Value {
+		rets := value.Call(args)
+		if err := rets[1]; !err.IsNil() {
+			return []reflect.Value{err}
+		}
+
+		session := args[0].Interface().(*cproto.Session)
+		if session != nil && session.Mid > 0 && !isNilValue(rets[0]) {
+			Response(iActor, session.AgentPath, session.Sid, session.Mid, rets[0].Interface())
+		}
+
+		return []reflect.Value{reflect.Zero(errorType)}
+	}).Interface()
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
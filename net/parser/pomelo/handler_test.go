@@ -0,0 +1,93 @@
+package pomelo
+
+import (
+	"reflect"
+	"testing"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	creflect "github.com/cherry-game/cherry/extend/reflect"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cactor "github.com/cherry-game/cherry/net/actor"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+// testCallActor records the responses of the handler
+type testCallActor struct {
+	cfacade.IActor
+	responses []*cproto.PomeloResponse
+}
+
+func (*testCallActor) App() cfacade.IApplication {
+	return testApp{}
+}
+
+func (p *testCallActor) Call(_, funcName string, arg interface{}) int32 {
+	if funcName == ResponseFuncName {
+		p.responses = append(p.responses, arg.(*cproto.PomeloResponse))
+	}
+	return ccode.OK
+}
+
+func TestNewHandler(t *testing.T) {
+	backend := &testCallActor{}
+
+	handler := NewHandler(backend, func(_ *cproto.Session, req *testPush) (*testPush, error) {
+		if req.ID < 1 {
+			return nil, cerr.WithCode(cerr.Error("id value error"), ccode.HandlerError)
+		}
+		return &testPush{ID: req.ID, Name: "hello " + req.Name}, nil
+	})
+
+	fi, err := creflect.GetFuncInfo(handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoke := func(mid uint32, args string) {
+		cactor.InvokeLocalFunc(testApp{}, &fi, &cfacade.Message{
+			Target:   "game-1.player",
+			FuncName: "login",
+			Session:  &cproto.Session{Sid: "typed-handler", AgentPath: "gate-1.user", Mid: mid},
+			Args:     []byte(args),
+		})
+	}
+
+	// the request is decoded and the resp is responded
+	invoke(7, `{"id":1,"name":"cherry"}`)
+	if len(backend.responses) != 1 || backend.responses[0].Mid != 7 || string(backend.responses[0].Data) != `{"id":1,"name":"hello cherry"}` {
+		t.Fatalf("responses = %v", backend.responses)
+	}
+
+	// the notify has no response
+	invoke(0, `{"id":1,"name":"cherry"}`)
+	if len(backend.responses) != 1 {
+		t.Fatalf("responses = %v", backend.responses)
+	}
+
+	// the error is returned to the handler error func
+	rets := fi.Value.Call([]reflect.Value{reflect.ValueOf(&cproto.Session{Mid: 8}), reflect.ValueOf(&testPush{})})
+	if code, _ := cerr.Code(rets[0].Interface().(error)); code != ccode.HandlerError || len(backend.responses) != 1 {
+		t.Fatalf("rets = %v, responses = %v", rets[0], backend.responses)
+	}
+}
+
+func TestNewHandlerShape(t *testing.T) {
+	for _, fn := range []interface{}{
+		nil,
+		"login",
+		func(*cproto.Session, *testPush) error { return nil },
+		func(*cproto.Session, testPush) (*testPush, error) { return nil, nil },
+		func(*testPush) (*testPush, error) { return nil, nil },
+		func(*cproto.Session, *testPush) (*testPush, int32) { return nil, 0 },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("fn = %T is registered", fn)
+				}
+			}()
+			NewHandler(&testCallActor{}, fn)
+		}()
+	}
+}
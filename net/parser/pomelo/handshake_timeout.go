@@ -0,0 +1,46 @@
+package pomelo
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SetHandshakeTimeout close the new connection if the handshake ack is not received within d, zero is disabled(default).
+// the agent never became a working session, so it's closed without the kick packet and the onClose listeners.
+// see Stats().HandshakeTimeouts. it's applied to the connections accepted after it's called.
+func (*actor) SetHandshakeTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	storeDuration(&cmd.handshakeTimeout, d)
+}
+
+// watchHandshake close the agent if it's not working after the handshake timeout
+func (a *Agent) watchHandshake() {
+	d := loadDuration(&cmd.handshakeTimeout)
+	if d <= 0 {
+		return
+	}
+
+	time.AfterFunc(d, a.handshakeExpired)
+}
+
+func (a *Agent) handshakeExpired() {
+	// the handshake ack is not changed to working after the check
+	if !atomic.CompareAndSwapInt32(&a.state, AgentInit, AgentClosed) &&
+		!atomic.CompareAndSwapInt32(&a.state, AgentWaitAck, AgentClosed) {
+		return
+	}
+
+	atomic.AddInt64(&counters.handshakeTimeouts, 1)
+	atomic.StoreInt32(&a.fired, 1) // the onClose listeners are not fired
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Handshake timeout. [ip = %s]", a.RemoteAddress())
+	}
+
+	a.setCloseCause(CauseHandshakeTimeout, nil)
+	a.Close()
+}
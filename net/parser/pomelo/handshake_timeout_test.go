@@ -0,0 +1,56 @@
+package pomelo
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandshakeTimeout(t *testing.T) {
+	p := &actor{}
+	defer p.SetHandshakeTimeout(0)
+	p.SetHandshakeTimeout(20 * time.Millisecond)
+
+	before := Stats().HandshakeTimeouts
+
+	var closed int32
+	idle := newTestAgent("handshake-idle")
+	idle.AddOnClose(func(*Agent) {
+		atomic.AddInt32(&closed, 1)
+	})
+
+	working := newTestAgent("handshake-working")
+	working.AddOnClose(func(*Agent) {
+		atomic.AddInt32(&closed, 1)
+	})
+
+	for _, agent := range []*Agent{idle, working} {
+		BindSID(agent)
+		agent.Run()
+	}
+	defer working.Close()
+
+	working.SetState(AgentWaitAck)
+	handshakeACKCommand(working, nil)
+
+	// unbound by the write goroutine after it's closed
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, found := GetAgent(idle.SID()); !found {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if !idle.IsClosed() || working.IsClosed() {
+		t.Fatalf("idle closed = %v, working closed = %v", idle.IsClosed(), working.IsClosed())
+	}
+
+	if _, found := GetAgent(idle.SID()); found {
+		t.Fatal("the timeout agent is not unbound")
+	}
+
+	if n := Stats().HandshakeTimeouts - before; n != 1 || atomic.LoadInt32(&closed) != 0 {
+		t.Fatalf("handshake timeouts = %d, onClose fired = %d", n, atomic.LoadInt32(&closed))
+	}
+}
@@ -0,0 +1,60 @@
+package pomelo
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SetHeartbeat the heartbeat interval sent to the client in the handshake, less than 1s is 60s(default).
+// the agent without any packet within t is closed with CauseHeartbeatTimeout.
+// maxMissed kick the working agent with KickHeartbeat(CauseHeartbeatTimeout) after maxMissed intervals without
+// the heartbeat of the client(see Agent.SinceHeartbeat). they're checked by the actor instead of the agents,
+// the agents not working are still closed after t without any packet.
+// with maxMissed, the server also sends the heartbeat packet to the working agents every t, so the client knows
+// the connection is alive. the pomelo clients reply it, the clients must accept the unsolicited heartbeats.
+// zero maxMissed is disabled(default), nothing is sent by the server.
+// it must be called before the actor is started, the interval of the handshake and the check is fixed on start,
+// a later call only changes the timeout of the missed heartbeats.
+func (*actor) SetHeartbeat(t time.Duration, maxMissed ...int) {
+	if t.Seconds() < 1 {
+		t = 60 * time.Second
+	}
+	storeDuration(&cmd.heartbeatTime, t)
+
+	var missed int32
+	if len(maxMissed) > 0 && maxMissed[0] > 0 {
+		missed = int32(maxMissed[0])
+	}
+	atomic.StoreInt32(&cmd.heartbeatMissed, missed)
+}
+
+// checkHeartbeat kick the agents missed the heartbeats, and send the heartbeat to the others.
+// the kick writes to the conn synchronously, it's done in a goroutine so a stalled client doesn't block the actor
+func (p *actor) checkHeartbeat() {
+	missed := atomic.LoadInt32(&cmd.heartbeatMissed)
+	if missed <= 0 {
+		return
+	}
+
+	timeout := loadDuration(&cmd.heartbeatTime) * time.Duration(missed)
+	ForeachAgent(func(agent *Agent) {
+		if agent.State() != AgentWorking {
+			return
+		}
+
+		if agent.SinceHeartbeat() > timeout {
+			if agent.PrintLevel(zapcore.DebugLevel) {
+				agent.Debugf("Heartbeat missed. [since = %s]", agent.SinceHeartbeat())
+			}
+			go agent.KickWith(KickReason{
+				Code:    KickCodeHeartbeat,
+				Message: KickHeartbeat,
+			}, true)
+			return
+		}
+
+		_ = agent.sendBytes(cmd.heartbeatBytes)
+	})
+}
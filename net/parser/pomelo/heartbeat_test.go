@@ -0,0 +1,107 @@
+package pomelo
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestCheckHeartbeat(t *testing.T) {
+	// the heartbeat time(60s) is read by the running agents, see SetHeartbeat
+	defer atomic.StoreInt32(&cmd.heartbeatMissed, 0)
+	cmd.setHeartbeatBytes()
+
+	alive, aliveConn, _ := NewMockAgent(testApp{}, "heartbeat-alive", 0)
+	stale, staleConn, _ := NewMockAgent(testApp{}, "heartbeat-stale", 0)
+
+	defer alive.Close()
+
+	handshaking := newTestAgent("heartbeat-handshaking")
+	BindSID(handshaking)
+	defer Unbind(handshaking.SID())
+
+	alive.Heartbeat()
+	for _, agent := range []*Agent{stale, handshaking} {
+		atomic.StoreInt64(&agent.lastHeartbeat, time.Now().Add(-3*loadDuration(&cmd.heartbeatTime)).UnixMilli())
+	}
+
+	// disabled
+	atomic.StoreInt32(&cmd.heartbeatMissed, 0)
+	if (&actor{}).checkHeartbeat(); stale.IsClosed() {
+		t.Fatal("the missed heartbeats are checked")
+	}
+
+	atomic.StoreInt32(&cmd.heartbeatMissed, 2)
+
+	// the stalled client doesn't block the actor, the kick is not written by it
+	staleConn.Lock()
+	checked := make(chan struct{})
+	go func() {
+		(&actor{}).checkHeartbeat()
+		close(checked)
+	}()
+
+	select {
+	case <-checked:
+	case <-time.After(time.Second):
+		t.Fatal("the actor is blocked by the stalled client")
+	}
+	staleConn.Unlock()
+
+	for i := 0; i < 100 && !stale.IsClosed(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !stale.IsClosed() || stale.CloseCause() != CauseHeartbeatTimeout || handshaking.IsClosed() {
+		t.Fatalf("closed = %v, cause = %s", stale.IsClosed(), stale.CloseCause())
+	}
+
+	if frames := staleConn.SentFrames(); len(frames) != 1 || frames[0].Type != pomeloPacket.Kick {
+		t.Fatalf("stale frames = %+v", frames)
+	}
+
+	// the server heartbeat
+	_ = alive.Flush()
+	if frames := aliveConn.SentFrames(); alive.IsClosed() || len(frames) != 1 || frames[0].Type != pomeloPacket.Heartbeat {
+		t.Fatalf("alive frames = %+v", frames)
+	}
+}
+
+func TestHeartbeatNotWorking(t *testing.T) {
+	// the agents read the heartbeat time on start
+	heartbeatTime := loadDuration(&cmd.heartbeatTime)
+	defer func() {
+		storeDuration(&cmd.heartbeatTime, heartbeatTime)
+		atomic.StoreInt32(&cmd.heartbeatMissed, 0)
+	}()
+	storeDuration(&cmd.heartbeatTime, 10*time.Millisecond)
+	atomic.StoreInt32(&cmd.heartbeatMissed, 2)
+
+	working, _, _ := NewMockAgent(testApp{}, "heartbeat-working", 0)
+	defer working.Close()
+
+	handshaking := NewAgent(testApp{}, NewMockConn(), &cproto.Session{Sid: "heartbeat-not-working"})
+	BindSID(&handshaking)
+	handshaking.Run()
+
+	// no packet since a minute ago, the working agent is checked by the actor
+	for _, agent := range []*Agent{working, &handshaking} {
+		atomic.StoreInt64(&agent.lastAt, time.Now().Add(-time.Minute).Unix())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !handshaking.IsClosed() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !handshaking.IsClosed() || handshaking.CloseCause() != CauseHeartbeatTimeout {
+		t.Fatalf("closed = %v, cause = %s", handshaking.IsClosed(), handshaking.CloseCause())
+	}
+
+	if time.Sleep(50 * time.Millisecond); working.IsClosed() {
+		t.Fatalf("the working agent is closed, cause = %s", working.CloseCause())
+	}
+}
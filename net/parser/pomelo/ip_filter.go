@@ -0,0 +1,77 @@
+package pomelo
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+type (
+	// ipFilter the cidrs checked when the conn is accepted, it's replaced by SetIPFilter at runtime
+	ipFilter struct {
+		allow []*net.IPNet // empty is allow all
+		deny  []*net.IPNet
+	}
+)
+
+var (
+	acceptFilter atomic.Value // *ipFilter
+)
+
+func init() {
+	acceptFilter.Store(&ipFilter{})
+}
+
+// SetIPFilter the allow and deny cidrs of the new connections. eg. "10.0.0.0/8", "203.0.113.7/32".
+// the deny takes precedence over the allow, empty allow is allow all. the rejected conn is closed
+// before the agent is created. it can be changed at runtime, nothing is changed if it returns error.
+func (*actor) SetIPFilter(allow, deny []string) error {
+	allowNets, err := parseCIDRs(allow)
+	if err != nil {
+		return err
+	}
+
+	denyNets, err := parseCIDRs(deny)
+	if err != nil {
+		return err
+	}
+
+	acceptFilter.Store(&ipFilter{
+		allow: allowNets,
+		deny:  denyNets,
+	})
+	return nil
+}
+
+// IsIPAllowed returns false if the ip is denied or not allowed by SetIPFilter, the nil ip is allowed
+// only if the filter is empty
+func (*actor) IsIPAllowed(ip net.IP) bool {
+	return acceptFilter.Load().(*ipFilter).allowed(ip)
+}
+
+func (p *ipFilter) allowed(ip net.IP) bool {
+	if len(p.allow) < 1 && len(p.deny) < 1 {
+		return true
+	}
+
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range p.deny {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+
+	if len(p.allow) < 1 {
+		return true
+	}
+
+	for _, ipNet := range p.allow {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
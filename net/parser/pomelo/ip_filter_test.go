@@ -0,0 +1,73 @@
+package pomelo
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	p := &actor{}
+	defer p.SetIPFilter(nil, nil)
+
+	if !p.IsIPAllowed(net.ParseIP("203.0.113.7")) || !p.IsIPAllowed(nil) {
+		t.Fatal("empty filter denies the ip")
+	}
+
+	if err := p.SetIPFilter([]string{"10.0.0.0/8"}, []string{"bad"}); err == nil {
+		t.Fatal("the invalid cidr is accepted")
+	}
+
+	if err := p.SetIPFilter([]string{"10.0.0.0/8", "192.168.1.0/24"}, []string{"10.1.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"10.2.3.4":    true,
+		"192.168.1.9": true,
+		"10.1.2.3":    false, // deny takes precedence
+		"203.0.113.7": false, // not allowed
+	}
+
+	for ip, allowed := range cases {
+		if p.IsIPAllowed(net.ParseIP(ip)) != allowed {
+			t.Fatalf("ip = %s, allowed = %v", ip, !allowed)
+		}
+	}
+
+	if p.IsIPAllowed(nil) {
+		t.Fatal("nil ip is allowed")
+	}
+
+	// deny only
+	if err := p.SetIPFilter(nil, []string{"203.0.113.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.IsIPAllowed(net.ParseIP("203.0.113.7")) || !p.IsIPAllowed(net.ParseIP("10.1.2.3")) {
+		t.Fatal("deny list is not applied")
+	}
+}
+
+func TestIPFilterAccept(t *testing.T) {
+	p := &actor{}
+	defer p.SetIPFilter(nil, nil)
+
+	if err := p.SetIPFilter(nil, []string{"127.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	server, client := newTCPPair(t)
+	defer client.Close()
+
+	count := Count()
+	p.defaultOnConnectFunc(server)
+
+	// closed without the agent
+	if Count() != count {
+		t.Fatalf("agent is created. [count = %d]", Count())
+	}
+
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Fatal("the denied conn is not closed")
+	}
+}
@@ -0,0 +1,109 @@
+package pomelo
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+type (
+	// ipLimiter the online agents of each ip, the ip is removed when the count drops to zero
+	ipLimiter struct {
+		sync.Mutex
+		max       int64            // zero is unlimited, it's changed at runtime by atomic
+		counts    map[string]int64 // key:ip, value:online agents
+		allowlist []*net.IPNet     // the ips bypass the limit. eg. NAT gateways, load balancers
+	}
+)
+
+var (
+	ipLimit = &ipLimiter{
+		counts: make(map[string]int64),
+	}
+)
+
+// acquireIP returns the counted ip if the agent is allowed, it's released by releaseIP() on close.
+// the ip is not counted if the limit is disabled or the ip is in the allowlist(empty ip is returned)
+func acquireIP(ip net.IP) (string, bool) {
+	maxSessions := atomic.LoadInt64(&ipLimit.max)
+	if maxSessions < 1 || ip == nil {
+		return "", true
+	}
+
+	ipLimit.Lock()
+	defer ipLimit.Unlock()
+
+	for _, ipNet := range ipLimit.allowlist {
+		if ipNet.Contains(ip) {
+			return "", true
+		}
+	}
+
+	key := ip.String()
+	if ipLimit.counts[key] >= maxSessions {
+		return "", false
+	}
+
+	ipLimit.counts[key]++
+	return key, true
+}
+
+func releaseIP(key string) {
+	if key == "" {
+		return
+	}
+
+	ipLimit.Lock()
+	defer ipLimit.Unlock()
+
+	if n := ipLimit.counts[key] - 1; n > 0 {
+		ipLimit.counts[key] = n
+	} else {
+		delete(ipLimit.counts, key)
+	}
+}
+
+// IPSessionCount returns the online agents of the ip counted by the session limit of the ip
+func IPSessionCount(ip net.IP) int {
+	ipLimit.Lock()
+	defer ipLimit.Unlock()
+
+	return int(ipLimit.counts[ip.String()])
+}
+
+// SetMaxSessionsPerIP the online agents limit of each remote ip, the new connections are rejected with KickIPLimited
+// when the limit is reached. zero is unlimited(default), it can be changed at runtime.
+func (*actor) SetMaxSessionsPerIP(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&ipLimit.max, int64(n))
+}
+
+// SetIPAllowlist the ips of the cidrs bypass the session limit of the ip. eg. "10.0.0.0/8", "192.168.1.10/32"
+func (*actor) SetIPAllowlist(cidrs ...string) error {
+	allowlist, err := parseCIDRs(cidrs)
+	if err != nil {
+		return err
+	}
+
+	ipLimit.Lock()
+	defer ipLimit.Unlock()
+
+	ipLimit.allowlist = allowlist
+	return nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	ipNets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, cerr.Errorf("cidr parse fail. [cidr = %s, err = %v]", cidr, err)
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets, nil
+}
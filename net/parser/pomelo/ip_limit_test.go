@@ -0,0 +1,59 @@
+package pomelo
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPLimit(t *testing.T) {
+	p := NewActor("ip-limit")
+	p.SetMaxSessionsPerIP(2)
+	defer p.SetMaxSessionsPerIP(0)
+
+	if err := p.SetIPAllowlist("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	defer p.SetIPAllowlist()
+
+	ip := net.ParseIP("192.168.1.10")
+
+	key1, ok1 := acquireIP(ip)
+	key2, ok2 := acquireIP(ip)
+	if !ok1 || !ok2 {
+		t.Fatal("ip is limited before the limit")
+	}
+
+	if _, ok := acquireIP(ip); ok {
+		t.Fatal("ip is not limited")
+	}
+
+	// the allowlist bypass the limit
+	for i := 0; i < 3; i++ {
+		if key, ok := acquireIP(net.ParseIP("10.1.2.3")); !ok || key != "" {
+			t.Fatalf("allowlist ip is limited. [key = %s]", key)
+		}
+	}
+
+	releaseIP(key1)
+	if _, ok := acquireIP(ip); !ok {
+		t.Fatal("ip is limited after release")
+	}
+
+	releaseIP(key1)
+	releaseIP(key2)
+	if n := IPSessionCount(ip); n != 0 {
+		t.Fatalf("count = %d", n)
+	}
+
+	// the ip of zero count is removed
+	ipLimit.Lock()
+	_, found := ipLimit.counts[ip.String()]
+	ipLimit.Unlock()
+	if found {
+		t.Fatal("ip is not removed")
+	}
+
+	if err := p.SetIPAllowlist("10.0.0.0"); err == nil {
+		t.Fatal("invalid cidr is accepted")
+	}
+}
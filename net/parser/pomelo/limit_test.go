@@ -0,0 +1,58 @@
+package pomelo
+
+import (
+	"net"
+	"testing"
+
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestMaxSessions(t *testing.T) {
+	p := NewActor("limit")
+	defer p.SetMaxSessions(0)
+
+	agent := newTestAgent("limit-1")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	p.SetMaxSessions(Count() + 1)
+	if p.IsFull() {
+		t.Fatal("is full before the limit")
+	}
+
+	agent2 := newTestAgent("limit-2")
+	BindSID(agent2)
+	defer Unbind(agent2.SID())
+
+	if !p.IsFull() {
+		t.Fatalf("is not full. [count = %d]", Count())
+	}
+
+	// raised at runtime
+	p.SetMaxSessions(Count() + 1)
+	if p.IsFull() {
+		t.Fatal("is full after the limit is raised")
+	}
+}
+
+func TestRejectConn(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	go rejectConn(testApp{}.Serializer(), conn, KickReason{Code: KickCodeServerFull, Message: KickServerFull})
+
+	packets, _, err := pomeloPacket.Read(peer)
+	if err != nil || len(packets) != 1 || packets[0].Type() != pomeloPacket.Kick {
+		t.Fatalf("packets = %v, err = %v", packets, err)
+	}
+
+	reason := KickReason{}
+	if err := jsoniter.Unmarshal(packets[0].Data(), &reason); err != nil {
+		t.Fatal(err)
+	}
+
+	if reason.Code != KickCodeServerFull || reason.Message != KickServerFull {
+		t.Fatalf("reason = %+v", reason)
+	}
+}
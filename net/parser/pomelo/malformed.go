@@ -0,0 +1,42 @@
+package pomelo
+
+import (
+	"sync/atomic"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+// SetMaxMalformedPackets the agent is kicked with KickMalformed after n malformed packets, zero is disabled(default).
+// the malformed data packet is skipped, the stream is still framed by the packet header.
+// the invalid packet header can't be skipped, the agent is kicked immediately.
+func (*actor) SetMaxMalformedPackets(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&cmd.maxMalformed, int32(n))
+}
+
+// unframed the packet header is invalid, the next packet can't be found in the stream
+func unframed(err error) bool {
+	return err == cerr.PacketWrongType || err == cerr.PacketSizeExceed
+}
+
+// malformedPacket counts the malformed packet, returns false if the agent is kicked
+func (a *Agent) malformedPacket(err error, data []byte) bool {
+	atomic.AddInt64(&counters.malformed, 1)
+	malformed := atomic.AddInt32(&a.malformed, 1)
+
+	a.Warnf("Malformed packet. [malformed = %d, len = %d, error = %s]", malformed, len(data), err)
+
+	maxMalformed := atomic.LoadInt32(&cmd.maxMalformed)
+	if !unframed(err) && (maxMalformed < 1 || malformed < maxMalformed) {
+		return true
+	}
+
+	_ = a.KickWith(KickReason{
+		Code:    KickCodeMalformed,
+		Message: KickMalformed,
+	}, true)
+
+	return false
+}
@@ -0,0 +1,81 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+func TestMaxMalformedPackets(t *testing.T) {
+	p := &actor{}
+	defer p.SetMaxMalformedPackets(0)
+	p.SetMaxMalformedPackets(2)
+
+	agent := newTestAgent("max-malformed")
+	agent.SetState(AgentWorking)
+
+	stats := Stats()
+
+	// truncated route
+	pkg, _ := ppacket.Encode(ppacket.Data, []byte{0x02, 0x05, 'a'})
+	packets, _ := ppacket.Decode(pkg)
+
+	dataCommand(agent, packets[0])
+	if agent.IsClosed() || Stats().Malformed != stats.Malformed+1 {
+		t.Fatalf("closed = %v, malformed = %d", agent.IsClosed(), Stats().Malformed-stats.Malformed)
+	}
+
+	dataCommand(agent, packets[0])
+	if !agent.IsClosed() || Stats().Kicks[KickMalformed] != stats.Kicks[KickMalformed]+1 {
+		t.Fatalf("closed = %v, kicks = %v", agent.IsClosed(), Stats().Kicks)
+	}
+}
+
+func TestReadMalformedPackets(t *testing.T) {
+	defer func() {
+		cmd.onPacketFuncMap = make(map[ppacket.Type]PacketFunc, 4)
+	}()
+	cmd.setOnPacketFunc()
+
+	server, client := newTCPPair(t)
+	defer client.Close()
+
+	agent := newTestAgent("read-malformed")
+	agent.conn = server
+	agent.SetState(AgentWorking)
+
+	stats := Stats()
+
+	done := make(chan struct{})
+	go func() {
+		agent.readChan()
+		close(done)
+	}()
+
+	// the malformed data packet is skipped
+	pkg, _ := ppacket.Encode(ppacket.Data, []byte{0x02, 0x05, 'a'})
+	client.Write(pkg)
+
+	deadline := time.Now().Add(time.Second)
+	for Stats().Malformed == stats.Malformed && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if agent.IsClosed() || Stats().Malformed != stats.Malformed+1 {
+		t.Fatalf("closed = %v, malformed = %d", agent.IsClosed(), Stats().Malformed-stats.Malformed)
+	}
+
+	// the invalid header can't be skipped
+	client.Write([]byte{0x09, 0x00, 0x00, 0x01, 'a'})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("read chan is not exit")
+	}
+
+	if !agent.IsClosed() || agent.isLost() || Stats().Kicks[KickMalformed] != stats.Kicks[KickMalformed]+1 {
+		t.Fatalf("closed = %v, lost = %v, kicks = %v", agent.IsClosed(), agent.isLost(), Stats().Kicks)
+	}
+}
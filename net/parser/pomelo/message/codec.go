@@ -0,0 +1,60 @@
+package pomeloMessage
+
+import (
+	cerr "github.com/cherry-game/cherry/error"
+	ccompress "github.com/cherry-game/cherry/extend/compress"
+)
+
+const (
+	CodecZlib = "zlib"
+	CodecGzip = "gzip"
+)
+
+type (
+	// Codec compress the message data, the compressed message is marked by GZIPMask
+	Codec struct {
+		Name       string
+		Compress   func(data []byte) ([]byte, error)
+		Decompress func(data []byte) ([]byte, error)
+	}
+)
+
+var (
+	codecs = map[string]*Codec{
+		CodecZlib: {
+			Name:       CodecZlib,
+			Compress:   ccompress.DeflateData,
+			Decompress: ccompress.InflateData,
+		},
+		CodecGzip: {
+			Name:       CodecGzip,
+			Compress:   ccompress.GzipData,
+			Decompress: ccompress.UnGzipData,
+		},
+	}
+	codec = codecs[CodecZlib] // current codec
+)
+
+// RegisterCodec register the codec used by SetCompression
+func RegisterCodec(c *Codec) {
+	if c == nil || c.Name == "" || c.Compress == nil || c.Decompress == nil {
+		return
+	}
+	codecs[c.Name] = c
+}
+
+// SetCodec set the current codec, the client must use the same codec to inflate
+func SetCodec(name string) error {
+	c, found := codecs[name]
+	if !found {
+		return cerr.Errorf("codec not found. [name = %s]", name)
+	}
+
+	codec = c
+	return nil
+}
+
+// CodecName returns the name of the current codec
+func CodecName() string {
+	return codec.Name
+}
@@ -1,5 +1,7 @@
 package pomeloMessage
 
+import "sync/atomic"
+
 // Message types
 const (
 	Request  Type = 0x00 // ----000-
@@ -37,10 +39,12 @@ const (
 	TypeMask          = 0x07 // 获取消息类型 00000111
 	GZIPMask          = 0x10 // data compressed gzip mark
 	ErrorMask         = 0x20 // 响应错误标识 00100000
+	HeaderMask        = 0x40 // 消息携带header 01000000
 )
 
 var (
-	dataCompression = false // encode message is compression
+	dataCompression  = false // encode message is compression
+	compressMinBytes int64   // the data less than min bytes is not compressed, it's changed at runtime by atomic
 )
 
 func IsDataCompression() bool {
@@ -50,3 +54,37 @@ func IsDataCompression() bool {
 func SetDataCompression(compression bool) {
 	dataCompression = compression
 }
+
+// SetCompression enable the data compression with the codec(see RegisterCodec),
+// the data less than minBytes is sent uncompressed.
+func SetCompression(codecName string, minBytes int) error {
+	if err := SetCodec(codecName); err != nil {
+		return err
+	}
+
+	if minBytes < 0 {
+		minBytes = 0
+	}
+
+	atomic.StoreInt64(&compressMinBytes, int64(minBytes))
+	dataCompression = true
+
+	return nil
+}
+
+// SetCompressMinBytes change the min bytes of the compressed data at runtime. see SetCompression
+func SetCompressMinBytes(minBytes int) {
+	if minBytes < 0 {
+		minBytes = 0
+	}
+	atomic.StoreInt64(&compressMinBytes, int64(minBytes))
+}
+
+func CompressMinBytes() int {
+	return int(atomic.LoadInt64(&compressMinBytes))
+}
+
+// IsCompressData returns true if the data should be compressed
+func IsCompressData(data []byte) bool {
+	return dataCompression && int64(len(data)) >= atomic.LoadInt64(&compressMinBytes)
+}
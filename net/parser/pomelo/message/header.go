@@ -0,0 +1,115 @@
+package pomeloMessage
+
+import (
+	"sort"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+const (
+	headerMaxLength = 0xFF // the max length of the header count, key and value
+)
+
+var (
+	headerMaxCount = 16   // the max count of the header keys
+	headerMaxBytes = 1024 // the max bytes of the header keys and values
+)
+
+// SetHeaderLimit set the max count of the header keys and the max bytes of the header keys and values,
+// the message exceeds the limit returns cerr.MessageHeaderExceed while encode or decode.
+// the count is at most 255, the limit less than 1 is not changed.
+func SetHeaderLimit(maxCount, maxBytes int) {
+	if maxCount > 0 {
+		if maxCount > headerMaxLength {
+			maxCount = headerMaxLength
+		}
+		headerMaxCount = maxCount
+	}
+
+	if maxBytes > 0 {
+		headerMaxBytes = maxBytes
+	}
+}
+
+// encodeHeader appends the header sorted by key to buf.
+// count(1byte) + count * (key length(1byte) + key + value length(1byte) + value)
+func encodeHeader(buf []byte, header map[string]string) ([]byte, error) {
+	if len(header) > headerMaxCount {
+		return nil, cerr.MessageHeaderExceed
+	}
+
+	keys := make([]string, 0, len(header))
+	size := 0
+
+	for k, v := range header {
+		if len(k) > headerMaxLength || len(v) > headerMaxLength {
+			return nil, cerr.MessageHeaderExceed
+		}
+
+		size += len(k) + len(v)
+		keys = append(keys, k)
+	}
+
+	if size > headerMaxBytes {
+		return nil, cerr.MessageHeaderExceed
+	}
+
+	sort.Strings(keys)
+
+	buf = append(buf, byte(len(keys)))
+	for _, k := range keys {
+		v := header[k]
+		buf = append(buf, byte(len(k)))
+		buf = append(buf, k...)
+		buf = append(buf, byte(len(v)))
+		buf = append(buf, v...)
+	}
+
+	return buf, nil
+}
+
+// decodeHeader returns the header and the offset of the data
+func decodeHeader(data []byte, offset int) (map[string]string, int, error) {
+	if offset >= len(data) {
+		return nil, offset, cerr.MessageInvalid
+	}
+
+	count := int(data[offset])
+	offset++
+
+	if count > headerMaxCount {
+		return nil, offset, cerr.MessageHeaderExceed
+	}
+
+	var (
+		header = make(map[string]string, count)
+		size   = 0
+		field  [2]string
+	)
+
+	for i := 0; i < count; i++ {
+		for j := range field {
+			if offset >= len(data) {
+				return nil, offset, cerr.MessageInvalid
+			}
+
+			length := int(data[offset])
+			offset++
+
+			if offset+length > len(data) {
+				return nil, offset, cerr.MessageInvalid
+			}
+
+			if size += length; size > headerMaxBytes {
+				return nil, offset, cerr.MessageHeaderExceed
+			}
+
+			field[j] = string(data[offset:(offset + length)])
+			offset += length
+		}
+
+		header[field[0]] = field[1]
+	}
+
+	return header, offset, nil
+}
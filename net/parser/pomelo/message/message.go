@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	cerr "github.com/cherry-game/cherry/error"
-	ccompress "github.com/cherry-game/cherry/extend/compress"
 )
 
 var (
@@ -44,13 +43,19 @@ var (
 // flag的最后一位为1时，表示路由压缩，需要通过查询字典来获取route;
 // flag最后一位为0是，后面route则由一个uInt8的byte，用来表示route的字节长度。
 // 之后是通过utf8编码后的route字 符串，其长度就是前面一位byte的uInt8的值，因此route的长度最大支持256B。
+//
+// header标志
+// flag的第7位(HeaderMask)为1时，route(或message id)之后为header:
+// count(1byte) + count * (key length(1byte) + key + value length(1byte) + value)
 type Message struct {
-	Type            Type   // message type 4中消息类型
-	ID              uint   // unique id, zero while notify mode 消息id（request response）
-	Route           string // route for locating service 消息路由
-	Data            []byte // payload  消息体的原始数据
-	routeCompressed bool   // is route Compressed 是否启用路由压缩
-	Error           bool   // response error
+	Type            Type              // message type 4中消息类型
+	ID              uint              // unique id, zero while notify mode 消息id（request response）
+	Route           string            // route for locating service 消息路由
+	Data            []byte            // payload  消息体的原始数据
+	Header          map[string]string // metadata of the message, eg. client version, locale. see SetHeaderLimit
+	routeCompressed bool              // is route Compressed 是否启用路由压缩
+	Error           bool              // response error
+	Uncompressed    bool              // the data is never compressed, eg. the client can not inflate it
 }
 
 func New() Message {
@@ -59,11 +64,12 @@ func New() Message {
 
 func (t *Message) String() string {
 	return fmt.Sprintf(
-		"Type: %s, ID: %d, Route: %s, RouteCompressed: %t, Data: %v, BodyLength: %d, Error:%v",
+		"Type: %s, ID: %d, Route: %s, RouteCompressed: %t, Header: %v, Data: %v, BodyLength: %d, Error:%v",
 		t.Type.String(),
 		t.ID,
 		t.Route,
 		t.routeCompressed,
+		t.Header,
 		t.Data,
 		len(t.Data),
 		t.Error)
@@ -101,6 +107,10 @@ func Encode(m *Message) ([]byte, error) {
 		flag |= ErrorMask
 	}
 
+	if len(m.Header) > 0 {
+		flag |= HeaderMask
+	}
+
 	buf = append(buf, flag)
 
 	if m.Type == Request || m.Type == Response {
@@ -128,8 +138,15 @@ func Encode(m *Message) ([]byte, error) {
 		}
 	}
 
-	if IsDataCompression() {
-		d, err := ccompress.DeflateData(m.Data)
+	if len(m.Header) > 0 {
+		var err error
+		if buf, err = encodeHeader(buf, m.Header); err != nil {
+			return nil, err
+		}
+	}
+
+	if !m.Uncompressed && IsCompressData(m.Data) {
+		d, err := codec.Compress(m.Data)
 		if err != nil {
 			return nil, err
 		}
@@ -162,6 +179,7 @@ func Decode(data []byte) (Message, error) {
 
 	if m.Type == Request || m.Type == Response {
 		id := uint(0)
+		end := 0
 		// little end byte order
 		// WARNING: must can be stored in 64 bits integer
 		// variant length encode
@@ -169,11 +187,18 @@ func Decode(data []byte) (Message, error) {
 			b := data[i]
 			id += uint(b&0x7F) << uint(7*(i-offset))
 			if b < 128 {
-				offset = i + 1
+				end = i + 1
 				break
 			}
 		}
+
+		// the id is truncated
+		if end == 0 {
+			return nilMessage, cerr.MessageInvalid
+		}
+
 		m.ID = id
+		offset = end
 	}
 
 	if offset > len(data) {
@@ -184,6 +209,10 @@ func Decode(data []byte) (Message, error) {
 
 	if Routable(m.Type) {
 		if flag&RouteCompressMask == 1 {
+			if offset+2 > len(data) {
+				return nilMessage, cerr.MessageInvalid
+			}
+
 			m.routeCompressed = true
 			code := binary.BigEndian.Uint16(data[offset:(offset + 2)])
 			route, found := GetRoute(code)
@@ -194,9 +223,17 @@ func Decode(data []byte) (Message, error) {
 			offset += 2
 
 		} else {
+			if offset >= len(data) {
+				return nilMessage, cerr.MessageInvalid
+			}
+
 			m.routeCompressed = false
 			rl := data[offset]
 			offset++
+
+			if offset+int(rl) > len(data) {
+				return nilMessage, cerr.MessageInvalid
+			}
 			m.Route = string(data[offset:(offset + int(rl))])
 			offset += int(rl)
 		}
@@ -206,11 +243,18 @@ func Decode(data []byte) (Message, error) {
 		return nilMessage, cerr.MessageInvalid
 	}
 
+	if flag&HeaderMask == HeaderMask {
+		var err error
+		if m.Header, offset, err = decodeHeader(data, offset); err != nil {
+			return nilMessage, err
+		}
+	}
+
 	m.Data = data[offset:]
 
 	var err error
 	if flag&GZIPMask == GZIPMask {
-		m.Data, err = ccompress.InflateData(m.Data)
+		m.Data, err = codec.Decompress(m.Data)
 		if err != nil {
 			return nilMessage, err
 		}
@@ -1,7 +1,10 @@
 package pomeloMessage
 
 import (
+	"bytes"
 	"testing"
+
+	cerr "github.com/cherry-game/cherry/error"
 )
 
 func TestResponseMessageEncode1(t *testing.T) {
@@ -19,3 +22,153 @@ func TestResponseMessageEncode1(t *testing.T) {
 	decode, err := Decode(encode)
 	t.Log(decode, err)
 }
+
+func TestCompressionThreshold(t *testing.T) {
+	defer func() {
+		_ = SetCodec(CodecZlib)
+		compressMinBytes = 0
+		SetDataCompression(false)
+	}()
+
+	if err := SetCompression(CodecGzip, 64); err != nil {
+		t.Fatal(err)
+	}
+
+	small := []byte(`hello world`)
+	large := bytes.Repeat([]byte(`hello world`), 20)
+
+	for _, data := range [][]byte{small, large} {
+		m := &Message{
+			Type:  Push,
+			Route: "room.sync",
+			Data:  data,
+		}
+
+		encode, err := Encode(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		compressed := encode[0]&GZIPMask == GZIPMask
+		if compressed != (len(data) >= 64) {
+			t.Fatalf("data len = %d, compressed = %v", len(data), compressed)
+		}
+
+		decode, err := Decode(encode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(decode.Data, data) {
+			t.Fatalf("decode data = %s", decode.Data)
+		}
+	}
+
+	if err := SetCompression("none", 0); err == nil {
+		t.Fatal("unknown codec is set")
+	}
+}
+
+func TestMessageHeader(t *testing.T) {
+	header := map[string]string{
+		"version": "1.2.0",
+		"locale":  "zh-CN",
+	}
+
+	for _, m := range []*Message{
+		{Type: Request, ID: 300, Route: "game.player.login", Header: header, Data: []byte(`hello`)},
+		{Type: Notify, Route: "game.player.move", Header: header},
+		{Type: Response, ID: 7, Header: header, Data: []byte(`world`)},
+	} {
+		encode, err := Encode(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decode, err := Decode(encode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decode.ID != m.ID || decode.Route != m.Route || !bytes.Equal(decode.Data, m.Data) {
+			t.Fatalf("decode = %s", decode.String())
+		}
+
+		if len(decode.Header) != len(header) || decode.Header["version"] != "1.2.0" || decode.Header["locale"] != "zh-CN" {
+			t.Fatalf("decode header = %v", decode.Header)
+		}
+	}
+
+	encode, _ := Encode(&Message{Type: Push, Route: "room.sync", Data: []byte(`hello`)})
+	if encode[0]&HeaderMask == HeaderMask {
+		t.Fatal("header flag is set without header")
+	}
+}
+
+func TestMessageHeaderLimit(t *testing.T) {
+	defer SetHeaderLimit(16, 1024)
+	SetHeaderLimit(2, 16)
+
+	for _, header := range []map[string]string{
+		{"a": "1", "b": "2", "c": "3"},
+		{"trace": "0123456789abcdef"},
+	} {
+		if _, err := Encode(&Message{Type: Notify, Route: "game.player.move", Header: header}); err != cerr.MessageHeaderExceed {
+			t.Fatalf("header = %v, err = %v", header, err)
+		}
+	}
+
+	encode, err := Encode(&Message{Type: Notify, Route: "game.player.move", Header: map[string]string{"a": "1", "b": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetHeaderLimit(1, 16)
+	if _, err = Decode(encode); err != cerr.MessageHeaderExceed {
+		t.Fatalf("decode err = %v", err)
+	}
+
+	// truncated header
+	SetHeaderLimit(2, 16)
+	if _, err = Decode(encode[:len(encode)-1]); err != cerr.MessageInvalid {
+		t.Fatalf("decode truncated err = %v", err)
+	}
+}
+
+// FuzzDecode the malformed data returns an error and never panics
+//
+//	go test -run none -fuzz FuzzDecode ./net/parser/pomelo/message/
+func FuzzDecode(f *testing.F) {
+	for _, m := range []*Message{
+		{Type: Request, ID: 300, Route: "game.player.login", Data: []byte(`hello`)},
+		{Type: Notify, Route: "game.player.move", Header: map[string]string{"version": "1.2.0"}},
+		{Type: Response, ID: 7, Data: []byte(`world`)},
+		{Type: Push, Route: "room.sync", Data: []byte(`{}`)},
+	} {
+		encode, err := Encode(m)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(encode)
+	}
+
+	f.Add([]byte{0x00, 0xff, 0xff}) // truncated id
+	f.Add([]byte{0x01, 0x01})       // truncated route code
+	f.Add([]byte{0x02, 0x05, 'a'})  // truncated route
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := Decode(data)
+		if err != nil {
+			return
+		}
+
+		if InvalidType(m.Type) {
+			t.Fatalf("decode type = %d", m.Type)
+		}
+
+		// the data is a part of the input without compression
+		if data[0]&GZIPMask != GZIPMask && len(m.Data) > len(data) {
+			t.Fatalf("decode data = %d, input = %d", len(m.Data), len(data))
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package pomelo
+
+import (
+	"sync/atomic"
+
+	ccode "github.com/cherry-game/cherry/code"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	messageSizeExceed = "message size exceed" // the response data of ccode.MessageSizeExceed
+)
+
+// SetMaxMessageSize the max data bytes of the inbound messages of the route, empty route is the global limit.
+// the route limit takes precedence over the global limit, zero bytes removes the limit(default is unlimited).
+// the global limit can be changed at runtime, the route limits must be set before the actor is started.
+func (*actor) SetMaxMessageSize(route string, bytes int) {
+	if bytes < 0 {
+		bytes = 0
+	}
+
+	if route == "" {
+		atomic.StoreInt32(&cmd.maxMessageSize, int32(bytes))
+		return
+	}
+
+	if bytes == 0 {
+		delete(cmd.routeMessageSize, route)
+		return
+	}
+	cmd.routeMessageSize[route] = int32(bytes)
+}
+
+// SetMessageSizeViolation the agent is kicked with KickMessageSize after n oversized messages, zero is disabled
+func (*actor) SetMessageSizeViolation(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&cmd.sizeViolation, int32(n))
+}
+
+func maxMessageSize(route string) int {
+	if size, found := cmd.routeMessageSize[route]; found {
+		return int(size)
+	}
+	return int(atomic.LoadInt32(&cmd.maxMessageSize))
+}
+
+// allowMessageSize returns false if the data of the message exceeds the max size, the request is responded
+func (a *Agent) allowMessageSize(msg *pmessage.Message) bool {
+	maxSize := maxMessageSize(msg.Route)
+	if maxSize < 1 || len(msg.Data) <= maxSize {
+		return true
+	}
+
+	atomic.AddInt64(&counters.oversized, 1)
+	oversized := atomic.AddInt32(&a.oversized, 1)
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Message size exceed. [route = %s, size = %d, max = %d, oversized = %d]",
+			msg.Route,
+			len(msg.Data),
+			maxSize,
+			oversized,
+		)
+	}
+
+	if msg.Type == pmessage.Request {
+		_ = a.ResponseError(uint32(msg.ID), ccode.MessageSizeExceed, messageSizeExceed)
+	}
+
+	if maxViolation := atomic.LoadInt32(&cmd.sizeViolation); maxViolation > 0 && oversized >= maxViolation {
+		_ = a.KickWith(KickReason{
+			Code:    KickCodeMessageSize,
+			Message: KickMessageSize,
+		}, true)
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package pomelo
+
+import (
+	"bytes"
+	"testing"
+
+	ccode "github.com/cherry-game/cherry/code"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestMaxMessageSize(t *testing.T) {
+	p := &actor{}
+	defer func() {
+		p.SetMaxMessageSize("", 0)
+		p.SetMaxMessageSize("game.room.upload", 0)
+		p.SetMessageSizeViolation(0)
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+
+	p.SetMaxMessageSize("", 64)
+	p.SetMaxMessageSize("game.room.upload", 1024)
+	p.SetMessageSizeViolation(2)
+
+	var routed []int
+	cmd.onDataRouteFunc = func(_ *Agent, _ *pmessage.Route, msg *pmessage.Message) {
+		routed = append(routed, len(msg.Data))
+	}
+
+	agent := newTestAgent("max-message-size")
+	agent.SetState(AgentWorking)
+
+	send := func(mid uint, route string, size int) {
+		data, _ := pmessage.Encode(&pmessage.Message{
+			Type:  pmessage.Request,
+			ID:    mid,
+			Route: route,
+			Data:  bytes.Repeat([]byte("a"), size),
+		})
+		pkg, _ := ppacket.Encode(ppacket.Data, data)
+		packets, _ := ppacket.Decode(pkg)
+		dataCommand(agent, packets[0])
+	}
+
+	oversized := Stats().Oversized
+
+	send(1, "game.room.chat", 64)
+	send(2, "game.room.upload", 1024)
+	send(3, "game.room.chat", 65) // just over the global limit
+
+	if len(routed) != 2 || routed[0] != 64 || routed[1] != 1024 {
+		t.Fatalf("routed = %v", routed)
+	}
+
+	pending := <-agent.chPending
+	rsp, ok := pending.payload.(*cproto.Response)
+	if !ok || pending.mid != 3 || rsp.Code != ccode.MessageSizeExceed {
+		t.Fatalf("pending = %s", pending.String())
+	}
+
+	if Stats().Oversized != oversized+1 || agent.IsClosed() {
+		t.Fatalf("oversized = %d, closed = %v", Stats().Oversized-oversized, agent.IsClosed())
+	}
+
+	// kicked after the repeated violations
+	send(4, "game.room.upload", 1025)
+	if !agent.IsClosed() || len(routed) != 2 {
+		t.Fatalf("closed = %v, routed = %v", agent.IsClosed(), routed)
+	}
+}
@@ -0,0 +1,72 @@
+package pomelo
+
+import (
+	"testing"
+
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestMessageMetadata(t *testing.T) {
+	defer func() {
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+
+	cmd.onDataRouteFunc = func(agent *Agent, _ *pmessage.Route, msg *pmessage.Message) {
+		session := BuildSession(agent, msg)
+
+		locale, _ := session.LookupMetadata("locale")
+		agent.ResponseMetadata(session, locale, map[string]string{"server": "1.0.0"})
+	}
+
+	agent := newTestAgent("message-metadata")
+	agent.SetState(AgentWorking)
+
+	route, _ := pmessage.DecodeRoute("game.player.login")
+	dispatchData(agent, route, &pmessage.Message{
+		Type:   pmessage.Request,
+		ID:     9,
+		Route:  "game.player.login",
+		Header: map[string]string{"locale": "zh-CN", "version": "1.2.0"},
+	})
+
+	if agent.session.Clone().Metadata["version"] != "1.2.0" {
+		t.Fatalf("session metadata = %v", agent.session.Metadata)
+	}
+
+	pkg, err := agent.encodePending(<-agent.chPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packets, err := ppacket.Decode(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := pmessage.Decode(packets[0].Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.ID != 9 || string(msg.Data) != `"zh-CN"` || msg.Header["server"] != "1.0.0" {
+		t.Fatalf("response msg = %s", msg.String())
+	}
+
+	// the response of the remote actor
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	(&actor{}).response(&cproto.PomeloResponse{
+		Sid:      agent.SID(),
+		Mid:      10,
+		Data:     []byte(`ok`),
+		Metadata: map[string]string{"server": "1.0.1"},
+	})
+
+	pending := <-agent.chPending
+	if pending.mid != 10 || pending.header["server"] != "1.0.1" {
+		t.Fatalf("pending = %s, header = %v", pending.String(), pending.header)
+	}
+}
@@ -0,0 +1,182 @@
+package pomelo
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type (
+	// MockConn the in-memory conn of the mock agent, it captures the packets written to the client.
+	// nothing is read from it, the read goroutine is blocked until it's closed. see NewMockAgent
+	MockConn struct {
+		sync.Mutex
+		buf    []byte // the bytes of the incomplete packet
+		frames []Frame
+		die    chan struct{}
+		closed bool
+	}
+
+	// Frame the packet written to the mock conn
+	Frame struct {
+		Type    pomeloPacket.Type      // eg. pomeloPacket.Data, pomeloPacket.Kick, pomeloPacket.Heartbeat
+		Data    []byte                 // body of the packet, eg. the kick reason marshaled by the serializer
+		Message *pomeloMessage.Message // decoded message of the data packet(Push or Response), nil of the others
+	}
+
+	mockAddr struct{}
+)
+
+// NewMockAgent returns the running agent on the mock conn for the tests without the network, eg. the handlers
+// and the broadcasts. the agent is working and bound the uid(the invalid uid is not bound), so it's the same as the
+// agent after the handshake. call Agent.Flush before MockConn.SentFrames to wait for the queued messages, the
+// onCreate listeners are not called. the encrypted data packets can not be decoded, see actor.SetEncryption
+func NewMockAgent(app cfacade.IApplication, sid cfacade.SID, uid cfacade.UID) (*Agent, *MockConn, error) {
+	conn := NewMockConn()
+	session := &cproto.Session{
+		Sid:  sid,
+		Data: map[string]string{},
+	}
+
+	agent := NewAgent(app, conn, session)
+	agent.SetState(AgentWorking)
+	BindSID(&agent)
+	agent.Run()
+
+	if cfacade.IsValidUID(uid) {
+		if err := agent.Bind(uid); err != nil {
+			agent.Close()
+			return nil, nil, err
+		}
+	}
+
+	return &agent, conn, nil
+}
+
+func NewMockConn() *MockConn {
+	return &MockConn{
+		die: make(chan struct{}),
+	}
+}
+
+// SentFrames returns the captured frames in the written order
+func (p *MockConn) SentFrames() []Frame {
+	p.Lock()
+	defer p.Unlock()
+
+	frames := make([]Frame, len(p.frames))
+	copy(frames, p.frames)
+	return frames
+}
+
+// Reset clear the captured frames
+func (p *MockConn) Reset() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.frames = nil
+}
+
+// IsClosed returns true if the conn is closed, eg. the agent is kicked
+func (p *MockConn) IsClosed() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.closed
+}
+
+func (p *MockConn) Read(_ []byte) (int, error) {
+	<-p.die
+	return 0, io.EOF
+}
+
+func (p *MockConn) Write(b []byte) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return 0, net.ErrClosed
+	}
+
+	p.buf = append(p.buf, b...)
+	for len(p.buf) >= pomeloPacket.HeadLength {
+		size, err := pomeloPacket.ParseHeader(p.buf[:pomeloPacket.HeadLength])
+		if err != nil {
+			p.buf = nil
+			return 0, err
+		}
+
+		if len(p.buf) < pomeloPacket.HeadLength+size {
+			break
+		}
+
+		data := make([]byte, size)
+		copy(data, p.buf[pomeloPacket.HeadLength:])
+		p.frames = append(p.frames, newFrame(p.buf[0], data))
+		p.buf = p.buf[pomeloPacket.HeadLength+size:]
+	}
+
+	return len(b), nil
+}
+
+func newFrame(typ pomeloPacket.Type, data []byte) Frame {
+	frame := Frame{
+		Type: typ,
+		Data: data,
+	}
+
+	if typ == pomeloPacket.Data {
+		if m, err := pomeloMessage.Decode(data); err == nil {
+			frame.Message = &m
+		}
+	}
+
+	return frame
+}
+
+func (p *MockConn) Close() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return net.ErrClosed
+	}
+
+	p.closed = true
+	close(p.die)
+	return nil
+}
+
+func (p *MockConn) LocalAddr() net.Addr {
+	return mockAddr{}
+}
+
+func (p *MockConn) RemoteAddr() net.Addr {
+	return mockAddr{}
+}
+
+func (p *MockConn) SetDeadline(_ time.Time) error {
+	return nil
+}
+
+func (p *MockConn) SetReadDeadline(_ time.Time) error {
+	return nil
+}
+
+func (p *MockConn) SetWriteDeadline(_ time.Time) error {
+	return nil
+}
+
+func (mockAddr) Network() string {
+	return "mock"
+}
+
+func (mockAddr) String() string {
+	return "127.0.0.1:0"
+}
@@ -0,0 +1,70 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestMockAgent(t *testing.T) {
+	agent, conn, err := NewMockAgent(testApp{}, "mock-agent", 9901)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found, ok := GetAgentWithUID(9901); !ok || found != agent {
+		t.Fatal("uid is not bound")
+	}
+
+	_ = agent.Push("test.push", "hello")
+	_ = agent.ResponseMID(7, "ok")
+	if err = agent.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := conn.SentFrames()
+	if len(frames) != 2 || frames[0].Message == nil || frames[1].Message == nil {
+		t.Fatalf("frames = %+v", frames)
+	}
+
+	if m := frames[0].Message; m.Type != pomeloMessage.Push || m.Route != "test.push" || string(m.Data) != `"hello"` {
+		t.Fatalf("push = %+v", m)
+	}
+
+	if m := frames[1].Message; m.Type != pomeloMessage.Response || m.ID != 7 || string(m.Data) != `"ok"` {
+		t.Fatalf("response = %+v", m)
+	}
+
+	conn.Reset()
+	if err = agent.Kick("banned", true); err != nil {
+		t.Fatal(err)
+	}
+
+	frames = conn.SentFrames()
+	reason := KickReason{}
+	if len(frames) != 1 || frames[0].Type != pomeloPacket.Kick || frames[0].Message != nil {
+		t.Fatalf("frames = %+v", frames)
+	}
+	if err = jsoniter.Unmarshal(frames[0].Data, &reason); err != nil || reason.Message != "banned" {
+		t.Fatalf("kick reason = %+v, err = %v", reason, err)
+	}
+
+	for _, found := GetAgent(agent.SID()); found; _, found = GetAgent(agent.SID()) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !conn.IsClosed() {
+		t.Fatal("conn is not closed")
+	}
+
+	// the rejected bind
+	defer func() { cmd.onBindFuncs = nil }()
+	cmd.onBindFuncs = []OnBindFunc{func(*Agent) bool { return false }}
+
+	if _, _, err = NewMockAgent(testApp{}, "mock-rejected", 9902); err == nil {
+		t.Fatal("bind is not rejected")
+	}
+}
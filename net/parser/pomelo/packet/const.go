@@ -84,7 +84,7 @@ func forward(buf *bytes.Buffer) (int, Type, error) {
 
 	typ := header[0]
 	if InvalidType(typ) {
-		return 0, None, cerr.PacketSizeExceed
+		return 0, None, cerr.PacketWrongType
 	}
 
 	// get 2,3,4 byte
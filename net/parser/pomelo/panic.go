@@ -0,0 +1,68 @@
+package pomelo
+
+import (
+	ccode "github.com/cherry-game/cherry/code"
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+const (
+	PanicPolicyContinue PanicPolicy = 0 // recover and keep the agent
+	PanicPolicyKick     PanicPolicy = 1 // recover and kick the agent with KickHandlerPanic
+
+	panicMessage = "internal error" // the response data of HandlerPanic, the panic value is not sent to the client
+)
+
+type (
+	// PanicPolicy the policy of the handler panic
+	PanicPolicy int32
+)
+
+// SetPanicPolicy the policy of the handler panic, default is PanicPolicyContinue.
+// the panic is always recovered and logged with the stack by the agent logger.
+func (*actor) SetPanicPolicy(policy PanicPolicy) {
+	cmd.panicPolicy = policy
+}
+
+// SetPanicResponse response ccode.HandlerPanic to the request when the handler panic, default is true.
+func (*actor) SetPanicResponse(enable bool) {
+	cmd.panicResponse = enable
+}
+
+// onHandlerPanic the handler of the message is panic in the agent goroutine
+func onHandlerPanic(agent *Agent, typ pmessage.Type, mid uint32) {
+	if cmd.panicResponse && typ == pmessage.Request {
+		_ = agent.ResponseError(mid, ccode.HandlerPanic, panicMessage)
+	}
+
+	if cmd.panicPolicy == PanicPolicyKick {
+		agent.KickWith(KickReason{
+			Code:    KickCodeHandlerPanic,
+			Message: KickHandlerPanic,
+		}, true)
+	}
+}
+
+// kickHandlerPanic the handler of the local actor is panic, the agent is kicked by the agent actor
+func kickHandlerPanic(app cfacade.IApplication, m *cfacade.Message) {
+	if cmd.panicPolicy != PanicPolicyKick {
+		return
+	}
+
+	data, err := app.Serializer().Marshal(&KickReason{
+		Code:    KickCodeHandlerPanic,
+		Message: KickHandlerPanic,
+	})
+	if err != nil {
+		clog.Warnf("[kickHandlerPanic] Marshal error. [err = %v]", err)
+		return
+	}
+
+	app.ActorSystem().Call(m.Target, m.Session.AgentPath, KickFuncName, &cproto.PomeloKick{
+		Sid:    m.Session.Sid,
+		Reason: data,
+		Close:  true,
+	})
+}
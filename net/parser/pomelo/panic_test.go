@@ -0,0 +1,51 @@
+package pomelo
+
+import (
+	"testing"
+
+	ccode "github.com/cherry-game/cherry/code"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestHandlerPanic(t *testing.T) {
+	defer func() {
+		cmd.onDataRouteFunc = DefaultDataRoute
+		cmd.panicPolicy = PanicPolicyContinue
+	}()
+
+	cmd.onDataRouteFunc = func(_ *Agent, _ *pmessage.Route, _ *pmessage.Message) {
+		panic("boom")
+	}
+
+	agent := newTestAgent("handler-panic")
+	agent.SetState(AgentWorking)
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	route, _ := pmessage.DecodeRoute("game.room.join")
+	dispatchData(agent, route, &pmessage.Message{Type: pmessage.Request, ID: 7, Route: "game.room.join"})
+
+	// the agent survives and the request gets the error response
+	if agent.State() == AgentClosed {
+		t.Fatal("agent is closed")
+	}
+
+	pending := <-agent.chPending
+	rsp, ok := pending.payload.(*cproto.Response)
+	if !ok || pending.mid != 7 || rsp.Code != ccode.HandlerPanic || string(rsp.Data) != panicMessage {
+		t.Fatalf("pending = %s", pending.String())
+	}
+
+	// notify has no response
+	dispatchData(agent, route, &pmessage.Message{Type: pmessage.Notify, Route: "game.room.join"})
+	if len(agent.chPending) != 0 {
+		t.Fatal("notify is responded")
+	}
+
+	cmd.panicPolicy = PanicPolicyKick
+	dispatchData(agent, route, &pmessage.Message{Type: pmessage.Notify, Route: "game.room.join"})
+	if agent.State() != AgentClosed {
+		t.Fatal("agent is not kicked")
+	}
+}
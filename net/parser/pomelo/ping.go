@@ -0,0 +1,131 @@
+package pomelo
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+const (
+	pingTimeout = 5 * time.Second // the ping fails if the pong is not received in timeout
+	pingIDBytes = 4               // the body of the ping heartbeat packet, the client echoes it as the pong
+	rttWeight   = 8               // the weight of the old rtt, rtt = rtt + (sample - rtt) / rttWeight
+)
+
+type (
+	// pingState the in-flight ping and the rtt of the agent, see Agent.Ping
+	pingState struct {
+		sync.Mutex
+		id      uint32
+		round   *pingRound // the in-flight ping, nil if no ping is in flight
+		rtt     int64      // time.Duration, the ewma of the samples
+		sampled bool
+	}
+
+	pingRound struct {
+		sentAt time.Time
+		done   chan struct{} // closed when the pong is received
+		sample time.Duration // the rtt of this round, it's set before done is closed
+	}
+)
+
+func newPingState() *pingState {
+	return &pingState{}
+}
+
+// Ping send the heartbeat packet with the ping id and wait for the pong, returns the rtt of this round trip.
+// the client must echo the heartbeat packet with the same body. the concurrent pings share the in-flight ping.
+// returns cerr.SessionPingTimeout if the pong is not received in 5 seconds.
+func (a *Agent) Ping() (time.Duration, error) {
+	if a.IsClosed() {
+		return 0, cerr.SessionClosed
+	}
+
+	round, err := a.startPing()
+	if err != nil {
+		return 0, err
+	}
+
+	timer := time.NewTimer(pingTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-round.done:
+		return round.sample, nil
+	case <-a.chDie:
+		return 0, cerr.SessionClosed
+	case <-timer.C:
+		// the next ping is sent again
+		a.ping.Lock()
+		if a.ping.round == round {
+			a.ping.round = nil
+		}
+		a.ping.Unlock()
+		return 0, cerr.SessionPingTimeout
+	}
+}
+
+// RTT returns the ewma of the ping round trip times, zero if it has not been pinged
+func (a *Agent) RTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&a.ping.rtt))
+}
+
+// startPing returns the in-flight ping, the ping packet is sent if no ping is in flight
+func (a *Agent) startPing() (*pingRound, error) {
+	a.ping.Lock()
+	defer a.ping.Unlock()
+
+	if a.ping.round != nil {
+		return a.ping.round, nil
+	}
+
+	a.ping.id++
+
+	body := make([]byte, pingIDBytes)
+	binary.BigEndian.PutUint32(body, a.ping.id)
+
+	if err := a.SendPacket(pomeloPacket.Heartbeat, body); err != nil {
+		return nil, err
+	}
+
+	a.ping.round = &pingRound{
+		sentAt: time.Now(),
+		done:   make(chan struct{}),
+	}
+
+	return a.ping.round, nil
+}
+
+// pong resolve the in-flight ping by the echoed heartbeat packet, the stale pong is ignored
+func (a *Agent) pong(body []byte) {
+	if len(body) != pingIDBytes {
+		return
+	}
+
+	a.ping.Lock()
+	defer a.ping.Unlock()
+
+	round := a.ping.round
+	if round == nil || binary.BigEndian.Uint32(body) != a.ping.id {
+		return
+	}
+
+	sample := time.Since(round.sentAt)
+	rtt := time.Duration(atomic.LoadInt64(&a.ping.rtt))
+	if a.ping.sampled {
+		rtt += (sample - rtt) / rttWeight
+	} else {
+		rtt = sample
+		a.ping.sampled = true
+	}
+
+	atomic.StoreInt64(&a.ping.rtt, int64(rtt))
+
+	round.sample = sample
+	close(round.done)
+	a.ping.round = nil
+}
@@ -0,0 +1,77 @@
+package pomelo
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+func TestAgentPing(t *testing.T) {
+	agent := newTestAgent("agent-ping")
+
+	// the concurrent pings share the in-flight ping
+	var wg sync.WaitGroup
+	results := make([]time.Duration, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			rtt, err := agent.Ping()
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = rtt
+		}(i)
+	}
+
+	packets, _ := pomeloPacket.Decode(<-agent.chWrite)
+	if len(packets) != 1 || packets[0].Type() != pomeloPacket.Heartbeat || len(packets[0].Data()) != pingIDBytes {
+		t.Fatalf("ping packets = %v", packets)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	// the client echoes the ping
+	heartbeatCommand(agent, packets[0])
+	wg.Wait()
+
+	if len(agent.chWrite) != 0 {
+		t.Fatalf("ping is sent %d times again", len(agent.chWrite))
+	}
+
+	for _, rtt := range results {
+		if rtt < 10*time.Millisecond || rtt != results[0] {
+			t.Fatalf("results = %v", results)
+		}
+	}
+
+	if agent.RTT() != results[0] {
+		t.Fatalf("rtt = %v, results = %v", agent.RTT(), results)
+	}
+
+	// the stale pong is ignored, the rtt is the ewma of the samples
+	heartbeatCommand(agent, packets[0])
+
+	done := make(chan time.Duration)
+	go func() {
+		rtt, _ := agent.Ping()
+		done <- rtt
+	}()
+
+	packets, _ = pomeloPacket.Decode(<-agent.chWrite)
+	heartbeatCommand(agent, packets[0])
+
+	sample := <-done
+	if expect := results[0] + (sample-results[0])/rttWeight; agent.RTT() != expect {
+		t.Fatalf("rtt = %v, expect = %v", agent.RTT(), expect)
+	}
+
+	agent.Close()
+	if _, err := agent.Ping(); err != cerr.SessionClosed {
+		t.Fatalf("closed agent ping err = %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package pomelo
 
 import (
+	"context"
+
+	ccode "github.com/cherry-game/cherry/code"
 	cfacade "github.com/cherry-game/cherry/facade"
-	clog "github.com/cherry-game/cherry/logger"
+	cdiscovery "github.com/cherry-game/cherry/net/discovery"
 	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
 	cproto "github.com/cherry-game/cherry/net/proto"
 )
@@ -19,25 +22,28 @@ func DefaultDataRoute(agent *Agent, route *pmessage.Route, msg *pmessage.Message
 	}
 
 	if !session.IsBind() {
-		clog.Warnf("[sid = %s,uid = %d] Session is not bind with UID. failed to forward message.[route = %s]",
-			agent.SID(),
-			agent.UID(),
+		agent.Warnf("Session is not bind with UID. failed to forward message.[route = %s]",
 			msg.Route,
 		)
 		return
 	}
 
-	member, found := agent.Discovery().Random(route.NodeType())
-	if !found {
+	member, err := cdiscovery.Route(agent.Discovery(), route.NodeType(), cdiscovery.RouteHint{
+		UID:   session.Uid,
+		Route: msg.Route,
+	})
+	if err != nil {
+		agent.Warnf("Route node fail. [route = %s, err = %v]",
+			msg.Route,
+			err,
+		)
 		return
 	}
 
 	targetPath := cfacade.NewPath(member.GetNodeId(), route.HandleName())
-	err := ClusterLocalDataRoute(agent, session, route, msg, member.GetNodeId(), targetPath)
+	err = ClusterLocalDataRoute(agent, session, route, msg, member.GetNodeId(), targetPath)
 	if err != nil {
-		clog.Warnf("[sid = %s,uid = %d,route = %s] cluster local data error. err= %v",
-			agent.SID(),
-			agent.UID(),
+		agent.Warnf("Cluster local data error. [route = %s, err = %v]",
 			msg.Route,
 			err,
 		)
@@ -60,13 +66,24 @@ func ClusterLocalDataRoute(agent *Agent, session *cproto.Session, route *pmessag
 	clusterPacket.SourcePath = session.AgentPath
 	clusterPacket.TargetPath = targetPath
 	clusterPacket.FuncName = route.Method()
-	clusterPacket.Session = session   // agent session
-	clusterPacket.ArgBytes = msg.Data // packet -> message -> data
+	clusterPacket.Session = session.Clone() // copy of the agent session, it's marshaled without the data lock
+	clusterPacket.ArgBytes = msg.Data       // packet -> message -> data
+
+	header, end := agent.ActorSystem().Tracer().Inject(context.Background(), route.HandleName()+"."+route.Method(), session)
+	clusterPacket.Header = header
+
+	err := agent.Cluster().PublishLocal(nodeID, clusterPacket)
+	if err != nil {
+		end(ccode.ActorPublishRemoteError)
+	} else {
+		end(ccode.OK)
+	}
 
-	return agent.Cluster().PublishLocal(nodeID, clusterPacket)
+	return err
 }
 
 func BuildSession(agent *Agent, msg *pmessage.Message) *cproto.Session {
 	agent.session.Mid = uint32(msg.ID)
+	agent.session.Metadata = msg.Header
 	return agent.session
 }
@@ -0,0 +1,158 @@
+package pomelo
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	PriorityLow    = -1 // written when the normal messages are written, or it's waited for priorityAging
+	PriorityNormal = 0  // the priority of Push and Response
+	PriorityHigh   = 1  // written before the queued normal messages, eg. security alert
+	// the kick packets are written at once, they are always ahead of all messages. see Kick
+
+	priorityAging = time.Second // the low priority message is written even if the normal messages are queued
+)
+
+type (
+	// priorityQueue the bounded queue of the high and low priority messages, FIFO within the same priority.
+	// the messages are popped by the write goroutine, see drainPriority
+	priorityQueue struct {
+		sync.Mutex
+		items  priorityItems
+		seq    uint64
+		queued int32         // len(items), the write goroutine checks it without the lock
+		notify chan struct{} // signaled when a message is pushed
+	}
+
+	priorityItem struct {
+		pending  *pendingMessage
+		priority int
+		seq      uint64 // arrival order
+		at       int64  // unix milli of the push
+	}
+
+	priorityItems []*priorityItem
+)
+
+func newPriorityQueue() *priorityQueue {
+	return &priorityQueue{
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// PushPriority push the message with the priority, PriorityNormal is the same as Push.
+// returns cerr.SessionSendQueueFull if the queued high and low priority messages exceed the write backlog.
+func (a *Agent) PushPriority(priority int, route string, v interface{}) error {
+	if priority == PriorityNormal {
+		return a.Push(route, v)
+	}
+
+	return a.pushPriority(&pendingMessage{
+		typ:      pmessage.Push,
+		route:    route,
+		payload:  v,
+		priority: priority,
+	})
+}
+
+func (a *Agent) pushPriority(pending *pendingMessage) error {
+	if a.IsClosed() || a.isDraining() {
+		return cerr.SessionClosed
+	}
+
+	if !a.prio.push(pending, int(atomic.LoadInt32(&cmd.writeBacklog))) {
+		if a.PrintLevel(zapcore.DebugLevel) {
+			a.Debugf("Priority message dropped. [priority = %d, data = %s]", pending.priority, pending.String())
+		}
+		return cerr.SessionSendQueueFull
+	}
+
+	return nil
+}
+
+func (p *priorityQueue) push(pending *pendingMessage, backlog int) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.items) >= backlog {
+		return false
+	}
+
+	p.seq++
+	atomic.AddInt32(&p.queued, 1)
+	heap.Push(&p.items, &priorityItem{
+		pending:  pending,
+		priority: pending.priority,
+		seq:      p.seq,
+		at:       time.Now().UnixMilli(),
+	})
+
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+// pop returns the message of the highest priority, the low priority message is returned if idle is true or it's aged
+func (p *priorityQueue) pop(idle bool) (*pendingMessage, bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.items) < 1 {
+		return nil, false
+	}
+
+	top := p.items[0]
+	if top.priority < PriorityNormal && !idle && time.Now().UnixMilli()-top.at < priorityAging.Milliseconds() {
+		return nil, false
+	}
+
+	atomic.AddInt32(&p.queued, -1)
+	return heap.Pop(&p.items).(*priorityItem).pending, true
+}
+
+// drainPriority process the high priority messages, and the low priority messages if the send queues are empty.
+// all messages are processed if force is true, must be called in the write goroutine
+func (a *Agent) drainPriority(force bool) {
+	for atomic.LoadInt32(&a.prio.queued) > 0 {
+		idle := force || (len(a.chPending) < 1 && len(a.chWrite) < 1)
+
+		pending, found := a.prio.pop(idle)
+		if !found {
+			return
+		}
+
+		a.processPending(pending)
+	}
+}
+
+func (p priorityItems) Len() int { return len(p) }
+
+func (p priorityItems) Less(i, j int) bool {
+	if p[i].priority == p[j].priority {
+		return p[i].seq < p[j].seq
+	}
+	return p[i].priority > p[j].priority
+}
+
+func (p priorityItems) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func (p *priorityItems) Push(x interface{}) { *p = append(*p, x.(*priorityItem)) }
+
+func (p *priorityItems) Pop() interface{} {
+	old := *p
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*p = old[:n-1]
+	return item
+}
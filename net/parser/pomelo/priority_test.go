@@ -0,0 +1,120 @@
+package pomelo
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+func TestPushPriority(t *testing.T) {
+	conn, peer := net.Pipe()
+	agent := newTestAgent("push-priority")
+	agent.conn = conn
+	agent.SetState(AgentWorking)
+
+	pushes := []struct {
+		priority int
+		route    string
+	}{
+		{PriorityNormal, "normal.0"},
+		{PriorityLow, "low.0"},
+		{PriorityNormal, "normal.1"},
+		{PriorityHigh, "high.0"},
+		{PriorityLow, "low.1"},
+		{PriorityHigh, "high.1"},
+		{PriorityNormal, "normal.2"},
+	}
+
+	// queued before the write goroutine is started
+	for _, push := range pushes {
+		if err := agent.PushPriority(push.priority, push.route, push.route); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	routes := make(chan string, len(pushes))
+	go func() {
+		for {
+			packets, _, err := ppacket.Read(peer)
+			if err != nil {
+				return
+			}
+
+			for _, pkg := range packets {
+				if m, err := pmessage.Decode(pkg.Data()); err == nil {
+					routes <- m.Route
+				}
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		agent.writeChan()
+		close(done)
+	}()
+
+	defer func() {
+		agent.Close()
+		peer.Close()
+		<-done
+	}()
+
+	expected := []string{"high.0", "high.1", "normal.0", "normal.1", "normal.2", "low.0", "low.1"}
+	for i, route := range expected {
+		select {
+		case got := <-routes:
+			if got != route {
+				t.Fatalf("routes[%d] = %s, expected = %v", i, got, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("routes[%d] is not written", i)
+		}
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	queue := newPriorityQueue()
+
+	for _, pending := range []*pendingMessage{
+		{route: "low.0", priority: PriorityLow},
+		{route: "high.0", priority: PriorityHigh},
+		{route: "low.1", priority: PriorityLow},
+	} {
+		if !queue.push(pending, 3) {
+			t.Fatalf("push %s fail", pending.route)
+		}
+	}
+
+	if queue.push(&pendingMessage{route: "high.1", priority: PriorityHigh}, 3) {
+		t.Fatal("push the full queue")
+	}
+
+	if pending, found := queue.pop(false); !found || pending.route != "high.0" {
+		t.Fatalf("pop = %v", pending)
+	}
+
+	// the low priority message waits for the normal messages until it's aged
+	if pending, found := queue.pop(false); found {
+		t.Fatalf("pop the low priority = %v", pending)
+	}
+
+	queue.items[0].at -= priorityAging.Milliseconds()
+	if pending, found := queue.pop(false); !found || pending.route != "low.0" {
+		t.Fatalf("pop the aged = %v", pending)
+	}
+
+	if pending, found := queue.pop(true); !found || pending.route != "low.1" {
+		t.Fatalf("pop idle = %v", pending)
+	}
+
+	agent := newTestAgent("push-priority-closed")
+	agent.Close()
+	if err := agent.PushPriority(PriorityHigh, "high.0", nil); err != cerr.SessionClosed {
+		t.Fatalf("push the closed agent err = %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package pomelo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+)
+
+type (
+	// PushError the failed sids of a batch push
+	PushError map[cfacade.SID]error
+
+	// sharedPush the packet bytes shared by the agents, it's encoded once for the agents with
+	// and without the compression. see Agent.CompressionEnabled.
+	// it's only used if the packet is sharable, the numbered pushes are encoded by each agent.
+	sharedPush struct {
+		route string
+		v     interface{}
+		pkgs  [2][]byte
+	}
+)
+
+func (p PushError) Error() string {
+	sids := make([]string, 0, len(p))
+	for sid := range p {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+
+	list := make([]string, 0, len(sids))
+	for _, sid := range sids {
+		list = append(list, fmt.Sprintf("[sid = %s, err = %v]", sid, p[sid]))
+	}
+
+	return fmt.Sprintf("push fail. %s", strings.Join(list, ", "))
+}
+
+// MultiPush push the message to the agents of sids.
+// the payload is marshaled and encoded only once, the packet bytes are shared by all agents,
+// so a fan-out to n agents costs 1 marshal + n channel sends instead of n marshal + n encode.
+// failed sids(not found, closed, buffer exceed) do not abort the push and are returned as PushError.
+func MultiPush(sids []cfacade.SID, route string, v interface{}) error {
+	errs := PushError{}
+	list := make([]*Agent, 0, len(sids))
+
+	for _, sid := range sids {
+		if agent, found := GetAgent(sid); found {
+			list = append(list, agent)
+		} else {
+			errs[sid] = cerr.SessionMemberNotFound
+		}
+	}
+
+	return multiPush(list, route, v, errs)
+}
+
+func multiPush(list []*Agent, route string, v interface{}, errs PushError) error {
+	if !sharable() {
+		for _, agent := range list {
+			if err := agent.Push(route, v); err != nil {
+				errs[agent.SID()] = err
+			}
+		}
+	} else {
+		shared := newSharedPush(route, v)
+		for _, agent := range list {
+			pkg, err := shared.packet(agent)
+			if err != nil {
+				return err
+			}
+
+			if err := agent.sendBytes(pkg); err != nil {
+				errs[agent.SID()] = err
+				continue
+			}
+			atomic.AddInt64(&counters.messagesOut, 1)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// sharable returns true if the packet bytes can be shared by the agents. the outbound filters run per agent,
+// and the numbered pushes carry the sequence of each agent and are kept in its replay buffer by the write goroutine.
+func sharable() bool {
+	return len(cmd.outboundFilters) < 1 && cmd.deliveryMode == DeliveryNone
+}
+
+func newSharedPush(route string, v interface{}) *sharedPush {
+	return &sharedPush{route: route, v: v}
+}
+
+// packet returns the packet bytes for the agent, it's encoded by the first agent of the compression
+func (p *sharedPush) packet(agent *Agent) ([]byte, error) {
+	i := 0
+	if agent.CompressionEnabled() {
+		i = 1
+	}
+
+	if p.pkgs[i] == nil {
+		pkg, err := agent.encodePending(&pendingMessage{
+			typ:     pomeloMessage.Push,
+			route:   p.route,
+			payload: p.v,
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.pkgs[i] = pkg
+	}
+
+	return p.pkgs[i], nil
+}
@@ -0,0 +1,273 @@
+package pomelo
+
+import (
+	"strconv"
+	"testing"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+type testPush struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func newPushAgents(prefix string, num int) ([]*Agent, []cfacade.SID) {
+	agents := make([]*Agent, 0, num)
+	sids := make([]cfacade.SID, 0, num)
+
+	for i := 0; i < num; i++ {
+		agent := newTestAgent(prefix + strconv.Itoa(i))
+		BindSID(agent)
+
+		agents = append(agents, agent)
+		sids = append(sids, agent.SID())
+	}
+
+	return agents, sids
+}
+
+// discardPending encode the queued messages like the write goroutine and discard the bytes
+func discardPending(agents []*Agent) {
+	for _, agent := range agents {
+		for len(agent.chPending) > 0 {
+			pending := <-agent.chPending
+			_, _ = agent.encodePending(pending)
+		}
+
+		for len(agent.chWrite) > 0 {
+			bytes := <-agent.chWrite
+			agent.addPendingBytes(-len(bytes))
+		}
+	}
+}
+
+func TestMultiPushError(t *testing.T) {
+	agents, sids := newPushAgents("multi-push-", 3)
+	defer func() {
+		for _, agent := range agents {
+			Unbind(agent.SID())
+		}
+	}()
+
+	agents[1].Close()
+	sids = append(sids, "multi-push-not-found")
+
+	err := MultiPush(sids, "test.push", &testPush{ID: 1, Name: "hello"})
+
+	errs, ok := err.(PushError)
+	if !ok {
+		t.Fatalf("multi push err = %v", err)
+	}
+
+	if len(errs) != 2 || errs[agents[1].SID()] != cerr.SessionClosed || errs["multi-push-not-found"] != cerr.SessionMemberNotFound {
+		t.Fatalf("multi push errs = %v", errs)
+	}
+
+	// the others are pushed
+	for _, i := range []int{0, 2} {
+		if len(agents[i].chWrite) != 1 {
+			t.Fatalf("agent %s queued = %d", agents[i].SID(), len(agents[i].chWrite))
+		}
+	}
+}
+
+func BenchmarkMultiPush(b *testing.B) {
+	agents, sids := newPushAgents("bench-multi-push-", 100)
+	defer func() {
+		for _, agent := range agents {
+			Unbind(agent.SID())
+		}
+	}()
+
+	v := &testPush{ID: 1, Name: "hello"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := MultiPush(sids, "test.push", v); err != nil {
+			b.Fatal(err)
+		}
+		discardPending(agents)
+	}
+}
+
+func BenchmarkPushLoop(b *testing.B) {
+	agents, _ := newPushAgents("bench-push-loop-", 100)
+	defer func() {
+		for _, agent := range agents {
+			Unbind(agent.SID())
+		}
+	}()
+
+	v := &testPush{ID: 1, Name: "hello"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, agent := range agents {
+			if err := agent.Push("test.push", v); err != nil {
+				b.Fatal(err)
+			}
+		}
+		discardPending(agents)
+	}
+}
+
+// testPushActor the backend actor, the CallWait to "gate-1.user" is handled by the local agent actor
+type testPushActor struct {
+	cfacade.IActor
+}
+
+func (testPushActor) App() cfacade.IApplication {
+	return testApp{}
+}
+
+func (testPushActor) CallWait(targetPath, funcName string, arg interface{}, reply interface{}) int32 {
+	if targetPath != "gate-1.user" {
+		return ccode.DiscoveryNotFoundNode
+	}
+
+	switch funcName {
+	case PushUIDFuncName:
+		return (&actor{}).pushUID(arg.(*cproto.PomeloPush))
+	case MultiPushFuncName:
+		// the reply is marshaled by the frontend
+		result, code := (&actor{}).multiPush(arg.(*cproto.PomeloBroadcastPush))
+		bytes, _ := proto.Marshal(result)
+		_ = proto.Unmarshal(bytes, reply.(*cproto.PomeloPushResult))
+		return code
+	}
+
+	return ccode.ActorFuncNameError
+}
+
+func TestPushToClient(t *testing.T) {
+	agent := newTestAgent("push-to-client")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	if err := agent.Bind(5001); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := testPushActor{}
+	if err := PushToClient(backend, "gate-1.user", 5001, "test.push", &testPush{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := <-agent.chPending
+	if pending.route != "test.push" {
+		t.Fatalf("pending = %s", pending.String())
+	}
+
+	err := PushToClient(backend, "gate-1.user", 5002, "test.push", &testPush{ID: 2})
+	if code, _ := cerr.Code(err); code != ccode.SessionUIDNotBind {
+		t.Fatalf("not connected uid, err = %v", err)
+	}
+
+	err = PushToClient(backend, "gate-2.user", 5001, "test.push", &testPush{ID: 3})
+	if code, _ := cerr.Code(err); code != ccode.DiscoveryNotFoundNode {
+		t.Fatalf("unreachable frontend, err = %v", err)
+	}
+
+	agent.Close()
+	err = PushToClient(backend, "gate-1.user", 5001, "test.push", &testPush{ID: 4})
+	if code, _ := cerr.Code(err); code != ccode.SessionUIDNotBind && code != ccode.SessionPushFail {
+		t.Fatalf("closed agent, err = %v", err)
+	}
+}
+
+func TestMultiPushToClients(t *testing.T) {
+	var agents []*Agent
+	for i := 0; i < 3; i++ {
+		agent := newTestAgent("multi-push-" + strconv.Itoa(i))
+		BindSID(agent)
+		defer Unbind(agent.SID())
+
+		if err := agent.Bind(cfacade.UID(5101 + i)); err != nil {
+			t.Fatal(err)
+		}
+		agents = append(agents, agent)
+	}
+
+	targets := []ClientTarget{
+		{AgentPath: "gate-1.user", UID: 5101},
+		{AgentPath: "gate-1.user", UID: 5102},
+		{AgentPath: "gate-1.user", UID: 5103},
+		{AgentPath: "gate-1.user", UID: 5109}, // not connected
+		{AgentPath: "gate-2.user", UID: 5110}, // unreachable frontend
+	}
+
+	errs := MultiPushToClients(testPushActor{}, targets, "test.push", &testPush{ID: 1})
+	if len(errs) != 2 {
+		t.Fatalf("errs = %v", errs)
+	}
+
+	if code, _ := cerr.Code(errs[5109]); code != ccode.SessionUIDNotBind {
+		t.Fatalf("not connected uid, err = %v", errs[5109])
+	}
+
+	if code, _ := cerr.Code(errs[5110]); code != ccode.DiscoveryNotFoundNode {
+		t.Fatalf("unreachable frontend, err = %v", errs[5110])
+	}
+
+	for _, agent := range agents {
+		pending := <-agent.chPending
+		if pending.route != "test.push" {
+			t.Fatalf("pending = %s", pending.String())
+		}
+	}
+
+	if errs = MultiPushToClients(testPushActor{}, targets[:1], "", nil); errs[5101] == nil {
+		t.Fatal("empty route is pushed")
+	}
+}
+
+func TestMultiPushDelivery(t *testing.T) {
+	defer func() {
+		cmd.deliveryMode = DeliveryNone
+	}()
+	(&actor{}).SetDeliveryMode(DeliveryAtLeastOnce)
+
+	agents, sids := newPushAgents("multi-push-delivery-", 2)
+	defer func() {
+		for _, agent := range agents {
+			Unbind(agent.SID())
+		}
+	}()
+
+	for i := 1; i <= 2; i++ {
+		if err := MultiPush(sids, "test.push", &testPush{ID: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the pushes are numbered and kept by each agent
+	for _, agent := range agents {
+		if len(agent.chWrite) > 0 || len(agent.chPending) != 2 {
+			t.Fatalf("agent %s written = %d, pending = %d", agent.SID(), len(agent.chWrite), len(agent.chPending))
+		}
+
+		for i := 1; i <= 2; i++ {
+			pkg, err := agent.encodePending(<-agent.chPending)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if seq := pushSeq(t, pkg); seq != strconv.Itoa(i) {
+				t.Fatalf("agent %s seq = %s", agent.SID(), seq)
+			}
+		}
+
+		if kept := len(agent.delivery.unackedPackets()); kept != 2 {
+			t.Fatalf("agent %s kept = %d", agent.SID(), kept)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package pomelo
+
+import (
+	"sync/atomic"
+
+	cerr "github.com/cherry-game/cherry/error"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	OverflowReject     OverflowPolicy = 0 // return cerr.SessionSendQueueFull
+	OverflowDropOldest OverflowPolicy = 1 // drop the oldest queued message
+	OverflowDropNewest OverflowPolicy = 2 // drop the new message
+	OverflowKick       OverflowPolicy = 3 // kick the agent and return cerr.SessionSendQueueFull
+)
+
+type (
+	// OverflowPolicy the policy of the send queue is full
+	OverflowPolicy int32
+)
+
+// enqueue put v into the bounded queue without blocking, the overflow is handled by cmd.overflowPolicy
+func enqueue[T any](a *Agent, ch chan T, v T, onDrop func(T)) error {
+	select {
+	case ch <- v:
+		return nil
+	default:
+	}
+
+	switch cmd.overflowPolicy {
+	case OverflowDropOldest:
+		{
+			select {
+			case old := <-ch:
+				onDrop(old)
+			default:
+			}
+
+			select {
+			case ch <- v:
+				return nil
+			default:
+				onDrop(v)
+				return nil
+			}
+		}
+	case OverflowDropNewest:
+		{
+			onDrop(v)
+			return nil
+		}
+	case OverflowKick:
+		{
+			a.KickWith(KickReason{
+				Code:    KickCodeSendQueueFull,
+				Message: KickSendQueueFull,
+			}, true)
+		}
+	}
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Send queue is full. [size = %d, policy = %d]", cap(ch), cmd.overflowPolicy)
+	}
+
+	return cerr.SessionSendQueueFull
+}
+
+// PendingSendBytes returns the encoded bytes waiting for write. it's useful to find the slow consumers.
+func (a *Agent) PendingSendBytes() int {
+	return int(atomic.LoadInt64(&a.pendingBytes))
+}
+
+func (a *Agent) addPendingBytes(n int) {
+	atomic.AddInt64(&a.pendingBytes, int64(n))
+}
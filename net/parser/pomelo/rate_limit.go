@@ -0,0 +1,97 @@
+package pomelo
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// rateLimiter token bucket of the inbound messages, the rate is read from cmd
+	rateLimiter struct {
+		sync.Mutex
+		tokens     float64 // available tokens
+		lastAt     int64   // last refill unix nano
+		dropped    int64   // dropped message count
+		violations int     // continuous dropped count
+	}
+)
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{}
+}
+
+// allow returns false if the message should be dropped, and the continuous dropped count
+func (p *rateLimiter) allow() (bool, int) {
+	rate, burst := atomic.LoadInt32(&cmd.messageRate), atomic.LoadInt32(&cmd.messageBurst)
+	if rate <= 0 {
+		return true, 0
+	}
+
+	if burst < 1 {
+		burst = rate
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now().UnixNano()
+	if p.lastAt == 0 {
+		p.tokens = float64(burst) // first message, the bucket is full
+		p.lastAt = now
+	}
+
+	p.tokens += float64(now-p.lastAt) * float64(rate) / float64(time.Second)
+	if p.tokens > float64(burst) {
+		p.tokens = float64(burst)
+	}
+	p.lastAt = now
+
+	if p.tokens < 1 {
+		atomic.AddInt64(&p.dropped, 1)
+		p.violations++
+		return false, p.violations
+	}
+
+	p.tokens--
+	p.violations = 0
+
+	return true, 0
+}
+
+// AllowMessage returns false if the inbound message exceeds the rate, the message should be dropped.
+// the agent is kicked when the continuous dropped count exceeds the violation threshold.
+func (a *Agent) AllowMessage() bool {
+	if a.limiter == nil {
+		return true
+	}
+
+	allowed, violations := a.limiter.allow()
+	if allowed {
+		return true
+	}
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Message rate limited. [violations = %d]", violations)
+	}
+
+	if maxViolation := int(atomic.LoadInt32(&cmd.rateViolation)); maxViolation > 0 && violations > maxViolation {
+		a.KickWith(KickReason{
+			Code:    KickCodeRateLimited,
+			Message: KickRateLimited,
+		}, true)
+	}
+
+	return false
+}
+
+// DroppedMessages returns the count of the inbound messages dropped by the rate limiter
+func (a *Agent) DroppedMessages() int64 {
+	if a.limiter == nil {
+		return 0
+	}
+
+	return atomic.LoadInt64(&a.limiter.dropped)
+}
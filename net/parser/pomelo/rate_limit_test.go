@@ -0,0 +1,69 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	defer func() {
+		cmd.messageRate, cmd.messageBurst = 0, 0
+	}()
+	cmd.messageRate, cmd.messageBurst = 10, 5
+
+	limiter := newRateLimiter()
+
+	// the bucket is full at first
+	for i := 0; i < 5; i++ {
+		if ok, _ := limiter.allow(); !ok {
+			t.Fatalf("message %d is dropped", i)
+		}
+	}
+
+	if ok, violations := limiter.allow(); ok || violations != 1 {
+		t.Fatalf("allow = %v, violations = %d", ok, violations)
+	}
+
+	if ok, violations := limiter.allow(); ok || violations != 2 {
+		t.Fatalf("allow = %v, violations = %d", ok, violations)
+	}
+
+	// refill 10 tokens per second
+	time.Sleep(150 * time.Millisecond)
+
+	if ok, violations := limiter.allow(); !ok || violations != 0 {
+		t.Fatalf("allow after refill = %v, violations = %d", ok, violations)
+	}
+
+	if limiter.dropped != 2 {
+		t.Fatalf("dropped = %d", limiter.dropped)
+	}
+}
+
+func TestRateViolationKick(t *testing.T) {
+	defer func() {
+		cmd.messageRate, cmd.messageBurst, cmd.rateViolation = 0, 0, 0
+	}()
+	cmd.messageRate, cmd.messageBurst, cmd.rateViolation = 1, 1, 2
+
+	agent := newTestAgent("rate-violation")
+
+	if !agent.AllowMessage() {
+		t.Fatal("first message is dropped")
+	}
+
+	// violations 1, 2 are dropped, 3 exceeds the threshold and kicks the agent
+	for i := 0; i < 2; i++ {
+		if agent.AllowMessage() || agent.State() == AgentClosed {
+			t.Fatalf("violation %d allow or closed", i+1)
+		}
+	}
+
+	if agent.AllowMessage() || agent.State() != AgentClosed || !agent.isKicked() {
+		t.Fatalf("agent is not kicked. state = %d", agent.State())
+	}
+
+	if n := agent.DroppedMessages(); n != 3 {
+		t.Fatalf("dropped messages = %d", n)
+	}
+}
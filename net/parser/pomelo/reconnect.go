@@ -0,0 +1,231 @@
+package pomelo
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	reconnectTokenSize = 16 // random bytes of the reconnect token
+)
+
+type (
+	// retainState the state of a closed agent which is waiting for reconnect
+	retainState struct {
+		token   string            // reconnect token
+		agent   *Agent            // closed agent
+		uid     cfacade.UID       // bound uid
+		data    map[string]string // session data
+		expires map[string]int64  // session data expire times
+		deliver *deliveryState    // sequence state, see actor.SetDeliveryMode
+		timer   *time.Timer       // grace timer
+	}
+)
+
+var (
+	retainLock = &sync.Mutex{}
+	retainMap  = make(map[string]*retainState) // digest of the reconnect token -> retain state
+)
+
+// newReconnectToken returns the random token, it's the only credential to take over the retained session.
+// returns empty if the random bytes can not be read, the agent is not retained.
+func newReconnectToken() string {
+	b := make([]byte, reconnectTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// retainKey the retained states are keyed by the digest, so the map lookup does not compare the token itself
+func retainKey(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return string(digest[:])
+}
+
+// resumable returns true if the state of the closed agent can be retained for reconnect. the close causes:
+//
+//	read error, heartbeat timeout          resumable, the connection is lost by the client
+//	Kick(closed), Close, Shutdown          not resumable, the agent is closed by server
+//	idle timeout, handshake timeout        not resumable, kicked or closed by server
+//	missed heartbeats(SetHeartbeat)        not resumable, kicked by server
+//	Terminate, eg. ban                     not resumable, even if the connection is lost before it's closed
+func (a *Agent) resumable() bool {
+	return a.isLost() && !a.isKicked() && atomic.LoadInt32(&a.terminated) == 0
+}
+
+// retain the agent state for reconnect. returns false if the agent can not be reconnected. see resumable
+func retain(agent *Agent) bool {
+	grace := loadDuration(&cmd.reconnectGrace)
+	if grace <= 0 || !agent.IsBind() {
+		return false
+	}
+
+	token := agent.ReconnectToken()
+	if token == "" {
+		return false
+	}
+
+	state := &retainState{
+		token:   token,
+		agent:   agent,
+		uid:     agent.UID(),
+		data:    agent.session.CloneData(),
+		expires: agent.session.CloneExpires(),
+		deliver: agent.delivery,
+	}
+
+	retainLock.Lock()
+	defer retainLock.Unlock()
+
+	key := retainKey(token)
+	state.timer = time.AfterFunc(grace, func() {
+		expireRetain(key)
+	})
+	retainMap[key] = state
+
+	if agent.PrintLevel(zapcore.DebugLevel) {
+		agent.Debugf("Agent retained for reconnect. [grace = %v]",
+			grace,
+		)
+	}
+
+	return true
+}
+
+// takeRetain remove and returns the retained state. the token can only be taken once, it's compared in constant time.
+func takeRetain(token string) (*retainState, bool) {
+	retainLock.Lock()
+	defer retainLock.Unlock()
+
+	key := retainKey(token)
+	state, found := retainMap[key]
+	if !found || subtle.ConstantTimeCompare([]byte(state.token), []byte(token)) != 1 {
+		return nil, false
+	}
+
+	delete(retainMap, key)
+	state.timer.Stop()
+
+	return state, true
+}
+
+func expireRetain(key string) {
+	retainLock.Lock()
+	state, found := retainMap[key]
+	delete(retainMap, key)
+	retainLock.Unlock()
+
+	if found {
+		state.release()
+	}
+}
+
+// release fire the onClose listeners of the state not reconnected and clear the replay buffer
+func (p *retainState) release() {
+	p.agent.fireOnClose()
+	if p.deliver != nil {
+		p.deliver.clear()
+	}
+}
+
+// DiscardReconnect remove the retained states of the uid and fire the onClose listeners of them,
+// the uid can not reconnect into the old state. returns false if the uid is not retained. see Terminate
+func DiscardReconnect(uid cfacade.UID) bool {
+	if !cfacade.IsValidUID(uid) {
+		return false
+	}
+
+	retainLock.Lock()
+	var states []*retainState
+	for key, state := range retainMap {
+		if state.uid != uid {
+			continue
+		}
+		state.timer.Stop()
+		delete(retainMap, key)
+		states = append(states, state)
+	}
+	retainLock.Unlock()
+
+	for _, state := range states {
+		state.release()
+	}
+
+	return len(states) > 0
+}
+
+// Terminate kick and close the agent without retaining the state for reconnect, eg. ban.
+// the retained states of the uid are discarded, the reason is sent as Kick. see resumable
+func (a *Agent) Terminate(reason interface{}) error {
+	atomic.StoreInt32(&a.terminated, 1)
+	a.setCloseCause(CauseTerminate, nil)
+	DiscardReconnect(a.UID())
+
+	return a.Kick(reason, true)
+}
+
+// expireAllRetain fire the onClose listeners of all retained agents. see Shutdown()
+func expireAllRetain() {
+	retainLock.Lock()
+	var keys []string
+	for key, state := range retainMap {
+		state.timer.Stop()
+		keys = append(keys, key)
+	}
+	retainLock.Unlock()
+
+	for _, key := range keys {
+		expireRetain(key)
+	}
+}
+
+// Reconnect adopt the state retained by token onto the current agent.
+// the delivery sequence is resumed and the unacked pushes are resent, so it should be called before the pushes.
+func (a *Agent) Reconnect(token string) error {
+	return a.ReconnectFrom(token, 0)
+}
+
+// ReconnectFrom is Reconnect with the last acked sequence sent by the client on reconnect,
+// only the kept pushes after lastAck are replayed. zero replays all kept pushes. see actor.SetReplayBuffer
+func (a *Agent) ReconnectFrom(token string, lastAck uint64) error {
+	state, found := takeRetain(token)
+	if !found {
+		return cerr.SessionReconnectInvalid
+	}
+
+	// the retained data is restored before the bind, so the onBind listeners and the session store see it
+	data, expires := a.session.CloneData(), a.session.CloneExpires()
+	a.session.Restore(state.data)
+	a.session.RestoreExpires(state.expires)
+
+	if err := a.Bind(state.uid); err != nil {
+		a.session.Restore(data)
+		a.session.RestoreExpires(expires)
+		state.release()
+		return err
+	}
+
+	if lastAck > 0 && state.deliver != nil {
+		state.deliver.ack(lastAck)
+	}
+	a.resumeDelivery(state.deliver)
+	fireEvent(EventReconnect, a)
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Agent reconnect ok. [oldSid = %s]",
+			state.agent.SID(),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package pomelo
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+// newLostAgent run a bound agent and close the conn as the client lost
+func newLostAgent(t *testing.T, sid string, uid int64) (*Agent, string, chan struct{}) {
+	agent := newTestAgent(sid)
+	BindSID(agent)
+
+	if err := agent.Bind(uid); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	agent.AddOnClose(func(*Agent) {
+		close(closed)
+	})
+
+	token := agent.ReconnectToken()
+	agent.Run()
+	_ = agent.conn.Close()
+
+	return agent, token, closed
+}
+
+func waitRetained(t *testing.T, token string) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		retainLock.Lock()
+		_, found := retainMap[retainKey(token)]
+		retainLock.Unlock()
+
+		if found {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatal("agent is not retained")
+}
+
+func TestReconnectTokenRandom(t *testing.T) {
+	tokens := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		token := newReconnectToken()
+		if len(token) != reconnectTokenSize*2 || tokens[token] {
+			t.Fatalf("token = %s", token)
+		}
+		tokens[token] = true
+	}
+}
+
+func TestReconnectTokenOnce(t *testing.T) {
+	defer func() {
+		cmd.reconnectGrace = 0
+	}()
+	cmd.reconnectGrace = time.Second
+
+	_, token, _ := newLostAgent(t, "reconnect-1", 6001)
+	waitRetained(t, token)
+
+	agent2 := newTestAgent("reconnect-2")
+	agent3 := newTestAgent("reconnect-3")
+	BindSID(agent2)
+	BindSID(agent3)
+	defer Unbind(agent2.SID())
+	defer Unbind(agent3.SID())
+
+	if err := agent2.Reconnect(token); err != nil {
+		t.Fatal(err)
+	}
+
+	if agent2.UID() != 6001 {
+		t.Fatalf("reconnect uid = %d", agent2.UID())
+	}
+
+	if err := agent3.Reconnect(token); err != cerr.SessionReconnectInvalid {
+		t.Fatalf("reconnect twice err = %v", err)
+	}
+}
+
+func TestReconnectBindData(t *testing.T) {
+	defer func() {
+		cmd.reconnectGrace = 0
+		cmd.onBindFuncs = nil
+	}()
+	cmd.reconnectGrace = time.Second
+
+	agent := newTestAgent("reconnect-data-lost")
+	BindSID(agent)
+	if err := agent.Bind(6011); err != nil {
+		t.Fatal(err)
+	}
+	agent.session.SetWithTTL("room", "r1", time.Minute)
+
+	token := agent.ReconnectToken()
+	agent.Run()
+	_ = agent.conn.Close()
+	waitRetained(t, token)
+
+	// the listeners see the retained data on bind
+	var room string
+	var expired bool
+	(&actor{}).AddOnBind(func(a *Agent) bool {
+		room, _ = a.Session().Get("room")
+		_, ttl := a.Session().ExpireAt("room")
+		expired = !ttl
+		return true
+	})
+
+	reconnected := newTestAgent("reconnect-data")
+	BindSID(reconnected)
+	defer Unbind(reconnected.SID())
+
+	if err := reconnected.Reconnect(token); err != nil {
+		t.Fatal(err)
+	}
+
+	if room != "r1" || expired {
+		t.Fatalf("room on bind = %s, expired = %v", room, expired)
+	}
+}
+
+func TestReconnectGraceExpire(t *testing.T) {
+	defer func() {
+		cmd.reconnectGrace = 0
+	}()
+	cmd.reconnectGrace = 50 * time.Millisecond
+
+	_, token, closed := newLostAgent(t, "reconnect-4", 6002)
+	waitRetained(t, token)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("onClose is not fired after the grace")
+	}
+
+	agent := newTestAgent("reconnect-5")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	if err := agent.Reconnect(token); err != cerr.SessionReconnectInvalid {
+		t.Fatalf("reconnect after grace err = %v", err)
+	}
+}
+
+func TestCloseNotRetain(t *testing.T) {
+	defer func() {
+		cmd.reconnectGrace = 0
+	}()
+	cmd.reconnectGrace = time.Second
+
+	agent := newTestAgent("reconnect-6")
+	BindSID(agent)
+	if err := agent.Bind(6003); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	agent.AddOnClose(func(*Agent) {
+		close(closed)
+	})
+
+	token := agent.ReconnectToken()
+	agent.Run()
+	agent.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("onClose is not fired when the agent is closed by server")
+	}
+
+	if _, found := takeRetain(token); found {
+		t.Fatal("agent closed by server is retained")
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	defer func() {
+		cmd.reconnectGrace = 0
+	}()
+	cmd.reconnectGrace = time.Second
+
+	agent := newTestAgent("terminate-1")
+	BindSID(agent)
+	if err := agent.Bind(6004); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	agent.AddOnClose(func(*Agent) {
+		close(closed)
+	})
+
+	token := agent.ReconnectToken()
+	agent.Run()
+
+	// the connection is lost before it's terminated
+	atomic.StoreInt32(&agent.lost, 1)
+	if err := agent.Terminate("banned"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("onClose is not fired when the agent is terminated")
+	}
+
+	if _, found := takeRetain(token); found {
+		t.Fatal("terminated agent is retained")
+	}
+}
+
+func TestDiscardReconnect(t *testing.T) {
+	defer func() {
+		cmd.reconnectGrace = 0
+	}()
+	cmd.reconnectGrace = time.Second
+
+	_, token, closed := newLostAgent(t, "terminate-2", 6005)
+	waitRetained(t, token)
+
+	if DiscardReconnect(6006) {
+		t.Fatal("discard the uid not retained")
+	}
+
+	if !DiscardReconnect(6005) {
+		t.Fatal("retained state is not discarded")
+	}
+
+	select {
+	case <-closed:
+	default:
+		t.Fatal("onClose is not fired when the state is discarded")
+	}
+
+	agent := newTestAgent("terminate-3")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	if err := agent.Reconnect(token); err != cerr.SessionReconnectInvalid {
+		t.Fatalf("reconnect after discard err = %v", err)
+	}
+}
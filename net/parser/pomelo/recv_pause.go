@@ -0,0 +1,119 @@
+package pomelo
+
+import (
+	"sync"
+	"sync/atomic"
+
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// recvPause the data packets parked while the inbound messages of the agent are paused
+	recvPause struct {
+		sync.Mutex
+		paused   bool
+		resuming bool // the parked packets are processed by ResumeRecv, the new packets are parked in order
+		parked   []*pomeloPacket.Packet
+	}
+)
+
+// SetRecvPause the size of the parked data packets of the paused agent(default is 64), and the policy when it's full.
+// OverflowDropOldest drops the oldest parked packet, OverflowKick kicks the agent with KickRecvQueueFull,
+// the others drop the new packet. see Agent.PauseRecv
+func (*actor) SetRecvPause(backlog int, policy OverflowPolicy) {
+	if backlog > 0 {
+		atomic.StoreInt32(&cmd.pauseBacklog, int32(backlog))
+	}
+	atomic.StoreInt32((*int32)(&cmd.pausePolicy), int32(policy))
+}
+
+// PauseRecv stop processing the inbound data packets without closing the agent, eg. a transaction of the player is
+// applying. the data packets are parked until ResumeRecv, the heartbeats are still processed. see actor.SetRecvPause
+func (a *Agent) PauseRecv() {
+	a.recvPause.Lock()
+	defer a.recvPause.Unlock()
+
+	a.recvPause.paused = true
+}
+
+// IsRecvPaused returns true if PauseRecv is called and ResumeRecv is not
+func (a *Agent) IsRecvPaused() bool {
+	a.recvPause.Lock()
+	defer a.recvPause.Unlock()
+
+	return a.recvPause.paused
+}
+
+// ResumeRecv process the parked data packets in order on the calling goroutine, the packets received meanwhile
+// are processed after them. it returns when all packets are processed or PauseRecv is called again.
+func (a *Agent) ResumeRecv() {
+	p := a.recvPause
+
+	p.Lock()
+	p.paused = false
+	if p.resuming {
+		p.Unlock()
+		return
+	}
+	p.resuming = true
+	p.Unlock()
+
+	for {
+		p.Lock()
+		if p.paused || len(p.parked) < 1 {
+			p.resuming = false
+			p.Unlock()
+			return
+		}
+
+		packet := p.parked[0]
+		p.parked[0] = nil
+		p.parked = p.parked[1:]
+		p.Unlock()
+
+		a.handlePacket(packet)
+	}
+}
+
+// parkPacket returns true if the data packet is parked or dropped by the pause
+func (a *Agent) parkPacket(packet *pomeloPacket.Packet) bool {
+	if packet.Type() != pomeloPacket.Data {
+		return false
+	}
+
+	p := a.recvPause
+	p.Lock()
+
+	if !p.paused && !p.resuming {
+		p.Unlock()
+		return false
+	}
+
+	if len(p.parked) < int(atomic.LoadInt32(&cmd.pauseBacklog)) {
+		p.parked = append(p.parked, packet)
+		p.Unlock()
+		return true
+	}
+
+	size := len(p.parked)
+	policy := OverflowPolicy(atomic.LoadInt32((*int32)(&cmd.pausePolicy)))
+	if policy == OverflowDropOldest {
+		p.parked[0] = nil
+		p.parked = append(p.parked[1:], packet)
+	}
+	p.Unlock()
+
+	if a.PrintLevel(zapcore.DebugLevel) {
+		a.Debugf("Parked packets are full. [size = %d, policy = %d]", size, policy)
+	}
+
+	if policy == OverflowKick {
+		a.KickWith(KickReason{
+			Code:    KickCodeRecvQueueFull,
+			Message: KickRecvQueueFull,
+		}, true)
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package pomelo
+
+import (
+	"testing"
+
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+func dataPacket(t *testing.T, data string) *pomeloPacket.Packet {
+	em, err := pomeloMessage.Encode(&pomeloMessage.Message{
+		Type:  pomeloMessage.Notify,
+		Route: "game.player.pause",
+		Data:  []byte(data),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return decodePacket(t, pomeloPacket.Data, em)
+}
+
+func decodePacket(t *testing.T, typ pomeloPacket.Type, data []byte) *pomeloPacket.Packet {
+	pkg, _ := pomeloPacket.Encode(typ, data)
+	packets, err := pomeloPacket.Decode(pkg)
+	if err != nil || len(packets) != 1 {
+		t.Fatalf("packets = %v, err = %v", packets, err)
+	}
+
+	return packets[0]
+}
+
+func TestPauseRecv(t *testing.T) {
+	defer func() {
+		(&actor{}).SetRecvPause(64, OverflowReject)
+		cmd.onPacketFuncMap = make(map[pomeloPacket.Type]PacketFunc, 4)
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+	cmd.setOnPacketFunc()
+
+	var routed []string
+	cmd.onDataRouteFunc = func(_ *Agent, _ *pomeloMessage.Route, msg *pomeloMessage.Message) {
+		routed = append(routed, string(msg.Data))
+	}
+
+	// receive 1, 2, 3 while paused and returns the routed data after resume
+	paused := func(agent *Agent) []string {
+		routed = nil
+		agent.PauseRecv()
+		for _, data := range []string{"1", "2", "3"} {
+			agent.processPacket(dataPacket(t, data))
+		}
+
+		if len(routed) > 0 || !agent.IsRecvPaused() {
+			t.Fatalf("routed = %v while paused", routed)
+		}
+
+		agent.ResumeRecv()
+		return routed
+	}
+
+	agent := newTestAgent("pause-recv")
+	agent.SetState(AgentWorking)
+
+	if list := paused(agent); len(list) != 3 || list[0] != "1" || list[2] != "3" || agent.IsRecvPaused() {
+		t.Fatalf("routed = %v", list)
+	}
+
+	// the heartbeat is not parked
+	agent.PauseRecv()
+	if agent.parkPacket(decodePacket(t, pomeloPacket.Heartbeat, nil)) {
+		t.Fatal("the heartbeat is parked")
+	}
+	agent.ResumeRecv()
+
+	// processed at once after resume
+	routed = nil
+	if agent.processPacket(dataPacket(t, "4")); len(routed) != 1 {
+		t.Fatalf("routed = %v", routed)
+	}
+
+	(&actor{}).SetRecvPause(2, OverflowDropNewest)
+	if list := paused(agent); len(list) != 2 || list[0] != "1" || list[1] != "2" {
+		t.Fatalf("drop newest routed = %v", list)
+	}
+
+	(&actor{}).SetRecvPause(2, OverflowDropOldest)
+	if list := paused(agent); len(list) != 2 || list[0] != "2" || list[1] != "3" {
+		t.Fatalf("drop oldest routed = %v", list)
+	}
+
+	stats := Stats()
+	(&actor{}).SetRecvPause(2, OverflowKick)
+	paused(agent)
+
+	if !agent.IsClosed() || Stats().Kicks[KickRecvQueueFull] != stats.Kicks[KickRecvQueueFull]+1 {
+		t.Fatalf("closed = %v, kicks = %v", agent.IsClosed(), Stats().Kicks)
+	}
+}
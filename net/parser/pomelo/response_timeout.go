@@ -0,0 +1,142 @@
+package pomelo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+const (
+	responseTimeoutMessage = "response timeout" // the response data of ccode.ResponseTimeout
+	watchdogMaxExpired     = 64                 // the late responses of the oldest expired mid are not dropped
+)
+
+type (
+	// responseWatchdog the requests waiting for the response, see actor.SetResponseTimeout
+	responseWatchdog struct {
+		sync.Mutex
+		timers  map[uint]*time.Timer // key:mid
+		expired map[uint]struct{}    // the mids responded by the timeout, the late responses are dropped
+		order   []uint               // the expired mids in the expired order
+	}
+)
+
+func newResponseWatchdog() *responseWatchdog {
+	return &responseWatchdog{
+		timers:  make(map[uint]*time.Timer),
+		expired: make(map[uint]struct{}),
+	}
+}
+
+// SetResponseTimeout the request which is not responded in d is responded with ccode.ResponseTimeout,
+// the late response of the handler is dropped. zero is disabled(default).
+func (*actor) SetResponseTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	storeDuration(&cmd.responseTimeout, d)
+}
+
+// watchResponse start the watchdog of the request before it's dispatched
+func (a *Agent) watchResponse(msg *pmessage.Message) {
+	d := loadDuration(&cmd.responseTimeout)
+	if d <= 0 || msg.Type != pmessage.Request {
+		return
+	}
+
+	p := a.watchdog
+	mid, route := msg.ID, msg.Route
+
+	p.Lock()
+	defer p.Unlock()
+
+	if timer, found := p.timers[mid]; found {
+		timer.Stop()
+	}
+	delete(p.expired, mid)
+
+	p.timers[mid] = time.AfterFunc(d, func() {
+		a.responseExpired(mid, route, d)
+	})
+}
+
+// responded returns false if the request has been responded by the timeout, the watchdog of mid is stopped
+func (p *responseWatchdog) responded(mid uint) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if timer, found := p.timers[mid]; found {
+		timer.Stop()
+		delete(p.timers, mid)
+		return true
+	}
+
+	if _, found := p.expired[mid]; found {
+		delete(p.expired, mid)
+		return false
+	}
+
+	return true
+}
+
+// expire returns false if the request has been responded by the handler
+func (p *responseWatchdog) expire(mid uint) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, found := p.timers[mid]; !found {
+		return false
+	}
+	delete(p.timers, mid)
+
+	if len(p.order) >= watchdogMaxExpired {
+		delete(p.expired, p.order[0])
+		p.order = p.order[1:]
+	}
+
+	p.expired[mid] = struct{}{}
+	p.order = append(p.order, mid)
+
+	return true
+}
+
+func (p *responseWatchdog) stop() {
+	p.Lock()
+	defer p.Unlock()
+
+	for mid, timer := range p.timers {
+		timer.Stop()
+		delete(p.timers, mid)
+	}
+}
+
+func (a *Agent) responseExpired(mid uint, route string, d time.Duration) {
+	if a.IsClosed() || !a.watchdog.expire(mid) {
+		return
+	}
+
+	a.Warnf("Response timeout. [route = %s, mid = %d, timeout = %v]", route, mid, d)
+
+	rsp := &cproto.Response{
+		Code: ccode.ResponseTimeout,
+		Data: []byte(responseTimeoutMessage),
+	}
+
+	// bypass the watchdog check of responseMID
+	_ = a.sendPending(context.Background(), pmessage.Response, "", uint32(mid), nil, rsp, true)
+}
+
+// checkResponded returns cerr.SessionResponseTimeout if the request has been responded by the timeout
+func (a *Agent) checkResponded(mid uint32) error {
+	if a.watchdog.responded(uint(mid)) {
+		return nil
+	}
+
+	a.Warnf("Late response dropped. [mid = %d]", mid)
+	return cerr.SessionResponseTimeout
+}
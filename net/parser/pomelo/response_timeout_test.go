@@ -0,0 +1,68 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestResponseTimeout(t *testing.T) {
+	p := &actor{}
+	defer func() {
+		p.SetResponseTimeout(0)
+		cmd.onDataRouteFunc = DefaultDataRoute
+	}()
+	p.SetResponseTimeout(20 * time.Millisecond)
+
+	agent := newTestAgent("response-timeout")
+	cmd.onDataRouteFunc = func(agent *Agent, _ *pmessage.Route, msg *pmessage.Message) {
+		if msg.Route == "game.room.join" {
+			_ = agent.ResponseMID(uint32(msg.ID), "joined")
+		}
+	}
+
+	dispatch := func(typ pmessage.Type, mid uint, route string) {
+		msg := &pmessage.Message{Type: typ, ID: mid, Route: route}
+		r, _ := pmessage.DecodeRoute(route)
+		dispatchData(agent, r, msg)
+	}
+
+	// responded by the handler, the watchdog is stopped
+	dispatch(pmessage.Request, 1, "game.room.join")
+	if pending := <-agent.chPending; pending.mid != 1 || pending.err {
+		t.Fatalf("pending = %s", pending.String())
+	}
+
+	// not responded
+	dispatch(pmessage.Request, 2, "game.room.hang")
+	dispatch(pmessage.Notify, 0, "game.room.hang")
+
+	select {
+	case pending := <-agent.chPending:
+		rsp, ok := pending.payload.(*cproto.Response)
+		if pending.mid != 2 || !ok || rsp.Code != ccode.ResponseTimeout {
+			t.Fatalf("pending = %s", pending.String())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the request is not responded by the timeout")
+	}
+
+	// the late response is dropped
+	if err := agent.ResponseMID(2, "late"); err != cerr.SessionResponseTimeout {
+		t.Fatalf("late response err = %v", err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if len(agent.chPending) != 0 {
+		t.Fatalf("pending = %d", len(agent.chPending))
+	}
+
+	// the next response of the mid is not dropped
+	if err := agent.ResponseMID(2, "again"); err != nil {
+		t.Fatal(err)
+	}
+}
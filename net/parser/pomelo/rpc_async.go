@@ -0,0 +1,105 @@
+package pomelo
+
+import (
+	"sync/atomic"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	cutils "github.com/cherry-game/cherry/extend/utils"
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	cdiscovery "github.com/cherry-game/cherry/net/discovery"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type (
+	// AsyncCallback reply is marshaled by the serializer of the remote node, err has the code if the call is failed. see cerr.Code
+	AsyncCallback func(reply []byte, err error)
+)
+
+// RPCAsync send the request to the actor of the route(nodeType.actorID.funcName) and returns immediately,
+// cb is called on a new goroutine when the reply or timeout arrives.
+// returns cerr.TooManyPendingCalls if the pending calls exceed SetMaxAsyncCalls,
+// the pending calls get cerr.SessionClosed once the agent is closed and the reply is dropped.
+// returns cerr.SessionClosed after Shutdown() started.
+func (a *Agent) RPCAsync(route string, arg interface{}, cb AsyncCallback) error {
+	if a.IsClosed() || isShuttingDown() {
+		return cerr.SessionClosed
+	}
+
+	nodeRoute, err := pmessage.DecodeRoute(route)
+	if err != nil {
+		return err
+	}
+
+	member, err := cdiscovery.Route(a.Discovery(), nodeRoute.NodeType(), cdiscovery.RouteHint{
+		UID:   a.UID(),
+		Route: route,
+	})
+	if err != nil {
+		return err
+	}
+
+	packet := cproto.BuildClusterPacket(
+		a.session.AgentPath,
+		cfacade.NewPath(member.GetNodeId(), nodeRoute.HandleName()),
+		nodeRoute.Method(),
+	)
+
+	if arg != nil {
+		if packet.ArgBytes, err = a.Serializer().Marshal(arg); err != nil {
+			packet.Recycle()
+			return err
+		}
+	}
+
+	maxCalls := atomic.LoadInt32(&cmd.maxAsyncCalls)
+	if n := atomic.AddInt32(&a.asyncCalls, 1); maxCalls > 0 && n > maxCalls {
+		atomic.AddInt32(&a.asyncCalls, -1)
+		packet.Recycle()
+		return cerr.TooManyPendingCalls
+	}
+
+	chResult := make(chan *cproto.Response, 1) // buffered, the request never blocks if the agent is closed
+	go func(nodeId string) {
+		rsp := a.Cluster().RequestRemote(nodeId, packet)
+		chResult <- &rsp
+	}(member.GetNodeId())
+
+	go func() {
+		defer atomic.AddInt32(&a.asyncCalls, -1)
+
+		select {
+		case rsp := <-chResult:
+			if a.IsClosed() {
+				invokeAsync(cb, nil, cerr.SessionClosed)
+			} else if ccode.IsFail(rsp.Code) {
+				invokeAsync(cb, nil, cerr.WithCode(cerr.Errorf("rpc async call fail. [route = %s, code = %d]", route, rsp.Code), rsp.Code))
+			} else {
+				invokeAsync(cb, rsp.Data, nil)
+			}
+		case <-a.chDie:
+			invokeAsync(cb, nil, cerr.SessionClosed)
+		}
+	}()
+
+	return nil
+}
+
+// PendingAsyncCalls the RPCAsync calls waiting for the reply
+func (a *Agent) PendingAsyncCalls() int {
+	return int(atomic.LoadInt32(&a.asyncCalls))
+}
+
+func invokeAsync(cb AsyncCallback, reply []byte, err error) {
+	if cb == nil {
+		return
+	}
+
+	cutils.Try(func() {
+		cb(reply, err)
+	}, func(errString string) {
+		clog.Warn(errString)
+	})
+}
@@ -0,0 +1,136 @@
+package pomelo
+
+import (
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type (
+	asyncApp struct {
+		testApp
+		cluster *asyncCluster
+	}
+
+	asyncDiscovery struct {
+		cfacade.IDiscovery
+	}
+
+	// asyncCluster replies the packets sent on release
+	asyncCluster struct {
+		cfacade.ICluster
+		release chan asyncReply
+	}
+
+	asyncReply struct {
+		code int32
+		data []byte
+	}
+)
+
+func (p asyncApp) Discovery() cfacade.IDiscovery {
+	return asyncDiscovery{}
+}
+
+func (p asyncApp) Cluster() cfacade.ICluster {
+	return p.cluster
+}
+
+func (asyncDiscovery) Random(nodeType string) (cfacade.IMember, bool) {
+	return &cproto.Member{NodeId: nodeType + "-1", NodeType: nodeType}, true
+}
+
+func (p *asyncCluster) RequestRemote(_ string, packet *cproto.ClusterPacket, _ ...time.Duration) cproto.Response {
+	packet.Recycle()
+	reply := <-p.release
+	return cproto.Response{Code: reply.code, Data: reply.data}
+}
+
+func newAsyncAgent(sid string) (*Agent, *asyncCluster) {
+	cluster := &asyncCluster{release: make(chan asyncReply, 8)}
+
+	agent := newTestAgent(sid)
+	agent.IApplication = asyncApp{cluster: cluster}
+	agent.session.AgentPath = "gate-1.user"
+
+	return agent, cluster
+}
+
+type asyncResult struct {
+	reply []byte
+	err   error
+}
+
+func TestRPCAsync(t *testing.T) {
+	agent, cluster := newAsyncAgent("async-1")
+
+	results := make(chan asyncResult, 2)
+	cb := func(reply []byte, err error) {
+		results <- asyncResult{reply, err}
+	}
+
+	if err := agent.RPCAsync("game.room", nil, cb); err != cerr.RouteInvalid {
+		t.Fatalf("invalid route err = %v", err)
+	}
+
+	if err := agent.RPCAsync("game.room.join", nil, cb); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster.release <- asyncReply{data: []byte("ok")}
+	if r := <-results; r.err != nil || string(r.reply) != "ok" {
+		t.Fatalf("reply = %s, err = %v", r.reply, r.err)
+	}
+
+	if err := agent.RPCAsync("game.room.join", nil, cb); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster.release <- asyncReply{code: ccode.RPCTimeoutError}
+	if r := <-results; r.reply != nil {
+		t.Fatalf("fail reply = %s", r.reply)
+	} else if code, _ := cerr.Code(r.err); code != ccode.RPCTimeoutError {
+		t.Fatalf("fail err = %v", r.err)
+	}
+}
+
+func TestRPCAsyncLimit(t *testing.T) {
+	old := cmd.maxAsyncCalls
+	cmd.maxAsyncCalls = 2
+	defer func() {
+		cmd.maxAsyncCalls = old
+	}()
+
+	agent, _ := newAsyncAgent("async-2")
+
+	results := make(chan asyncResult, 2)
+	cb := func(reply []byte, err error) {
+		results <- asyncResult{reply, err}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := agent.RPCAsync("game.room.join", nil, cb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := agent.RPCAsync("game.room.join", nil, cb); err != cerr.TooManyPendingCalls {
+		t.Fatalf("exceed err = %v", err)
+	}
+
+	// the pending calls get the error on close
+	agent.Close()
+	for i := 0; i < 2; i++ {
+		if r := <-results; r.err != cerr.SessionClosed {
+			t.Fatalf("closed err = %v", r.err)
+		}
+	}
+
+	if err := agent.RPCAsync("game.room.join", nil, cb); err != cerr.SessionClosed {
+		t.Fatalf("closed agent err = %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package pomelo
+
+import (
+	"sync"
+	"time"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+const (
+	sessionStoreDelay = 500 * time.Millisecond // the changed data is written to the store after it
+)
+
+type (
+	// SessionStore the shared storage of the session data keyed by uid for the other nodes, eg. redis or etcd.
+	// see actor.EnableSessionStore
+	SessionStore interface {
+		Save(uid cfacade.UID, data map[string]string) error // replace the stored data of the uid
+		Load(uid cfacade.UID) (map[string]string, error)    // returns empty data if the uid is not stored
+	}
+
+	// sessionStorer write the changed data of the bound agents to the store in batches
+	sessionStorer struct {
+		sync.Mutex
+		store    SessionStore
+		dirty    map[cfacade.SID]storeEntry
+		flushing sync.Mutex // the saves of a uid are in order
+		die      chan struct{}
+	}
+
+	storeEntry struct {
+		agent *Agent // the unbound agent, nil is found by sid on flush
+		uid   cfacade.UID
+	}
+)
+
+// EnableSessionStore write through the session data of the bound agents to the store keyed by uid,
+// the changes are batched and written after sessionStoreDelay, so every Set does not hit the store.
+// the stored data is loaded on bind, the keys already in the session are kept. the values set by SetWithTTL
+// are saved without the expire time. the store failures are logged, the data of the session is authoritative.
+// nil disables it(default). it must be called before the actor is started.
+func (*actor) EnableSessionStore(store SessionStore) {
+	if s := loadSessionStore(); s != nil {
+		cproto.SetDataListener(nil)
+		s.stop()
+		cmd.sessionStore.Store((*sessionStorer)(nil))
+	}
+
+	if store == nil {
+		return
+	}
+
+	s := newSessionStorer(store)
+	cmd.sessionStore.Store(s)
+	cproto.SetDataListener(s.mark)
+	go s.run()
+}
+
+// loadSessionStore the store is read by the closing agents
+func loadSessionStore() *sessionStorer {
+	s, _ := cmd.sessionStore.Load().(*sessionStorer)
+	return s
+}
+
+func newSessionStorer(store SessionStore) *sessionStorer {
+	return &sessionStorer{
+		store: store,
+		dirty: make(map[cfacade.SID]storeEntry),
+		die:   make(chan struct{}),
+	}
+}
+
+func (s *sessionStorer) run() {
+	ticker := time.NewTicker(sessionStoreDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.die:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *sessionStorer) stop() {
+	close(s.die)
+}
+
+// mark the data of the sid is changed, it's called with the session lock held
+func (s *sessionStorer) mark(sid cfacade.SID) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, found := s.dirty[sid]; !found {
+		s.dirty[sid] = storeEntry{}
+	}
+}
+
+// unbound keep the changed data of the agent is saved with the uid after it's unbound or closed
+func (s *sessionStorer) unbound(agent *Agent) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, found := s.dirty[agent.SID()]; found {
+		s.dirty[agent.SID()] = storeEntry{agent: agent, uid: agent.UID()}
+	}
+}
+
+// flush save the changed data, the failed data of the online agents is saved again on the next flush
+func (s *sessionStorer) flush() {
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+
+	s.Lock()
+	dirty := s.dirty
+	s.dirty = make(map[cfacade.SID]storeEntry)
+	s.Unlock()
+
+	for sid, entry := range dirty {
+		agent, uid := entry.agent, entry.uid
+		if agent == nil {
+			var found bool
+			if agent, found = GetAgent(sid); !found {
+				continue
+			}
+			uid = agent.UID()
+		}
+
+		if !cfacade.IsValidUID(uid) {
+			continue
+		}
+
+		if err := s.store.Save(uid, agent.session.CloneData()); err != nil {
+			clog.Warnf("[sessionStore] Save fail. [sid = %s, uid = %d, err = %v]", sid, uid, err)
+			if entry.agent == nil {
+				s.mark(sid)
+			}
+		}
+	}
+}
+
+// loadStoredData returns the stored data of the uid, nil if the store is disabled or failed
+func loadStoredData(uid cfacade.UID) map[string]string {
+	s := loadSessionStore()
+	if s == nil || !cfacade.IsValidUID(uid) {
+		return nil
+	}
+
+	data, err := s.store.Load(uid)
+	if err != nil {
+		clog.Warnf("[sessionStore] Load fail. [uid = %d, err = %v]", uid, err)
+		return nil
+	}
+
+	return data
+}
+
+// fillData set the stored values of the keys not in the session, returns the filled keys
+func fillData(session *cproto.Session, stored map[string]string) []string {
+	var filled []string
+	for k, v := range stored {
+		if session.Contains(k) {
+			continue
+		}
+		session.Set(k, v)
+		filled = append(filled, k)
+	}
+
+	return filled
+}
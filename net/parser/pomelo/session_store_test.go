@@ -0,0 +1,136 @@
+package pomelo
+
+import (
+	"sync"
+	"testing"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type testSessionStore struct {
+	sync.Mutex
+	data  map[cfacade.UID]map[string]string
+	saves int
+	fail  bool
+}
+
+func (p *testSessionStore) Save(uid cfacade.UID, data map[string]string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.fail {
+		return cerr.Error("store unavailable")
+	}
+
+	p.saves++
+	p.data[uid] = data
+	return nil
+}
+
+func (p *testSessionStore) Load(uid cfacade.UID) (map[string]string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.fail {
+		return nil, cerr.Error("store unavailable")
+	}
+	return p.data[uid], nil
+}
+
+func (p *testSessionStore) get(uid cfacade.UID) (map[string]string, int) {
+	p.Lock()
+	defer p.Unlock()
+	return p.data[uid], p.saves
+}
+
+func TestSessionStore(t *testing.T) {
+	defer func() {
+		cmd.onBindFuncs = nil
+		(&actor{}).EnableSessionStore(nil)
+	}()
+
+	store := &testSessionStore{
+		data: map[cfacade.UID]map[string]string{
+			4101: {"level": "5", "gold": "100"},
+			4102: {"vip": "1"},
+		},
+	}
+	// flushed by the test, see EnableSessionStore
+	storer := newSessionStorer(store)
+	cmd.sessionStore.Store(storer)
+	cproto.SetDataListener(storer.mark)
+
+	cmd.onBindFuncs = []OnBindFunc{func(agent *Agent) bool {
+		return agent.UID() != 4102
+	}}
+
+	// rejected, the loaded data is rolled back
+	rejected := newTestAgent("store-rejected")
+	BindSID(rejected)
+	defer Unbind(rejected.SID())
+
+	if err := rejected.Bind(4102); err != cerr.SessionBindRejected || rejected.Session().Contains("vip") {
+		t.Fatalf("bind err = %v, data = %v", err, rejected.Session().CloneData())
+	}
+
+	agent := newTestAgent("store-agent")
+	agent.Session().Set("level", "1")
+	BindSID(agent)
+
+	// the keys in the session are kept
+	if err := agent.Bind(4101); err != nil {
+		t.Fatal(err)
+	}
+
+	if level, _ := agent.Session().Get("level"); level != "1" || agent.Session().GetString("gold") != "100" {
+		t.Fatalf("loaded data = %v", agent.Session().CloneData())
+	}
+
+	// the changes are batched
+	for _, gold := range []string{"200", "300", "400"} {
+		agent.Session().Set("gold", gold)
+	}
+	storer.flush()
+
+	data, saves := store.get(4101)
+	if saves != 1 || data["gold"] != "400" || data["level"] != "1" {
+		t.Fatalf("saves = %d, data = %v", saves, data)
+	}
+
+	// nothing is changed
+	storer.flush()
+	if _, saves = store.get(4101); saves != 1 {
+		t.Fatalf("saves = %d", saves)
+	}
+
+	// the failed data is saved on the next flush, the session is not changed
+	store.Lock()
+	store.fail = true
+	store.Unlock()
+
+	agent.Session().Set("gold", "500")
+	storer.flush()
+	if agent.Session().GetString("gold") != "500" {
+		t.Fatalf("data = %v", agent.Session().CloneData())
+	}
+
+	store.Lock()
+	store.fail = false
+	store.Unlock()
+
+	storer.flush()
+	if data, saves = store.get(4101); saves != 2 || data["gold"] != "500" {
+		t.Fatalf("saves = %d, data = %v", saves, data)
+	}
+
+	// the data changed before the close is saved with the uid
+	agent.Session().Set("gold", "600")
+	Unbind(agent.SID())
+	storer.flush()
+
+	if data, saves = store.get(4101); saves != 3 || data["gold"] != "600" {
+		t.Fatalf("saves = %d, data = %v", saves, data)
+	}
+}
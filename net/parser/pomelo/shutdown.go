@@ -0,0 +1,110 @@
+package pomelo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	clog "github.com/cherry-game/cherry/logger"
+)
+
+const (
+	shutdownCheckTime = 10 * time.Millisecond // check interval of the pending agents and calls
+)
+
+var (
+	shuttingDown    int32 // set by Shutdown(), the new connections and data messages are rejected
+	inflightPackets int64 // packets processed by the read goroutines
+)
+
+func isShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+// Shutdown stop the connectors, wait for the messages in process and the pending RPCAsync calls,
+// kick all agents with KickShutdown and wait for they are closed. the onClose listeners of all agents are fired.
+// returns an error with the pending agents and calls if ctx is done before the shutdown is finished.
+func (p *actor) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&shuttingDown, 1)
+
+	for _, connector := range p.connectors {
+		connector.Stop()
+	}
+
+	// the replies of the pending calls can still be pushed to the clients
+	if err := waitShutdown(ctx, func() bool {
+		return atomic.LoadInt64(&inflightPackets) < 1 && pendingAsyncCalls() < 1
+	}); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	ForeachAgent(func(agent *Agent) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent.shutdown(ctx)
+		}()
+	})
+	wg.Wait()
+
+	// the agents waiting for reconnect are not come back
+	expireAllRetain()
+
+	if err := waitShutdown(ctx, func() bool {
+		return Count() < 1
+	}); err != nil {
+		return err
+	}
+
+	// the data of the closed agents is saved before the node is stopped
+	if s := loadSessionStore(); s != nil {
+		s.flush()
+	}
+
+	clog.Info("[pomelo] Shutdown ok.")
+	return nil
+}
+
+func (a *Agent) shutdown(ctx context.Context) {
+	if cmd.shutdownDrain {
+		if err := a.drain(ctx); err != nil {
+			a.Warnf("Agent drain fail on shutdown. [err = %v]", err)
+		}
+	}
+
+	a.KickWith(KickReason{
+		Code:    KickCodeShutdown,
+		Message: KickShutdown,
+	}, true)
+}
+
+// waitShutdown wait until done returns true or ctx is done
+func waitShutdown(ctx context.Context, done func() bool) error {
+	ticker := time.NewTicker(shutdownCheckTime)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return cerr.Errorf("shutdown is not finished. [sessions = %d, calls = %d, err = %v]",
+				Count(),
+				pendingAsyncCalls(),
+				ctx.Err(),
+			)
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func pendingAsyncCalls() int {
+	calls := 0
+	ForeachAgent(func(agent *Agent) {
+		calls += agent.PendingAsyncCalls()
+	})
+	return calls
+}
@@ -0,0 +1,70 @@
+package pomelo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	defer atomic.StoreInt32(&shuttingDown, 0)
+
+	var closed int32
+	for i := 0; i < 3; i++ {
+		agent := newTestAgent("shutdown-" + strconv.Itoa(i))
+		agent.AddOnClose(func(*Agent) {
+			atomic.AddInt32(&closed, 1)
+		})
+		BindSID(agent)
+		agent.Run()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := NewActor("shutdown").Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt32(&closed); n != 3 || Count() != 0 {
+		t.Fatalf("closed = %d, count = %d", n, Count())
+	}
+
+	if stats := Stats(); stats.Kicks[KickShutdown] < 3 {
+		t.Fatalf("kicks = %+v", stats.Kicks)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	defer atomic.StoreInt32(&shuttingDown, 0)
+
+	agent := newTestAgent("shutdown-timeout")
+	BindSID(agent)
+	agent.Run()
+
+	// a pending call never returns
+	atomic.AddInt32(&agent.asyncCalls, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := NewActor("shutdown").Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), "calls = 1") {
+		t.Fatalf("err = %v", err)
+	}
+
+	if err := agent.RPCAsync("game.room.join", nil, nil); err == nil {
+		t.Fatal("rpc async is accepted after shutdown")
+	}
+
+	atomic.AddInt32(&agent.asyncCalls, -1)
+	agent.Close()
+
+	// the agent is unbound by the write goroutine
+	for _, found := GetAgent(agent.SID()); found; _, found = GetAgent(agent.SID()) {
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,57 @@
+package pomelo
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	csnowflake "github.com/cherry-game/cherry/extend/snowflake"
+	cfacade "github.com/cherry-game/cherry/facade"
+	"github.com/nats-io/nuid"
+)
+
+type (
+	// SIDGenerator returns the unique sid of the new agent, see actor.SetSIDGenerator
+	SIDGenerator func() cfacade.SID
+)
+
+var (
+	sidCounter uint64 // see CounterSID
+)
+
+// NuidSID the random sid, it's the default generator
+func NuidSID() cfacade.SID {
+	return nuid.Next()
+}
+
+// CounterSID the process-local sequential sid, it's only unique in the node
+func CounterSID() cfacade.SID {
+	return strconv.FormatUint(atomic.AddUint64(&sidCounter, 1), 10)
+}
+
+// SnowflakeSID returns the generator of the snowflake sid embedding the node number and the timestamp,
+// node should be different in the frontend nodes. see csnowflake.NewNode
+func SnowflakeSID(node int64) (SIDGenerator, error) {
+	n, err := csnowflake.NewNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() cfacade.SID {
+		return n.Generate().String()
+	}, nil
+}
+
+// SetSIDGenerator set the sid generator of the new agents, the default is NuidSID
+func (*actor) SetSIDGenerator(fn SIDGenerator) {
+	if fn != nil {
+		cmd.sidGenerator = fn
+	}
+}
+
+// nextSID the generator returns the empty sid is replaced by NuidSID
+func nextSID() cfacade.SID {
+	if sid := cmd.sidGenerator(); sid != "" {
+		return sid
+	}
+	return NuidSID()
+}
@@ -0,0 +1,40 @@
+package pomelo
+
+import (
+	"testing"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+func TestSIDGenerator(t *testing.T) {
+	defer func() {
+		cmd.sidGenerator = NuidSID
+	}()
+
+	snowflake, err := SnowflakeSID(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = SnowflakeSID(-1); err == nil {
+		t.Fatal("invalid node is accepted")
+	}
+
+	for _, fn := range []SIDGenerator{NuidSID, CounterSID, snowflake} {
+		(&actor{}).SetSIDGenerator(fn)
+
+		sids := make(map[cfacade.SID]struct{})
+		for i := 0; i < 1000; i++ {
+			sid := nextSID()
+			if _, found := sids[sid]; found || sid == "" {
+				t.Fatalf("sid = %s is duplicated", sid)
+			}
+			sids[sid] = struct{}{}
+		}
+	}
+
+	(&actor{}).SetSIDGenerator(func() cfacade.SID { return "" })
+	if nextSID() == "" {
+		t.Fatal("empty sid")
+	}
+}
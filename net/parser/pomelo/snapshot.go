@@ -0,0 +1,87 @@
+package pomelo
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+type (
+	// SessionSnapshot the read-only view of the agent for the admin and debug, it's marshaled by json
+	SessionSnapshot struct {
+		SID           cfacade.SID       `json:"sid"`
+		UID           cfacade.UID       `json:"uid"`
+		FrontendID    string            `json:"frontendId"` // node id of the agent actor
+		AgentPath     string            `json:"agentPath"`
+		RemoteAddr    string            `json:"remoteAddr"`
+		Bound         bool              `json:"bound"`
+		State         int32             `json:"state"`
+		Encrypted     bool              `json:"encrypted"`
+		Tags          []string          `json:"tags"`
+		Topics        []string          `json:"topics"`
+		LastHeartbeat time.Time         `json:"lastHeartbeat"` // zero time if the client has not sent heartbeat yet
+		LastActiveAt  time.Time         `json:"lastActiveAt"`
+		RTT           time.Duration     `json:"rtt"` // nanoseconds, see Agent.Ping
+		PendingBytes  int64             `json:"pendingBytes"`
+		Settings      map[string]string `json:"settings"` // session data without the redacted keys
+	}
+)
+
+// SetSnapshotRedact the keys of the session data are not copied to the snapshot. eg. token, password
+func (*actor) SetSnapshotRedact(keys ...string) {
+	redact := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		redact[key] = struct{}{}
+	}
+	cmd.snapshotRedact = redact
+}
+
+// Snapshots returns the snapshots of all agents sorted by sid. eg. the admin http endpoint
+func (*actor) Snapshots() []SessionSnapshot {
+	list := agents()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SID() < list[j].SID()
+	})
+
+	snapshots := make([]SessionSnapshot, 0, len(list))
+	for _, agent := range list {
+		snapshots = append(snapshots, agent.Snapshot())
+	}
+	return snapshots
+}
+
+// Snapshot returns the copy of the agent state, the agent is not changed
+func (a *Agent) Snapshot() SessionSnapshot {
+	settings := a.session.CloneData()
+	for key := range cmd.snapshotRedact {
+		delete(settings, key)
+	}
+
+	snapshot := SessionSnapshot{
+		SID:           a.SID(),
+		UID:           a.UID(),
+		AgentPath:     a.session.AgentPath,
+		RemoteAddr:    a.RemoteAddress(),
+		Bound:         a.IsBind(),
+		State:         a.State(),
+		Encrypted:     a.IsEncrypted(),
+		Tags:          a.Tags(),
+		Topics:        a.Topics(),
+		LastHeartbeat: a.LastHeartbeat(),
+		LastActiveAt:  time.UnixMilli(a.LastActiveAt()),
+		RTT:           a.RTT(),
+		PendingBytes:  atomic.LoadInt64(&a.pendingBytes),
+		Settings:      settings,
+	}
+
+	sort.Strings(snapshot.Tags)
+	sort.Strings(snapshot.Topics)
+
+	if path, err := cfacade.ToActorPath(a.session.AgentPath); err == nil {
+		snapshot.FrontendID = path.NodeID
+	}
+
+	return snapshot
+}
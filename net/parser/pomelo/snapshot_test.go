@@ -0,0 +1,79 @@
+package pomelo
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestAgentSnapshot(t *testing.T) {
+	defer (&actor{}).SetSnapshotRedact()
+
+	(&actor{}).SetSnapshotRedact("token")
+
+	agent := newTestAgent("agent-snapshot")
+	agent.session.AgentPath = "gate-1.user"
+	agent.session.Set("level", "10")
+	agent.session.Set("token", "secret")
+	BindSID(agent)
+	defer Unbind(agent.SID())
+
+	if err := agent.Bind(7001); err != nil {
+		t.Fatal(err)
+	}
+
+	agent.AddTag("vip")
+	agent.AddTag("beta")
+	agent.Subscribe("chat.world")
+	defer leaveTags(agent.SID())
+	defer leaveTopics(agent.SID())
+
+	// concurrent snapshots
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent.Snapshot()
+		}()
+	}
+	wg.Wait()
+
+	snapshot := agent.Snapshot()
+	if snapshot.UID != 7001 || !snapshot.Bound || snapshot.FrontendID != "gate-1" {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+
+	if len(snapshot.Tags) != 2 || snapshot.Tags[0] != "beta" || len(snapshot.Topics) != 1 {
+		t.Fatalf("tags = %v, topics = %v", snapshot.Tags, snapshot.Topics)
+	}
+
+	if _, found := snapshot.Settings["token"]; found || snapshot.Settings["level"] != "10" {
+		t.Fatalf("settings = %v", snapshot.Settings)
+	}
+
+	// the copy is not shared with the session
+	snapshot.Settings["level"] = "99"
+	if value, _ := agent.session.Get("level"); value != "10" {
+		t.Fatalf("session is changed by the snapshot. [level = %s]", value)
+	}
+
+	data, err := json.Marshal((&actor{}).Snapshots())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var list []SessionSnapshot
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, item := range list {
+		found = found || item.SID == agent.SID()
+	}
+
+	if !found {
+		t.Fatalf("snapshots = %s", data)
+	}
+}
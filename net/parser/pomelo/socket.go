@@ -0,0 +1,97 @@
+package pomelo
+
+import (
+	"bufio"
+	"net"
+	"sync/atomic"
+
+	cerr "github.com/cherry-game/cherry/error"
+	clog "github.com/cherry-game/cherry/logger"
+)
+
+type (
+	// socketBuffer the conn can change the kernel buffer sizes. eg. *net.TCPConn
+	socketBuffer interface {
+		SetReadBuffer(bytes int) error
+		SetWriteBuffer(bytes int) error
+	}
+
+	// bufferedConn the reads of the conn are buffered, the packet header and body are read in one syscall
+	bufferedConn struct {
+		net.Conn
+		reader *bufio.Reader
+	}
+)
+
+// SetSocketBuffers the kernel buffer sizes of the new connections and the read buffer(bufio) of the agents.
+// the larger buffers cost more memory of each connection(readBytes + 2 * kernel buffers),
+// and save the syscalls of the small packets, the too small kernel buffers(eg. 4k) throttle the tcp window.
+// default is the os default without the read buffer. see BenchmarkReadPackets
+// it's applied to the connections accepted after it's called.
+func (*actor) SetSocketBuffers(readBytes, writeBytes int) error {
+	if readBytes < 1 || writeBytes < 1 {
+		return cerr.Errorf("socket buffers must be greater than zero. [read = %d, write = %d]", readBytes, writeBytes)
+	}
+
+	atomic.StoreInt32(&cmd.readBuffer, int32(readBytes))
+	atomic.StoreInt32(&cmd.writeBuffer, int32(writeBytes))
+	return nil
+}
+
+// applySocketBuffers set the kernel buffers of the underlying tcp conn, eg. tls or websocket conn
+func applySocketBuffers(conn net.Conn) {
+	readBytes := int(atomic.LoadInt32(&cmd.readBuffer))
+	writeBytes := int(atomic.LoadInt32(&cmd.writeBuffer))
+	if readBytes < 1 || writeBytes < 1 {
+		return
+	}
+
+	socket, found := underlyingSocket(conn)
+	if !found {
+		return
+	}
+
+	if err := socket.SetReadBuffer(readBytes); err != nil {
+		clog.Warnf("Set read buffer fail. [bytes = %d, err = %v]", readBytes, err)
+	}
+
+	if err := socket.SetWriteBuffer(writeBytes); err != nil {
+		clog.Warnf("Set write buffer fail. [bytes = %d, err = %v]", writeBytes, err)
+	}
+}
+
+func underlyingSocket(conn net.Conn) (socketBuffer, bool) {
+	for conn != nil {
+		if socket, ok := conn.(socketBuffer); ok {
+			return socket, true
+		}
+
+		switch c := conn.(type) {
+		case interface{ NetConn() net.Conn }: // *tls.Conn
+			conn = c.NetConn()
+		case interface{ UnderlyingConn() net.Conn }: // *cherryConnector.WSConn
+			conn = c.UnderlyingConn()
+		default:
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// readConn returns the conn read by the read goroutine
+func (a *Agent) readConn() net.Conn {
+	readBytes := int(atomic.LoadInt32(&cmd.readBuffer))
+	if readBytes < 1 {
+		return a.conn
+	}
+
+	return &bufferedConn{
+		Conn:   a.conn,
+		reader: bufio.NewReaderSize(a.conn, readBytes),
+	}
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,147 @@
+package pomelo
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+func resetSocketBuffers() {
+	atomic.StoreInt32(&cmd.readBuffer, 0)
+	atomic.StoreInt32(&cmd.writeBuffer, 0)
+}
+
+func newTCPPair(t testing.TB) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, client
+}
+
+func TestSetSocketBuffers(t *testing.T) {
+	defer resetSocketBuffers()
+
+	if err := (&actor{}).SetSocketBuffers(0, 1024); err == nil {
+		t.Fatal("zero read buffer is accepted")
+	}
+
+	if err := (&actor{}).SetSocketBuffers(1024, -1); err == nil {
+		t.Fatal("negative write buffer is accepted")
+	}
+
+	if err := (&actor{}).SetSocketBuffers(64*1024, 64*1024); err != nil {
+		t.Fatal(err)
+	}
+
+	server, client := newTCPPair(t)
+	defer server.Close()
+	defer client.Close()
+
+	if _, found := underlyingSocket(tls.Server(server, &tls.Config{})); !found {
+		t.Fatal("the tcp conn of the tls conn is not found")
+	}
+
+	pipe, _ := net.Pipe()
+	if _, found := underlyingSocket(pipe); found {
+		t.Fatal("pipe has no socket buffers")
+	}
+
+	applySocketBuffers(server)
+
+	// the reads are buffered
+	agent := newTestAgent("socket-buffers")
+	agent.conn = server
+
+	conn := agent.readConn()
+	if _, ok := conn.(*bufferedConn); !ok {
+		t.Fatalf("conn = %T", conn)
+	}
+
+	heartbeat, _ := pomeloPacket.Encode(pomeloPacket.Heartbeat, nil)
+	data, _ := pomeloPacket.Encode(pomeloPacket.Data, []byte("hello"))
+	client.Write(append(heartbeat, data...))
+
+	for _, typ := range []pomeloPacket.Type{pomeloPacket.Heartbeat, pomeloPacket.Data} {
+		packets, _, err := pomeloPacket.Read(conn)
+		if err != nil || len(packets) != 1 || packets[0].Type() != typ {
+			t.Fatalf("packets = %v, err = %v", packets, err)
+		}
+	}
+}
+
+// BenchmarkReadPackets the throughput of the small packets over the tcp loopback,
+// the packet header and body are read in two syscalls without the read buffer.
+//
+//	go test -run none -bench ReadPackets ./net/parser/pomelo/
+func BenchmarkReadPackets(b *testing.B) {
+	defer resetSocketBuffers()
+
+	cases := []struct {
+		name  string
+		bytes int
+	}{
+		{"default", 0},
+		{"buffered-32k", 32 * 1024},
+		{"buffered-256k", 256 * 1024},
+	}
+
+	data, _ := pomeloPacket.Encode(pomeloPacket.Data, make([]byte, 64))
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			resetSocketBuffers()
+			if c.bytes > 0 {
+				_ = (&actor{}).SetSocketBuffers(c.bytes, c.bytes)
+			}
+
+			server, client := newTCPPair(b)
+			defer server.Close()
+			applySocketBuffers(server)
+
+			go func() {
+				batch := make([]byte, 0, len(data)*64)
+				for i := 0; i < 64; i++ {
+					batch = append(batch, data...)
+				}
+
+				for n := 0; n < b.N; n += 64 {
+					if _, err := client.Write(batch); err != nil {
+						break
+					}
+				}
+				_ = client.Close()
+			}()
+
+			conn := (&Agent{conn: server}).readConn()
+
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, _, err := pomeloPacket.Read(conn); err != nil {
+					if err != io.EOF {
+						b.Fatal(err)
+					}
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package pomelo
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// SessionStats the snapshot of the agent counters since start
+	SessionStats struct {
+		Total             int64            // online agents
+		Bound             int64            // online agents bound uid
+		Created           int64            // agents created since start
+		Closed            int64            // agents closed since start
+		Kicks             map[string]int64 // kicks by reason message. see KickReason.Message
+		MessagesIn        int64            // data messages received
+		MessagesOut       int64            // data messages queued to write(Push, Response)
+		Oversized         int64            // inbound messages rejected by the max message size
+		Malformed         int64            // inbound packets failed to decode
+		HandshakeTimeouts int64            // agents closed by the handshake timeout, see actor.SetHandshakeTimeout
+		SlowConsumerDrops int64            // broadcast messages dropped for the saturated agents, see BroadcastOpts
+		WriteTimeouts     int64            // writes timeout, see actor.SetWriteTimeout
+
+		DispatchQueued    int64 // packets queued in the worker pool, see DispatchWorkerPool
+		DispatchSaturated int64 // dispatches blocked by the full worker since the pool is created
+	}
+
+	// sessionCounters updated by atomics in the hot paths, the kicks are counted by reason
+	sessionCounters struct {
+		total             int64
+		bound             int64
+		created           int64
+		closed            int64
+		messagesIn        int64
+		messagesOut       int64
+		oversized         int64
+		malformed         int64
+		handshakeTimeouts int64
+		slowConsumerDrops int64
+		writeTimeouts     int64
+		kicks             sync.Map // key:reason, value:*int64
+	}
+)
+
+var (
+	counters = &sessionCounters{}
+)
+
+func (p *sessionCounters) kick(reason string) {
+	value, found := p.kicks.Load(reason)
+	if !found {
+		value, _ = p.kicks.LoadOrStore(reason, new(int64))
+	}
+	atomic.AddInt64(value.(*int64), 1)
+}
+
+// Stats returns the snapshot of the agent counters, it's never blocked by the agents
+func Stats() SessionStats {
+	stats := SessionStats{
+		Total:             atomic.LoadInt64(&counters.total),
+		Bound:             atomic.LoadInt64(&counters.bound),
+		Created:           atomic.LoadInt64(&counters.created),
+		Closed:            atomic.LoadInt64(&counters.closed),
+		Kicks:             map[string]int64{},
+		MessagesIn:        atomic.LoadInt64(&counters.messagesIn),
+		MessagesOut:       atomic.LoadInt64(&counters.messagesOut),
+		Oversized:         atomic.LoadInt64(&counters.oversized),
+		Malformed:         atomic.LoadInt64(&counters.malformed),
+		HandshakeTimeouts: atomic.LoadInt64(&counters.handshakeTimeouts),
+		SlowConsumerDrops: atomic.LoadInt64(&counters.slowConsumerDrops),
+		WriteTimeouts:     atomic.LoadInt64(&counters.writeTimeouts),
+	}
+
+	if pool := cmd.dispatchPool; pool != nil {
+		stats.DispatchQueued = atomic.LoadInt64(&pool.queued)
+		stats.DispatchSaturated = atomic.LoadInt64(&pool.saturated)
+	}
+
+	counters.kicks.Range(func(key, value interface{}) bool {
+		stats.Kicks[key.(string)] = atomic.LoadInt64(value.(*int64))
+		return true
+	})
+
+	return stats
+}
@@ -0,0 +1,46 @@
+package pomelo
+
+import (
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	before := Stats()
+
+	agent1 := newTestAgent("stats-1")
+	agent2 := newTestAgent("stats-2")
+	BindSID(agent1)
+	BindSID(agent2)
+
+	if err := BindUID(agent1.SID(), 3001); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := Stats()
+	if stats.Total-before.Total != 2 || stats.Created-before.Created != 2 || stats.Bound-before.Bound != 1 {
+		t.Fatalf("before = %+v, stats = %+v", before, stats)
+	}
+
+	// rebind does not change the bound count
+	if err := RebindUID(agent1.SID(), 3002); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats = Stats(); stats.Bound-before.Bound != 1 {
+		t.Fatalf("rebind bound = %d, before = %d", stats.Bound, before.Bound)
+	}
+
+	agent1.KickWith(KickReason{Code: KickCodeIdleTimeout, Message: KickIdleTimeout}, false)
+	if stats = Stats(); stats.Kicks[KickIdleTimeout]-before.Kicks[KickIdleTimeout] != 1 {
+		t.Fatalf("kicks = %+v, before = %+v", stats.Kicks, before.Kicks)
+	}
+
+	Unbind(agent1.SID())
+	Unbind(agent2.SID())
+	Unbind(agent2.SID()) // unbind twice
+
+	stats = Stats()
+	if stats.Total != before.Total || stats.Bound != before.Bound || stats.Created-before.Created != 2 {
+		t.Fatalf("before = %+v, stats = %+v", before, stats)
+	}
+}
@@ -0,0 +1,108 @@
+package pomelo
+
+import (
+	"sync"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+var (
+	tagLock   = &sync.RWMutex{}
+	tagMap    = make(map[string]map[cfacade.SID]*Agent)   // tag -> sid -> Agent
+	agentTags = make(map[cfacade.SID]map[string]struct{}) // sid -> tags
+)
+
+// AddTag add the tag to the agent. eg. region:eu, vip, beta
+func (a *Agent) AddTag(tag string) {
+	if tag == "" || a.IsClosed() {
+		return
+	}
+
+	tagLock.Lock()
+	defer tagLock.Unlock()
+
+	members, found := tagMap[tag]
+	if !found {
+		members = make(map[cfacade.SID]*Agent)
+		tagMap[tag] = members
+	}
+	members[a.SID()] = a
+
+	tags, found := agentTags[a.SID()]
+	if !found {
+		tags = make(map[string]struct{})
+		agentTags[a.SID()] = tags
+	}
+	tags[tag] = struct{}{}
+}
+
+// RemoveTag remove the tag from the agent
+func (a *Agent) RemoveTag(tag string) {
+	tagLock.Lock()
+	defer tagLock.Unlock()
+
+	removeTag(a.SID(), tag)
+}
+
+func (a *Agent) HasTag(tag string) bool {
+	tagLock.RLock()
+	defer tagLock.RUnlock()
+
+	_, found := agentTags[a.SID()][tag]
+	return found
+}
+
+// Tags returns the tags of the agent
+func (a *Agent) Tags() []string {
+	tagLock.RLock()
+	defer tagLock.RUnlock()
+
+	list := make([]string, 0, len(agentTags[a.SID()]))
+	for tag := range agentTags[a.SID()] {
+		list = append(list, tag)
+	}
+	return list
+}
+
+// BroadcastToTag push the message to the agents with the tag. see MultiPush
+func BroadcastToTag(tag, route string, v interface{}) error {
+	return multiPush(tagAgents(tag), route, v, PushError{})
+}
+
+func tagAgents(tag string) []*Agent {
+	tagLock.RLock()
+	defer tagLock.RUnlock()
+
+	list := make([]*Agent, 0, len(tagMap[tag]))
+	for _, agent := range tagMap[tag] {
+		list = append(list, agent)
+	}
+	return list
+}
+
+// leaveTags remove all tags of the agent
+func leaveTags(sid cfacade.SID) {
+	tagLock.Lock()
+	defer tagLock.Unlock()
+
+	for tag := range agentTags[sid] {
+		removeTag(sid, tag)
+	}
+}
+
+// removeTag must be called with the write lock held
+func removeTag(sid cfacade.SID, tag string) {
+	if members, found := tagMap[tag]; found {
+		delete(members, sid)
+		if len(members) == 0 {
+			delete(tagMap, tag)
+		}
+	}
+
+	if tags, found := agentTags[sid]; found {
+		delete(tags, tag)
+		if len(tags) == 0 {
+			delete(agentTags, sid)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package pomelo
+
+import (
+	"testing"
+)
+
+func TestBroadcastToTag(t *testing.T) {
+	agent1 := newTestAgent("tag-1")
+	agent2 := newTestAgent("tag-2")
+	defer leaveTags(agent1.SID())
+	defer leaveTags(agent2.SID())
+
+	agent1.AddTag("vip")
+	agent1.AddTag("region:eu")
+	agent2.AddTag("region:eu")
+
+	if !agent1.HasTag("vip") || agent2.HasTag("vip") {
+		t.Fatal("has tag error")
+	}
+
+	if err := BroadcastToTag("vip", "test.push", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(agent1.chWrite) != 1 || len(agent2.chWrite) != 0 {
+		t.Fatalf("broadcast = %d, %d", len(agent1.chWrite), len(agent2.chWrite))
+	}
+
+	agent1.RemoveTag("vip")
+	if agent1.HasTag("vip") || len(tagAgents("vip")) != 0 {
+		t.Fatal("tag is not removed")
+	}
+
+	// the tags are removed on close
+	leaveTags(agent2.SID())
+	if len(agent2.Tags()) != 0 || len(tagAgents("region:eu")) != 1 {
+		t.Fatalf("tags = %v", agent2.Tags())
+	}
+}
@@ -0,0 +1,146 @@
+package pomelo
+
+import (
+	"strings"
+	"sync"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+const (
+	TopicWildcard      = "*" // matches one level of the topic. eg. chat.room.*
+	TopicMultiWildcard = ">" // matches one or more trailing levels of the topic. eg. chat.>
+	topicSeparator     = "."
+)
+
+var (
+	topicLock   = &sync.RWMutex{}
+	topicMap    = make(map[string]map[cfacade.SID]*Agent)   // topic pattern -> sid -> Agent
+	agentTopics = make(map[cfacade.SID]map[string]struct{}) // sid -> topic patterns
+)
+
+// Subscribe subscribe the topic pattern, the levels are separated by ".".
+// "*" matches one level and ">" matches the trailing levels. eg. chat.room.*, chat.>
+func (a *Agent) Subscribe(topic string) {
+	if topic == "" || a.IsClosed() {
+		return
+	}
+
+	topicLock.Lock()
+	defer topicLock.Unlock()
+
+	members, found := topicMap[topic]
+	if !found {
+		members = make(map[cfacade.SID]*Agent)
+		topicMap[topic] = members
+	}
+	members[a.SID()] = a
+
+	topics, found := agentTopics[a.SID()]
+	if !found {
+		topics = make(map[string]struct{})
+		agentTopics[a.SID()] = topics
+	}
+	topics[topic] = struct{}{}
+}
+
+// Unsubscribe unsubscribe the topic pattern
+func (a *Agent) Unsubscribe(topic string) {
+	topicLock.Lock()
+	defer topicLock.Unlock()
+
+	unsubscribe(a.SID(), topic)
+}
+
+// Topics returns the subscribed topic patterns
+func (a *Agent) Topics() []string {
+	topicLock.RLock()
+	defer topicLock.RUnlock()
+
+	list := make([]string, 0, len(agentTopics[a.SID()]))
+	for topic := range agentTopics[a.SID()] {
+		list = append(list, topic)
+	}
+	return list
+}
+
+// PublishTopic push the message to the agents subscribed the patterns matching topic.
+// an agent matched by several patterns receives the message once. see MultiPush
+func PublishTopic(topic string, route string, v interface{}) error {
+	return multiPush(topicAgents(topic), route, v, PushError{})
+}
+
+func topicAgents(topic string) []*Agent {
+	topicLock.RLock()
+	defer topicLock.RUnlock()
+
+	agentMap := make(map[cfacade.SID]*Agent)
+	for pattern, members := range topicMap {
+		if !matchTopic(pattern, topic) {
+			continue
+		}
+
+		for sid, agent := range members {
+			agentMap[sid] = agent
+		}
+	}
+
+	list := make([]*Agent, 0, len(agentMap))
+	for _, agent := range agentMap {
+		list = append(list, agent)
+	}
+	return list
+}
+
+// leaveTopics unsubscribe all topics of the agent
+func leaveTopics(sid cfacade.SID) {
+	topicLock.Lock()
+	defer topicLock.Unlock()
+
+	for topic := range agentTopics[sid] {
+		unsubscribe(sid, topic)
+	}
+}
+
+// unsubscribe must be called with the write lock held
+func unsubscribe(sid cfacade.SID, topic string) {
+	if members, found := topicMap[topic]; found {
+		delete(members, sid)
+		if len(members) == 0 {
+			delete(topicMap, topic)
+		}
+	}
+
+	if topics, found := agentTopics[sid]; found {
+		delete(topics, topic)
+		if len(topics) == 0 {
+			delete(agentTopics, sid)
+		}
+	}
+}
+
+// matchTopic returns true if the topic matches the pattern
+func matchTopic(pattern, topic string) bool {
+	if pattern == topic {
+		return true
+	}
+
+	patterns := strings.Split(pattern, topicSeparator)
+	levels := strings.Split(topic, topicSeparator)
+
+	for i, p := range patterns {
+		if p == TopicMultiWildcard {
+			return i == len(patterns)-1 && len(levels) > i
+		}
+
+		if i >= len(levels) {
+			return false
+		}
+
+		if p != TopicWildcard && p != levels[i] {
+			return false
+		}
+	}
+
+	return len(patterns) == len(levels)
+}
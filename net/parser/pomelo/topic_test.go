@@ -0,0 +1,61 @@
+package pomelo
+
+import (
+	"testing"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		match   bool
+	}{
+		{"chat.room.1", "chat.room.1", true},
+		{"chat.room.*", "chat.room.1", true},
+		{"chat.room.*", "chat.room", false},
+		{"chat.room.*", "chat.room.1.2", false},
+		{"chat.*.1", "chat.room.1", true},
+		{"chat.>", "chat.room.1", true},
+		{"chat.>", "chat", false},
+		{">", "chat", true},
+		{"chat.>.1", "chat.room.1", false},
+		{"mail.*", "chat.room", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchTopic(tt.pattern, tt.topic); got != tt.match {
+			t.Fatalf("match(%s, %s) = %v", tt.pattern, tt.topic, got)
+		}
+	}
+}
+
+func TestPublishTopic(t *testing.T) {
+	agent1 := newTestAgent("topic-1")
+	agent2 := newTestAgent("topic-2")
+	defer leaveTopics(agent1.SID())
+	defer leaveTopics(agent2.SID())
+
+	agent1.Subscribe("chat.room.*")
+	agent1.Subscribe("chat.>")
+	agent2.Subscribe("chat.room.2")
+
+	if err := PublishTopic("chat.room.1", "test.push", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	// matched by two patterns, pushed once
+	if len(agent1.chWrite) != 1 || len(agent2.chWrite) != 0 {
+		t.Fatalf("published = %d, %d", len(agent1.chWrite), len(agent2.chWrite))
+	}
+
+	agent1.Unsubscribe("chat.>")
+	if topics := agent1.Topics(); len(topics) != 1 || topics[0] != "chat.room.*" {
+		t.Fatalf("topics = %v", topics)
+	}
+
+	// the subscriptions are removed on close
+	leaveTopics(agent1.SID())
+	if len(agent1.Topics()) != 0 || len(topicAgents("chat.room.1")) != 0 {
+		t.Fatal("topics are not removed")
+	}
+}
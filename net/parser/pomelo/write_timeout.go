@@ -0,0 +1,58 @@
+package pomelo
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+// SetWriteTimeout set the deadline of each write to the conn, zero is disabled(default).
+// the agent is closed with CauseWriteTimeout on the write timeout if closeOnTimeout is true,
+// the client can not drain the writes for d is dead. see Stats().WriteTimeouts and Agent.SendRawDeadline
+func (*actor) SetWriteTimeout(d time.Duration, closeOnTimeout bool) {
+	if d < 0 {
+		d = 0
+	}
+
+	var closed int32
+	if closeOnTimeout {
+		closed = 1
+	}
+
+	storeDuration(&cmd.writeTimeout, d)
+	atomic.StoreInt32(&cmd.writeTimeoutClose, closed)
+}
+
+// SendRawDeadline queue the bytes and wait until they are written to the conn,
+// returns cerr.SessionWriteTimeout if they are not written in d.
+func (a *Agent) SendRawDeadline(bytes []byte, d time.Duration) error {
+	if err := a.SendRaw(bytes); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	err := a.flush(ctx)
+	if err != nil && err != cerr.SessionClosed {
+		a.onWriteTimeout()
+		return cerr.SessionWriteTimeout
+	}
+
+	return err
+}
+
+// onWriteTimeout the conn is closed to release the blocked write goroutine
+func (a *Agent) onWriteTimeout() {
+	closed := atomic.LoadInt32(&cmd.writeTimeoutClose) == 1
+	atomic.AddInt64(&counters.writeTimeouts, 1)
+	a.Warnf("Write timeout. [closed = %v]", closed)
+
+	if closed {
+		a.setCloseCause(CauseWriteTimeout, cerr.SessionWriteTimeout)
+		a.Close()
+		_ = a.conn.Close()
+	}
+}
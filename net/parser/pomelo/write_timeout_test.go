@@ -0,0 +1,98 @@
+package pomelo
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+func TestSendRawDeadline(t *testing.T) {
+	defer (&actor{}).SetWriteTimeout(0, false)
+
+	agent := newTestAgent("send-raw-deadline")
+	agent.SetState(AgentWorking)
+
+	done := make(chan struct{})
+	go func() {
+		agent.writeChan()
+		close(done)
+	}()
+	defer func() { <-done }()
+
+	if err := agent.SendRawDeadline([]byte(`hello`), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	agent.Close()
+
+	// the peer never reads
+	(&actor{}).SetWriteTimeout(0, true)
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	stuck := newTestAgent("send-raw-stuck")
+	stuck.conn = conn
+	stuck.SetState(AgentWorking)
+
+	exit := make(chan struct{})
+	go func() {
+		stuck.writeChan()
+		close(exit)
+	}()
+	defer func() { <-exit }()
+
+	if err := stuck.SendRawDeadline([]byte(`hello`), 50*time.Millisecond); err != cerr.SessionWriteTimeout {
+		t.Fatalf("err = %v", err)
+	}
+
+	if !stuck.IsClosed() {
+		t.Fatal("agent is not closed")
+	}
+
+	if err := stuck.SendRaw([]byte(`hello`)); err != cerr.SessionClosed {
+		t.Fatalf("send after timeout err = %v", err)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	defer (&actor{}).SetWriteTimeout(0, false)
+	(&actor{}).SetWriteTimeout(50*time.Millisecond, true)
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	agent := newTestAgent("write-timeout")
+	agent.conn = conn
+	agent.SetState(AgentWorking)
+
+	stats := Stats()
+	done := make(chan struct{})
+	go func() {
+		agent.write([]byte(`hello`))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("write is blocked")
+	}
+
+	if !agent.IsClosed() || agent.CloseCause() != CauseWriteTimeout || Stats().WriteTimeouts != stats.WriteTimeouts+1 {
+		t.Fatalf("closed = %v, cause = %s, timeouts = %d", agent.IsClosed(), agent.CloseCause(), Stats().WriteTimeouts-stats.WriteTimeouts)
+	}
+
+	// the write in deadline is not closed
+	conn, peer = net.Pipe()
+	go io.Copy(io.Discard, peer)
+
+	agent = newTestAgent("write-in-deadline")
+	agent.conn = conn
+	agent.write([]byte(`hello`))
+	if agent.IsClosed() {
+		t.Fatal("agent is closed")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"time"
 
+	ccode "github.com/cherry-game/cherry/code"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 	cactor "github.com/cherry-game/cherry/net/actor"
@@ -119,5 +120,12 @@ func (p *actor) response(rsp *cproto.PomeloResponse) {
 		return
 	}
 
-	agent.Response(rsp.Mid, rsp.Data)
+	if ccode.IsOK(rsp.Code) {
+		agent.Response(rsp.Mid, rsp.Data)
+	} else {
+		agent.Response(rsp.Mid, &cproto.Response{
+			Code: rsp.Code,
+			Data: rsp.Data,
+		})
+	}
 }
@@ -53,7 +53,7 @@ func NewAgent(app cfacade.IApplication, conn net.Conn, session *cproto.Session)
 		onCloseFunc:  nil,
 	}
 
-	agent.session.Ip = agent.RemoteAddr()
+	agent.session.Ip = agent.RemoteAddress()
 	agent.SetLastAt()
 
 	if clog.PrintLevel(zapcore.DebugLevel) {
@@ -61,7 +61,7 @@ func NewAgent(app cfacade.IApplication, conn net.Conn, session *cproto.Session)
 			agent.SID(),
 			agent.UID(),
 			Count(),
-			agent.RemoteAddr(),
+			agent.RemoteAddress(),
 		)
 	}
 
@@ -206,7 +206,7 @@ func (a *Agent) closeProcess() {
 			a.SID(),
 			a.UID(),
 			Count(),
-			a.RemoteAddr(),
+			a.RemoteAddress(),
 		)
 	}
 
@@ -241,12 +241,23 @@ func (a *Agent) processPacket(msg *Message) {
 	a.SetLastAt()
 }
 
-func (a *Agent) RemoteAddr() string {
+// RemoteAddr returns the remote address of the conn, nil if the conn is nil
+func (a *Agent) RemoteAddr() net.Addr {
 	if a.conn != nil {
-		return cnet.GetIPV4(a.conn.RemoteAddr())
+		return a.conn.RemoteAddr()
 	}
 
-	return ""
+	return nil
+}
+
+// RemoteIP returns the ip of the remote address
+func (a *Agent) RemoteIP() net.IP {
+	return cnet.GetIP(a.RemoteAddr())
+}
+
+// RemoteAddress returns the ip string of the remote address
+func (a *Agent) RemoteAddress() string {
+	return cnet.GetIPV4(a.RemoteAddr())
 }
 
 func (p *pendingMessage) String() string {
@@ -26,7 +26,7 @@ func BindUID(sid cfacade.SID, uid cfacade.UID) error {
 		return cerr.Errorf("[sid = %s] less than 1.", sid)
 	}
 
-	if uid < 1 {
+	if !cfacade.IsValidUID(uid) {
 		return cerr.Errorf("[uid = %d] less than 1.", uid)
 	}
 
@@ -38,7 +38,7 @@ func BindUID(sid cfacade.SID, uid cfacade.UID) error {
 		return cerr.Errorf("[sid = %s] does not exist.", sid)
 	}
 
-	if agent.UID() > 0 && agent.UID() == uid {
+	if cfacade.IsValidUID(agent.UID()) && agent.UID() == uid {
 		return cerr.Errorf("[uid = %d] has already bound.", agent.UID())
 	}
 
@@ -76,7 +76,7 @@ func GetAgent(sid cfacade.SID) (*Agent, bool) {
 }
 
 func GetAgentWithUID(uid cfacade.UID) (*Agent, bool) {
-	if uid < 1 {
+	if !cfacade.IsValidUID(uid) {
 		return nil, false
 	}
 
@@ -1,8 +1,12 @@
 package simple
 
 import (
+	"context"
+
+	ccode "github.com/cherry-game/cherry/code"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
+	cdiscovery "github.com/cherry-game/cherry/net/discovery"
 	cproto "github.com/cherry-game/cherry/net/proto"
 )
 
@@ -54,8 +58,16 @@ func DefaultDataRoute(agent *Agent, msg *Message, route *NodeRoute) {
 		return
 	}
 
-	member, found := agent.Discovery().Random(route.NodeType)
-	if !found {
+	member, err := cdiscovery.Route(agent.Discovery(), route.NodeType, cdiscovery.RouteHint{
+		UID: session.Uid,
+	})
+	if err != nil {
+		clog.Warnf("[sid = %s,uid = %d] Route node fail. [route = %+v, err = %v]",
+			agent.SID(),
+			agent.UID(),
+			route,
+			err,
+		)
 		return
 	}
 
@@ -79,8 +91,18 @@ func ClusterLocalDataRoute(agent *Agent, session *cproto.Session, msg *Message,
 	clusterPacket.SourcePath = session.AgentPath
 	clusterPacket.TargetPath = targetPath
 	clusterPacket.FuncName = nodeRoute.FuncName
-	clusterPacket.Session = session   // agent session
-	clusterPacket.ArgBytes = msg.Data // packet -> message -> data
+	clusterPacket.Session = session.Clone() // copy of the agent session, it's marshaled without the data lock
+	clusterPacket.ArgBytes = msg.Data       // packet -> message -> data
+
+	header, end := agent.ActorSystem().Tracer().Inject(context.Background(), nodeRoute.ActorID+"."+nodeRoute.FuncName, session)
+	clusterPacket.Header = header
+
+	err := agent.Cluster().PublishLocal(nodeID, clusterPacket)
+	if err != nil {
+		end(ccode.ActorPublishRemoteError)
+	} else {
+		end(ccode.OK)
+	}
 
-	return agent.Cluster().PublishLocal(nodeID, clusterPacket)
+	return err
 }
@@ -35,6 +35,7 @@ func (x *ClusterPacket) Recycle() {
 	x.FuncName = ""
 	x.ArgBytes = nil
 	x.Session = nil
+	x.Header = nil
 	clusterPacketPool.Put(x)
 }
 
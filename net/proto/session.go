@@ -1,10 +1,62 @@
 package cherryProto
 
 import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	cconst "github.com/cherry-game/cherry/const"
+	cerr "github.com/cherry-game/cherry/error"
 	cstring "github.com/cherry-game/cherry/extend/string"
 )
 
+const (
+	sessionLockSize = 64 // session data lock stripes
+)
+
+var (
+	// Session is a generated protobuf struct and can not embed a mutex,
+	// so the data map is guarded by a lock stripe selected by sid.
+	sessionLocks [sessionLockSize]sync.RWMutex
+	// expire time(unix milli) of the keys set by SetWithTTL, it's guarded by the same lock stripe of the session.
+	// it's kept out of the data map, so it is not sent to other nodes and can not collide with user keys.
+	// the entry is removed with the last expire time of the session, see ReleaseExpires
+	sessionExpires [sessionLockSize]map[*Session]map[string]int64
+	// func(sid string), see SetDataListener
+	dataListener atomic.Value
+)
+
+type (
+	// ExpireValue the exported value set by SetWithTTL. see ExportData
+	ExpireValue struct {
+		Value    string `json:"value"`    // value
+		ExpireAt int64  `json:"expireAt"` // expire time(unix milli)
+	}
+)
+
+// SetDataListener fn is called with the sid after the data of any session is changed(eg. Set, Remove, Restore).
+// it's called with the data lock held, so fn must be fast and must not access the session. nil removes it.
+func SetDataListener(fn func(sid string)) {
+	dataListener.Store(fn)
+}
+
+func (x *Session) stripe() uint32 {
+	// fnv-1a
+	hash := uint32(2166136261)
+	for i := 0; i < len(x.Sid); i++ {
+		hash ^= uint32(x.Sid[i])
+		hash *= 16777619
+	}
+
+	return hash % sessionLockSize
+}
+
+func (x *Session) locker() *sync.RWMutex {
+	return &sessionLocks[x.stripe()]
+}
+
+// IsBind the same as cfacade.IsValidUID(x.Uid), cfacade imports this package
 func (x *Session) IsBind() bool {
 	return x.Uid > 0
 }
@@ -14,11 +66,60 @@ func (x *Session) ActorPath() string {
 }
 
 func (x *Session) Add(key string, value interface{}) {
-	x.Data[key] = cstring.ToString(value)
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.setValue(key, cstring.ToString(value))
+	x.deleteExpire(key)
+}
+
+// SetWithTTL set the value and expires it after ttl.
+// expired value is treated as absent and deleted lazily on read.
+func (x *Session) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	if key == "" {
+		return
+	}
+
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.setValue(key, cstring.ToString(value))
+
+	if ttl > 0 {
+		x.setExpire(key, time.Now().Add(ttl).UnixMilli())
+	} else {
+		x.deleteExpire(key)
+	}
+}
+
+// ExpireAt returns the expire time of the key set by SetWithTTL.
+func (x *Session) ExpireAt(key string) (time.Time, bool) {
+	lock := x.locker()
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, found := x.Data[key]; !found {
+		return time.Time{}, false
+	}
+
+	expireAt, found := x.expireAt(key)
+	if !found || expireAt <= time.Now().UnixMilli() {
+		return time.Time{}, false
+	}
+
+	return time.UnixMilli(expireAt), true
 }
 
 func (x *Session) Remove(key string) {
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
 	delete(x.Data, key)
+	x.deleteExpire(key)
+	x.changed()
 }
 
 func (x *Session) Set(key string, value string) {
@@ -26,95 +127,493 @@ func (x *Session) Set(key string, value string) {
 		return
 	}
 
-	x.Data[key] = value
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.setValue(key, value)
+	x.deleteExpire(key)
+}
+
+// Incr add delta to the integer value of the key and returns the new value, the absent key starts from zero.
+// returns 0 and keeps the value if it's not an integer. see IncrErr
+func (x *Session) Incr(key string, delta int64) int64 {
+	value, _ := x.IncrErr(key, delta)
+	return value
+}
+
+// Decr subtract delta from the integer value of the key and returns the new value. see Incr
+func (x *Session) Decr(key string, delta int64) int64 {
+	return x.Incr(key, -delta)
+}
+
+// IncrErr add delta to the integer value of the key atomically and returns the new value.
+// returns cerr.SessionValueNotInteger if the value is not an integer. the expire time set by SetWithTTL is kept.
+func (x *Session) IncrErr(key string, delta int64) (int64, error) {
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	var value int64
+	if v, found := x.Data[key]; found {
+		if x.isExpired(key) {
+			x.deleteExpire(key)
+		} else {
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return 0, cerr.SessionValueNotInteger
+			}
+			value = i
+		}
+	}
+
+	value += delta
+	x.setValue(key, strconv.FormatInt(value, 10))
+
+	return value, nil
 }
 
+// ImportAll set all values of data, the imported keys are not expired.
 func (x *Session) ImportAll(data map[string]string) {
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
 	for k, v := range data {
-		x.Set(k, v)
+		if k == "" || v == "" {
+			continue
+		}
+		x.setValue(k, v)
+		x.deleteExpire(k)
 	}
 }
 
 func (x *Session) Contains(key string) bool {
-	_, found := x.Data[key]
+	_, found := x.Get(key)
 	return found
 }
 
+// Restore replace the data, the expire times are cleared. see RestoreExpires
 func (x *Session) Restore(data map[string]string) {
-	x.Clear()
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.clear()
 
 	for k, v := range data {
-		x.Set(k, v)
+		if k == "" || v == "" {
+			continue
+		}
+		x.setValue(k, v)
 	}
 }
 
 // Clear releases all settings related to current sc
 func (x *Session) Clear() {
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.clear()
+}
+
+// Get returns the value associated with the key.
+func (x *Session) Get(key string) (string, bool) {
+	lock := x.locker()
+	lock.RLock()
+	v, found := x.Data[key]
+	expired := found && x.isExpired(key)
+	lock.RUnlock()
+
+	if !expired {
+		return v, found
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if x.isExpired(key) {
+		delete(x.Data, key)
+		x.deleteExpire(key)
+	}
+
+	return "", false
+}
+
+// Keys returns all keys of the session data.
+func (x *Session) Keys() []string {
+	lock := x.locker()
+	lock.RLock()
+	defer lock.RUnlock()
+
+	keys := make([]string, 0, len(x.Data))
 	for k := range x.Data {
-		delete(x.Data, k)
+		if x.isExpired(k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// CloneData returns a copy of the session data, the expired keys are excluded.
+func (x *Session) CloneData() map[string]string {
+	lock := x.locker()
+	lock.RLock()
+	defer lock.RUnlock()
+
+	data := make(map[string]string, len(x.Data))
+	for k, v := range x.Data {
+		if x.isExpired(k) {
+			continue
+		}
+		data[k] = v
+	}
+	return data
+}
+
+// CloneExpires returns a copy of the expire times(unix milli) of the keys set by SetWithTTL.
+func (x *Session) CloneExpires() map[string]int64 {
+	lock := x.locker()
+	lock.RLock()
+	defer lock.RUnlock()
+
+	current := sessionExpires[x.stripe()][x]
+	expires := make(map[string]int64, len(current))
+	for k, v := range current {
+		expires[k] = v
+	}
+	return expires
+}
+
+// RestoreExpires set the expire times(unix milli) of the existing keys. see CloneExpires
+func (x *Session) RestoreExpires(expires map[string]int64) {
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	for k, v := range expires {
+		if _, found := x.Data[k]; found {
+			x.setExpire(k, v)
+		}
+	}
+}
+
+// ReleaseExpires release the expire times of the session, called when the session is closed.
+func (x *Session) ReleaseExpires() {
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	delete(sessionExpires[x.stripe()], x)
+}
+
+// ExportData returns a copy of the session data for migration or storage.
+// the values set by SetWithTTL are exported as ExpireValue with the absolute expire time, the expired keys are excluded.
+func (x *Session) ExportData() map[string]interface{} {
+	lock := x.locker()
+	lock.RLock()
+	defer lock.RUnlock()
+
+	data := make(map[string]interface{}, len(x.Data))
+	for k, v := range x.Data {
+		if expireAt, found := x.expireAt(k); found {
+			if expireAt <= time.Now().UnixMilli() {
+				continue
+			}
+			data[k] = ExpireValue{Value: v, ExpireAt: expireAt}
+			continue
+		}
+		data[k] = v
+	}
+	return data
+}
+
+// ImportData replace the session data by the data exported by ExportData.
+// ExpireValue keeps the remaining lifetime, other values are converted to string.
+func (x *Session) ImportData(data map[string]interface{}) {
+	lock := x.locker()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.clear()
+
+	now := time.Now().UnixMilli()
+	for k, v := range data {
+		if k == "" {
+			continue
+		}
+
+		switch value := v.(type) {
+		case ExpireValue:
+			x.importExpireValue(k, value, now)
+		case *ExpireValue:
+			if value != nil {
+				x.importExpireValue(k, *value, now)
+			}
+		default:
+			if s := cstring.ToString(v); s != "" {
+				x.setValue(k, s)
+			}
+		}
+	}
+}
+
+// importExpireValue must be called with the write lock held
+func (x *Session) importExpireValue(key string, value ExpireValue, now int64) {
+	if value.Value == "" || (value.ExpireAt > 0 && value.ExpireAt <= now) {
+		return
+	}
+
+	x.setValue(key, value.Value)
+	if value.ExpireAt > 0 {
+		x.setExpire(key, value.ExpireAt)
+	}
+}
+
+// Clone returns a copy of the session, the data map is copied under the lock.
+// marshal the copy when the session may be modified by other goroutines.
+// the expired keys are excluded and the expire times are not copied, see CloneExpires
+func (x *Session) Clone() *Session {
+	return &Session{
+		Sid:       x.Sid,
+		Uid:       x.Uid,
+		AgentPath: x.AgentPath,
+		Ip:        x.Ip,
+		Mid:       x.Mid,
+		Data:      x.CloneData(),
+		Metadata:  x.CloneMetadata(),
+	}
+}
+
+// LookupMetadata returns the metadata(header) of the current message sent by the client
+func (x *Session) LookupMetadata(key string) (string, bool) {
+	v, ok := x.Metadata[key]
+	return v, ok
+}
+
+// CloneMetadata returns a copy of the metadata of the current message, nil if it's empty
+func (x *Session) CloneMetadata() map[string]string {
+	if len(x.Metadata) < 1 {
+		return nil
+	}
+
+	metadata := make(map[string]string, len(x.Metadata))
+	for k, v := range x.Metadata {
+		metadata[k] = v
 	}
+	return metadata
 }
 
-func (x *Session) GetUint(key string) uint {
-	v, ok := x.Data[key]
+// LookupInt64 returns the value associated with the key as a int64.
+// float values (eg. "12.0") are truncated.
+func (x *Session) LookupInt64(key string) (int64, bool) {
+	v, ok := x.Get(key)
 	if !ok {
-		return 0
+		return 0, false
 	}
 
-	value, ok := cstring.ToUint(v)
+	if value, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return value, true
+	}
+
+	value, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(value), true
+}
+
+// LookupFloat64 returns the value associated with the key as a float64.
+func (x *Session) LookupFloat64(key string) (float64, bool) {
+	v, ok := x.Get(key)
 	if !ok {
-		return 0
+		return 0, false
 	}
-	return value
+
+	value, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
 }
 
-func (x *Session) GetInt(key string) int {
-	v, ok := x.Data[key]
+// LookupBool returns the value associated with the key as a bool.
+func (x *Session) LookupBool(key string) (bool, bool) {
+	v, ok := x.Get(key)
 	if !ok {
-		return 0
+		return false, false
+	}
+
+	value, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, false
 	}
+	return value, true
+}
+
+// LookupString returns the value associated with the key as a string.
+func (x *Session) LookupString(key string) (string, bool) {
+	return x.Get(key)
+}
+
+// GetUint returns the value associated with the key as a uint.
+func (x *Session) GetUint(key string, def ...uint) uint {
+	value, ok := x.LookupInt64(key)
+	if !ok || value < 0 {
+		return defaultValue(def)
+	}
+	return uint(value)
+}
 
-	value, ok := cstring.ToInt(v)
+// GetInt returns the value associated with the key as a int.
+func (x *Session) GetInt(key string, def ...int) int {
+	value, ok := x.LookupInt64(key)
 	if !ok {
-		return 0
+		return defaultValue(def)
 	}
-	return value
+	return int(value)
 }
 
 // GetInt32 returns the value associated with the key as a int32.
-func (x *Session) GetInt32(key string) int32 {
-	v, ok := x.Data[key]
+func (x *Session) GetInt32(key string, def ...int32) int32 {
+	value, ok := x.LookupInt64(key)
 	if !ok {
-		return 0
+		return defaultValue(def)
 	}
+	return int32(value)
+}
 
-	value, ok := cstring.ToInt32(v)
+// GetInt64 returns the value associated with the key as a int64.
+func (x *Session) GetInt64(key string, def ...int64) int64 {
+	value, ok := x.LookupInt64(key)
 	if !ok {
-		return 0
+		return defaultValue(def)
 	}
 	return value
 }
 
-// GetInt64 returns the value associated with the key as a int64.
-func (x *Session) GetInt64(key string) int64 {
-	v, ok := x.Data[key]
+// GetFloat64 returns the value associated with the key as a float64.
+func (x *Session) GetFloat64(key string, def ...float64) float64 {
+	value, ok := x.LookupFloat64(key)
 	if !ok {
-		return 0
+		return defaultValue(def)
 	}
+	return value
+}
 
-	value, ok := cstring.ToInt64(v)
+// GetBool returns the value associated with the key as a bool.
+func (x *Session) GetBool(key string, def ...bool) bool {
+	value, ok := x.LookupBool(key)
 	if !ok {
-		return 0
+		return defaultValue(def)
 	}
 	return value
 }
 
 // GetString returns the value associated with the key as a string.
-func (x *Session) GetString(key string) string {
-	v, ok := x.Data[key]
+func (x *Session) GetString(key string, def ...string) string {
+	value, ok := x.Get(key)
 	if !ok {
-		return ""
+		return defaultValue(def)
+	}
+	return value
+}
+
+func defaultValue[T any](def []T) T {
+	var value T
+	if len(def) > 0 {
+		value = def[0]
+	}
+	return value
+}
+
+// setValue must be called with the write lock held
+func (x *Session) setValue(key, value string) {
+	if x.Data == nil {
+		x.Data = make(map[string]string)
+	}
+
+	x.Data[key] = value
+	x.changed()
+}
+
+// clear must be called with the write lock held
+func (x *Session) clear() {
+	for k := range x.Data {
+		delete(x.Data, k)
+	}
+
+	delete(sessionExpires[x.stripe()], x)
+	x.changed()
+}
+
+// changed must be called with the write lock held
+func (x *Session) changed() {
+	if fn, _ := dataListener.Load().(func(sid string)); fn != nil {
+		fn(x.Sid)
 	}
+}
+
+// setExpire must be called with the write lock held
+func (x *Session) setExpire(key string, expireAt int64) {
+	i := x.stripe()
+	if sessionExpires[i] == nil {
+		sessionExpires[i] = make(map[*Session]map[string]int64)
+	}
+
+	expires, found := sessionExpires[i][x]
+	if !found {
+		// the sessions dropped without ReleaseExpires are released after their keys are expired
+		sweepExpires(i)
+		expires = make(map[string]int64)
+		sessionExpires[i][x] = expires
+	}
+
+	expires[key] = expireAt
+}
+
+// deleteExpire must be called with the write lock held
+func (x *Session) deleteExpire(key string) {
+	i := x.stripe()
+
+	expires, found := sessionExpires[i][x]
+	if !found {
+		return
+	}
+
+	delete(expires, key)
+	if len(expires) == 0 {
+		delete(sessionExpires[i], x)
+	}
+}
+
+// sweepExpires delete the expired keys of the sessions in the lock stripe i, must be called with the write lock held
+func sweepExpires(i uint32) {
+	now := time.Now().UnixMilli()
+	for session, expires := range sessionExpires[i] {
+		for key, expireAt := range expires {
+			if expireAt <= now {
+				delete(session.Data, key)
+				delete(expires, key)
+			}
+		}
+
+		if len(expires) == 0 {
+			delete(sessionExpires[i], session)
+		}
+	}
+}
+
+// expireAt must be called with the lock held
+func (x *Session) expireAt(key string) (int64, bool) {
+	expireAt, found := sessionExpires[x.stripe()][x][key]
+	return expireAt, found
+}
 
-	return v
+// isExpired must be called with the lock held
+func (x *Session) isExpired(key string) bool {
+	expireAt, found := x.expireAt(key)
+	return found && expireAt <= time.Now().UnixMilli()
 }
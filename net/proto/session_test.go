@@ -0,0 +1,338 @@
+package cherryProto
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSessionConcurrentSetGet(t *testing.T) {
+	session := &Session{
+		Sid:  "1",
+		Data: map[string]string{},
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 500; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			session.Set("key"+strconv.Itoa(i%10), strconv.Itoa(i))
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+			session.GetString("key" + strconv.Itoa(i%10))
+			session.Contains("key" + strconv.Itoa(i%10))
+		}(i)
+	}
+	wg.Wait()
+
+	if len(session.Data) != 10 {
+		t.Fatalf("data size = %d, want 10", len(session.Data))
+	}
+}
+
+func TestSessionTypedGetter(t *testing.T) {
+	session := &Session{
+		Sid:  "1",
+		Data: map[string]string{},
+	}
+
+	session.Add("int", 100)
+	session.Set("float", "12.5")
+	session.Set("bool", "true")
+	session.Set("str", "cherry")
+
+	if v, ok := session.LookupInt64("int"); !ok || v != 100 {
+		t.Fatalf("LookupInt64(int) = %d, %v", v, ok)
+	}
+
+	if v, ok := session.LookupInt64("float"); !ok || v != 12 {
+		t.Fatalf("LookupInt64(float) = %d, %v", v, ok)
+	}
+
+	if v, ok := session.LookupInt64("str"); ok || v != 0 {
+		t.Fatalf("LookupInt64(str) = %d, %v", v, ok)
+	}
+
+	if v, ok := session.LookupFloat64("float"); !ok || v != 12.5 {
+		t.Fatalf("LookupFloat64(float) = %v, %v", v, ok)
+	}
+
+	if v, ok := session.LookupBool("bool"); !ok || !v {
+		t.Fatalf("LookupBool(bool) = %v, %v", v, ok)
+	}
+
+	if v := session.GetInt64("none", 99); v != 99 {
+		t.Fatalf("GetInt64(none, 99) = %d", v)
+	}
+
+	if v := session.GetString("none"); v != "" {
+		t.Fatalf("GetString(none) = %s", v)
+	}
+
+	if v := session.GetBool("str", true); !v {
+		t.Fatalf("GetBool(str, true) = %v", v)
+	}
+}
+
+func TestSessionRemoveClear(t *testing.T) {
+	session := &Session{
+		Sid: "1",
+	}
+
+	// nil data map
+	session.Remove("none")
+	session.Clear()
+
+	session.Set("a", "1")
+	session.Set("b", "2")
+
+	if keys := session.Keys(); len(keys) != 2 {
+		t.Fatalf("keys = %v", keys)
+	}
+
+	session.Remove("a")
+	if session.Contains("a") || !session.Contains("b") {
+		t.Fatalf("remove fail. data = %v", session.Data)
+	}
+
+	session.Clear()
+	if keys := session.Keys(); len(keys) != 0 {
+		t.Fatalf("clear fail. keys = %v", keys)
+	}
+}
+
+func TestSessionTTL(t *testing.T) {
+	session := &Session{
+		Sid: "1",
+	}
+
+	session.Set("forever", "1")
+	session.SetWithTTL("captcha", 1234, 50*time.Millisecond)
+
+	if _, found := session.ExpireAt("forever"); found {
+		t.Fatal("forever key has expire time")
+	}
+
+	if _, found := session.ExpireAt("captcha"); !found {
+		t.Fatal("captcha key has not expire time")
+	}
+
+	if v := session.GetInt("captcha"); v != 1234 {
+		t.Fatalf("captcha = %d", v)
+	}
+
+	if keys := session.Keys(); len(keys) != 2 {
+		t.Fatalf("keys = %v", keys)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if session.Contains("captcha") {
+		t.Fatal("captcha key is not expired")
+	}
+
+	if len(session.Data) != 1 || !session.Contains("forever") {
+		t.Fatalf("data = %v", session.Data)
+	}
+
+	// overwrite with regular value clears the ttl
+	session.SetWithTTL("token", "a", 50*time.Millisecond)
+	session.Set("token", "b")
+	if _, found := session.ExpireAt("token"); found {
+		t.Fatal("token key has expire time")
+	}
+}
+
+func TestSessionTTLImport(t *testing.T) {
+	session := &Session{
+		Sid: "2",
+	}
+
+	session.SetWithTTL("captcha", 1234, 50*time.Millisecond)
+	if len(session.Data) != 1 {
+		t.Fatalf("expire time in data. data = %v", session.Data)
+	}
+
+	expires := session.CloneExpires()
+	if len(expires) != 1 {
+		t.Fatalf("expires = %v", expires)
+	}
+
+	// imported value replaces the ttl value
+	session.ImportAll(map[string]string{"captcha": "5678"})
+	if _, found := session.ExpireAt("captcha"); found {
+		t.Fatal("imported key has expire time")
+	}
+
+	session.Restore(map[string]string{"captcha": "1234"})
+	session.RestoreExpires(expires)
+	if _, found := session.ExpireAt("captcha"); !found {
+		t.Fatal("restored key has not expire time")
+	}
+
+	session.ReleaseExpires()
+	if _, found := session.ExpireAt("captcha"); found {
+		t.Fatal("released key has expire time")
+	}
+}
+
+func TestSessionTTLInstance(t *testing.T) {
+	session := &Session{
+		Sid: "4",
+	}
+	session.SetWithTTL("captcha", 1234, time.Minute)
+
+	// the expire times are kept by the session, not shared by the sid
+	forwarded := &Session{
+		Sid: "4",
+	}
+	forwarded.Set("captcha", "5678")
+
+	if _, found := session.ExpireAt("captcha"); !found {
+		t.Fatal("the expire time is overwritten by the session of the same sid")
+	}
+
+	forwarded.SetWithTTL("token", "t1", time.Minute)
+	if _, found := session.ExpireAt("token"); found {
+		t.Fatal("the expire time of the session of the same sid is read")
+	}
+
+	// the clone does not share the expire times
+	clone := session.Clone()
+	clone.SetWithTTL("captcha", 5678, time.Minute)
+	clone.Remove("captcha")
+	if _, found := session.ExpireAt("captcha"); !found {
+		t.Fatal("the expire time is removed by the clone")
+	}
+
+	// the session dropped without ReleaseExpires is released after the keys are expired
+	dropped := &Session{
+		Sid: "4",
+	}
+	dropped.SetWithTTL("captcha", 1234, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	forwarded.ReleaseExpires()
+	forwarded.SetWithTTL("token", "t2", time.Minute)
+
+	lock := dropped.locker()
+	lock.RLock()
+	_, found := sessionExpires[dropped.stripe()][dropped]
+	_, kept := sessionExpires[session.stripe()][session]
+	lock.RUnlock()
+
+	if found || !kept {
+		t.Fatalf("dropped = %v, kept = %v", found, kept)
+	}
+}
+
+func TestSessionCloneMarshal(t *testing.T) {
+	session := &Session{
+		Sid:  "1",
+		Uid:  1001,
+		Data: map[string]string{},
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 200; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			session.Set("key"+strconv.Itoa(i%10), strconv.Itoa(i))
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			if _, err := proto.Marshal(session.Clone()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	clone := session.Clone()
+	if clone.Uid != session.Uid || len(clone.Data) != len(session.Data) {
+		t.Fatalf("clone = %+v", clone)
+	}
+}
+
+func TestSessionExportData(t *testing.T) {
+	session := &Session{
+		Sid: "3",
+	}
+
+	session.Set("name", "cherry")
+	session.SetWithTTL("captcha", 1234, time.Minute)
+
+	data := session.ExportData()
+	if data["name"] != "cherry" {
+		t.Fatalf("data = %v", data)
+	}
+
+	value, ok := data["captcha"].(ExpireValue)
+	if !ok || value.Value != "1234" || value.ExpireAt <= time.Now().UnixMilli() {
+		t.Fatalf("captcha = %v", data["captcha"])
+	}
+
+	// the exported data is a copy
+	data["name"] = "changed"
+	if session.GetString("name") != "cherry" {
+		t.Fatal("exported data is not a copy")
+	}
+
+	other := &Session{
+		Sid:  "4",
+		Data: map[string]string{"old": "1"},
+	}
+	data["expired"] = ExpireValue{Value: "1", ExpireAt: time.Now().Add(-time.Second).UnixMilli()}
+	other.ImportData(data)
+
+	if other.Contains("old") || other.Contains("expired") || other.GetString("name") != "changed" {
+		t.Fatalf("import data = %v", other.Data)
+	}
+
+	if expireAt, found := other.ExpireAt("captcha"); !found || expireAt.UnixMilli() != value.ExpireAt {
+		t.Fatalf("imported expire at = %v, found = %v", expireAt, found)
+	}
+}
+
+func TestSessionIncr(t *testing.T) {
+	session := &Session{
+		Sid: "5",
+	}
+
+	if v := session.Incr("count", 2); v != 2 {
+		t.Fatalf("incr = %d", v)
+	}
+
+	if v := session.Decr("count", 3); v != -1 {
+		t.Fatalf("decr = %d", v)
+	}
+
+	session.Set("name", "cherry")
+	if _, err := session.IncrErr("name", 1); err != cerr.SessionValueNotInteger {
+		t.Fatalf("incr string err = %v", err)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session.Incr("concurrent", 1)
+		}()
+	}
+	wg.Wait()
+
+	if v := session.GetInt64("concurrent"); v != 100 {
+		t.Fatalf("concurrent incr = %d", v)
+	}
+}
@@ -0,0 +1,54 @@
+package cherrySerializer
+
+import (
+	"sort"
+	"sync"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+var (
+	registryLock = &sync.RWMutex{}
+	registry     = map[string]cfacade.ISerializer{} // serializer name -> ISerializer
+)
+
+func init() {
+	Register(NewJSON())
+	Register(NewProtobuf())
+}
+
+// Register add the serializer by its name, the serializer with the same name is replaced.
+// eg. register a msgpack serializer and select it by app.SetSerializerName("msgpack")
+func Register(serializer cfacade.ISerializer) {
+	if serializer == nil || serializer.Name() == "" {
+		return
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registry[serializer.Name()] = serializer
+}
+
+// Get returns the registered serializer by name
+func Get(name string) (cfacade.ISerializer, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	serializer, found := registry[name]
+	return serializer, found
+}
+
+// Names returns the sorted names of the registered serializers
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}